@@ -0,0 +1,111 @@
+// Package audit records who-did-what events, such as a user attempting to
+// publish a dataset, and publishes them to a sink.
+package audit
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Results of an audited action
+const (
+	Attempted    = "attempted"
+	Successful   = "successful"
+	Unsuccessful = "unsuccessful"
+)
+
+// ErrInvalidEvent is returned when an event has no action or an unknown result
+var ErrInvalidEvent = errors.New("audit: event requires an action and result")
+
+// Params describes the subject of an action, e.g. a dataset ID
+type Params map[string]string
+
+// Param is a single event parameter
+type Param struct {
+	Key   string `avro:"key"`
+	Value string `avro:"value"`
+}
+
+// Event is an audit record of an action
+type Event struct {
+	Created   string  `avro:"created"`
+	Service   string  `avro:"service"`
+	RequestID string  `avro:"request_id"`
+	User      string  `avro:"user"`
+	Action    string  `avro:"attempted_action"`
+	Result    string  `avro:"action_result"`
+	Params    []Param `avro:"params"`
+	// PrevHash and Hash are set by ChainSink
+	PrevHash string `avro:"prev_hash"`
+	Hash     string `avro:"hash"`
+}
+
+// Sink publishes audit events
+type Sink interface {
+	Publish(e Event) error
+}
+
+// Auditor records audit events
+type Auditor interface {
+	Record(ctx context.Context, action, result string, params Params) error
+}
+
+// auditor records events with the request ID and caller identity from the
+// context, publishing them to a sink
+type auditor struct {
+	service string
+	sink    Sink
+}
+
+// New returns an auditor which publishes events for a service to sink
+func New(sink Sink, service string) Auditor {
+	return &auditor{service: service, sink: sink}
+}
+
+// Record publishes an event for an action. The user is the caller identity
+// set by log.WithCaller, e.g. by the jwt handler.
+func (a *auditor) Record(ctx context.Context, action, result string, params Params) error {
+	if len(action) == 0 || (result != Attempted && result != Successful && result != Unsuccessful) {
+		return ErrInvalidEvent
+	}
+
+	e := Event{
+		Created:   time.Now().UTC().Format(time.RFC3339Nano),
+		Service:   a.service,
+		RequestID: log.RequestID(ctx),
+		User:      log.Caller(ctx),
+		Action:    action,
+		Result:    result,
+		Params:    sortedParams(params),
+	}
+
+	if err := a.sink.Publish(e); err != nil {
+		log.ErrorCtx(ctx, err, log.Data{"action": action, "result": result})
+		return err
+	}
+	return nil
+}
+
+// sortedParams returns params ordered by key
+func sortedParams(params Params) []Param {
+	p := make([]Param, 0, len(params))
+	for k, v := range params {
+		p = append(p, Param{k, v})
+	}
+	sort.Slice(p, func(i, j int) bool {
+		return p[i].Key < p[j].Key
+	})
+	return p
+}
+
+// NopAuditor discards events, e.g. when auditing is disabled
+type NopAuditor struct{}
+
+// Record does nothing
+func (NopAuditor) Record(ctx context.Context, action, result string, params Params) error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type sinkFunc func(e Event) error
+
+func (f sinkFunc) Publish(e Event) error {
+	return f(e)
+}
+
+func TestAuditor(t *testing.T) {
+	ctx := log.WithCaller(log.WithRequestID(context.Background(), "request-1"), "user@ons.gov.uk")
+
+	Convey("Record should publish an event with the identity from the context", t, func() {
+		var published Event
+		a := New(sinkFunc(func(e Event) error {
+			published = e
+			return nil
+		}), "dataset-api")
+
+		err := a.Record(ctx, "publish", Successful, Params{"instance_id": "123", "dataset_id": "cpih01"})
+		So(err, ShouldBeNil)
+
+		So(published.Service, ShouldEqual, "dataset-api")
+		So(published.RequestID, ShouldEqual, "request-1")
+		So(published.User, ShouldEqual, "user@ons.gov.uk")
+		So(published.Action, ShouldEqual, "publish")
+		So(published.Result, ShouldEqual, Successful)
+		So(published.Params, ShouldResemble, []Param{{"dataset_id", "cpih01"}, {"instance_id", "123"}})
+
+		created, err := time.Parse(time.RFC3339Nano, published.Created)
+		So(err, ShouldBeNil)
+		So(time.Since(created), ShouldBeLessThan, time.Minute)
+	})
+
+	Convey("Record should reject events without an action or a valid result", t, func() {
+		a := New(sinkFunc(func(e Event) error {
+			return nil
+		}), "dataset-api")
+
+		So(a.Record(ctx, "", Attempted, nil), ShouldEqual, ErrInvalidEvent)
+		So(a.Record(ctx, "publish", "done", nil), ShouldEqual, ErrInvalidEvent)
+	})
+
+	Convey("Record should log and return sink errors", t, func() {
+		defer log.SetEvent(nil)
+
+		var eventName, eventContext string
+		log.SetEvent(func(name string, context string, data log.Data) {
+			eventName = name
+			eventContext = context
+		})
+
+		sinkErr := errors.New("sink unavailable")
+		a := New(sinkFunc(func(e Event) error {
+			return sinkErr
+		}), "dataset-api")
+
+		So(a.Record(ctx, "publish", Attempted, nil), ShouldEqual, sinkErr)
+		So(eventName, ShouldEqual, "error")
+		So(eventContext, ShouldEqual, "request-1")
+	})
+
+	Convey("NopAuditor should discard events", t, func() {
+		var a Auditor = NopAuditor{}
+		So(a.Record(ctx, "publish", Attempted, nil), ShouldBeNil)
+	})
+}
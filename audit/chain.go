@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// AnchorAction is the action of anchor events published by ChainSink
+const AnchorAction = "audit_anchor"
+
+// ErrBrokenChain is returned by Verify when an event has been modified,
+// removed or reordered
+var ErrBrokenChain = errors.New("audit: hash chain is broken")
+
+// ChainConfig configures a ChainSink
+type ChainConfig struct {
+	// AnchorEvery publishes an anchor after this many events, defaults
+	// to 1000
+	AnchorEvery int
+	// AnchorInterval publishes an anchor if this long has passed since the
+	// last one when an event is published, defaults to an hour
+	AnchorInterval time.Duration
+}
+
+// ChainSink makes an audit stream tamper evident. Each event includes the
+// hash of the previous event, and its own hash covers that, so modifying,
+// removing or reordering an event breaks the chain from that point.
+//
+// Anchors are published to the sink as AnchorAction events, and logged as
+// "audit anchor" info events, recording the latest hash and the number of
+// events in the chain. As the log is stored separately, comparing it with
+// the audit stream detects the stream being rewritten from the start.
+type ChainSink struct {
+	sink Sink
+	cfg  ChainConfig
+
+	mutex       sync.Mutex
+	prev        string
+	sequence    int
+	sinceAnchor int
+	anchored    time.Time
+}
+
+// NewChainSink returns a sink which chains events before publishing them
+// to sink, e.g.
+//
+//	audit.New(audit.NewChainSink(audit.NewKafkaSink(output), audit.ChainConfig{}), "dataset-api")
+func NewChainSink(sink Sink, cfg ChainConfig) *ChainSink {
+	if cfg.AnchorEvery <= 0 {
+		cfg.AnchorEvery = 1000
+	}
+	if cfg.AnchorInterval <= 0 {
+		cfg.AnchorInterval = time.Hour
+	}
+	return &ChainSink{sink: sink, cfg: cfg, anchored: time.Now()}
+}
+
+// Publish chains an event to the previous one and publishes it, followed
+// by an anchor if one is due. The chain only advances when the sink
+// publishes the event.
+func (s *ChainSink) Publish(e Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.publish(e); err != nil {
+		return err
+	}
+
+	s.sinceAnchor++
+	if s.sinceAnchor < s.cfg.AnchorEvery && time.Since(s.anchored) < s.cfg.AnchorInterval {
+		return nil
+	}
+	return s.anchor(e.Service)
+}
+
+// anchor publishes an anchor event for the latest hash
+func (s *ChainSink) anchor(service string) error {
+	latest, sequence := s.prev, s.sequence
+	a := Event{
+		Created: time.Now().UTC().Format(time.RFC3339Nano),
+		Service: service,
+		Action:  AnchorAction,
+		Result:  Successful,
+		Params: []Param{
+			{"hash", latest},
+			{"sequence", strconv.Itoa(sequence)},
+		},
+	}
+	if err := s.publish(a); err != nil {
+		return err
+	}
+
+	log.Info("audit anchor", log.Data{"service": service, "hash": latest, "sequence": sequence})
+	s.sinceAnchor = 0
+	s.anchored = time.Now()
+	return nil
+}
+
+func (s *ChainSink) publish(e Event) error {
+	e.PrevHash = s.prev
+	e.Hash = hash(e)
+	if err := s.sink.Publish(e); err != nil {
+		return err
+	}
+	s.prev = e.Hash
+	s.sequence++
+	return nil
+}
+
+// hash returns the hex encoded SHA-256 hash of an event and the hash of
+// the previous event
+func hash(e Event) string {
+	e.Hash = ""
+	if len(e.Params) == 0 {
+		e.Params = nil
+	}
+	// the fields are encoded in a fixed order and params are sorted, so
+	// the encoding is deterministic
+	b, _ := json.Marshal(e)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that events form an unbroken chain, in the order they
+// were published. prev is the hash of the event before the first, which
+// is empty for the start of a stream.
+func Verify(events []Event, prev string) error {
+	for _, e := range events {
+		if e.PrevHash != prev || e.Hash != hash(e) {
+			return ErrBrokenChain
+		}
+		prev = e.Hash
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestChainSink(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var anchors []log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		if data["message"] == "audit anchor" {
+			anchors = append(anchors, data)
+		}
+	})
+
+	ctx := log.WithRequestID(context.Background(), "request-1")
+
+	var published []Event
+	var sinkErr error
+	sink := sinkFunc(func(e Event) error {
+		if sinkErr != nil {
+			return sinkErr
+		}
+		published = append(published, e)
+		return nil
+	})
+
+	Convey("ChainSink should chain events with a rolling hash", t, func() {
+		published, anchors, sinkErr = nil, nil, nil
+		a := New(NewChainSink(sink, ChainConfig{AnchorEvery: 3}), "dataset-api")
+
+		for _, action := range []string{"create", "update", "publish", "delete"} {
+			So(a.Record(ctx, action, Successful, Params{"dataset_id": "cpih01"}), ShouldBeNil)
+		}
+
+		So(published, ShouldHaveLength, 5)
+		So(published[0].PrevHash, ShouldBeEmpty)
+		So(published[0].Hash, ShouldHaveLength, 64)
+		So(published[1].PrevHash, ShouldEqual, published[0].Hash)
+		So(Verify(published, ""), ShouldBeNil)
+
+		Convey("and publish an anchor periodically", func() {
+			anchor := published[3]
+			So(anchor.Action, ShouldEqual, AnchorAction)
+			So(anchor.Params, ShouldResemble, []Param{{"hash", published[2].Hash}, {"sequence", "3"}})
+			So(anchors, ShouldHaveLength, 1)
+			So(anchors[0]["hash"], ShouldEqual, published[2].Hash)
+			So(published[4].PrevHash, ShouldEqual, anchor.Hash)
+		})
+
+		Convey("and detect modified events", func() {
+			published[1].User = "someone@ons.gov.uk"
+			So(Verify(published, ""), ShouldEqual, ErrBrokenChain)
+		})
+
+		Convey("and detect removed events", func() {
+			So(Verify(append(published[:1:1], published[2:]...), ""), ShouldEqual, ErrBrokenChain)
+		})
+
+		Convey("and detect reordered events", func() {
+			published[1], published[2] = published[2], published[1]
+			So(Verify(published, ""), ShouldEqual, ErrBrokenChain)
+		})
+
+		Convey("and verify part of a stream from an anchor", func() {
+			So(Verify(published[3:], published[2].Hash), ShouldBeNil)
+		})
+	})
+
+	Convey("ChainSink should not advance the chain when publishing fails", t, func() {
+		published, sinkErr = nil, nil
+		a := New(NewChainSink(sink, ChainConfig{}), "dataset-api")
+
+		So(a.Record(ctx, "create", Successful, nil), ShouldBeNil)
+		sinkErr = errors.New("sink unavailable")
+		So(a.Record(ctx, "update", Successful, nil), ShouldEqual, sinkErr)
+		sinkErr = nil
+		So(a.Record(ctx, "publish", Successful, nil), ShouldBeNil)
+
+		So(published, ShouldHaveLength, 2)
+		So(Verify(published, ""), ShouldBeNil)
+	})
+
+	Convey("Chained events should verify after being encoded", t, func() {
+		published, sinkErr = nil, nil
+		a := New(NewChainSink(sink, ChainConfig{}), "dataset-api")
+		So(a.Record(ctx, "create", Attempted, nil), ShouldBeNil)
+		So(a.Record(ctx, "create", Successful, Params{"dataset_id": "cpih01"}), ShouldBeNil)
+
+		decoded := make([]Event, len(published))
+		for i, e := range published {
+			b, err := Schema.Marshal(e)
+			So(err, ShouldBeNil)
+			So(Schema.Unmarshal(b, &decoded[i]), ShouldBeNil)
+		}
+		So(Verify(decoded, ""), ShouldBeNil)
+	})
+}
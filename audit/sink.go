@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"github.com/ONSdigital/go-ns/avro"
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Schema is the Avro schema for events published to Kafka
+var Schema = &avro.Schema{Definition: `{
+	"type": "record",
+	"name": "audit",
+	"fields": [
+		{"name": "created", "type": "string"},
+		{"name": "service", "type": "string"},
+		{"name": "request_id", "type": "string"},
+		{"name": "user", "type": "string"},
+		{"name": "attempted_action", "type": "string"},
+		{"name": "action_result", "type": "string"},
+		{"name": "params", "type": {"type": "array", "items": {
+			"type": "record",
+			"name": "param",
+			"fields": [{"name": "key", "type": "string"}, {"name": "value", "type": "string"}]
+		}}},
+		{"name": "prev_hash", "type": "string", "default": ""},
+		{"name": "hash", "type": "string", "default": ""}
+	]
+}`}
+
+// KafkaSink publishes Avro encoded events to a Kafka producer, e.g.
+//
+//	audit.New(audit.NewKafkaSink(producer.Output()), "dataset-api")
+type KafkaSink struct {
+	output chan<- []byte
+}
+
+// NewKafkaSink returns a sink which writes events to a producer's output channel
+func NewKafkaSink(output chan<- []byte) *KafkaSink {
+	return &KafkaSink{output: output}
+}
+
+// Publish encodes and sends an event
+func (s *KafkaSink) Publish(e Event) error {
+	b, err := Schema.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.output <- b
+	return nil
+}
+
+// LogSink publishes events as "audit" log events
+type LogSink struct{}
+
+// Publish logs an event
+func (LogSink) Publish(e Event) error {
+	params := make(map[string]string, len(e.Params))
+	for _, p := range e.Params {
+		params[p.Key] = p.Value
+	}
+
+	data := log.Data{
+		"service": e.Service,
+		"action":  e.Action,
+		"result":  e.Result,
+	}
+	if len(e.User) > 0 {
+		data["user"] = e.User
+	}
+	if len(params) > 0 {
+		data["params"] = params
+	}
+	if len(e.Hash) > 0 {
+		data["prev_hash"] = e.PrevHash
+		data["hash"] = e.Hash
+	}
+
+	log.Event("audit", e.RequestID, data)
+	return nil
+}
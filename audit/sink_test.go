@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testEvent = Event{
+	Created:   "2018-01-01T00:00:00Z",
+	Service:   "dataset-api",
+	RequestID: "request-1",
+	User:      "user@ons.gov.uk",
+	Action:    "publish",
+	Result:    Successful,
+	Params:    []Param{{"dataset_id", "cpih01"}},
+}
+
+func TestKafkaSink(t *testing.T) {
+	Convey("KafkaSink should write Avro encoded events to the output channel", t, func() {
+		output := make(chan []byte, 1)
+		So(NewKafkaSink(output).Publish(testEvent), ShouldBeNil)
+
+		var e Event
+		So(Schema.Unmarshal(<-output, &e), ShouldBeNil)
+		So(e, ShouldResemble, testEvent)
+	})
+}
+
+func TestLogSink(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var eventName, eventContext string
+	var eventData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	Convey("LogSink should log events", t, func() {
+		So(LogSink{}.Publish(testEvent), ShouldBeNil)
+		So(eventName, ShouldEqual, "audit")
+		So(eventContext, ShouldEqual, "request-1")
+		So(eventData["user"], ShouldEqual, "user@ons.gov.uk")
+		So(eventData["action"], ShouldEqual, "publish")
+		So(eventData["result"], ShouldEqual, Successful)
+		So(eventData["params"], ShouldResemble, map[string]string{"dataset_id": "cpih01"})
+	})
+}
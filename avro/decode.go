@@ -0,0 +1,182 @@
+package avro
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+)
+
+// ErrInvalidData is returned when data can't be decoded with the schema
+var ErrInvalidData = errors.New("avro: invalid data")
+
+// Unmarshal decodes data encoded with the schema into a pointer to a struct.
+// Record fields without a tagged struct field are skipped.
+func (s *Schema) Unmarshal(data []byte, v interface{}) error {
+	t, err := s.parse()
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("avro: Unmarshal requires a non-nil pointer")
+	}
+
+	d := &decoder{data: data}
+	return d.decode(t, rv.Elem())
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+// decode decodes a value of type t into v, or discards it if v is invalid
+func (d *decoder) decode(t *schemaType, v reflect.Value) error {
+	if v.IsValid() && v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+
+	switch t.kind {
+	case "string", "bytes":
+		p, err := d.readBytes()
+		if err != nil || !v.IsValid() {
+			return err
+		}
+		if t.kind == "string" && v.Kind() == reflect.String {
+			v.SetString(string(p))
+			return nil
+		}
+		if t.kind == "bytes" && v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			v.SetBytes(append([]byte(nil), p...))
+			return nil
+		}
+		return typeError(t, v)
+
+	case "int", "long":
+		n, err := d.readLong()
+		if err != nil {
+			return err
+		}
+		if t.kind == "int" && (n < math.MinInt32 || n > math.MaxInt32) {
+			return fmt.Errorf("avro: %d overflows int", n)
+		}
+		if !v.IsValid() {
+			return nil
+		}
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(n) {
+				return fmt.Errorf("avro: %d overflows %s", n, v.Type())
+			}
+			v.SetInt(n)
+			return nil
+		}
+		return typeError(t, v)
+
+	case "boolean":
+		if d.pos >= len(d.data) {
+			return io.ErrUnexpectedEOF
+		}
+		b := d.data[d.pos] != 0
+		d.pos++
+		if !v.IsValid() {
+			return nil
+		}
+		if v.Kind() != reflect.Bool {
+			return typeError(t, v)
+		}
+		v.SetBool(b)
+		return nil
+
+	case "array":
+		if v.IsValid() && v.Kind() != reflect.Slice {
+			return typeError(t, v)
+		}
+		if v.IsValid() {
+			v.Set(reflect.MakeSlice(v.Type(), 0, 0))
+		}
+		for {
+			n, err := d.readLong()
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				return nil
+			}
+			// a negative count is followed by the block size in bytes
+			if n < 0 {
+				n = -n
+				if _, err := d.readLong(); err != nil {
+					return err
+				}
+			}
+			for i := int64(0); i < n; i++ {
+				var item reflect.Value
+				if v.IsValid() {
+					v.Set(reflect.Append(v, reflect.Zero(v.Type().Elem())))
+					item = v.Index(v.Len() - 1)
+				}
+				if err := d.decode(t.items, item); err != nil {
+					return err
+				}
+			}
+		}
+
+	case "record":
+		var fields map[string]int
+		if v.IsValid() {
+			if v.Kind() != reflect.Struct {
+				return typeError(t, v)
+			}
+			fields = fieldsByTag(v.Type())
+		}
+		for _, f := range t.fields {
+			var fv reflect.Value
+			if i, ok := fields[f.name]; ok {
+				fv = v.Field(i)
+			}
+			if err := d.decode(f.typ, fv); err != nil {
+				return fmt.Errorf("%v (field %q)", err, f.name)
+			}
+		}
+		return nil
+	}
+
+	return ErrUnsupportedType
+}
+
+// readLong reads a zig-zag encoded variable length integer
+func (d *decoder) readLong() (int64, error) {
+	var u uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if d.pos >= len(d.data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		c := d.data[d.pos]
+		d.pos++
+		u |= uint64(c&0x7f) << shift
+		if c < 0x80 {
+			return int64(u>>1) ^ -int64(u&1), nil
+		}
+	}
+	return 0, ErrInvalidData
+}
+
+func (d *decoder) readBytes() ([]byte, error) {
+	n, err := d.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 || int64(len(d.data)-d.pos) < n {
+		return nil, ErrInvalidData
+	}
+	p := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return p, nil
+}
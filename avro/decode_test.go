@@ -0,0 +1,81 @@
+package avro
+
+import (
+	"io"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnmarshal(t *testing.T) {
+	Convey("Unmarshal should decode values encoded by Marshal", t, func() {
+		in := instance{
+			InstanceID: "instance-1",
+			Count:      -42,
+			Total:      1 << 40,
+			Complete:   true,
+			Payload:    []byte{0, 1, 2},
+			Dimension:  &dimension{Name: "time", Options: []string{"2017", "2018"}},
+			Dimensions: []dimension{{Name: "geography", Options: []string{"K02000001"}}, {Name: "age"}},
+			Ignored:    "not encoded",
+		}
+
+		b, err := testSchema.Marshal(in)
+		So(err, ShouldBeNil)
+
+		var out instance
+		So(testSchema.Unmarshal(b, &out), ShouldBeNil)
+
+		in.Ignored = ""
+		in.Dimensions[1].Options = []string{}
+		So(out, ShouldResemble, in)
+	})
+
+	Convey("Unmarshal should skip fields which aren't in the struct", t, func() {
+		b, err := testSchema.Marshal(instance{InstanceID: "instance-1", Total: 3, Dimension: &dimension{Name: "time"}})
+		So(err, ShouldBeNil)
+
+		var out struct {
+			Total int64 `avro:"total"`
+		}
+		So(testSchema.Unmarshal(b, &out), ShouldBeNil)
+		So(out.Total, ShouldEqual, 3)
+	})
+
+	Convey("Unmarshal should decode arrays with block sizes", t, func() {
+		s := &Schema{Definition: `{"type": "record", "name": "r", "fields": [{"name": "a", "type": {"type": "array", "items": "int"}}]}`}
+		var out struct {
+			A []int `avro:"a"`
+		}
+		So(s.Unmarshal([]byte{0x03, 0x04, 0x02, 0x04, 0x02, 0x06, 0x00}, &out), ShouldBeNil)
+		So(out.A, ShouldResemble, []int{1, 2, 3})
+	})
+
+	Convey("Unmarshal should return an error for invalid data", t, func() {
+		var out instance
+		So(testSchema.Unmarshal([]byte{0x06, 'a'}, &out), ShouldNotBeNil)
+		So(testSchema.Unmarshal(nil, &out).Error(), ShouldContainSubstring, io.ErrUnexpectedEOF.Error())
+		So(testSchema.Unmarshal([]byte{0x00}, out), ShouldNotBeNil)
+	})
+
+	Convey("Unmarshal should return an error for overflowing values", t, func() {
+		s := &Schema{Definition: `{"type": "record", "name": "r", "fields": [{"name": "n", "type": "long"}]}`}
+		b, err := s.Marshal(struct {
+			N int64 `avro:"n"`
+		}{1 << 40})
+		So(err, ShouldBeNil)
+
+		var out struct {
+			N int32 `avro:"n"`
+		}
+		So(s.Unmarshal(b, &out), ShouldNotBeNil)
+
+		// 1<<40 read with an int schema, into a field wide enough to hold it
+		s = &Schema{Definition: `{"type": "record", "name": "r", "fields": [{"name": "n", "type": "int"}]}`}
+		var wide struct {
+			N int64 `avro:"n"`
+		}
+		So(s.Unmarshal(b, &wide), ShouldNotBeNil)
+		So(s.Unmarshal(b, &struct{}{}), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,124 @@
+package avro
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Marshal encodes a struct, or a pointer to a struct, with the schema
+func (s *Schema) Marshal(v interface{}) ([]byte, error) {
+	t, err := s.parse()
+	if err != nil {
+		return nil, err
+	}
+	return encode(nil, t, reflect.ValueOf(v))
+}
+
+func encode(b []byte, t *schemaType, v reflect.Value) ([]byte, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, errors.New("avro: cannot encode nil value")
+		}
+		v = v.Elem()
+	}
+
+	switch t.kind {
+	case "string":
+		if v.Kind() != reflect.String {
+			return nil, typeError(t, v)
+		}
+		return appendBytes(b, []byte(v.String())), nil
+
+	case "bytes":
+		if v.Kind() != reflect.Slice || v.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, typeError(t, v)
+		}
+		return appendBytes(b, v.Bytes()), nil
+
+	case "int", "long":
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			n := v.Int()
+			if t.kind == "int" && (n < math.MinInt32 || n > math.MaxInt32) {
+				return nil, fmt.Errorf("avro: %d overflows int", n)
+			}
+			return appendLong(b, n), nil
+		}
+		return nil, typeError(t, v)
+
+	case "boolean":
+		if v.Kind() != reflect.Bool {
+			return nil, typeError(t, v)
+		}
+		if v.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+
+	case "array":
+		if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+			return nil, typeError(t, v)
+		}
+		// a single block followed by the zero length terminating block
+		if v.Len() > 0 {
+			b = appendLong(b, int64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				var err error
+				if b, err = encode(b, t.items, v.Index(i)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(b, 0), nil
+
+	case "record":
+		if v.Kind() != reflect.Struct {
+			return nil, typeError(t, v)
+		}
+		fields := fieldsByTag(v.Type())
+		for _, f := range t.fields {
+			i, ok := fields[f.name]
+			if !ok {
+				return nil, fmt.Errorf("avro: no field tagged %q in %s", f.name, v.Type())
+			}
+			var err error
+			if b, err = encode(b, f.typ, v.Field(i)); err != nil {
+				return nil, fmt.Errorf("%v (field %q)", err, f.name)
+			}
+		}
+		return b, nil
+	}
+
+	return nil, ErrUnsupportedType
+}
+
+// fieldsByTag returns the index of each struct field by its avro tag
+func fieldsByTag(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("avro"); len(tag) > 0 && tag != "-" {
+			fields[tag] = i
+		}
+	}
+	return fields
+}
+
+func typeError(t *schemaType, v reflect.Value) error {
+	return fmt.Errorf("avro: cannot use %s as %s", v.Type(), t.kind)
+}
+
+// appendLong appends a zig-zag encoded variable length integer
+func appendLong(b []byte, n int64) []byte {
+	u := uint64((n << 1) ^ (n >> 63))
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+func appendBytes(b []byte, p []byte) []byte {
+	return append(appendLong(b, int64(len(p))), p...)
+}
@@ -0,0 +1,102 @@
+package avro
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testSchema = &Schema{Definition: `{
+	"type": "record",
+	"name": "instance",
+	"fields": [
+		{"name": "instance_id", "type": "string"},
+		{"name": "count", "type": "int"},
+		{"name": "total", "type": "long"},
+		{"name": "complete", "type": "boolean"},
+		{"name": "payload", "type": "bytes"},
+		{"name": "dimension", "type": {
+			"type": "record",
+			"name": "dimension",
+			"fields": [{"name": "name", "type": "string"}, {"name": "options", "type": {"type": "array", "items": "string"}}]
+		}},
+		{"name": "dimensions", "type": {"type": "array", "items": "dimension"}}
+	]
+}`}
+
+type dimension struct {
+	Name    string   `avro:"name"`
+	Options []string `avro:"options"`
+}
+
+type instance struct {
+	InstanceID string      `avro:"instance_id"`
+	Count      int32       `avro:"count"`
+	Total      int64       `avro:"total"`
+	Complete   bool        `avro:"complete"`
+	Payload    []byte      `avro:"payload"`
+	Dimension  *dimension  `avro:"dimension"`
+	Dimensions []dimension `avro:"dimensions"`
+	Ignored    string
+}
+
+func TestMarshal(t *testing.T) {
+	Convey("Marshal should use the Avro binary encoding", t, func() {
+		s := &Schema{Definition: `{"type": "record", "name": "r", "fields": [
+			{"name": "s", "type": "string"},
+			{"name": "i", "type": "int"},
+			{"name": "b", "type": "boolean"},
+			{"name": "a", "type": {"type": "array", "items": "long"}}
+		]}`}
+		v := struct {
+			S string  `avro:"s"`
+			I int     `avro:"i"`
+			B bool    `avro:"b"`
+			A []int64 `avro:"a"`
+		}{"foo", -64, true, []int64{1, 64}}
+
+		b, err := s.Marshal(v)
+		So(err, ShouldBeNil)
+		So(b, ShouldResemble, []byte{
+			0x06, 'f', 'o', 'o', // length 3, "foo"
+			0x7f,                         // -64
+			0x01,                         // true
+			0x04, 0x02, 0x80, 0x01, 0x00, // block of 2 items: 1, 64, then end of array
+		})
+	})
+
+	Convey("Marshal should accept a pointer to a struct", t, func() {
+		b, err := testSchema.Marshal(&instance{InstanceID: "a", Dimension: &dimension{}})
+		So(err, ShouldBeNil)
+		So(b, ShouldNotBeEmpty)
+	})
+
+	Convey("Marshal should return an error for missing or mismatched fields", t, func() {
+		_, err := testSchema.Marshal(struct {
+			InstanceID string `avro:"instance_id"`
+		}{})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, `"count"`)
+
+		_, err = testSchema.Marshal(struct {
+			InstanceID int `avro:"instance_id"`
+		}{})
+		So(err, ShouldNotBeNil)
+
+		_, err = testSchema.Marshal(instance{})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Marshal should return an error for values which overflow int", t, func() {
+		s := &Schema{Definition: `{"type": "record", "name": "r", "fields": [{"name": "n", "type": "int"}]}`}
+		_, err := s.Marshal(struct {
+			N int64 `avro:"n"`
+		}{1 << 31})
+		So(err, ShouldNotBeNil)
+
+		_, err = s.Marshal(struct {
+			N int64 `avro:"n"`
+		}{-1 << 31})
+		So(err, ShouldBeNil)
+	})
+}
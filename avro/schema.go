@@ -0,0 +1,107 @@
+// Package avro encodes and decodes structs using Avro binary encoding,
+// mapping record fields to struct fields with `avro:"field_name"` tags.
+//
+// Supported schema types are string, int, long, boolean, bytes, arrays and
+// nested records.
+package avro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Schema is an Avro schema definition, e.g.
+//
+//	var schema = &avro.Schema{Definition: `{
+//		"type": "record",
+//		"name": "instance-completed",
+//		"fields": [{"name": "instance_id", "type": "string"}]
+//	}`}
+type Schema struct {
+	Definition string
+
+	once   sync.Once
+	parsed *schemaType
+	err    error
+}
+
+// schemaType is a parsed schema type
+type schemaType struct {
+	kind   string
+	name   string
+	items  *schemaType
+	fields []field
+}
+
+type field struct {
+	name string
+	typ  *schemaType
+}
+
+// ErrUnsupportedType is returned for schema types which aren't supported
+var ErrUnsupportedType = errors.New("avro: unsupported schema type")
+
+func (s *Schema) parse() (*schemaType, error) {
+	s.once.Do(func() {
+		var def interface{}
+		if s.err = json.Unmarshal([]byte(s.Definition), &def); s.err != nil {
+			return
+		}
+		s.parsed, s.err = parseType(def, map[string]*schemaType{})
+	})
+	return s.parsed, s.err
+}
+
+// parseType parses a type from its JSON definition, recording named records
+// so they can be referenced by later fields
+func parseType(def interface{}, named map[string]*schemaType) (*schemaType, error) {
+	switch d := def.(type) {
+	case string:
+		switch d {
+		case "string", "int", "long", "boolean", "bytes":
+			return &schemaType{kind: d}, nil
+		}
+		if t, ok := named[d]; ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("%v: %q", ErrUnsupportedType, d)
+
+	case map[string]interface{}:
+		kind, _ := d["type"].(string)
+		switch kind {
+		case "array":
+			items, err := parseType(d["items"], named)
+			if err != nil {
+				return nil, err
+			}
+			return &schemaType{kind: kind, items: items}, nil
+
+		case "record":
+			name, _ := d["name"].(string)
+			t := &schemaType{kind: kind, name: name}
+			if len(name) > 0 {
+				named[name] = t
+			}
+
+			fields, _ := d["fields"].([]interface{})
+			for _, f := range fields {
+				fd, _ := f.(map[string]interface{})
+				name, _ := fd["name"].(string)
+				if len(name) == 0 {
+					return nil, fmt.Errorf("avro: field without a name in record %q", t.name)
+				}
+				ft, err := parseType(fd["type"], named)
+				if err != nil {
+					return nil, err
+				}
+				t.fields = append(t.fields, field{name, ft})
+			}
+			return t, nil
+		}
+		return parseType(kind, named)
+	}
+
+	return nil, fmt.Errorf("%v: %v", ErrUnsupportedType, def)
+}
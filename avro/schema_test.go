@@ -0,0 +1,40 @@
+package avro
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSchema(t *testing.T) {
+	Convey("Schema should parse records, arrays and named references", t, func() {
+		s := &Schema{Definition: `{
+			"type": "record",
+			"name": "parent",
+			"fields": [
+				{"name": "id", "type": "string"},
+				{"name": "child", "type": {"type": "record", "name": "child", "fields": [{"name": "n", "type": "int"}]}},
+				{"name": "children", "type": {"type": "array", "items": "child"}}
+			]
+		}`}
+
+		parsed, err := s.parse()
+		So(err, ShouldBeNil)
+		So(parsed.kind, ShouldEqual, "record")
+		So(parsed.fields, ShouldHaveLength, 3)
+		So(parsed.fields[1].typ.kind, ShouldEqual, "record")
+		So(parsed.fields[2].typ.items, ShouldEqual, parsed.fields[1].typ)
+	})
+
+	Convey("Schema should return an error for invalid definitions", t, func() {
+		_, err := (&Schema{Definition: `{`}).parse()
+		So(err, ShouldNotBeNil)
+
+		_, err = (&Schema{Definition: `{"type": "record", "fields": [{"name": "f", "type": "double"}]}`}).parse()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, ErrUnsupportedType.Error())
+
+		_, err = (&Schema{Definition: `{"type": "record", "fields": [{"type": "int"}]}`}).parse()
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,204 @@
+// Package baggage implements W3C Baggage propagation
+// (https://www.w3.org/TR/baggage/), carrying application defined key/value
+// pairs across service boundaries in the baggage header.
+package baggage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Header is the HTTP header used to propagate baggage
+const Header = "baggage"
+
+// limits from the W3C specification
+const (
+	maxMembers = 180
+	maxBytes   = 8192
+)
+
+// ErrInvalidMember is returned when parsing a malformed list member
+var ErrInvalidMember = errors.New("baggage: invalid list member")
+
+// Member is a single baggage entry
+type Member struct {
+	Key        string
+	Value      string
+	Properties []string
+}
+
+// Baggage is an ordered, immutable set of members
+type Baggage struct {
+	members []Member
+}
+
+// Parse parses a baggage header value. Malformed members are skipped and
+// reported via ErrInvalidMember, with the valid members still returned.
+func Parse(header string) (Baggage, error) {
+	var b Baggage
+	var err error
+
+	if len(header) > maxBytes {
+		header = header[:maxBytes]
+	}
+
+	for _, item := range strings.Split(header, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		if len(b.members) >= maxMembers {
+			break
+		}
+
+		m, e := parseMember(item)
+		if e != nil {
+			err = e
+			continue
+		}
+		b = b.Set(m.Key, m.Value, m.Properties...)
+	}
+
+	return b, err
+}
+
+func parseMember(item string) (Member, error) {
+	parts := strings.Split(item, ";")
+
+	kv := strings.SplitN(parts[0], "=", 2)
+	if len(kv) != 2 {
+		return Member{}, ErrInvalidMember
+	}
+
+	key := strings.TrimSpace(kv[0])
+	if !validKey(key) {
+		return Member{}, ErrInvalidMember
+	}
+
+	value, err := url.PathUnescape(strings.TrimSpace(kv[1]))
+	if err != nil {
+		return Member{}, ErrInvalidMember
+	}
+
+	m := Member{Key: key, Value: value}
+	for _, p := range parts[1:] {
+		if p = strings.TrimSpace(p); len(p) > 0 {
+			m.Properties = append(m.Properties, p)
+		}
+	}
+	return m, nil
+}
+
+// validKey reports whether a key is a valid RFC 7230 token
+func validKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+	for _, c := range key {
+		if c <= ' ' || c >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the value for a key
+func (b Baggage) Get(key string) (string, bool) {
+	for _, m := range b.members {
+		if m.Key == key {
+			return m.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set returns a copy of the baggage with the key set to value, replacing any
+// existing member with the same key
+func (b Baggage) Set(key, value string, properties ...string) Baggage {
+	members := make([]Member, 0, len(b.members)+1)
+	for _, m := range b.members {
+		if m.Key != key {
+			members = append(members, m)
+		}
+	}
+	return Baggage{append(members, Member{Key: key, Value: value, Properties: properties})}
+}
+
+// Delete returns a copy of the baggage without the given key
+func (b Baggage) Delete(key string) Baggage {
+	members := make([]Member, 0, len(b.members))
+	for _, m := range b.members {
+		if m.Key != key {
+			members = append(members, m)
+		}
+	}
+	return Baggage{members}
+}
+
+// Members returns a copy of the baggage members
+func (b Baggage) Members() []Member {
+	return append([]Member(nil), b.members...)
+}
+
+// Len returns the number of members
+func (b Baggage) Len() int {
+	return len(b.members)
+}
+
+// String encodes the baggage as a header value
+func (b Baggage) String() string {
+	items := make([]string, 0, len(b.members))
+	for _, m := range b.members {
+		item := m.Key + "=" + url.PathEscape(m.Value)
+		for _, p := range m.Properties {
+			item += ";" + p
+		}
+		items = append(items, item)
+	}
+	return strings.Join(items, ",")
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the baggage
+func NewContext(ctx context.Context, b Baggage) context.Context {
+	return context.WithValue(ctx, contextKey{}, b)
+}
+
+// FromContext returns the baggage carried by a context, which is empty if
+// none has been set
+func FromContext(ctx context.Context) Baggage {
+	b, _ := ctx.Value(contextKey{}).(Baggage)
+	return b
+}
+
+// Get returns a baggage value from a context
+func Get(ctx context.Context, key string) (string, bool) {
+	return FromContext(ctx).Get(key)
+}
+
+// Set returns a context with a baggage value set
+func Set(ctx context.Context, key, value string) context.Context {
+	return NewContext(ctx, FromContext(ctx).Set(key, value))
+}
+
+// Handler parses the baggage header of incoming requests into the request context
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if header := req.Header.Get(Header); len(header) > 0 {
+			b, _ := Parse(header)
+			req = req.WithContext(NewContext(req.Context(), b))
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+// Inject sets the baggage header on an outbound request from its context
+func Inject(req *http.Request) {
+	if b := FromContext(req.Context()); b.Len() > 0 {
+		req.Header.Set(Header, b.String())
+	}
+}
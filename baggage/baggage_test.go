@@ -0,0 +1,99 @@
+package baggage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParse(t *testing.T) {
+	Convey("Parse should parse list members", t, func() {
+		b, err := Parse("userId=alice, serverNode = DF%2028 ,isProduction=false;prop1;p2=v")
+		So(err, ShouldBeNil)
+		So(b.Len(), ShouldEqual, 3)
+
+		v, ok := b.Get("serverNode")
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, "DF 28")
+
+		m := b.Members()[2]
+		So(m.Key, ShouldEqual, "isProduction")
+		So(m.Value, ShouldEqual, "false")
+		So(m.Properties, ShouldResemble, []string{"prop1", "p2=v"})
+	})
+
+	Convey("Parse should skip invalid members", t, func() {
+		b, err := Parse("a=1,novalue,b c=2,d=3")
+		So(err, ShouldEqual, ErrInvalidMember)
+		So(b.Len(), ShouldEqual, 2)
+		_, ok := b.Get("d")
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey("Parse should keep the last value for a duplicate key", t, func() {
+		b, err := Parse("a=1,a=2")
+		So(err, ShouldBeNil)
+		So(b.Len(), ShouldEqual, 1)
+		v, _ := b.Get("a")
+		So(v, ShouldEqual, "2")
+	})
+}
+
+func TestBaggage(t *testing.T) {
+	Convey("Set and Delete should not modify the original baggage", t, func() {
+		b := Baggage{}.Set("a", "1")
+		c := b.Set("b", "2")
+		d := c.Delete("a")
+		So(b.Len(), ShouldEqual, 1)
+		So(c.Len(), ShouldEqual, 2)
+		So(d.Len(), ShouldEqual, 1)
+	})
+
+	Convey("String should encode values", t, func() {
+		b := Baggage{}.Set("a", "x y,z").Set("b", "2", "prop")
+		So(b.String(), ShouldEqual, "a=x%20y%2Cz,b=2;prop")
+
+		p, err := Parse(b.String())
+		So(err, ShouldBeNil)
+		So(p, ShouldResemble, b)
+	})
+
+	Convey("Context helpers should get and set values", t, func() {
+		ctx := Set(context.Background(), "tenant", "ons")
+		v, ok := Get(ctx, "tenant")
+		So(ok, ShouldBeTrue)
+		So(v, ShouldEqual, "ons")
+
+		_, ok = Get(context.Background(), "tenant")
+		So(ok, ShouldBeFalse)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	Convey("Handler should add baggage to the request context", t, func() {
+		var tenant string
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			tenant, _ = Get(req.Context(), "tenant")
+		}))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set(Header, "tenant=ons")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		So(tenant, ShouldEqual, "ons")
+	})
+
+	Convey("Inject should set the header from the request context", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		Inject(req)
+		So(req.Header.Get(Header), ShouldBeEmpty)
+
+		req = req.WithContext(Set(req.Context(), "tenant", "ons"))
+		Inject(req)
+		So(req.Header.Get(Header), ShouldEqual, "tenant=ons")
+	})
+}
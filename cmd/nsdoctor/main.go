@@ -0,0 +1,58 @@
+// Command nsdoctor checks a service's runtime configuration and
+// dependencies, printing a JSON report and exiting non-zero on failure
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/go-ns/doctor"
+)
+
+type dependencies map[string][]string
+
+func (d dependencies) String() string {
+	return fmt.Sprint(map[string][]string(d))
+}
+
+func (d dependencies) Set(v string) error {
+	kv := strings.SplitN(v, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected name=host:port, got %q", v)
+	}
+	d[kv[0]] = append(d[kv[0]], strings.Split(kv[1], ",")...)
+	return nil
+}
+
+func main() {
+	deps := dependencies{}
+	if addr := os.Getenv("KAFKA_ADDR"); len(addr) > 0 {
+		deps["kafka"] = strings.Split(addr, ",")
+	}
+	if addr := os.Getenv("MONGODB_BIND_ADDR"); len(addr) > 0 {
+		deps["mongo"] = strings.Split(addr, ",")
+	}
+
+	healthURL := flag.String("health", "", "health endpoint URL to check")
+	timeout := flag.Duration("timeout", 5*time.Second, "timeout for each network check")
+	flag.Var(deps, "dep", "dependency to check as name=host:port[,host:port] (repeatable)")
+	flag.Parse()
+
+	r := doctor.Diagnose(doctor.Config{
+		HealthURL:    *healthURL,
+		Dependencies: deps,
+		Timeout:      *timeout,
+	})
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+
+	if !r.OK {
+		os.Exit(1)
+	}
+}
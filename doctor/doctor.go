@@ -0,0 +1,215 @@
+// Package doctor checks a service's runtime configuration and the
+// reachability of its dependencies to help troubleshoot deployments
+package doctor
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/ONSdigital/go-ns/log/fluent"
+	"github.com/ONSdigital/go-ns/log/gelf"
+	"github.com/ONSdigital/go-ns/log/syslog"
+)
+
+// Check statuses
+const (
+	StatusOK   = "ok"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+)
+
+// Config configures the checks run by Diagnose
+type Config struct {
+	// HealthURL, if set, must respond with a 2xx status
+	HealthURL string
+	// Dependencies maps a name (e.g. "kafka") to TCP addresses which must
+	// accept connections
+	Dependencies map[string][]string
+	// Timeout for each network check, defaults to 5s
+	Timeout time.Duration
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Check    string        `json:"check"`
+	Status   string        `json:"status"`
+	Message  string        `json:"message,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Report is the outcome of all checks
+type Report struct {
+	Created time.Time `json:"created"`
+	OK      bool      `json:"ok"`
+	Results []Result  `json:"results"`
+}
+
+// Diagnose runs all checks. The report is OK unless a check fails.
+func Diagnose(cfg Config) Report {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+
+	r := Report{Created: time.Now(), OK: true}
+	r.add(checkHumanLog())
+	r.add(checkLevel())
+	r.add(checkLevels())
+	r.add(checkExtraFields())
+
+	for _, sink := range sinks() {
+		r.add(checkDial("log.sink."+sink.name, sink.network, sink.addr, cfg.Timeout))
+	}
+
+	if len(cfg.HealthURL) > 0 {
+		r.add(checkHealth(cfg.HealthURL, cfg.Timeout))
+	}
+
+	names := make([]string, 0, len(cfg.Dependencies))
+	for name := range cfg.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, addr := range cfg.Dependencies[name] {
+			r.add(checkDial("dependency."+name, "tcp", addr, cfg.Timeout))
+		}
+	}
+
+	return r
+}
+
+func (r *Report) add(res Result) {
+	if res.Status == StatusFail {
+		r.OK = false
+	}
+	r.Results = append(r.Results, res)
+}
+
+// checkHumanLog checks HUMAN_LOG is a boolean, or "pretty"
+func checkHumanLog() Result {
+	v := os.Getenv("HUMAN_LOG")
+	if len(v) == 0 {
+		return Result{Check: "log.human_log", Status: StatusOK, Message: "HUMAN_LOG is not set"}
+	}
+	if strings.EqualFold(strings.TrimSpace(v), "pretty") {
+		return Result{Check: "log.human_log", Status: StatusOK}
+	}
+	if _, err := strconv.ParseBool(v); err != nil {
+		return Result{Check: "log.human_log", Status: StatusWarn, Message: fmt.Sprintf("HUMAN_LOG=%q is not a valid boolean or \"pretty\" and is treated as false", v)}
+	}
+	return Result{Check: "log.human_log", Status: StatusOK}
+}
+
+// checkLevel checks LOG_LEVEL is a level name
+func checkLevel() Result {
+	v := os.Getenv("LOG_LEVEL")
+	if len(v) == 0 {
+		return Result{Check: "log.level", Status: StatusOK, Message: "LOG_LEVEL is not set"}
+	}
+	if _, err := log.ParseLevel(v); err != nil {
+		return Result{Check: "log.level", Status: StatusWarn, Message: fmt.Sprintf("LOG_LEVEL=%q is not a valid level and is ignored", v)}
+	}
+	return Result{Check: "log.level", Status: StatusOK}
+}
+
+// checkLevels checks LOG_LEVELS is a list of name=level pairs
+func checkLevels() Result {
+	v := os.Getenv("LOG_LEVELS")
+	if len(v) == 0 {
+		return Result{Check: "log.levels", Status: StatusOK, Message: "LOG_LEVELS is not set"}
+	}
+	if _, err := log.ParseLevels(v); err != nil {
+		return Result{Check: "log.levels", Status: StatusWarn, Message: fmt.Sprintf("LOG_LEVELS is invalid and is ignored: %s", err)}
+	}
+	return Result{Check: "log.levels", Status: StatusOK}
+}
+
+type sink struct {
+	name, network, addr string
+}
+
+// sinks returns the log sinks configured by environment variables which
+// have a network address
+func sinks() []sink {
+	var s []sink
+	if c := gelf.ConfigFromEnv(); len(c.Address) > 0 {
+		if len(c.Network) == 0 {
+			c.Network = "udp"
+		}
+		s = append(s, sink{"gelf", c.Network, c.Address})
+	}
+	// syslog uses a local socket unless a network is set
+	if c := syslog.ConfigFromEnv(); len(c.Network) > 0 && len(c.Address) > 0 {
+		s = append(s, sink{"syslog", c.Network, c.Address})
+	}
+	if c := fluent.ConfigFromEnv(); len(c.Address) > 0 {
+		s = append(s, sink{"fluent", "tcp", c.Address})
+	}
+	return s
+}
+
+func checkExtraFields() Result {
+	var invalid []string
+	for _, field := range strings.Split(os.Getenv("LOG_EXTRA_FIELDS"), ",") {
+		if len(field) == 0 {
+			continue
+		}
+		if kv := strings.SplitN(field, "=", 2); len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			invalid = append(invalid, field)
+		}
+	}
+	if len(invalid) > 0 {
+		return Result{Check: "log.extra_fields", Status: StatusWarn, Message: "ignored invalid LOG_EXTRA_FIELDS entries: " + strings.Join(invalid, ",")}
+	}
+	return Result{Check: "log.extra_fields", Status: StatusOK}
+}
+
+func checkHealth(url string, timeout time.Duration) Result {
+	res := Result{Check: "health"}
+
+	s := time.Now()
+	resp, err := (&http.Client{Timeout: timeout}).Get(url)
+	res.Duration = time.Since(s)
+	if err != nil {
+		res.Status = StatusFail
+		res.Message = err.Error()
+		return res
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		res.Status = StatusFail
+		res.Message = fmt.Sprintf("%s returned %d", url, resp.StatusCode)
+		return res
+	}
+
+	res.Status = StatusOK
+	return res
+}
+
+// checkDial checks a connection can be made to an address. UDP addresses
+// are only resolved, as there's no connection to make.
+func checkDial(check, network, addr string, timeout time.Duration) Result {
+	res := Result{Check: check}
+
+	s := time.Now()
+	conn, err := net.DialTimeout(network, addr, timeout)
+	res.Duration = time.Since(s)
+	if err != nil {
+		res.Status = StatusFail
+		res.Message = err.Error()
+		return res
+	}
+	conn.Close()
+
+	res.Status = StatusOK
+	res.Message = addr + " is reachable"
+	return res
+}
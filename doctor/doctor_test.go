@@ -0,0 +1,126 @@
+package doctor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func result(r Report, check string) Result {
+	for _, res := range r.Results {
+		if res.Check == check {
+			return res
+		}
+	}
+	return Result{}
+}
+
+func TestDiagnose(t *testing.T) {
+	defer func() {
+		os.Unsetenv("HUMAN_LOG")
+		os.Unsetenv("LOG_EXTRA_FIELDS")
+	}()
+
+	Convey("Diagnose should warn about invalid log configuration", t, func() {
+		os.Setenv("HUMAN_LOG", "yes please")
+		os.Setenv("LOG_EXTRA_FIELDS", "region=eu-west-1,team")
+
+		r := Diagnose(Config{})
+		So(r.OK, ShouldBeTrue)
+		So(result(r, "log.human_log").Status, ShouldEqual, StatusWarn)
+		So(result(r, "log.extra_fields").Status, ShouldEqual, StatusWarn)
+		So(result(r, "log.extra_fields").Message, ShouldEqual, "ignored invalid LOG_EXTRA_FIELDS entries: team")
+
+		os.Setenv("HUMAN_LOG", "true")
+		os.Setenv("LOG_EXTRA_FIELDS", "region=eu-west-1")
+		r = Diagnose(Config{})
+		So(result(r, "log.human_log").Status, ShouldEqual, StatusOK)
+		So(result(r, "log.extra_fields").Status, ShouldEqual, StatusOK)
+	})
+
+	Convey("Diagnose should accept HUMAN_LOG=pretty", t, func() {
+		os.Setenv("HUMAN_LOG", "Pretty")
+
+		r := Diagnose(Config{})
+		So(result(r, "log.human_log").Status, ShouldEqual, StatusOK)
+	})
+
+	Convey("Diagnose should warn about invalid log levels", t, func() {
+		defer os.Unsetenv("LOG_LEVEL")
+		defer os.Unsetenv("LOG_LEVELS")
+		os.Setenv("LOG_LEVEL", "verbose")
+		os.Setenv("LOG_LEVELS", "kafka=debug,mongo")
+
+		r := Diagnose(Config{})
+		So(r.OK, ShouldBeTrue)
+		So(result(r, "log.level").Status, ShouldEqual, StatusWarn)
+		So(result(r, "log.levels").Status, ShouldEqual, StatusWarn)
+
+		os.Setenv("LOG_LEVEL", "warn")
+		os.Setenv("LOG_LEVELS", "kafka=debug")
+		r = Diagnose(Config{})
+		So(result(r, "log.level").Status, ShouldEqual, StatusOK)
+		So(result(r, "log.levels").Status, ShouldEqual, StatusOK)
+	})
+
+	Convey("Diagnose should check configured log sinks are reachable", t, func() {
+		defer os.Unsetenv("GELF_NETWORK")
+		defer os.Unsetenv("GELF_ADDR")
+		defer os.Unsetenv("FLUENT_ADDR")
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		addr := l.Addr().String()
+		os.Setenv("GELF_NETWORK", "tcp")
+		os.Setenv("GELF_ADDR", addr)
+		os.Setenv("FLUENT_ADDR", addr)
+
+		r := Diagnose(Config{})
+		So(r.OK, ShouldBeTrue)
+		So(result(r, "log.sink.gelf").Status, ShouldEqual, StatusOK)
+		So(result(r, "log.sink.fluent").Status, ShouldEqual, StatusOK)
+		So(result(r, "log.sink.syslog").Check, ShouldBeEmpty)
+
+		l.Close()
+		r = Diagnose(Config{})
+		So(r.OK, ShouldBeFalse)
+		So(result(r, "log.sink.gelf").Status, ShouldEqual, StatusFail)
+		So(result(r, "log.sink.fluent").Status, ShouldEqual, StatusFail)
+	})
+
+	Convey("Diagnose should check the health endpoint", t, func() {
+		status := 200
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(status)
+		}))
+		defer server.Close()
+
+		r := Diagnose(Config{HealthURL: server.URL})
+		So(r.OK, ShouldBeTrue)
+		So(result(r, "health").Status, ShouldEqual, StatusOK)
+
+		status = 503
+		r = Diagnose(Config{HealthURL: server.URL})
+		So(r.OK, ShouldBeFalse)
+		So(result(r, "health").Status, ShouldEqual, StatusFail)
+	})
+
+	Convey("Diagnose should check dependencies are reachable", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		addr := l.Addr().String()
+
+		r := Diagnose(Config{Dependencies: map[string][]string{"kafka": {addr}}})
+		So(r.OK, ShouldBeTrue)
+		So(result(r, "dependency.kafka").Status, ShouldEqual, StatusOK)
+
+		l.Close()
+		r = Diagnose(Config{Dependencies: map[string][]string{"mongo": {addr}}})
+		So(r.OK, ShouldBeFalse)
+		So(result(r, "dependency.mongo").Status, ShouldEqual, StatusFail)
+	})
+}
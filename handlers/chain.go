@@ -0,0 +1,38 @@
+// Package handlers composes the middleware in its subpackages
+package handlers
+
+import "net/http"
+
+// Middleware wraps a http.Handler
+type Middleware func(http.Handler) http.Handler
+
+// Compose returns middleware which applies mw in the order given, so the
+// first is outermost and sees each request first
+func Compose(mw ...Middleware) Middleware {
+	return func(h http.Handler) http.Handler {
+		for i := len(mw) - 1; i >= 0; i-- {
+			h = mw[i](h)
+		}
+		return h
+	}
+}
+
+// Chain is an ordered list of middleware, e.g.
+//
+//	handlers.NewChain(requestID.Handler(16), log.Handler, timeout.Handler(10*time.Second)).Then(router)
+type Chain []Middleware
+
+// NewChain returns a chain of middleware
+func NewChain(mw ...Middleware) Chain {
+	return append(Chain(nil), mw...)
+}
+
+// Use returns a new chain with mw appended, inside the existing middleware
+func (c Chain) Use(mw ...Middleware) Chain {
+	return append(append(Chain(nil), c...), mw...)
+}
+
+// Then wraps a handler with the chain
+func (c Chain) Then(h http.Handler) http.Handler {
+	return Compose(c...)(h)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/handlers/requestID"
+	"github.com/ONSdigital/go-ns/handlers/timeout"
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func record(name string, calls *[]string) Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			*calls = append(*calls, name)
+			h.ServeHTTP(w, req)
+		})
+	}
+}
+
+func TestCompose(t *testing.T) {
+	Convey("Compose should apply middleware in the order given", t, func() {
+		var calls []string
+		h := Compose(record("a", &calls), record("b", &calls), record("c", &calls))(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			calls = append(calls, "handler")
+		}))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		So(calls, ShouldResemble, []string{"a", "b", "c", "handler"})
+	})
+
+	Convey("Compose with no middleware should return the handler", t, func() {
+		called := false
+		h := Compose()(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+		}))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		So(called, ShouldBeTrue)
+	})
+}
+
+func TestChain(t *testing.T) {
+	Convey("Use should not modify the original chain", t, func() {
+		var calls []string
+		base := NewChain(record("a", &calls))
+		extended := base.Use(record("b", &calls))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		base.Then(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+		So(calls, ShouldResemble, []string{"a"})
+
+		calls = nil
+		extended.Then(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+		So(calls, ShouldResemble, []string{"a", "b"})
+	})
+
+	Convey("Chain should compose the go-ns middleware", t, func() {
+		defer log.SetEvent(nil)
+
+		var eventContext string
+		log.SetEvent(func(name string, context string, data log.Data) {
+			eventContext = context
+		})
+
+		h := NewChain(requestID.Handler(16), log.Handler, timeout.Handler(time.Second)).Then(http.NotFoundHandler())
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		So(w.Code, ShouldEqual, 404)
+		So(eventContext, ShouldHaveLength, 16)
+	})
+}
@@ -0,0 +1,28 @@
+// Package chiroute adds the matched chi route pattern to request events
+package chiroute
+
+import (
+	"net/http"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/go-chi/chi/v5"
+)
+
+// Handler records the matched route pattern, e.g. /users/{id}, with
+// log.SetRoute, so it's in the "route" field of the request event and
+// returned by log.Route, and the request event uses the level and sampling
+// set for the route with log.SetRouteLevels and log.SetRouteSampling. It
+// should be added to the router with Use, inside log.Handler.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h.ServeHTTP(w, req)
+
+		// chi builds the pattern as sub-routers match, so it's only
+		// complete once the request has been handled
+		if rc := chi.RouteContext(req.Context()); rc != nil {
+			if pattern := rc.RoutePattern(); len(pattern) > 0 {
+				log.SetRoute(req, pattern)
+			}
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package chiroute
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/go-chi/chi/v5"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHandler(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var eventData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		eventData = data
+	})
+
+	r := chi.NewRouter()
+	r.Use(Handler)
+	r.Get("/users/{id}", func(w http.ResponseWriter, req *http.Request) {})
+	r.Route("/datasets", func(r chi.Router) {
+		r.Get("/{id}/editions", func(w http.ResponseWriter, req *http.Request) {})
+	})
+
+	var route string
+	serve := func(path string) {
+		eventData = nil
+		req, _ := http.NewRequest("GET", path, nil)
+		log.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			r.ServeHTTP(w, req)
+			route = log.Route(req)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	Convey("Handler should add the route pattern to the request event", t, func() {
+		serve("/users/123")
+		So(eventData["path"], ShouldEqual, "/users/123")
+		So(eventData["route"], ShouldEqual, "/users/{id}")
+		So(route, ShouldEqual, "/users/{id}")
+	})
+
+	Convey("Handler should include sub-router patterns", t, func() {
+		serve("/datasets/cpih/editions")
+		So(eventData["route"], ShouldEqual, "/datasets/{id}/editions")
+	})
+
+	Convey("Handler should not add a route for unmatched requests", t, func() {
+		serve("/unknown")
+		So(eventData, ShouldNotContainKey, "route")
+		So(route, ShouldBeEmpty)
+	})
+}
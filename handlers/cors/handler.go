@@ -0,0 +1,189 @@
+// Package cors provides middleware which implements cross-origin resource
+// sharing (CORS), including preflight request handling
+package cors
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Options configures CORS for the routes a handler is added to
+type Options struct {
+	// AllowedOrigins lists the origins which may make cross-origin requests,
+	// e.g. https://www.ons.gov.uk. An origin may contain a single * wildcard,
+	// e.g. https://*.ons.gov.uk, and "*" allows any origin. Defaults to "*".
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, HEAD and POST
+	AllowedMethods []string
+	// AllowedHeaders lists the request headers which may be sent, "*"
+	// allows any header
+	AllowedHeaders []string
+	// ExposedHeaders lists the response headers which may be read by clients
+	ExposedHeaders []string
+	// AllowCredentials allows cookies and authorization headers to be sent.
+	// The request origin is returned rather than "*" when it's set, and
+	// AllowedOrigins must list each origin without wildcards.
+	AllowCredentials bool
+	// MaxAge is how long the result of a preflight request can be cached
+	MaxAge time.Duration
+}
+
+// Handler allows cross-origin GET, HEAD and POST requests from any origin
+func Handler(h http.Handler) http.Handler {
+	return HandlerWithOptions(Options{})(h)
+}
+
+// HandlerWithOptions adds CORS headers to responses for allowed origins and
+// responds to preflight requests without calling the wrapped handler. It
+// can be added to individual routes to configure them differently. It
+// panics if credentials are allowed from wildcard origins, since that would
+// let any matching site make authenticated requests.
+func HandlerWithOptions(opts Options) func(http.Handler) http.Handler {
+	if opts.AllowedOrigins == nil {
+		opts.AllowedOrigins = []string{"*"}
+	}
+	if opts.AllowCredentials {
+		for _, o := range opts.AllowedOrigins {
+			if strings.Contains(o, "*") {
+				panic("cors: AllowCredentials can't be used with wildcard origin " + strconv.Quote(o))
+			}
+		}
+	}
+	if opts.AllowedMethods == nil {
+		opts.AllowedMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	methods := make([]string, len(opts.AllowedMethods))
+	for i, m := range opts.AllowedMethods {
+		methods[i] = strings.ToUpper(m)
+	}
+	opts.AllowedMethods = methods
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			// the CORS headers depend on the origin, so caches mustn't
+			// reuse responses for requests without one or from others
+			w.Header().Add("Vary", "Origin")
+
+			origin := req.Header.Get("Origin")
+			if len(origin) == 0 {
+				h.ServeHTTP(w, req)
+				return
+			}
+
+			if req.Method == http.MethodOptions && len(req.Header.Get("Access-Control-Request-Method")) > 0 {
+				preflight(opts, w, req, origin)
+				return
+			}
+
+			if opts.allowOrigin(origin) {
+				opts.setOrigin(w, origin)
+				if len(opts.ExposedHeaders) > 0 {
+					w.Header().Set("Access-Control-Expose-Headers", strings.Join(opts.ExposedHeaders, ", "))
+				}
+			}
+
+			h.ServeHTTP(w, req)
+		})
+	}
+}
+
+// preflight responds to a preflight request. CORS headers are only set if
+// the origin, method and headers are all allowed, otherwise the browser
+// will block the request.
+func preflight(opts Options, w http.ResponseWriter, req *http.Request, origin string) {
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+
+	method := strings.ToUpper(req.Header.Get("Access-Control-Request-Method"))
+	headers := requestHeaders(req.Header.Get("Access-Control-Request-Headers"))
+
+	if opts.allowOrigin(origin) && opts.allowMethod(method) && opts.allowHeaders(headers) {
+		opts.setOrigin(w, origin)
+		w.Header().Set("Access-Control-Allow-Methods", method)
+		if len(headers) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+		}
+		if opts.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge.Seconds())))
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (opts Options) setOrigin(w http.ResponseWriter, origin string) {
+	if opts.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		return
+	}
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			return
+		}
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
+
+func (opts Options) allowOrigin(origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range opts.AllowedOrigins {
+		if matchOrigin(strings.ToLower(o), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin matches an origin against a pattern containing at most one
+// * wildcard, which must match at least one character
+func matchOrigin(pattern, origin string) bool {
+	i := strings.IndexByte(pattern, '*')
+	if i < 0 {
+		return pattern == origin
+	}
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	return len(origin) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+func (opts Options) allowMethod(method string) bool {
+	for _, m := range opts.AllowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (opts Options) allowHeaders(headers []string) bool {
+	for _, h := range headers {
+		if !opts.allowHeader(h) {
+			return false
+		}
+	}
+	return true
+}
+
+func (opts Options) allowHeader(header string) bool {
+	for _, h := range opts.AllowedHeaders {
+		if h == "*" || http.CanonicalHeaderKey(h) == header {
+			return true
+		}
+	}
+	return false
+}
+
+// requestHeaders parses an Access-Control-Request-Headers value
+func requestHeaders(value string) []string {
+	var headers []string
+	for _, h := range strings.Split(value, ",") {
+		if h = strings.TrimSpace(h); len(h) > 0 {
+			headers = append(headers, http.CanonicalHeaderKey(h))
+		}
+	}
+	return headers
+}
@@ -0,0 +1,120 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHandler(t *testing.T) {
+	var called bool
+	ok := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		called = true
+	})
+
+	serve := func(h http.Handler, method, origin string, headers map[string]string) *httptest.ResponseRecorder {
+		called = false
+		req, _ := http.NewRequest(method, "/", nil)
+		if len(origin) > 0 {
+			req.Header.Set("Origin", origin)
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("Handler should allow any origin by default", t, func() {
+		w := serve(Handler(ok), "GET", "https://example.com", nil)
+		So(called, ShouldBeTrue)
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "*")
+		So(w.Header().Get("Vary"), ShouldEqual, "Origin")
+	})
+
+	Convey("Handler should ignore requests without an Origin", t, func() {
+		w := serve(Handler(ok), "GET", "", nil)
+		So(called, ShouldBeTrue)
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+		So(w.Header().Get("Vary"), ShouldEqual, "Origin")
+	})
+
+	Convey("Handler should only allow configured origins", t, func() {
+		h := HandlerWithOptions(Options{
+			AllowedOrigins: []string{"https://www.ons.gov.uk", "https://*.example.com"},
+			ExposedHeaders: []string{"ETag"},
+		})(ok)
+
+		w := serve(h, "GET", "https://www.ons.gov.uk", nil)
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://www.ons.gov.uk")
+		So(w.Header().Get("Access-Control-Expose-Headers"), ShouldEqual, "ETag")
+
+		w = serve(h, "GET", "https://api.example.com", nil)
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://api.example.com")
+
+		for _, origin := range []string{"https://example.com", "https://evil.com", "http://api.example.com"} {
+			w = serve(h, "GET", origin, nil)
+			So(called, ShouldBeTrue)
+			So(w.Header().Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+			So(w.Header().Get("Vary"), ShouldEqual, "Origin")
+		}
+	})
+
+	Convey("Handler should return the request origin when credentials are allowed", t, func() {
+		h := HandlerWithOptions(Options{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true})(ok)
+		w := serve(h, "GET", "https://example.com", nil)
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "https://example.com")
+		So(w.Header().Get("Access-Control-Allow-Credentials"), ShouldEqual, "true")
+	})
+
+	Convey("HandlerWithOptions should refuse credentials for wildcard origins", t, func() {
+		So(func() { HandlerWithOptions(Options{AllowCredentials: true}) }, ShouldPanic)
+		So(func() {
+			HandlerWithOptions(Options{AllowedOrigins: []string{"https://*.example.com"}, AllowCredentials: true})
+		}, ShouldPanic)
+	})
+
+	Convey("Handler should respond to allowed preflight requests", t, func() {
+		h := HandlerWithOptions(Options{
+			AllowedMethods: []string{"get", "put"},
+			AllowedHeaders: []string{"content-type", "X-Florence-Token"},
+			MaxAge:         time.Hour,
+		})(ok)
+
+		w := serve(h, "OPTIONS", "https://example.com", map[string]string{
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "Content-Type, x-florence-token",
+		})
+		So(called, ShouldBeFalse)
+		So(w.Code, ShouldEqual, http.StatusNoContent)
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldEqual, "*")
+		So(w.Header().Get("Access-Control-Allow-Methods"), ShouldEqual, "PUT")
+		So(w.Header().Get("Access-Control-Allow-Headers"), ShouldEqual, "Content-Type, X-Florence-Token")
+		So(w.Header().Get("Access-Control-Max-Age"), ShouldEqual, "3600")
+	})
+
+	Convey("Handler should not allow preflight requests for other methods or headers", t, func() {
+		h := HandlerWithOptions(Options{})(ok)
+
+		w := serve(h, "OPTIONS", "https://example.com", map[string]string{
+			"Access-Control-Request-Method": "DELETE",
+		})
+		So(w.Code, ShouldEqual, http.StatusNoContent)
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+
+		w = serve(h, "OPTIONS", "https://example.com", map[string]string{
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "X-Custom",
+		})
+		So(w.Header().Get("Access-Control-Allow-Origin"), ShouldBeEmpty)
+	})
+
+	Convey("Handler should pass other OPTIONS requests to the wrapped handler", t, func() {
+		serve(Handler(ok), "OPTIONS", "https://example.com", nil)
+		So(called, ShouldBeTrue)
+	})
+}
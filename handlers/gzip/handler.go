@@ -0,0 +1,226 @@
+// Package gzip provides middleware which compresses responses for clients
+// which accept gzip encoding
+package gzip
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Options configures response compression
+type Options struct {
+	// Level is the gzip compression level, defaults to gzip.DefaultCompression
+	Level int
+	// MinSize is the minimum response size in bytes which is compressed,
+	// defaults to 1024. Smaller responses aren't worth the overhead.
+	MinSize int
+}
+
+// Handler compresses responses with the default options
+func Handler(h http.Handler) http.Handler {
+	return HandlerWithOptions(Options{})(h)
+}
+
+// HandlerWithOptions compresses responses of at least MinSize bytes when the
+// client sends Accept-Encoding: gzip. Responses which already have a
+// Content-Encoding and event streams aren't compressed. It panics if the
+// compression level is invalid.
+//
+// It should be added inside log.Handler, so the bytes_sent field of request
+// events counts the compressed bytes written to the client.
+func HandlerWithOptions(opts Options) func(http.Handler) http.Handler {
+	if opts.Level == 0 {
+		opts.Level = gzip.DefaultCompression
+	}
+	if opts.MinSize == 0 {
+		opts.MinSize = 1024
+	}
+	if _, err := gzip.NewWriterLevel(nil, opts.Level); err != nil {
+		panic(err)
+	}
+
+	pool := &sync.Pool{New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(nil, opts.Level)
+		return gz
+	}}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			if req.Method == http.MethodHead || !acceptsGzip(req.Header.Get("Accept-Encoding")) {
+				h.ServeHTTP(w, req)
+				return
+			}
+
+			gw := &gzipWriter{ResponseWriter: w, minSize: opts.MinSize, pool: pool}
+			defer gw.close()
+			h.ServeHTTP(gw, req)
+		})
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header includes gzip with
+// a non-zero quality
+func acceptsGzip(header string) bool {
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(item, ";")
+		coding := strings.ToLower(strings.TrimSpace(parts[0]))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := 1.0
+		for _, p := range parts[1:] {
+			if p = strings.TrimSpace(p); strings.HasPrefix(p, "q=") {
+				q, _ = strconv.ParseFloat(p[2:], 64)
+			}
+		}
+		if q > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipWriter buffers the start of a response until it's known whether it
+// should be compressed
+type gzipWriter struct {
+	http.ResponseWriter
+	minSize int
+	pool    *sync.Pool
+
+	status  int
+	buf     []byte
+	started bool
+	gz      *gzip.Writer
+}
+
+func (g *gzipWriter) WriteHeader(status int) {
+	// informational responses, e.g. 103 Early Hints, precede the final
+	// status, so they're passed through rather than recorded
+	if status >= 100 && status < 200 && status != http.StatusSwitchingProtocols {
+		if !g.started {
+			g.ResponseWriter.WriteHeader(status)
+		}
+		return
+	}
+	if g.started || g.status != 0 {
+		return
+	}
+	g.status = status
+}
+
+func (g *gzipWriter) Write(b []byte) (int, error) {
+	if g.started {
+		if g.gz != nil {
+			return g.gz.Write(b)
+		}
+		return g.ResponseWriter.Write(b)
+	}
+
+	g.buf = append(g.buf, b...)
+	if len(g.buf) >= g.minSize || !g.compressible() {
+		if err := g.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// compressible reports whether the response can be compressed
+func (g *gzipWriter) compressible() bool {
+	switch {
+	case g.status == http.StatusNoContent, g.status == http.StatusNotModified,
+		g.status >= 100 && g.status < 200:
+		return false
+	case len(g.Header().Get("Content-Encoding")) > 0:
+		return false
+	case strings.HasPrefix(g.Header().Get("Content-Type"), "text/event-stream"):
+		return false
+	}
+	return true
+}
+
+// start writes the headers and any buffered data, compressing the response
+// if it's compressible and at least minSize bytes have been written
+func (g *gzipWriter) start() error {
+	g.started = true
+
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+
+	h := g.Header()
+	if len(g.buf) >= g.minSize && g.compressible() {
+		// sniff the content type from the uncompressed data, since net/http
+		// would otherwise detect the compressed data
+		if len(h.Get("Content-Type")) == 0 {
+			h.Set("Content-Type", http.DetectContentType(g.buf))
+		}
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+
+		g.gz = g.pool.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+
+	g.ResponseWriter.WriteHeader(g.status)
+
+	buf := g.buf
+	g.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(buf)
+	} else {
+		_, err = g.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// close writes any buffered response and finishes the gzip stream
+func (g *gzipWriter) close() {
+	if !g.started && (g.status != 0 || len(g.buf) > 0) {
+		g.start()
+	}
+	if g.gz != nil {
+		g.gz.Close()
+		g.pool.Put(g.gz)
+		g.gz = nil
+	}
+}
+
+// Flush writes the response so far, compressing it if possible regardless
+// of its size, since the handler is streaming
+func (g *gzipWriter) Flush() {
+	if !g.started {
+		if g.compressible() && g.minSize > len(g.buf) {
+			g.minSize = len(g.buf)
+		}
+		g.start()
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the underlying ResponseWriter, e.g. for WebSocket upgrades
+func (g *gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := g.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("gzip: ResponseWriter does not implement http.Hijacker")
+	}
+	g.started = true
+	return h.Hijack()
+}
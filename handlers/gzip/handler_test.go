@@ -0,0 +1,165 @@
+package gzip
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var body = strings.Repeat("hello world ", 200)
+
+func serve(h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/", nil)
+	if len(acceptEncoding) > 0 {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func gunzip(b []byte) string {
+	r, err := gzip.NewReader(strings.NewReader(string(b)))
+	So(err, ShouldBeNil)
+	data, err := ioutil.ReadAll(r)
+	So(err, ShouldBeNil)
+	return string(data)
+}
+
+func TestHandler(t *testing.T) {
+	write := func(s string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(s))
+		})
+	}
+
+	Convey("Handler should compress responses for clients accepting gzip", t, func() {
+		w := serve(Handler(write(body)), "deflate, gzip")
+		So(w.Code, ShouldEqual, 200)
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(w.Header().Get("Vary"), ShouldEqual, "Accept-Encoding")
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/plain; charset=utf-8")
+		So(w.Body.Len(), ShouldBeLessThan, len(body))
+		So(gunzip(w.Body.Bytes()), ShouldEqual, body)
+	})
+
+	Convey("Handler should not compress for clients not accepting gzip", t, func() {
+		for _, accept := range []string{"", "deflate", "gzip;q=0"} {
+			w := serve(Handler(write(body)), accept)
+			So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+			So(w.Body.String(), ShouldEqual, body)
+		}
+	})
+
+	Convey("Handler should not compress responses smaller than the minimum size", t, func() {
+		w := serve(Handler(write("small")), "gzip")
+		So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+		So(w.Body.String(), ShouldEqual, "small")
+
+		w = serve(HandlerWithOptions(Options{MinSize: 4})(write("small")), "gzip")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(gunzip(w.Body.Bytes()), ShouldEqual, "small")
+	})
+
+	Convey("Handler should preserve the status code", t, func() {
+		w := serve(Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(body))
+		})), "gzip")
+		So(w.Code, ShouldEqual, 201)
+		So(gunzip(w.Body.Bytes()), ShouldEqual, body)
+
+		w = serve(Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})), "gzip")
+		So(w.Code, ShouldEqual, 204)
+		So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+	})
+
+	Convey("Handler should pass informational responses through", t, func() {
+		server := httptest.NewServer(Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Link", "</style.css>; rel=preload; as=style")
+			w.WriteHeader(http.StatusEarlyHints)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(body))
+		})))
+		defer server.Close()
+
+		var informational []int
+		trace := &httptrace.ClientTrace{
+			Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+				informational = append(informational, code)
+				return nil
+			},
+		}
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+		res, err := http.DefaultClient.Do(req)
+		So(err, ShouldBeNil)
+		defer res.Body.Close()
+
+		So(informational, ShouldResemble, []int{http.StatusEarlyHints})
+		So(res.StatusCode, ShouldEqual, http.StatusCreated)
+		b, err := ioutil.ReadAll(res.Body)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, body)
+	})
+
+	Convey("Handler should not compress already encoded responses or event streams", t, func() {
+		w := serve(Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(body))
+		})), "gzip")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "br")
+		So(w.Body.String(), ShouldEqual, body)
+
+		w = serve(Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte(body))
+		})), "gzip")
+		So(w.Header().Get("Content-Encoding"), ShouldBeEmpty)
+		So(w.Body.String(), ShouldEqual, body)
+	})
+
+	Convey("Flush should write the compressed response so far", t, func() {
+		var flushedLen int
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("part"))
+			w.(http.Flusher).Flush()
+			flushedLen = rec.Body.Len()
+		})).ServeHTTP(rec, req)
+
+		So(rec.Flushed, ShouldBeTrue)
+		So(flushedLen, ShouldBeGreaterThan, 0)
+		So(rec.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(gunzip(rec.Body.Bytes()), ShouldEqual, "part")
+	})
+
+	Convey("Request events should count the compressed bytes sent", t, func() {
+		defer log.SetEvent(nil)
+
+		var eventData log.Data
+		log.SetEvent(func(name string, context string, data log.Data) {
+			eventData = data
+		})
+
+		w := serve(log.Handler(Handler(write(body))), "gzip")
+		So(w.Header().Get("Content-Encoding"), ShouldEqual, "gzip")
+		So(eventData["bytes_sent"], ShouldEqual, int64(w.Body.Len()))
+	})
+
+	Convey("HandlerWithOptions should panic on an invalid level", t, func() {
+		So(func() { HandlerWithOptions(Options{Level: 42}) }, ShouldPanic)
+	})
+}
@@ -0,0 +1,271 @@
+// Package jwt provides middleware which validates RS256 and ES256 bearer
+// tokens against the keys published at a JWKS endpoint.
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Errors returned when validating a token
+var (
+	ErrMissingToken     = errors.New("jwt: missing bearer token")
+	ErrMalformedToken   = errors.New("jwt: malformed token")
+	ErrUnsupportedAlg   = errors.New("jwt: unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	ErrExpired          = errors.New("jwt: token has expired")
+	ErrMissingExpiry    = errors.New("jwt: token has no expiry")
+	ErrNotYetValid      = errors.New("jwt: token is not yet valid")
+	ErrInvalidIssuer    = errors.New("jwt: invalid issuer")
+	ErrInvalidAudience  = errors.New("jwt: invalid audience")
+)
+
+// keysError is returned when the signing keys can't be fetched, so tokens
+// can't be validated
+type keysError struct {
+	err error
+}
+
+func (e *keysError) Error() string {
+	return "jwt: signing keys unavailable: " + e.err.Error()
+}
+
+func (e *keysError) Unwrap() error {
+	return e.err
+}
+
+// Config configures the JWT middleware
+type Config struct {
+	// JWKSURL is the endpoint publishing the signing keys
+	JWKSURL string
+	// Issuer, if set, must match the iss claim
+	Issuer string
+	// Audience, if set, must be included in the aud claim
+	Audience string
+	// CacheTTL is how long keys are cached for, defaulting to an hour
+	CacheTTL time.Duration
+	// Leeway allows for clock skew when checking exp and nbf
+	Leeway time.Duration
+	// LogClaims lists the claims added to the request event, in addition to
+	// the subject which is always logged as the caller
+	LogClaims []string
+	// HTTPClient is used to fetch the JWKS, defaulting to a client with a
+	// 10 second timeout
+	HTTPClient *http.Client
+}
+
+// Claims are the verified claims from a token
+type Claims map[string]interface{}
+
+// Subject returns the sub claim
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+type claimsKey struct{}
+
+// FromContext returns the verified claims from a request context
+func FromContext(ctx context.Context) (Claims, bool) {
+	c, ok := ctx.Value(claimsKey{}).(Claims)
+	return c, ok
+}
+
+// Handler returns middleware which rejects requests without a valid bearer
+// token, and otherwise adds the verified claims to the request context. If
+// the JWKS can't be fetched, requests are answered with a 503 and an error
+// is logged.
+func Handler(cfg Config) func(http.Handler) http.Handler {
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = time.Hour
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	v := &validator{cfg: cfg, keys: newKeySet(cfg.JWKSURL, cfg.CacheTTL, cfg.HTTPClient)}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			claims, err := v.validate(bearerToken(req))
+			var keysErr *keysError
+			if errors.As(err, &keysErr) {
+				// the token can't be checked, which isn't the client's fault
+				log.ErrorR(req, err, nil)
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if err != nil {
+				// invalid tokens are a client problem, not a service error
+				log.WarnR(req, "rejected bearer token", log.Data{"error": err.Error()})
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			data := log.Data{"caller": claims.Subject()}
+			for _, name := range cfg.LogClaims {
+				if c, ok := claims[name]; ok {
+					data["claim_"+name] = c
+				}
+			}
+			log.AddRequestData(req, data)
+
+			ctx := context.WithValue(req.Context(), claimsKey{}, claims)
+			ctx = log.WithCaller(ctx, claims.Subject())
+			h.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+func bearerToken(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+type validator struct {
+	cfg  Config
+	keys *keySet
+}
+
+func (v *validator) validate(token string) (Claims, error) {
+	if len(token) == 0 {
+		return nil, ErrMissingToken
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	key, err := v.keys.key(header.Kid)
+	if err == ErrUnknownKey {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &keysError{err}
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verify(header.Alg, key, digest[:], sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrMalformedToken
+	}
+
+	return claims, v.checkClaims(claims)
+}
+
+func verify(alg string, key crypto.PublicKey, digest, sig []byte) error {
+	switch alg {
+	case "RS256":
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrInvalidSignature
+		}
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case "ES256":
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return ErrUnsupportedAlg
+}
+
+func (v *validator) checkClaims(c Claims) error {
+	now := time.Now()
+
+	exp, ok := c["exp"].(float64)
+	if !ok {
+		return ErrMissingExpiry
+	}
+	if now.After(unix(exp).Add(v.cfg.Leeway)) {
+		return ErrExpired
+	}
+	if nbf, ok := c["nbf"].(float64); ok && now.Add(v.cfg.Leeway).Before(unix(nbf)) {
+		return ErrNotYetValid
+	}
+
+	if len(v.cfg.Issuer) > 0 {
+		if iss, _ := c["iss"].(string); iss != v.cfg.Issuer {
+			return ErrInvalidIssuer
+		}
+	}
+
+	if len(v.cfg.Audience) > 0 && !hasAudience(c["aud"], v.cfg.Audience) {
+		return ErrInvalidAudience
+	}
+
+	return nil
+}
+
+func hasAudience(aud interface{}, audience string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == audience
+	case []interface{}:
+		for _, v := range a {
+			if s, ok := v.(string); ok && s == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func unix(f float64) time.Time {
+	return time.Unix(int64(f), 0)
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("jwt: %s", err)
+	}
+	return nil
+}
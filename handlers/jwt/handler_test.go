@@ -0,0 +1,184 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func encodeSegment(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims Claims) string {
+	signed := encodeSegment(map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func signES256(key *ecdsa.PrivateKey, kid string, claims Claims) string {
+	signed := encodeSegment(map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+type jwksServer struct {
+	*httptest.Server
+	keys     []jwk
+	requests int
+}
+
+func newJWKSServer() *jwksServer {
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	return s
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jwk {
+	return jwk{Kid: kid, Kty: "RSA", N: encodeInt(key.N), E: encodeInt(big.NewInt(int64(key.E)))}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jwk {
+	return jwk{Kid: kid, Kty: "EC", Crv: "P-256", X: encodeInt(key.X), Y: encodeInt(key.Y)}
+}
+
+func TestHandler(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var events []string
+	var requestData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		events = append(events, name)
+		if name == "request" {
+			requestData = data
+		}
+	})
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	server := newJWKSServer()
+	defer server.Close()
+	server.keys = []jwk{rsaJWK("rsa", rsaKey), ecJWK("ec", ecKey)}
+
+	var claims Claims
+	h := Handler(Config{
+		JWKSURL:   server.URL,
+		Issuer:    "https://auth.ons.gov.uk",
+		Audience:  "api",
+		LogClaims: []string{"scope"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		claims, _ = FromContext(req.Context())
+	}))
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		claims = nil
+		events = nil
+		req, _ := http.NewRequest("GET", "/", nil)
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		log.Handler(h).ServeHTTP(w, req)
+		return w
+	}
+
+	valid := func() Claims {
+		return Claims{
+			"sub":   "user@ons.gov.uk",
+			"iss":   "https://auth.ons.gov.uk",
+			"aud":   []string{"api", "other"},
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"scope": "read",
+		}
+	}
+
+	Convey("Valid RS256 and ES256 tokens should be accepted", t, func() {
+		w := serve(signRS256(rsaKey, "rsa", valid()))
+		So(w.Code, ShouldEqual, 200)
+		So(claims.Subject(), ShouldEqual, "user@ons.gov.uk")
+		So(requestData["caller"], ShouldEqual, "user@ons.gov.uk")
+		So(requestData["claim_scope"], ShouldEqual, "read")
+
+		w = serve(signES256(ecKey, "ec", valid()))
+		So(w.Code, ShouldEqual, 200)
+		So(claims.Subject(), ShouldEqual, "user@ons.gov.uk")
+		So(server.requests, ShouldEqual, 1)
+	})
+
+	Convey("Invalid tokens should be rejected", t, func() {
+		w := serve("")
+		So(w.Code, ShouldEqual, 401)
+		So(w.Header().Get("WWW-Authenticate"), ShouldEqual, `Bearer error="invalid_token"`)
+		So(events, ShouldResemble, []string{"warn", "request"})
+		So(claims, ShouldBeNil)
+
+		So(serve("not.a.token").Code, ShouldEqual, 401)
+
+		expired := valid()
+		expired["exp"] = time.Now().Add(-time.Minute).Unix()
+		So(serve(signRS256(rsaKey, "rsa", expired)).Code, ShouldEqual, 401)
+
+		noExpiry := valid()
+		delete(noExpiry, "exp")
+		So(serve(signRS256(rsaKey, "rsa", noExpiry)).Code, ShouldEqual, 401)
+
+		wrongAudience := valid()
+		wrongAudience["aud"] = "other"
+		So(serve(signRS256(rsaKey, "rsa", wrongAudience)).Code, ShouldEqual, 401)
+
+		wrongIssuer := valid()
+		wrongIssuer["iss"] = "https://example.com"
+		So(serve(signRS256(rsaKey, "rsa", wrongIssuer)).Code, ShouldEqual, 401)
+
+		otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		So(serve(signRS256(otherKey, "rsa", valid())).Code, ShouldEqual, 401)
+	})
+
+	Convey("Requests should be answered with a 503 if the JWKS can't be fetched", t, func() {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer failing.Close()
+
+		h = Handler(Config{JWKSURL: failing.URL})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		w := serve(signRS256(rsaKey, "rsa", valid()))
+		So(w.Code, ShouldEqual, 503)
+		So(w.Header().Get("WWW-Authenticate"), ShouldBeEmpty)
+		So(events, ShouldResemble, []string{"error", "request"})
+	})
+}
+
+func TestValidateAlg(t *testing.T) {
+	Convey("verify should reject a key of the wrong type", t, func() {
+		ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		So(verify("RS256", &ecKey.PublicKey, nil, nil), ShouldEqual, ErrInvalidSignature)
+		So(verify("HS256", &ecKey.PublicKey, nil, nil), ShouldEqual, ErrUnsupportedAlg)
+	})
+}
@@ -0,0 +1,185 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is returned when no key in the JWKS matches a token's key ID
+var ErrUnknownKey = errors.New("jwt: unknown signing key")
+
+// minRefreshInterval limits how often an unknown key ID or a failed fetch
+// triggers a refetch
+const minRefreshInterval = 30 * time.Second
+
+// maxJWKSSize limits the size of a JWKS response which is read
+const maxJWKSSize = 1 << 20
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet caches the public keys from a JWKS endpoint
+type keySet struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+
+	// fetchMutex serializes fetches, so concurrent requests for an unknown
+	// key make a single request without blocking requests for known keys
+	fetchMutex sync.Mutex
+
+	mutex   sync.RWMutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+	err     error
+}
+
+func newKeySet(url string, ttl time.Duration, client *http.Client) *keySet {
+	return &keySet{url: url, ttl: ttl, client: client}
+}
+
+// key returns the public key for a key ID, refetching the JWKS if the cache
+// has expired or the key ID is unknown (e.g. after key rotation)
+func (s *keySet) key(kid string) (crypto.PublicKey, error) {
+	keys, fetched, err := s.cached()
+	if time.Since(fetched) > s.ttl || (keys == nil && time.Since(fetched) >= minRefreshInterval) {
+		keys, fetched, err = s.refresh(fetched)
+	}
+	if keys == nil {
+		return nil, err
+	}
+
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+
+	if time.Since(fetched) < minRefreshInterval {
+		return nil, ErrUnknownKey
+	}
+	if keys, _, err = s.refresh(fetched); err != nil {
+		return nil, err
+	}
+	if k, ok := keys[kid]; ok {
+		return k, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (s *keySet) cached() (map[string]crypto.PublicKey, time.Time, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.keys, s.fetched, s.err
+}
+
+// refresh fetches the JWKS unless it's been fetched since seen by a
+// concurrent request. Failures are recorded as a fetch too, so the
+// endpoint isn't retried by every request while it's failing.
+func (s *keySet) refresh(seen time.Time) (map[string]crypto.PublicKey, time.Time, error) {
+	s.fetchMutex.Lock()
+	defer s.fetchMutex.Unlock()
+
+	if keys, fetched, err := s.cached(); !fetched.Equal(seen) {
+		return keys, fetched, err
+	}
+
+	keys, err := s.fetch()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.fetched = time.Now()
+	s.err = err
+	if err == nil {
+		s.keys = keys
+	}
+	return s.keys, s.fetched, err
+}
+
+func (s *keySet) fetch() (map[string]crypto.PublicKey, error) {
+	res, err := s.client.Get(s.url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwt: unexpected JWKS response status %d", res.StatusCode)
+	}
+
+	var body struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxJWKSSize)).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(body.Keys))
+	for _, k := range body.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("jwt: unsupported curve %q", k.Crv)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("jwt: unsupported key type %q", k.Kty)
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,91 @@
+package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKeySet(t *testing.T) {
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	server := newJWKSServer()
+	defer server.Close()
+	server.keys = []jwk{rsaJWK("one", key)}
+
+	Convey("Keys should be cached", t, func() {
+		s := newKeySet(server.URL, time.Hour, http.DefaultClient)
+		k, err := s.key("one")
+		So(err, ShouldBeNil)
+		So(k, ShouldNotBeNil)
+		_, err = s.key("one")
+		So(err, ShouldBeNil)
+		So(server.requests, ShouldEqual, 1)
+	})
+
+	Convey("An unknown key ID should refetch the JWKS after rotation", t, func() {
+		server.requests = 0
+		s := newKeySet(server.URL, time.Hour, http.DefaultClient)
+		_, err := s.key("one")
+		So(err, ShouldBeNil)
+
+		server.keys = append(server.keys, rsaJWK("two", key))
+
+		_, err = s.key("two")
+		So(err, ShouldEqual, ErrUnknownKey)
+		So(server.requests, ShouldEqual, 1)
+
+		s.fetched = time.Now().Add(-minRefreshInterval)
+		k, err := s.key("two")
+		So(err, ShouldBeNil)
+		So(k, ShouldNotBeNil)
+		So(server.requests, ShouldEqual, 2)
+	})
+
+	Convey("Keys with unsupported types or uses should be ignored", t, func() {
+		server.keys = []jwk{{Kid: "enc", Kty: "RSA", Use: "enc"}, {Kid: "oct", Kty: "oct"}}
+		s := newKeySet(server.URL, time.Hour, http.DefaultClient)
+		_, err := s.key("enc")
+		So(err, ShouldEqual, ErrUnknownKey)
+		_, err = s.key("oct")
+		So(err, ShouldEqual, ErrUnknownKey)
+	})
+	Convey("A failed fetch should not be retried until after a back off", t, func() {
+		var requests int
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer failing.Close()
+
+		s := newKeySet(failing.URL, time.Hour, http.DefaultClient)
+		_, err := s.key("one")
+		So(err, ShouldNotBeNil)
+		_, err = s.key("one")
+		So(err, ShouldNotBeNil)
+		So(requests, ShouldEqual, 1)
+
+		s.fetched = time.Now().Add(-minRefreshInterval)
+		_, err = s.key("one")
+		So(err, ShouldNotBeNil)
+		So(requests, ShouldEqual, 2)
+	})
+
+	Convey("Oversized JWKS responses should be rejected", t, func() {
+		large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"keys":[],"padding":"` + strings.Repeat("x", maxJWKSSize) + `"}`))
+		}))
+		defer large.Close()
+
+		s := newKeySet(large.URL, time.Hour, http.DefaultClient)
+		_, err := s.key("one")
+		So(err, ShouldNotBeNil)
+		So(err, ShouldNotEqual, ErrUnknownKey)
+	})
+}
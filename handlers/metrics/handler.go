@@ -0,0 +1,149 @@
+// Package metrics provides middleware which records Prometheus metrics for
+// HTTP requests, and a handler to serve them
+package metrics
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Config configures the metrics middleware
+type Config struct {
+	// Registerer defaults to prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+	// Buckets are the duration histogram buckets in seconds, defaults to
+	// prometheus.DefBuckets
+	Buckets []float64
+	// Path returns the path label for a request, defaults to the route set
+	// by log.SetRoute, or "other" if there isn't one. It's called after the
+	// request has been handled, so it can use a router's matched pattern.
+	// Raw paths shouldn't be used, as IDs and scanners give them unbounded
+	// cardinality.
+	Path func(req *http.Request) string
+	// Labels are extra labels, returning their values for a request, e.g.
+	// an operation ID. Like Path, they're called after the request has been
+	// handled and should have bounded cardinality.
+	Labels map[string]func(req *http.Request) string
+}
+
+// Metrics records request count, duration and in-flight requests
+type Metrics struct {
+	path     func(req *http.Request) string
+	labels   []func(req *http.Request) string
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	inFlight prometheus.Gauge
+}
+
+// New creates the request metrics and registers them
+func New(cfg Config) (*Metrics, error) {
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+	if cfg.Buckets == nil {
+		cfg.Buckets = prometheus.DefBuckets
+	}
+	if cfg.Path == nil {
+		cfg.Path = path
+	}
+
+	var names []string
+	for name := range cfg.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := &Metrics{path: cfg.Path}
+	for _, name := range names {
+		m.labels = append(m.labels, cfg.Labels[name])
+	}
+	labels := append([]string{"method", "path", "status"}, names...)
+
+	m.requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "Total number of HTTP requests handled.",
+	}, labels)
+	m.duration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Duration of HTTP requests handled in seconds.",
+		Buckets: cfg.Buckets,
+	}, labels)
+	m.inFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "http_server_requests_in_flight",
+		Help: "Number of HTTP requests currently being handled.",
+	})
+
+	for _, c := range []prometheus.Collector{m.requests, m.duration, m.inFlight} {
+		if err := cfg.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Handler is middleware which records metrics for each request. The status
+// is captured in the same way as log.Handler, and durations of traced
+// requests are recorded with a trace_id exemplar.
+func (m *Metrics) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		rc := log.CaptureResponse(w)
+		s := time.Now()
+		h.ServeHTTP(rc, req)
+		d := time.Since(s)
+
+		labels := []string{req.Method, m.path(req), strconv.Itoa(rc.Status())}
+		for _, label := range m.labels {
+			labels = append(labels, label(req))
+		}
+		m.requests.WithLabelValues(labels...).Inc()
+		observer := m.duration.WithLabelValues(labels...)
+		if traceID := log.TraceID(req); exemplarTraceID(traceID) {
+			observer.(prometheus.ExemplarObserver).ObserveWithExemplar(d.Seconds(), prometheus.Labels{"trace_id": traceID})
+			return
+		}
+		observer.Observe(d.Seconds())
+	})
+}
+
+// exemplarTraceID reports whether a trace ID can be used as an exemplar
+// label. Trace IDs come from request headers, and ObserveWithExemplar
+// panics on labels over 128 runes or which aren't valid UTF-8, so only
+// hex or decimal IDs of a sensible length are used.
+func exemplarTraceID(traceID string) bool {
+	if len(traceID) == 0 || len(traceID) > 64 {
+		return false
+	}
+	for _, c := range traceID {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func path(req *http.Request) string {
+	if route := log.Route(req); len(route) > 0 {
+		return route
+	}
+	return "other"
+}
+
+// MetricsHandler serves the metrics from a gatherer, e.g. on /metrics,
+// defaulting to prometheus.DefaultGatherer. Exemplars are only served to
+// scrapers which accept the OpenMetrics format.
+func MetricsHandler(g prometheus.Gatherer) http.Handler {
+	if g == nil {
+		g = prometheus.DefaultGatherer
+	}
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHandler(t *testing.T) {
+	Convey("Handler should record request count and duration by method, path and status", t, func() {
+		path := func(req *http.Request) string { return req.URL.Path }
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg, Path: path})
+		So(err, ShouldBeNil)
+
+		h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte("ok"))
+		}))
+
+		for _, path := range []string{"/", "/", "/missing"} {
+			req, _ := http.NewRequest("GET", path, nil)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "/", "200")), ShouldEqual, 2)
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "/missing", "404")), ShouldEqual, 1)
+		So(testutil.CollectAndCount(m.duration), ShouldEqual, 2)
+		So(testutil.ToFloat64(m.inFlight), ShouldEqual, 0)
+	})
+
+	Convey("Handler should track in-flight requests", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		var inFlight float64
+		h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			inFlight = testutil.ToFloat64(m.inFlight)
+		}))
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		So(inFlight, ShouldEqual, 1)
+		So(testutil.ToFloat64(m.inFlight), ShouldEqual, 0)
+	})
+
+	Convey("Handler should use the configured path label", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg, Path: func(req *http.Request) string { return "/users/{id}" }})
+		So(err, ShouldBeNil)
+
+		req, _ := http.NewRequest("POST", "/users/123", nil)
+		m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(testutil.ToFloat64(m.requests.WithLabelValues("POST", "/users/{id}", "201")), ShouldEqual, 1)
+	})
+
+	Convey("Handler should add the configured labels", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg, Labels: map[string]func(req *http.Request) string{
+			"operation_id": func(req *http.Request) string { return "getUser" },
+			"api":          func(req *http.Request) string { return "users" },
+		}})
+		So(err, ShouldBeNil)
+
+		req, _ := http.NewRequest("GET", "/users/123", nil)
+		m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(testutil.ToFloat64(m.requests.With(prometheus.Labels{
+			"method": "GET", "path": "other", "status": "200", "api": "users", "operation_id": "getUser",
+		})), ShouldEqual, 1)
+	})
+
+	Convey("Handler should label requests without a route as other", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		req, _ := http.NewRequest("GET", "/users/123", nil)
+		m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "other", "200")), ShouldEqual, 1)
+	})
+
+	Convey("Handler should use the route set by log.SetRoute", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		h := log.Handler(m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			log.SetRoute(req, "/users/{id}")
+		})))
+
+		log.SetEvent(func(name, context string, data log.Data) {})
+		defer log.SetEvent(nil)
+
+		req, _ := http.NewRequest("GET", "/users/123", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "/users/{id}", "200")), ShouldEqual, 1)
+	})
+
+	Convey("New should fail if the metrics are already registered", t, func() {
+		reg := prometheus.NewRegistry()
+		_, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+		_, err = New(Config{Registerer: reg})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("MetricsHandler should serve the gathered metrics", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+		req, _ = http.NewRequest("GET", "/metrics", nil)
+		w := httptest.NewRecorder()
+		MetricsHandler(reg).ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, 200)
+		So(strings.Contains(w.Body.String(), `http_server_requests_total{method="GET",path="other",status="200"} 1`), ShouldBeTrue)
+		So(strings.Contains(w.Body.String(), "http_server_requests_in_flight 0"), ShouldBeTrue)
+	})
+	Convey("MetricsHandler should serve trace exemplars as OpenMetrics", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+
+		req, _ = http.NewRequest("GET", "/metrics", nil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		w := httptest.NewRecorder()
+		MetricsHandler(reg).ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, 200)
+		So(w.Header().Get("Content-Type"), ShouldStartWith, "application/openmetrics-text")
+		So(w.Body.String(), ShouldContainSubstring, `# {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"}`)
+	})
+	Convey("Handler should not record an exemplar for an oversized trace header", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Cloud-Trace-Context", strings.Repeat("a", 200)+"/1;o=1")
+		h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+		So(func() { h.ServeHTTP(httptest.NewRecorder(), req) }, ShouldNotPanic)
+
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "other", "200")), ShouldEqual, 1)
+		So(testutil.CollectAndCount(m.duration), ShouldEqual, 1)
+	})
+}
@@ -0,0 +1,29 @@
+// Package muxroute adds the matched gorilla/mux route to request events
+package muxroute
+
+import (
+	"net/http"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/gorilla/mux"
+)
+
+// Handler records the matched route template, e.g. /users/{id}, with
+// log.SetRoute, so it's in the "route" field of the request event and
+// returned by log.Route, and adds the route name if it has one. Events
+// logged for the request use the level and sampling set for the route with
+// log.SetRouteLevels and log.SetRouteSampling. It should be added to the
+// router with Use, inside log.Handler.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if route := mux.CurrentRoute(req); route != nil {
+			if tpl, err := route.GetPathTemplate(); err == nil {
+				log.SetRoute(req, tpl)
+			}
+			if name := route.GetName(); len(name) > 0 {
+				log.AddRequestData(req, log.Data{"route_name": name})
+			}
+		}
+		h.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,55 @@
+package muxroute
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHandler(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var eventData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		eventData = data
+	})
+
+	r := mux.NewRouter()
+	r.Use(Handler)
+	r.HandleFunc("/users/{id}", func(w http.ResponseWriter, req *http.Request) {}).Name("getUser")
+	r.HandleFunc("/datasets/{id}", func(w http.ResponseWriter, req *http.Request) {})
+
+	var route string
+	serve := func(path string) {
+		eventData = nil
+		req, _ := http.NewRequest("GET", path, nil)
+		log.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			r.ServeHTTP(w, req)
+			route = log.Route(req)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	Convey("Handler should add the route template and name to the request event", t, func() {
+		serve("/users/123")
+		So(eventData["path"], ShouldEqual, "/users/123")
+		So(eventData["route"], ShouldEqual, "/users/{id}")
+		So(route, ShouldEqual, "/users/{id}")
+		So(eventData["route_name"], ShouldEqual, "getUser")
+	})
+
+	Convey("Handler should omit the name of unnamed routes", t, func() {
+		serve("/datasets/cpih")
+		So(eventData["route"], ShouldEqual, "/datasets/{id}")
+		So(eventData, ShouldNotContainKey, "route_name")
+	})
+
+	Convey("Handler should not add a route for unmatched requests", t, func() {
+		serve("/unknown")
+		So(eventData, ShouldNotContainKey, "route")
+		So(route, ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,145 @@
+// Package ratelimit provides middleware which limits the request rate from
+// each client IP using a token bucket
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/handlers/realip"
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Options configures rate limiting
+type Options struct {
+	// RequestsPerSecond is the sustained rate allowed for each client, and
+	// must be positive
+	RequestsPerSecond float64
+	// Burst is the number of requests a client can make at once. Zero
+	// defaults to 1 or RequestsPerSecond rounded up if greater.
+	Burst int
+}
+
+// idleTimeout is how long an idle bucket is kept before being discarded
+const idleTimeout = 10 * time.Minute
+
+// Handler limits each client IP to rps requests per second with the given
+// burst, returning a 429 when the limit is exceeded. It panics if rps isn't
+// positive or burst is negative.
+func Handler(rps float64, burst int) func(h http.Handler) http.Handler {
+	return HandlerWithOptions(Options{RequestsPerSecond: rps, Burst: burst})
+}
+
+// HandlerWithOptions limits the request rate from each client IP, returning
+// a 429 with a Retry-After header and logging a "rate_limited" event when
+// the limit is exceeded. The client IP is resolved by realip.Handler, so
+// behind proxies it must wrap this handler, otherwise the connection's
+// address is used. It panics if RequestsPerSecond isn't positive or Burst
+// is negative, rather than limiting every client to almost nothing.
+func HandlerWithOptions(opts Options) func(h http.Handler) http.Handler {
+	if !(opts.RequestsPerSecond > 0) || math.IsInf(opts.RequestsPerSecond, 1) {
+		panic(fmt.Sprintf("ratelimit: invalid requests per second %v", opts.RequestsPerSecond))
+	}
+	if opts.Burst < 0 {
+		panic(fmt.Sprintf("ratelimit: invalid burst %d", opts.Burst))
+	}
+	if opts.Burst == 0 {
+		opts.Burst = int(math.Max(1, math.Ceil(opts.RequestsPerSecond)))
+	}
+	l := newLimiter(opts.RequestsPerSecond, opts.Burst)
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ip := realip.RealIP(req)
+
+			ok, wait := l.allow(ip)
+			if ok {
+				h.ServeHTTP(w, req)
+				return
+			}
+
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+
+			log.FromContext(req.Context()).Event("rate_limited", log.Data{
+				"ip":          ip,
+				"method":      req.Method,
+				"path":        req.URL.Path,
+				"retry_after": retryAfter,
+			})
+
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		})
+	}
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// limiter holds a token bucket for each key
+type limiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	pruned  time.Time
+}
+
+func newLimiter(rate float64, burst int) *limiter {
+	return &limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token from the bucket for key, or returns how long until a
+// token will be available
+func (l *limiter) allow(key string) (bool, time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// prune discards buckets which have been idle for idleTimeout, so
+// memory isn't held for clients which have gone away
+func (l *limiter) prune(now time.Time) {
+	if now.Sub(l.pruned) < idleTimeout {
+		return
+	}
+	l.pruned = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
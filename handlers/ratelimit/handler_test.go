@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/handlers/realip"
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLimiter(t *testing.T) {
+	Convey("limiter should allow a burst then refill at the configured rate", t, func() {
+		now := time.Unix(0, 0)
+		l := newLimiter(2, 3)
+		l.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			ok, _ := l.allow("a")
+			So(ok, ShouldBeTrue)
+		}
+		ok, wait := l.allow("a")
+		So(ok, ShouldBeFalse)
+		So(wait, ShouldEqual, 500*time.Millisecond)
+
+		ok, _ = l.allow("b")
+		So(ok, ShouldBeTrue)
+
+		now = now.Add(500 * time.Millisecond)
+		ok, _ = l.allow("a")
+		So(ok, ShouldBeTrue)
+		ok, _ = l.allow("a")
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("limiter should discard idle buckets", t, func() {
+		now := time.Unix(0, 0)
+		l := newLimiter(1, 1)
+		l.now = func() time.Time { return now }
+
+		l.allow("a")
+		now = now.Add(idleTimeout)
+		l.allow("b")
+		So(l.buckets, ShouldNotContainKey, "a")
+		So(l.buckets, ShouldContainKey, "b")
+	})
+}
+
+func TestHandler(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var eventName string
+	var eventData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		eventName = name
+		eventData = data
+	})
+
+	Convey("Handler should return a 429 once the limit is exceeded", t, func() {
+		h := Handler(1, 2)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+		serve := func(remoteAddr string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest("GET", "/datasets", nil)
+			req.RemoteAddr = remoteAddr
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			return w
+		}
+
+		So(serve("1.2.3.4:1000").Code, ShouldEqual, 200)
+		So(serve("1.2.3.4:1001").Code, ShouldEqual, 200)
+		So(eventName, ShouldBeEmpty)
+
+		w := serve("1.2.3.4:1002")
+		So(w.Code, ShouldEqual, http.StatusTooManyRequests)
+		So(w.Header().Get("Retry-After"), ShouldEqual, "1")
+
+		So(eventName, ShouldEqual, "rate_limited")
+		So(eventData["ip"], ShouldEqual, "1.2.3.4")
+		So(eventData["method"], ShouldEqual, "GET")
+		So(eventData["path"], ShouldEqual, "/datasets")
+		So(eventData["retry_after"], ShouldEqual, 1)
+
+		So(serve("5.6.7.8:1000").Code, ShouldEqual, 200)
+	})
+
+	Convey("HandlerWithOptions should key clients by the IP resolved by realip", t, func() {
+		h := realip.Handler(realip.Options{TrustedProxies: []string{"10.0.0.0/8"}})(
+			HandlerWithOptions(Options{RequestsPerSecond: 1})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})),
+		)
+
+		serve := func(forwardedFor string) int {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		So(serve("1.1.1.1"), ShouldEqual, 200)
+		So(serve("1.1.1.1"), ShouldEqual, http.StatusTooManyRequests)
+		So(serve("2.2.2.2"), ShouldEqual, 200)
+	})
+
+	Convey("HandlerWithOptions should panic on an invalid rate or burst", t, func() {
+		So(func() { Handler(0, 1) }, ShouldPanic)
+		So(func() { Handler(-1, 1) }, ShouldPanic)
+		So(func() { Handler(math.NaN(), 1) }, ShouldPanic)
+		So(func() { Handler(1, -1) }, ShouldPanic)
+		So(func() { Handler(0.5, 0) }, ShouldNotPanic)
+	})
+}
@@ -0,0 +1,173 @@
+// Package realip resolves the IP address of the client which made a
+// request, using proxy headers set by trusted proxies
+package realip
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Options configures client IP resolution
+type Options struct {
+	// TrustedProxies lists the CIDRs or IPs of proxies whose Forwarded,
+	// X-Forwarded-For and X-Real-IP headers are trusted. Headers from other
+	// addresses are ignored, since they can be spoofed by clients.
+	TrustedProxies []string
+}
+
+type ipKey struct{}
+
+// FromContext returns the client IP resolved by Handler
+func FromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(ipKey{}).(string)
+	return ip, ok
+}
+
+// RealIP returns the client IP resolved by Handler, or the address of the
+// connection if the request hasn't been handled by it
+func RealIP(req *http.Request) string {
+	if ip, ok := FromContext(req.Context()); ok {
+		return ip
+	}
+	return remoteIP(req)
+}
+
+// Handler resolves the client IP of each request, storing it in the request
+// context and adding it to the request event as "client_ip". It panics if a
+// trusted proxy isn't a valid CIDR or IP.
+func Handler(opts Options) func(http.Handler) http.Handler {
+	r := resolver{trusted: parseCIDRs(opts.TrustedProxies)}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ip := r.resolve(req)
+			log.AddRequestData(req, log.Data{"client_ip": ip})
+			h.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ipKey{}, ip)))
+		})
+	}
+}
+
+func parseCIDRs(cidrs []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+type resolver struct {
+	trusted []*net.IPNet
+}
+
+func (r resolver) isTrusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range r.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the client IP. Proxy headers are only used if the request
+// came from a trusted proxy, and the forwarding chain is walked from the
+// right, skipping trusted proxies, so a client can't spoof its address.
+func (r resolver) resolve(req *http.Request) string {
+	remote := remoteIP(req)
+	if !r.isTrusted(remote) {
+		return remote
+	}
+
+	chain := forwarded(req.Header)
+	if len(chain) == 0 {
+		chain = forwardedFor(req.Header)
+	}
+	if len(chain) == 0 {
+		if ip := strings.TrimSpace(req.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+			return ip
+		}
+		return remote
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !r.isTrusted(chain[i]) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+// remoteIP returns the IP of the connection a request was received on
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// forwardedFor returns the valid IPs listed in X-Forwarded-For headers
+func forwardedFor(h http.Header) []string {
+	var ips []string
+	for _, header := range h["X-Forwarded-For"] {
+		for _, addr := range strings.Split(header, ",") {
+			if ip := parseNode(addr); len(ip) > 0 {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
+
+// forwarded returns the valid IPs in the for parameters of RFC 7239
+// Forwarded headers
+func forwarded(h http.Header) []string {
+	var ips []string
+	for _, header := range h["Forwarded"] {
+		for _, element := range strings.Split(header, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+					continue
+				}
+				if ip := parseNode(kv[1]); len(ip) > 0 {
+					ips = append(ips, ip)
+				}
+			}
+		}
+	}
+	return ips
+}
+
+// parseNode returns the IP from a forwarded node, which may be quoted and
+// include a port, e.g. "[2001:db8::1]:8080", or an empty string if it isn't
+// an IP
+func parseNode(node string) string {
+	node = strings.Trim(strings.TrimSpace(node), `"`)
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		node = host
+	}
+	node = strings.TrimSuffix(strings.TrimPrefix(node, "["), "]")
+	if ip := net.ParseIP(node); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newRequest(remoteAddr string, headers map[string]string) *http.Request {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestResolve(t *testing.T) {
+	r := resolver{trusted: parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})}
+
+	Convey("resolve should ignore proxy headers from untrusted addresses", t, func() {
+		req := newRequest("1.2.3.4:1234", map[string]string{"X-Forwarded-For": "5.6.7.8", "X-Real-IP": "5.6.7.8"})
+		So(r.resolve(req), ShouldEqual, "1.2.3.4")
+	})
+
+	Convey("resolve should use the rightmost untrusted X-Forwarded-For address", t, func() {
+		req := newRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-For": "9.9.9.9, 5.6.7.8, 10.1.2.3"})
+		So(r.resolve(req), ShouldEqual, "5.6.7.8")
+	})
+
+	Convey("resolve should use the leftmost address if every proxy is trusted", t, func() {
+		req := newRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.1.1.1, 192.168.1.1"})
+		So(r.resolve(req), ShouldEqual, "10.1.1.1")
+	})
+
+	Convey("resolve should prefer the Forwarded header", t, func() {
+		req := newRequest("192.168.1.1:1234", map[string]string{
+			"Forwarded":       `for="[2001:db8::1]:4711";proto=https, for=10.0.0.2`,
+			"X-Forwarded-For": "5.6.7.8",
+		})
+		So(r.resolve(req), ShouldEqual, "2001:db8::1")
+	})
+
+	Convey("resolve should fall back to X-Real-IP then the connection address", t, func() {
+		req := newRequest("10.0.0.1:1234", map[string]string{"X-Real-IP": "5.6.7.8"})
+		So(r.resolve(req), ShouldEqual, "5.6.7.8")
+
+		req = newRequest("10.0.0.1:1234", map[string]string{"X-Real-IP": "unknown"})
+		So(r.resolve(req), ShouldEqual, "10.0.0.1")
+	})
+
+	Convey("parseCIDRs should panic on an invalid CIDR", t, func() {
+		So(func() { parseCIDRs([]string{"not-a-cidr"}) }, ShouldPanic)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var eventData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		eventData = data
+	})
+
+	Convey("Handler should store the client IP in the context and request event", t, func() {
+		var ip string
+		var ok bool
+		h := Handler(Options{TrustedProxies: []string{"10.0.0.0/8"}})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ip, ok = FromContext(req.Context())
+			So(RealIP(req), ShouldEqual, "5.6.7.8")
+		}))
+
+		req := newRequest("10.0.0.1:1234", map[string]string{"X-Forwarded-For": "5.6.7.8"})
+		log.Handler(h).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(ok, ShouldBeTrue)
+		So(ip, ShouldEqual, "5.6.7.8")
+		So(eventData["client_ip"], ShouldEqual, "5.6.7.8")
+	})
+
+	Convey("RealIP should use the connection address outside Handler", t, func() {
+		req := newRequest("1.2.3.4:1234", map[string]string{"X-Forwarded-For": "5.6.7.8"})
+		So(RealIP(req), ShouldEqual, "1.2.3.4")
+	})
+}
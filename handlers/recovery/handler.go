@@ -0,0 +1,107 @@
+// Package recovery provides middleware which recovers from panics in
+// downstream handlers and logs them as error events
+package recovery
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Options configures panic recovery
+type Options struct {
+	// Body is the response body, defaults to "internal server error"
+	Body []byte
+	// ContentType of the response body, defaults to text/plain
+	ContentType string
+	// Repanic panics again after logging, e.g. in development so the
+	// panic isn't hidden
+	Repanic bool
+	// StackDepth is the maximum number of stack frames logged, defaults to 32
+	StackDepth int
+}
+
+// Handler recovers from panics, logging an error event and returning a 500
+func Handler(h http.Handler) http.Handler {
+	return HandlerWithOptions(Options{})(h)
+}
+
+// HandlerWithOptions recovers from panics, logging an error event with the
+// stack of the panic and returning a 500 with the configured body
+func HandlerWithOptions(opts Options) func(http.Handler) http.Handler {
+	if opts.Body == nil {
+		opts.Body = []byte("internal server error")
+	}
+	if len(opts.ContentType) == 0 {
+		opts.ContentType = "text/plain; charset=utf-8"
+	}
+	if opts.StackDepth == 0 {
+		opts.StackDepth = 32
+	}
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				// net/http uses ErrAbortHandler to abort a response silently
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+
+				err, ok := v.(error)
+				if !ok {
+					err = fmt.Errorf("panic: %v", v)
+				}
+
+				log.ErrorR(req, err, log.Data{
+					"panic":  true,
+					"method": req.Method,
+					"path":   req.URL.Path,
+					"stack":  panicStack(opts.StackDepth),
+				})
+
+				// a panic often precedes a crash, so don't leave the event
+				// queued in an async or buffered output
+				log.Flush(log.GetFatalFlushTimeout())
+
+				if opts.Repanic {
+					panic(v)
+				}
+
+				w.Header().Set("Content-Type", opts.ContentType)
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write(opts.Body)
+			}()
+
+			h.ServeHTTP(w, req)
+		})
+	}
+}
+
+// panicStack returns the stack of the panicking goroutine from where the
+// panic was raised, skipping the recovery and runtime panic frames
+func panicStack(depth int) []log.StackFrame {
+	pc := make([]uintptr, depth+16)
+	pc = pc[:runtime.Callers(3, pc)]
+
+	var frames []log.StackFrame
+	panicking := false
+	iter := runtime.CallersFrames(pc)
+	for len(frames) < depth {
+		f, more := iter.Next()
+		if panicking {
+			frames = append(frames, log.StackFrame{Function: f.Function, File: f.File, Line: f.Line})
+		} else if f.Function == "runtime.gopanic" {
+			panicking = true
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
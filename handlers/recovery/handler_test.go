@@ -0,0 +1,108 @@
+package recovery
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func panicking(v interface{}) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic(v)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var eventName, eventContext string
+	var eventData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("GET", "/test", nil)
+		req.Header.Set("X-Request-Id", "test-request-id")
+		return req
+	}
+
+	Convey("Handler should log panics and return a 500", t, func() {
+		w := httptest.NewRecorder()
+		Handler(panicking("something broke")).ServeHTTP(w, newRequest())
+
+		So(w.Code, ShouldEqual, 500)
+		So(w.Body.String(), ShouldEqual, "internal server error")
+		So(eventName, ShouldEqual, "error")
+		So(eventContext, ShouldEqual, "test-request-id")
+		So(eventData["message"], ShouldEqual, "panic: something broke")
+		So(eventData["panic"], ShouldBeTrue)
+		So(eventData["path"], ShouldEqual, "/test")
+
+		stack := eventData["stack"].([]log.StackFrame)
+		So(stack, ShouldNotBeEmpty)
+		So(stack[0].Function, ShouldStartWith, "github.com/ONSdigital/go-ns/handlers/recovery.panicking")
+	})
+
+	Convey("Handler should log panics with errors unchanged", t, func() {
+		err := errors.New("test error")
+		Handler(panicking(err)).ServeHTTP(httptest.NewRecorder(), newRequest())
+		So(eventData["error"], ShouldEqual, err)
+	})
+
+	Convey("The response body should be configurable", t, func() {
+		w := httptest.NewRecorder()
+		HandlerWithOptions(Options{Body: []byte(`{"error":"internal"}`), ContentType: "application/json"})(panicking("test")).ServeHTTP(w, newRequest())
+
+		So(w.Code, ShouldEqual, 500)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(w.Body.String(), ShouldEqual, `{"error":"internal"}`)
+	})
+
+	Convey("Repanic should panic again after logging", t, func() {
+		eventName = ""
+		h := HandlerWithOptions(Options{Repanic: true})(panicking("test"))
+		So(func() { h.ServeHTTP(httptest.NewRecorder(), newRequest()) }, ShouldPanicWith, "test")
+		So(eventName, ShouldEqual, "error")
+	})
+
+	Convey("http.ErrAbortHandler should not be recovered", t, func() {
+		eventName = ""
+		h := Handler(panicking(http.ErrAbortHandler))
+		So(func() { h.ServeHTTP(httptest.NewRecorder(), newRequest()) }, ShouldPanicWith, http.ErrAbortHandler)
+		So(eventName, ShouldBeEmpty)
+	})
+
+	Convey("Handler should not affect requests which don't panic", t, func() {
+		w := httptest.NewRecorder()
+		Handler(http.NotFoundHandler()).ServeHTTP(w, newRequest())
+		So(w.Code, ShouldEqual, 404)
+	})
+}
+
+func TestHandlerFlush(t *testing.T) {
+	Convey("Handler should flush buffered events after logging a panic", t, func() {
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		log.EnableBuffering(log.BufferConfig{FlushInterval: time.Hour})
+		defer func() {
+			log.DisableBuffering()
+			log.SetOutput(nil)
+		}()
+
+		req, _ := http.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+		Handler(panicking("something broke")).ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, 500)
+		So(buf.String(), ShouldContainSubstring, "something broke")
+	})
+}
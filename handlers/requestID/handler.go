@@ -1,12 +1,35 @@
 package requestID
 
 import (
-	"math/rand"
+	"crypto/rand"
+	"encoding/binary"
+	mathrand "math/rand"
 	"net/http"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
 )
 
+// Options configures request ID generation
+type Options struct {
+	// Size is the number of random letters in a generated ID, ignored if
+	// ULID is set
+	Size int
+	// Prefix is prepended to generated IDs, e.g. the service name
+	Prefix string
+	// ULID generates lexically sortable ULIDs instead of random letters
+	ULID bool
+}
+
 // Handler is a wrapper which adds an X-Request-Id header if one does not yet exist
 func Handler(size int) func(http.Handler) http.Handler {
+	return HandlerWithOptions(Options{Size: size})
+}
+
+// HandlerWithOptions is a wrapper which adds an X-Request-Id header if one
+// does not yet exist. The ID is also added to the request context for
+// log.FromContext, and echoed in the X-Request-Id response header.
+func HandlerWithOptions(opts Options) func(http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
 
@@ -14,14 +37,42 @@ func Handler(size int) func(http.Handler) http.Handler {
 			requestID := req.Header.Get("X-Request-Id")
 
 			if len(requestID) == 0 {
-				b := make([]rune, size)
-				for i := range b {
-					b[i] = letters[rand.Intn(len(letters))]
+				if opts.ULID {
+					requestID = opts.Prefix + newULID(time.Now())
+				} else {
+					b := make([]rune, opts.Size)
+					for i := range b {
+						b[i] = letters[mathrand.Intn(len(letters))]
+					}
+					requestID = opts.Prefix + string(b)
 				}
-				req.Header.Set("X-Request-Id", string(b))
+				req.Header.Set("X-Request-Id", requestID)
 			}
 
-			h.ServeHTTP(w, req)
+			w.Header().Set("X-Request-Id", requestID)
+			h.ServeHTTP(w, req.WithContext(log.WithRequestID(req.Context(), requestID)))
 		})
 	}
 }
+
+// crockford is the base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a ULID: a 48 bit millisecond timestamp followed by 80
+// random bits, encoded as 26 characters of Crockford base32
+func newULID(t time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixNano()/int64(time.Millisecond))<<16)
+	rand.Read(b[6:])
+
+	// encode the 128 bits 5 at a time, with 2 leading zero bits
+	s := make([]byte, 26)
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s)
+}
@@ -3,7 +3,11 @@ package requestID
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -77,3 +81,55 @@ func TestHandler(t *testing.T) {
 		So(header, ShouldHaveLength, 30)
 	})
 }
+
+func TestHandlerWithOptions(t *testing.T) {
+	Convey("Generated IDs should include the prefix", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		w := httptest.NewRecorder()
+
+		HandlerWithOptions(Options{Size: 10, Prefix: "api-"})(dummyHandler).ServeHTTP(w, req)
+
+		header := req.Header.Get("X-Request-Id")
+		So(header, ShouldStartWith, "api-")
+		So(header, ShouldHaveLength, 14)
+	})
+
+	Convey("ULID request IDs should be generated when configured", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		w := httptest.NewRecorder()
+
+		HandlerWithOptions(Options{ULID: true})(dummyHandler).ServeHTTP(w, req)
+
+		header := req.Header.Get("X-Request-Id")
+		So(header, ShouldHaveLength, 26)
+		So(strings.Trim(header, crockford), ShouldBeEmpty)
+	})
+
+	Convey("The request ID should be echoed and added to the context", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("X-Request-Id", "test")
+		w := httptest.NewRecorder()
+
+		var ctxID string
+		Handler(20)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctxID = log.RequestID(req.Context())
+		})).ServeHTTP(w, req)
+
+		So(ctxID, ShouldEqual, "test")
+		So(w.Header().Get("X-Request-Id"), ShouldEqual, "test")
+	})
+}
+
+func TestULID(t *testing.T) {
+	Convey("ULIDs should encode the timestamp so they sort by time", t, func() {
+		a := newULID(time.Unix(1, 0))
+		b := newULID(time.Unix(2, 0))
+		So(a < b, ShouldBeTrue)
+
+		// 1469918176385ms is 01ARYZ6S41 in the ULID specification
+		So(newULID(time.Unix(0, 1469918176385*int64(time.Millisecond)))[:10], ShouldEqual, "01ARYZ6S41")
+	})
+}
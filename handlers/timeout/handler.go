@@ -1,13 +1,194 @@
 package timeout
 
 import (
+	"bytes"
+	"context"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/ONSdigital/go-ns/log"
 )
 
+// Body is the JSON response body returned when a request times out
+var Body = []byte(`{"error":"request timed out"}`)
+
+// Options configures request timeouts
+type Options struct {
+	// Timeout is the maximum time a handler has to respond
+	Timeout time.Duration
+	// Skip returns true for requests which aren't timed out, e.g.
+	// long-lived event streams
+	Skip func(req *http.Request) bool
+}
+
 // Handler implements a HTTP timeout
+//
+// It uses http.TimeoutHandler, which responds with a "timed out" text body
+// and buffers event streams. Use HandlerWithOptions for JSON errors, timeout
+// events and event streams.
 func Handler(timeout time.Duration) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		return http.TimeoutHandler(h, timeout, "timed out")
 	}
 }
+
+// HandlerWithOptions implements a HTTP timeout for requests which aren't
+// skipped, as middleware for Timeout
+func HandlerWithOptions(opts Options) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		th := Timeout(h, opts.Timeout)
+		if opts.Skip == nil {
+			return th
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if opts.Skip(req) {
+				h.ServeHTTP(w, req)
+				return
+			}
+			th.ServeHTTP(w, req)
+		})
+	}
+}
+
+// Timeout cancels the request context after d. If the handler hasn't
+// returned by then, a 503 is returned with a JSON error body and a "timeout"
+// event is logged, so slow endpoints can be tracked. Requests cancelled by
+// the client aren't timeouts, so they're discarded without a response.
+//
+// The response is buffered until the handler returns, except for
+// text/event-stream responses which are written and flushed as the handler
+// writes them. Event streams are still cancelled after d, so long-lived
+// streams should be skipped using HandlerWithOptions.
+func Timeout(h http.Handler, d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx, cancel := context.WithTimeout(req.Context(), d)
+		defer cancel()
+		req = req.WithContext(ctx)
+
+		tw := &timeoutWriter{w: w, header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+
+		s := time.Now()
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					panicked <- v
+				}
+			}()
+			h.ServeHTTP(tw, req)
+			close(done)
+		}()
+
+		select {
+		case v := <-panicked:
+			// re-panic in the server goroutine so recovery middleware sees it
+			panic(v)
+		case <-done:
+			tw.mutex.Lock()
+			defer tw.mutex.Unlock()
+			if tw.streaming {
+				return
+			}
+			for k, v := range tw.header {
+				w.Header()[k] = v
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			w.Write(tw.body.Bytes())
+		case <-ctx.Done():
+			tw.mutex.Lock()
+			defer tw.mutex.Unlock()
+			tw.timedOut = true
+
+			if ctx.Err() != context.DeadlineExceeded {
+				// the client has gone away, so there's no one to respond to
+				return
+			}
+
+			log.FromContext(ctx).Event("timeout", log.Data{
+				"method":  req.Method,
+				"path":    req.URL.Path,
+				"elapsed": time.Since(s),
+				"timeout": d,
+			})
+
+			if tw.streaming {
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write(Body)
+		}
+	})
+}
+
+// timeoutWriter buffers a response until the handler returns, discarding
+// it if the request has timed out. Event streams are written through.
+type timeoutWriter struct {
+	w         http.ResponseWriter
+	mutex     sync.Mutex
+	header    http.Header
+	body      bytes.Buffer
+	status    int
+	timedOut  bool
+	streaming bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.writeHeader(http.StatusOK)
+	}
+	if tw.streaming {
+		return tw.w.Write(b)
+	}
+	return tw.body.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	tw.writeHeader(status)
+}
+
+// writeHeader records the status, starting to write through if the
+// response is an event stream
+func (tw *timeoutWriter) writeHeader(status int) {
+	tw.status = status
+	if !strings.HasPrefix(tw.header.Get("Content-Type"), "text/event-stream") {
+		return
+	}
+	for k, v := range tw.header {
+		tw.w.Header()[k] = v
+	}
+	tw.w.WriteHeader(status)
+	tw.streaming = true
+}
+
+// Flush flushes an event stream, buffered responses are written when the
+// handler returns
+func (tw *timeoutWriter) Flush() {
+	tw.mutex.Lock()
+	defer tw.mutex.Unlock()
+	if tw.streaming && !tw.timedOut {
+		if f, ok := tw.w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
@@ -1,11 +1,14 @@
 package timeout
 
 import (
+	"bufio"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/ONSdigital/go-ns/log"
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -37,3 +40,142 @@ func TestHandler(t *testing.T) {
 	})
 
 }
+
+func TestEventStream(t *testing.T) {
+	Convey("Skipped requests should not be timed out or buffered", t, func() {
+		req, err := http.NewRequest("GET", "/events", nil)
+		So(err, ShouldBeNil)
+		w := httptest.NewRecorder()
+
+		var flushed bool
+		handler := HandlerWithOptions(Options{
+			Timeout: 10 * time.Millisecond,
+			Skip:    func(req *http.Request) bool { return req.URL.Path == "/events" },
+		})
+		wrapped := handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(time.Millisecond * 20)
+			_, flushed = w.(http.Flusher)
+			w.Write([]byte("data: test\n\n"))
+		}))
+
+		wrapped.ServeHTTP(w, req)
+		So(w.Code, ShouldEqual, 200)
+		So(w.Body.String(), ShouldEqual, "data: test\n\n")
+		So(flushed, ShouldBeTrue)
+	})
+
+	Convey("Event stream responses should be written through before the handler returns", t, func() {
+		release := make(chan struct{})
+		server := httptest.NewServer(HandlerWithOptions(Options{Timeout: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: test\n\n"))
+			w.(http.Flusher).Flush()
+			<-release
+		})))
+		defer server.Close()
+		defer close(release)
+
+		res, err := http.Get(server.URL)
+		So(err, ShouldBeNil)
+		defer res.Body.Close()
+
+		So(res.StatusCode, ShouldEqual, 200)
+		So(res.Header.Get("Content-Type"), ShouldEqual, "text/event-stream")
+		line, err := bufio.NewReader(res.Body).ReadString('\n')
+		So(err, ShouldBeNil)
+		So(line, ShouldEqual, "data: test\n")
+	})
+}
+
+func TestTimeout(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var eventName string
+	var eventData log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		eventName = name
+		eventData = data
+	})
+
+	Convey("Timeout should return a JSON error and log a timeout event", t, func() {
+		eventName, eventData = "", nil
+		cancelled := make(chan struct{})
+
+		wrapped := Timeout(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			<-req.Context().Done()
+			w.Write([]byte("too late"))
+			close(cancelled)
+		}), 10*time.Millisecond)
+
+		req, err := http.NewRequest("POST", "/slow", nil)
+		So(err, ShouldBeNil)
+		w := httptest.NewRecorder()
+		wrapped.ServeHTTP(w, req)
+		<-cancelled
+
+		So(w.Code, ShouldEqual, 503)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(w.Body.String(), ShouldEqual, `{"error":"request timed out"}`)
+
+		So(eventName, ShouldEqual, "timeout")
+		So(eventData["method"], ShouldEqual, "POST")
+		So(eventData["path"], ShouldEqual, "/slow")
+		So(eventData["timeout"], ShouldEqual, 10*time.Millisecond)
+		So(eventData["elapsed"], ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+	})
+
+	Convey("Timeout should not respond to requests cancelled by the client", t, func() {
+		eventName = ""
+		cancelled := make(chan struct{})
+
+		wrapped := Timeout(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			<-req.Context().Done()
+			w.Write([]byte("too late"))
+			close(cancelled)
+		}), time.Second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req = req.WithContext(ctx)
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		w := httptest.NewRecorder()
+		wrapped.ServeHTTP(w, req)
+		<-cancelled
+
+		So(w.Body.Len(), ShouldEqual, 0)
+		So(w.Header().Get("Content-Type"), ShouldBeEmpty)
+		So(eventName, ShouldBeEmpty)
+	})
+
+	Convey("Timeout should pass through a response written in time", t, func() {
+		eventName = ""
+
+		wrapped := Timeout(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("created"))
+		}), time.Second)
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		w := httptest.NewRecorder()
+		wrapped.ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, 201)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "text/plain")
+		So(w.Body.String(), ShouldEqual, "created")
+		So(eventName, ShouldBeEmpty)
+	})
+
+	Convey("Timeout should propagate a panic from the handler", t, func() {
+		wrapped := Timeout(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			panic("boom")
+		}), time.Second)
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		So(func() { wrapped.ServeHTTP(httptest.NewRecorder(), req) }, ShouldPanicWith, "boom")
+	})
+}
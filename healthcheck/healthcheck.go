@@ -0,0 +1,194 @@
+// Package healthcheck runs registered health checks and reports their
+// results over HTTP with an overall OK, WARNING or CRITICAL status, or in
+// the draft application/health+json format.
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Status is the result of a health check
+type Status string
+
+// Check statuses, in increasing order of severity
+const (
+	StatusOK       Status = "OK"
+	StatusWarning  Status = "WARNING"
+	StatusCritical Status = "CRITICAL"
+)
+
+func (s Status) severity() int {
+	switch s {
+	case StatusOK:
+		return 0
+	case StatusWarning:
+		return 1
+	}
+	return 2
+}
+
+// Checker checks the health of a dependency, returning its status and a
+// message describing it
+type Checker interface {
+	Check(ctx context.Context) (Status, string)
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) (Status, string)
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) (Status, string) {
+	return f(ctx)
+}
+
+// Result is the outcome of running a single check
+type Result struct {
+	Name        string
+	Status      Status
+	Message     string
+	LastChecked time.Time
+	Duration    time.Duration
+}
+
+// Report is the outcome of running all registered checks
+type Report struct {
+	Status Status
+	Checks []Result
+}
+
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Registry holds a set of named checkers
+type Registry struct {
+	mutex    sync.RWMutex
+	checkers []namedChecker
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry is used by Register and Handler
+var DefaultRegistry = NewRegistry()
+
+// Register adds a checker to the default registry
+func Register(name string, c Checker) {
+	DefaultRegistry.Register(name, c)
+}
+
+// Handler reports the checks in the default registry
+func Handler(w http.ResponseWriter, req *http.Request) {
+	DefaultRegistry.ServeHTTP(w, req)
+}
+
+// Register adds a named checker, replacing any existing checker with that name
+func (r *Registry) Register(name string, c Checker) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, nc := range r.checkers {
+		if nc.name == name {
+			r.checkers[i].checker = c
+			return
+		}
+	}
+	r.checkers = append(r.checkers, namedChecker{name, c})
+}
+
+// Run runs all checks concurrently and aggregates their results. The
+// overall status is the most severe status of any check.
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mutex.RLock()
+	checkers := append([]namedChecker(nil), r.checkers...)
+	r.mutex.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make([]Result, len(checkers))}
+
+	var wg sync.WaitGroup
+	for i, nc := range checkers {
+		wg.Add(1)
+		go func(i int, nc namedChecker) {
+			defer wg.Done()
+			report.Checks[i] = run(ctx, nc)
+		}(i, nc)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status.severity() > report.Status.severity() {
+			report.Status = result.Status
+		}
+	}
+	return report
+}
+
+// run runs a single check, treating a panic or an unknown status as critical
+func run(ctx context.Context, nc namedChecker) (result Result) {
+	result = Result{Name: nc.name, LastChecked: time.Now()}
+
+	defer func() {
+		if v := recover(); v != nil {
+			result.Status = StatusCritical
+			result.Message = "check panicked"
+		}
+		result.Duration = time.Since(result.LastChecked)
+	}()
+
+	result.Status, result.Message = nc.checker.Check(ctx)
+	if result.Status != StatusOK && result.Status != StatusWarning {
+		result.Status = StatusCritical
+	}
+	return result
+}
+
+type jsonResult struct {
+	Name        string    `json:"name"`
+	Status      Status    `json:"status"`
+	Message     string    `json:"message,omitempty"`
+	LastChecked time.Time `json:"last_checked"`
+	Duration    string    `json:"duration"`
+}
+
+type jsonReport struct {
+	Status Status       `json:"status"`
+	Checks []jsonResult `json:"checks"`
+}
+
+// ServeHTTP runs all checks and responds with a JSON report. It returns a
+// 503 if the overall status is critical.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	report := r.Run(req.Context())
+
+	body := jsonReport{Status: report.Status, Checks: make([]jsonResult, 0, len(report.Checks))}
+	for _, result := range report.Checks {
+		body.Checks = append(body.Checks, jsonResult{
+			Name:        result.Name,
+			Status:      result.Status,
+			Message:     result.Message,
+			LastChecked: result.LastChecked,
+			Duration:    result.Duration.String(),
+		})
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == StatusCritical {
+		w.WriteHeader(503)
+	} else {
+		w.WriteHeader(200)
+	}
+	w.Write(b)
+}
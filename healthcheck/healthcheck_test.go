@@ -0,0 +1,116 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func status(s Status, message string) Checker {
+	return CheckerFunc(func(ctx context.Context) (Status, string) {
+		return s, message
+	})
+}
+
+func serve(r *Registry) (*httptest.ResponseRecorder, jsonReport) {
+	req, _ := http.NewRequest("GET", "/healthcheck", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var report jsonReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	return w, report
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("An empty registry should be OK", t, func() {
+		w, report := serve(NewRegistry())
+		So(w.Code, ShouldEqual, 200)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+		So(report.Status, ShouldEqual, StatusOK)
+		So(report.Checks, ShouldBeEmpty)
+	})
+
+	Convey("The overall status should be the most severe check status", t, func() {
+		r := NewRegistry()
+		r.Register("mongo", status(StatusOK, ""))
+		r.Register("kafka", status(StatusWarning, "1 of 3 brokers unavailable"))
+
+		w, report := serve(r)
+		So(w.Code, ShouldEqual, 200)
+		So(report.Status, ShouldEqual, StatusWarning)
+		So(report.Checks, ShouldHaveLength, 2)
+		So(report.Checks[0].Name, ShouldEqual, "mongo")
+		So(report.Checks[0].LastChecked.IsZero(), ShouldBeFalse)
+		So(report.Checks[0].Duration, ShouldNotBeEmpty)
+		So(report.Checks[1].Name, ShouldEqual, "kafka")
+		So(report.Checks[1].Message, ShouldEqual, "1 of 3 brokers unavailable")
+
+		r.Register("elasticsearch", status(StatusCritical, "connection refused"))
+		w, report = serve(r)
+		So(w.Code, ShouldEqual, 503)
+		So(report.Status, ShouldEqual, StatusCritical)
+	})
+
+	Convey("Register should replace a checker with the same name", t, func() {
+		r := NewRegistry()
+		r.Register("mongo", status(StatusCritical, ""))
+		r.Register("mongo", status(StatusOK, ""))
+
+		report := r.Run(context.Background())
+		So(report.Status, ShouldEqual, StatusOK)
+		So(report.Checks, ShouldHaveLength, 1)
+	})
+
+	Convey("Panics and unknown statuses should be critical", t, func() {
+		r := NewRegistry()
+		r.Register("panics", CheckerFunc(func(ctx context.Context) (Status, string) {
+			panic("oops")
+		}))
+		r.Register("unknown", status("", ""))
+
+		report := r.Run(context.Background())
+		So(report.Status, ShouldEqual, StatusCritical)
+		So(report.Checks[0].Status, ShouldEqual, StatusCritical)
+		So(report.Checks[0].Message, ShouldEqual, "check panicked")
+		So(report.Checks[1].Status, ShouldEqual, StatusCritical)
+	})
+
+	Convey("Checks should receive the request context", t, func() {
+		type key struct{}
+		var value interface{}
+		r := NewRegistry()
+		r.Register("ctx", CheckerFunc(func(ctx context.Context) (Status, string) {
+			value = ctx.Value(key{})
+			return StatusOK, ""
+		}))
+
+		req, _ := http.NewRequest("GET", "/healthcheck", nil)
+		req = req.WithContext(context.WithValue(req.Context(), key{}, "test"))
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		So(value, ShouldEqual, "test")
+	})
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	defer func() {
+		DefaultRegistry = NewRegistry()
+	}()
+
+	Convey("Register and Handler should use the default registry", t, func() {
+		Register("mongo", status(StatusWarning, "slow"))
+
+		req, _ := http.NewRequest("GET", "/healthcheck", nil)
+		w := httptest.NewRecorder()
+		Handler(w, req)
+		So(w.Code, ShouldEqual, 200)
+
+		var report jsonReport
+		So(json.Unmarshal(w.Body.Bytes(), &report), ShouldBeNil)
+		So(report.Status, ShouldEqual, StatusWarning)
+	})
+}
@@ -0,0 +1,87 @@
+package healthcheck
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ServiceInfo describes the service in health+json responses
+type ServiceInfo struct {
+	Version   string
+	ReleaseID string
+	ServiceID string
+}
+
+type healthCheck struct {
+	Status string    `json:"status"`
+	Time   time.Time `json:"time"`
+	Output string    `json:"output,omitempty"`
+}
+
+type health struct {
+	Status    string                   `json:"status"`
+	Version   string                   `json:"version,omitempty"`
+	ReleaseID string                   `json:"releaseId,omitempty"`
+	ServiceID string                   `json:"serviceId,omitempty"`
+	Checks    map[string][]healthCheck `json:"checks,omitempty"`
+}
+
+// healthStatus maps a status to its health+json equivalent
+func healthStatus(s Status) string {
+	switch s {
+	case StatusOK:
+		return "pass"
+	case StatusWarning:
+		return "warn"
+	}
+	return "fail"
+}
+
+// HealthJSON reports the checks in the default registry in the health+json
+// format
+func HealthJSON(info ServiceInfo) http.Handler {
+	return DefaultRegistry.HealthJSON(info)
+}
+
+// HealthJSON returns a handler which runs all checks and responds with the
+// draft application/health+json format, for tooling which understands it.
+// Checks are keyed by name, so names should be in the form
+// "component:measurement", e.g. "mongo:connections". It returns a 503 if
+// the overall status is critical.
+func (r *Registry) HealthJSON(info ServiceInfo) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+
+		body := health{
+			Status:    healthStatus(report.Status),
+			Version:   info.Version,
+			ReleaseID: info.ReleaseID,
+			ServiceID: info.ServiceID,
+		}
+		for _, result := range report.Checks {
+			if body.Checks == nil {
+				body.Checks = map[string][]healthCheck{}
+			}
+			body.Checks[result.Name] = append(body.Checks[result.Name], healthCheck{
+				Status: healthStatus(result.Status),
+				Time:   result.LastChecked,
+				Output: result.Message,
+			})
+		}
+
+		b, err := json.Marshal(body)
+		if err != nil {
+			w.WriteHeader(500)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/health+json")
+		if report.Status == StatusCritical {
+			w.WriteHeader(503)
+		} else {
+			w.WriteHeader(200)
+		}
+		w.Write(b)
+	})
+}
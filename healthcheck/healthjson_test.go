@@ -0,0 +1,60 @@
+package healthcheck
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func serveHealthJSON(h http.Handler) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/health", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestHealthJSON(t *testing.T) {
+	Convey("HealthJSON should pass with no checks", t, func() {
+		w := serveHealthJSON(NewRegistry().HealthJSON(ServiceInfo{Version: "1.2.3"}))
+
+		So(w.Code, ShouldEqual, 200)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/health+json")
+		So(w.Body.String(), ShouldEqual, `{"status":"pass","version":"1.2.3"}`)
+	})
+
+	Convey("HealthJSON should report checks keyed by name", t, func() {
+		r := NewRegistry()
+		r.Register("mongo:connections", status(StatusOK, ""))
+		r.Register("kafka:connections", status(StatusWarning, "1 of 3 brokers unavailable"))
+
+		w := serveHealthJSON(r.HealthJSON(ServiceInfo{}))
+		So(w.Code, ShouldEqual, 200)
+
+		var h health
+		So(json.Unmarshal(w.Body.Bytes(), &h), ShouldBeNil)
+		So(h.Status, ShouldEqual, "warn")
+		So(h.Checks, ShouldContainKey, "mongo:connections")
+		So(h.Checks["mongo:connections"][0].Status, ShouldEqual, "pass")
+		So(h.Checks["mongo:connections"][0].Time.IsZero(), ShouldBeFalse)
+		So(h.Checks["kafka:connections"][0].Output, ShouldEqual, "1 of 3 brokers unavailable")
+	})
+
+	Convey("HealthJSON should return a 503 if a check fails or panics", t, func() {
+		r := NewRegistry()
+		r.Register("vault:status", CheckerFunc(func(ctx context.Context) (Status, string) {
+			panic("boom")
+		}))
+
+		w := serveHealthJSON(r.HealthJSON(ServiceInfo{}))
+		So(w.Code, ShouldEqual, 503)
+
+		var h health
+		So(json.Unmarshal(w.Body.Bytes(), &h), ShouldBeNil)
+		So(h.Status, ShouldEqual, "fail")
+		So(h.Checks["vault:status"][0].Output, ShouldEqual, "check panicked")
+	})
+}
@@ -0,0 +1,188 @@
+// Package kafka provides a consumer group and producer which log through
+// the log package.
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ONSdigital/go-ns/log"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// ErrNoBrokers is returned when no broker addresses are given
+var ErrNoBrokers = errors.New("kafka: no brokers")
+
+// reader is the subset of kafka.Reader used by ConsumerGroup
+type reader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Message is a message received by a consumer group
+type Message struct {
+	msg kafka.Message
+	cg  *ConsumerGroup
+}
+
+// GetData returns the message value
+func (m Message) GetData() []byte {
+	return m.msg.Value
+}
+
+// Key returns the message key
+func (m Message) Key() []byte {
+	return m.msg.Key
+}
+
+// Partition returns the partition the message was received from
+func (m Message) Partition() int {
+	return m.msg.Partition
+}
+
+// Offset returns the offset of the message in its partition
+func (m Message) Offset() int64 {
+	return m.msg.Offset
+}
+
+// Commit marks the message as consumed, so it isn't redelivered to the
+// group after a restart or rebalance
+func (m Message) Commit() error {
+	err := m.cg.reader.CommitMessages(context.Background(), m.msg)
+	if err != nil {
+		log.ErrorC(m.cg.group, err, log.Data{"topic": m.cg.topic, "partition": m.msg.Partition, "offset": m.msg.Offset})
+	}
+	return err
+}
+
+// ConsumerGroup consumes messages from a topic as a member of a consumer
+// group. Partitions are reassigned between members as they join and leave.
+type ConsumerGroup struct {
+	reader reader
+	topic  string
+	group  string
+
+	incoming chan Message
+	errors   chan error
+
+	cancel    context.CancelFunc
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewConsumerGroup returns a consumer group member consuming from topic
+func NewConsumerGroup(brokers []string, topic, group string) (*ConsumerGroup, error) {
+	return NewConsumerGroupWithSecurity(brokers, topic, group, SecurityConfig{})
+}
+
+// NewConsumerGroupWithSecurity returns a consumer group member consuming
+// from topic, connecting to the brokers with TLS or SASL authentication
+func NewConsumerGroupWithSecurity(brokers []string, topic, group string, sec SecurityConfig) (*ConsumerGroup, error) {
+	if len(brokers) == 0 {
+		return nil, ErrNoBrokers
+	}
+
+	dialer, err := sec.dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		Dialer:      dialer,
+		Topic:       topic,
+		GroupID:     group,
+		Logger:      kafka.LoggerFunc(logger(group, topic, false)),
+		ErrorLogger: kafka.LoggerFunc(logger(group, topic, true)),
+	})
+
+	return newConsumerGroup(r, topic, group), nil
+}
+
+func newConsumerGroup(r reader, topic, group string) *ConsumerGroup {
+	ctx, cancel := context.WithCancel(context.Background())
+	cg := &ConsumerGroup{
+		reader:   r,
+		topic:    topic,
+		group:    group,
+		incoming: make(chan Message),
+		errors:   make(chan error, 10),
+		cancel:   cancel,
+		closed:   make(chan struct{}),
+	}
+	go cg.consume(ctx)
+	return cg
+}
+
+// logger returns a function which logs kafka client messages, such as
+// rebalances, with the consumer group as context
+func logger(group, topic string, isError bool) func(string, ...interface{}) {
+	return func(format string, args ...interface{}) {
+		message := fmt.Sprintf(format, args...)
+		if isError {
+			log.ErrorC(group, errors.New(message), log.Data{"topic": topic})
+			return
+		}
+		log.DebugC(group, message, log.Data{"topic": topic})
+	}
+}
+
+// Incoming returns the channel of received messages
+func (cg *ConsumerGroup) Incoming() <-chan Message {
+	return cg.incoming
+}
+
+// Errors returns the channel of errors. Errors are also logged, and are
+// dropped if the channel isn't read.
+func (cg *ConsumerGroup) Errors() <-chan error {
+	return cg.errors
+}
+
+func (cg *ConsumerGroup) consume(ctx context.Context) {
+	defer close(cg.closed)
+
+	for {
+		msg, err := cg.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.ErrorC(cg.group, err, log.Data{"topic": cg.topic})
+			select {
+			case cg.errors <- err:
+			default:
+			}
+			continue
+		}
+
+		select {
+		case cg.incoming <- Message{msg, cg}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Close stops consuming and leaves the group, waiting until ctx is done for
+// consumption to stop. Uncommitted messages are redelivered to the group.
+func (cg *ConsumerGroup) Close(ctx context.Context) (err error) {
+	cg.closeOnce.Do(func() {
+		cg.cancel()
+
+		select {
+		case <-cg.closed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+
+		if closeErr := cg.reader.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+
+		log.DebugC(cg.group, "consumer group closed", log.Data{"topic": cg.topic})
+	})
+	return err
+}
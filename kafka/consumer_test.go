@@ -0,0 +1,157 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	kafka "github.com/segmentio/kafka-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeReader struct {
+	messages chan kafka.Message
+	errors   chan error
+
+	mutex     sync.Mutex
+	committed []kafka.Message
+	closed    bool
+}
+
+func newFakeReader() *fakeReader {
+	return &fakeReader{messages: make(chan kafka.Message, 10), errors: make(chan error, 10)}
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case m := <-r.messages:
+		return m, nil
+	case err := <-r.errors:
+		return kafka.Message{}, err
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.closed = true
+	return nil
+}
+
+func TestConsumerGroup(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	type event struct {
+		name, context string
+		data          log.Data
+	}
+	events := make(chan event, 10)
+	log.SetEvent(func(name string, context string, data log.Data) {
+		events <- event{name, context, data}
+	})
+	drain := func() {
+		for len(events) > 0 {
+			<-events
+		}
+	}
+
+	Convey("NewConsumerGroup should require brokers", t, func() {
+		_, err := NewConsumerGroup(nil, "topic", "group")
+		So(err, ShouldEqual, ErrNoBrokers)
+	})
+
+	Convey("Messages should be delivered to Incoming and committed", t, func() {
+		r := newFakeReader()
+		cg := newConsumerGroup(r, "topic", "group")
+
+		r.messages <- kafka.Message{Partition: 1, Offset: 42, Key: []byte("key"), Value: []byte("value")}
+
+		var msg Message
+		select {
+		case msg = <-cg.Incoming():
+		case <-time.After(time.Second):
+		}
+		So(string(msg.GetData()), ShouldEqual, "value")
+		So(string(msg.Key()), ShouldEqual, "key")
+		So(msg.Partition(), ShouldEqual, 1)
+		So(msg.Offset(), ShouldEqual, 42)
+
+		So(msg.Commit(), ShouldBeNil)
+		So(r.committed, ShouldHaveLength, 1)
+		So(r.committed[0].Offset, ShouldEqual, 42)
+
+		So(cg.Close(context.Background()), ShouldBeNil)
+	})
+
+	Convey("Errors should be logged with the consumer group as context", t, func() {
+		drain()
+		r := newFakeReader()
+		cg := newConsumerGroup(r, "topic", "group")
+
+		r.errors <- errors.New("broker unavailable")
+
+		var err error
+		select {
+		case err = <-cg.Errors():
+		case <-time.After(time.Second):
+		}
+		So(err, ShouldNotBeNil)
+
+		e := <-events
+		So(e.name, ShouldEqual, "error")
+		So(e.context, ShouldEqual, "group")
+		So(e.data["message"], ShouldEqual, "broker unavailable")
+		So(e.data["topic"], ShouldEqual, "topic")
+
+		cg.Close(context.Background())
+	})
+
+	Convey("Close should stop consuming and close the reader", t, func() {
+		drain()
+		r := newFakeReader()
+		cg := newConsumerGroup(r, "topic", "group")
+
+		So(cg.Close(context.Background()), ShouldBeNil)
+		So(r.closed, ShouldBeTrue)
+
+		e := <-events
+		So(e.context, ShouldEqual, "group")
+		So(e.data["message"], ShouldEqual, "consumer group closed")
+
+		// consuming has stopped, so the message isn't delivered
+		r.messages <- kafka.Message{}
+		select {
+		case <-cg.Incoming():
+			So("message delivered", ShouldBeEmpty)
+		case <-time.After(10 * time.Millisecond):
+		}
+
+		So(cg.Close(context.Background()), ShouldBeNil)
+	})
+
+	Convey("logger should log kafka client messages", t, func() {
+		drain()
+		logger("group", "topic", false)("subscribed to partitions: %v", []int{1})
+		e := <-events
+		So(e.name, ShouldEqual, "debug")
+		So(e.context, ShouldEqual, "group")
+		So(e.data["message"], ShouldEqual, "subscribed to partitions: [1]")
+
+		logger("group", "topic", true)("rebalance failed")
+		e = <-events
+		So(e.name, ShouldEqual, "error")
+		So(e.data["message"], ShouldEqual, "rebalance failed")
+	})
+}
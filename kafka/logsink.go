@@ -0,0 +1,239 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// LogSinkConfig configures a LogSink
+type LogSinkConfig struct {
+	// BatchSize is the number of events which triggers a publish, defaults
+	// to 100
+	BatchSize int
+	// BatchTimeout is the maximum time events are buffered, defaults to 1s
+	BatchTimeout time.Duration
+	// QueueSize is the number of events buffered before they're written
+	// to Fallback instead, defaults to 1000
+	QueueSize int
+	// Timeout applies to each publish, defaults to 5s
+	Timeout time.Duration
+	// Fallback receives events which can't be published as JSON lines,
+	// defaults to os.Stdout
+	Fallback io.Writer
+	// Security configures TLS and SASL authentication with the brokers
+	Security SecurityConfig
+}
+
+// LogSink is a log.Sink which publishes events to a topic in batches, keyed
+// by their context so events for a request are kept in order on one
+// partition. Events are written to the fallback if the brokers can't be
+// reached, so log.SetOutput(ioutil.Discard) can be used to avoid writing
+// every event to stdout as well.
+type LogSink struct {
+	writer writer
+	cfg    LogSinkConfig
+
+	queue chan kafka.Message
+	flush chan chan error
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	fallbackMutex sync.Mutex
+
+	// closed is guarded by closeMutex, so an event can't be queued after
+	// the run goroutine has drained the queue
+	closeMutex sync.RWMutex
+	closed     bool
+	closeOnce  sync.Once
+	closeErr   error
+}
+
+// NewLogSink returns a LogSink which publishes events to topic
+func NewLogSink(brokers []string, topic string, cfg LogSinkConfig) (*LogSink, error) {
+	if len(brokers) == 0 {
+		return nil, ErrNoBrokers
+	}
+
+	dialer, err := cfg.Security.dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	w := kafka.NewWriter(kafka.WriterConfig{
+		Brokers:      brokers,
+		Dialer:       dialer,
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		MaxAttempts:  1,
+		BatchSize:    cfg.BatchSize,
+		BatchTimeout: 10 * time.Millisecond,
+	})
+
+	return newLogSink(w, cfg), nil
+}
+
+func newLogSink(w writer, cfg LogSinkConfig) *LogSink {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.BatchTimeout <= 0 {
+		cfg.BatchTimeout = time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1000
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.Fallback == nil {
+		cfg.Fallback = os.Stdout
+	}
+
+	s := &LogSink{
+		writer: w,
+		cfg:    cfg,
+		queue:  make(chan kafka.Message, cfg.QueueSize),
+		flush:  make(chan chan error),
+		done:   make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s
+}
+
+// Write queues an event to be published, writing it to the fallback if the
+// queue is full or the sink has been closed
+func (s *LogSink) Write(r log.Record) error {
+	b, err := r.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	m := kafka.Message{Value: b}
+	if len(r.Context) > 0 {
+		m.Key = []byte(r.Context)
+	}
+
+	s.closeMutex.RLock()
+	defer s.closeMutex.RUnlock()
+	if s.closed {
+		s.fallback([]kafka.Message{m})
+		return nil
+	}
+
+	select {
+	case s.queue <- m:
+	default:
+		s.fallback([]kafka.Message{m})
+	}
+	return nil
+}
+
+// Flush publishes all queued events
+func (s *LogSink) Flush() error {
+	reply := make(chan error)
+	select {
+	case s.flush <- reply:
+		return <-reply
+	case <-s.done:
+		return nil
+	}
+}
+
+// Close publishes any queued events and closes the connection. It returns
+// the same error if it's called again.
+func (s *LogSink) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeMutex.Lock()
+		s.closed = true
+		s.closeMutex.Unlock()
+
+		close(s.done)
+		s.wg.Wait()
+		s.closeErr = s.writer.Close()
+	})
+	return s.closeErr
+}
+
+func (s *LogSink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.BatchTimeout)
+	defer ticker.Stop()
+
+	batch := make([]kafka.Message, 0, s.cfg.BatchSize)
+	publish := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.publish(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	for {
+		select {
+		case m := <-s.queue:
+			if batch = append(batch, m); len(batch) >= s.cfg.BatchSize {
+				publish()
+			}
+		case <-ticker.C:
+			publish()
+		case reply := <-s.flush:
+			reply <- s.drain(&batch, publish)
+		case <-s.done:
+			s.drain(&batch, publish)
+			return
+		}
+	}
+}
+
+// drain publishes the current batch and everything in the queue
+func (s *LogSink) drain(batch *[]kafka.Message, publish func() error) error {
+	var err error
+	for {
+		select {
+		case m := <-s.queue:
+			if *batch = append(*batch, m); len(*batch) >= s.cfg.BatchSize {
+				if e := publish(); e != nil {
+					err = e
+				}
+			}
+		default:
+			if e := publish(); e != nil {
+				err = e
+			}
+			return err
+		}
+	}
+}
+
+// publish sends a batch, writing it to the fallback if it fails
+func (s *LogSink) publish(batch []kafka.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Timeout)
+	defer cancel()
+
+	err := s.writer.WriteMessages(ctx, batch...)
+	if err != nil {
+		s.fallback(batch)
+	}
+	return err
+}
+
+func (s *LogSink) fallback(msgs []kafka.Message) {
+	s.fallbackMutex.Lock()
+	defer s.fallbackMutex.Unlock()
+	for _, m := range msgs {
+		s.cfg.Fallback.Write(append(m.Value, '\n'))
+	}
+}
+
+var _ log.Sink = (*LogSink)(nil)
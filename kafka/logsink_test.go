@@ -0,0 +1,102 @@
+package kafka
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLogSink(t *testing.T) {
+	record := func(event, context string) log.Record {
+		return log.Record{Created: time.Now(), Event: event, Namespace: "dp-api", Context: context}
+	}
+
+	Convey("NewLogSink should fail without brokers", t, func() {
+		_, err := NewLogSink(nil, "logs", LogSinkConfig{})
+		So(err, ShouldEqual, ErrNoBrokers)
+	})
+
+	Convey("LogSink should publish events keyed by context", t, func() {
+		w := &fakeWriter{}
+		s := newLogSink(w, LogSinkConfig{BatchTimeout: time.Hour})
+
+		So(s.Write(record("request", "request-1")), ShouldBeNil)
+		So(s.Write(record("debug", "")), ShouldBeNil)
+		So(s.Flush(), ShouldBeNil)
+
+		w.mutex.Lock()
+		So(w.attempts, ShouldEqual, 1)
+		So(w.written, ShouldHaveLength, 2)
+		So(w.written[0], ShouldContainSubstring, `"event":"request"`)
+		So(w.keys, ShouldResemble, []string{"request-1", ""})
+		w.mutex.Unlock()
+
+		So(s.Close(), ShouldBeNil)
+		So(w.closed, ShouldBeTrue)
+	})
+
+	Convey("LogSink should publish full batches without waiting", t, func() {
+		w := &fakeWriter{}
+		s := newLogSink(w, LogSinkConfig{BatchSize: 2, BatchTimeout: time.Hour})
+		defer s.Close()
+
+		s.Write(record("one", ""))
+		s.Write(record("two", ""))
+
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			w.mutex.Lock()
+			n := len(w.written)
+			w.mutex.Unlock()
+			if n == 2 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		w.mutex.Lock()
+		So(w.written, ShouldHaveLength, 2)
+		w.mutex.Unlock()
+	})
+
+	Convey("LogSink should write events to the fallback when publishing fails", t, func() {
+		var buf bytes.Buffer
+		w := &fakeWriter{errors: []error{errors.New("broker unreachable")}}
+		s := newLogSink(w, LogSinkConfig{BatchTimeout: time.Hour, Fallback: &buf})
+
+		s.Write(record("request", "request-1"))
+		So(s.Flush(), ShouldNotBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		So(lines, ShouldHaveLength, 1)
+		So(lines[0], ShouldContainSubstring, `"context":"request-1"`)
+	})
+
+	Convey("Close should publish queued events", t, func() {
+		w := &fakeWriter{}
+		s := newLogSink(w, LogSinkConfig{BatchTimeout: time.Hour})
+
+		s.Write(record("request", ""))
+		So(s.Close(), ShouldBeNil)
+		So(w.written, ShouldHaveLength, 1)
+	})
+
+	Convey("Write should use the fallback after Close", t, func() {
+		var buf bytes.Buffer
+		w := &fakeWriter{}
+		s := newLogSink(w, LogSinkConfig{BatchTimeout: time.Hour, Fallback: &buf})
+
+		So(s.Close(), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		So(s.Write(record("request", "request-1")), ShouldBeNil)
+		So(s.Flush(), ShouldBeNil)
+		So(w.written, ShouldBeEmpty)
+		So(buf.String(), ShouldContainSubstring, `"context":"request-1"`)
+	})
+}
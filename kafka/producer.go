@@ -0,0 +1,231 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// writer is the subset of kafka.Writer used by Producer
+type writer interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// Producer sends messages written to its output channel to a topic
+type Producer struct {
+	writer writer
+	topic  string
+
+	// MaxRetries is the number of times a message is retried after a
+	// transient broker error
+	MaxRetries int
+	// RetryTime is the backoff before the first retry, doubled for each
+	// subsequent retry
+	RetryTime time.Duration
+	// Idempotent adds an IdempotencyKeyHeader to each message, which is
+	// unchanged when the message is retried, so consumers can discard
+	// duplicates
+	Idempotent bool
+
+	output chan []byte
+	errors chan error
+
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closing   chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewProducer returns a producer for topic, buffering up to bufferSize
+// messages written to its output channel
+func NewProducer(brokers []string, topic string, bufferSize int) (*Producer, error) {
+	return NewProducerWithSecurity(brokers, topic, bufferSize, SecurityConfig{})
+}
+
+// NewProducerWithSecurity returns a producer for topic, connecting to the
+// brokers with TLS or SASL authentication
+func NewProducerWithSecurity(brokers []string, topic string, bufferSize int, sec SecurityConfig) (*Producer, error) {
+	if len(brokers) == 0 {
+		return nil, ErrNoBrokers
+	}
+
+	dialer, err := sec.dialer()
+	if err != nil {
+		return nil, err
+	}
+
+	// messages are written one batch at a time, so kafka-go's default 1s
+	// BatchTimeout would delay every send
+	w := kafka.NewWriter(kafka.WriterConfig{
+		Brokers:      brokers,
+		Dialer:       dialer,
+		Topic:        topic,
+		MaxAttempts:  1,
+		BatchTimeout: 10 * time.Millisecond,
+	})
+
+	return newProducer(w, topic, bufferSize), nil
+}
+
+func newProducer(w writer, topic string, bufferSize int) *Producer {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Producer{
+		writer:     w,
+		topic:      topic,
+		MaxRetries: 5,
+		RetryTime:  100 * time.Millisecond,
+		output:     make(chan []byte, bufferSize),
+		errors:     make(chan error, 10),
+		ctx:        ctx,
+		cancel:     cancel,
+		closing:    make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+	go p.produce()
+	return p
+}
+
+// Output returns the channel messages are written to
+func (p *Producer) Output() chan<- []byte {
+	return p.output
+}
+
+// Errors returns the channel of messages which failed permanently. Errors
+// are also logged, and are dropped if the channel isn't read.
+func (p *Producer) Errors() <-chan error {
+	return p.errors
+}
+
+func (p *Producer) produce() {
+	defer close(p.closed)
+
+	for {
+		select {
+		case b := <-p.output:
+			p.send(b)
+		case <-p.closing:
+			// flush messages already buffered
+			for {
+				select {
+				case b := <-p.output:
+					p.send(b)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// send writes a message, retrying transient errors with exponential backoff
+func (p *Producer) send(b []byte) {
+	msg := kafka.Message{Value: b}
+	if p.Idempotent {
+		msg.Headers = []kafka.Header{{Key: IdempotencyKeyHeader, Value: []byte(newID())}}
+	}
+
+	if err := p.write(p.ctx, msg); err != nil {
+		select {
+		case p.errors <- err:
+		default:
+		}
+	}
+}
+
+// write writes messages in a single batch, retrying transient errors with
+// exponential backoff. Only the messages which failed are retried, and
+// they're resent unchanged, so idempotency keys are unchanged.
+func (p *Producer) write(ctx context.Context, msgs ...kafka.Message) error {
+	for attempt := 0; ; attempt++ {
+		err := p.writer.WriteMessages(ctx, msgs...)
+		if err == nil {
+			return nil
+		}
+		msgs = failed(msgs, err)
+
+		if attempt >= p.MaxRetries || !temporary(err) || ctx.Err() != nil {
+			log.Error(err, log.Data{"topic": p.topic, "attempt": attempt + 1})
+			return err
+		}
+
+		backoff := p.RetryTime << uint(attempt)
+		log.Event("kafka_producer", "", log.Data{
+			"topic":    p.topic,
+			"attempt":  attempt + 1,
+			"error":    err.Error(),
+			"retry_in": backoff,
+		})
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// failed returns the messages which weren't written. kafka.Writer returns
+// kafka.WriteErrors with an error for each message of a batch, otherwise
+// the whole batch failed.
+func failed(msgs []kafka.Message, err error) []kafka.Message {
+	var writeErrs kafka.WriteErrors
+	if !errors.As(err, &writeErrs) || len(writeErrs) != len(msgs) {
+		return msgs
+	}
+	var f []kafka.Message
+	for i, e := range writeErrs {
+		if e != nil {
+			f = append(f, msgs[i])
+		}
+	}
+	return f
+}
+
+// temporary reports whether an error is transient, e.g. a leader election
+// or a network timeout. kafka.WriteErrors are temporary if every message
+// failed with a temporary error.
+func temporary(err error) bool {
+	var writeErrs kafka.WriteErrors
+	if errors.As(err, &writeErrs) {
+		for _, e := range writeErrs {
+			if e != nil && !temporary(e) {
+				return false
+			}
+		}
+		return writeErrs.Count() > 0
+	}
+
+	var t interface {
+		Temporary() bool
+	}
+	return errors.As(err, &t) && t.Temporary()
+}
+
+// Close stops the producer once messages already written to the output
+// channel have been sent, abandoning any still unsent when ctx is done
+func (p *Producer) Close(ctx context.Context) (err error) {
+	p.closeOnce.Do(func() {
+		close(p.closing)
+
+		select {
+		case <-p.closed:
+		case <-ctx.Done():
+			err = ctx.Err()
+			p.cancel()
+			<-p.closed
+		}
+		p.cancel()
+
+		if closeErr := p.writer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+
+		log.Debug("producer closed", log.Data{"topic": p.topic})
+	})
+	return err
+}
@@ -0,0 +1,210 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	kafka "github.com/segmentio/kafka-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeWriter struct {
+	mutex    sync.Mutex
+	errors   []error
+	attempts int
+	written  []string
+	keys     []string
+	// idempotencyKeys are recorded for every attempt
+	idempotencyKeys []string
+	block           bool
+	closed          bool
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.block {
+		w.mutex.Unlock()
+		<-ctx.Done()
+		w.mutex.Lock()
+		return ctx.Err()
+	}
+
+	w.attempts++
+	for _, m := range msgs {
+		for _, h := range m.Headers {
+			if h.Key == IdempotencyKeyHeader {
+				w.idempotencyKeys = append(w.idempotencyKeys, string(h.Value))
+			}
+		}
+	}
+	if len(w.errors) > 0 {
+		err := w.errors[0]
+		w.errors = w.errors[1:]
+		// like kafka.Writer, messages without an error were written
+		if writeErrs, ok := err.(kafka.WriteErrors); ok {
+			for i, m := range msgs {
+				if writeErrs[i] == nil {
+					w.written = append(w.written, string(m.Value))
+					w.keys = append(w.keys, string(m.Key))
+				}
+			}
+		}
+		return err
+	}
+	for _, m := range msgs {
+		w.written = append(w.written, string(m.Value))
+		w.keys = append(w.keys, string(m.Key))
+	}
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.closed = true
+	return nil
+}
+
+func TestProducer(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var mutex sync.Mutex
+	var retries []log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if name == "kafka_producer" {
+			retries = append(retries, data)
+		}
+	})
+
+	testProducer := func(w *fakeWriter) *Producer {
+		p := newProducer(w, "topic", 10)
+		p.RetryTime = time.Millisecond
+		return p
+	}
+
+	Convey("NewProducer should require brokers", t, func() {
+		_, err := NewProducer(nil, "topic", 10)
+		So(err, ShouldEqual, ErrNoBrokers)
+	})
+
+	Convey("Messages written to Output should be sent and flushed on Close", t, func() {
+		w := &fakeWriter{}
+		p := testProducer(w)
+
+		p.Output() <- []byte("a")
+		p.Output() <- []byte("b")
+		So(p.Close(context.Background()), ShouldBeNil)
+
+		So(w.written, ShouldResemble, []string{"a", "b"})
+		So(w.closed, ShouldBeTrue)
+	})
+
+	Convey("Transient errors should be retried", t, func() {
+		retries = nil
+		w := &fakeWriter{errors: []error{kafka.LeaderNotAvailable, kafka.RequestTimedOut}}
+		p := testProducer(w)
+
+		p.Output() <- []byte("a")
+		So(p.Close(context.Background()), ShouldBeNil)
+
+		So(w.attempts, ShouldEqual, 3)
+		So(w.written, ShouldResemble, []string{"a"})
+		So(retries, ShouldHaveLength, 2)
+		So(retries[0]["topic"], ShouldEqual, "topic")
+		So(retries[0]["attempt"], ShouldEqual, 1)
+	})
+
+	Convey("Idempotent producers should keep a message's key when it's retried", t, func() {
+		w := &fakeWriter{errors: []error{kafka.RequestTimedOut}}
+		p := testProducer(w)
+		p.Idempotent = true
+
+		p.Output() <- []byte("a")
+		p.Output() <- []byte("b")
+		So(p.Close(context.Background()), ShouldBeNil)
+
+		So(w.written, ShouldResemble, []string{"a", "b"})
+		So(w.idempotencyKeys, ShouldHaveLength, 3)
+		So(w.idempotencyKeys[0], ShouldEqual, w.idempotencyKeys[1])
+		So(w.idempotencyKeys[2], ShouldNotEqual, w.idempotencyKeys[1])
+	})
+
+	Convey("Permanent errors should be sent to the errors channel", t, func() {
+		w := &fakeWriter{errors: []error{kafka.MessageSizeTooLarge}}
+		p := testProducer(w)
+
+		p.Output() <- []byte("a")
+		select {
+		case err := <-p.Errors():
+			So(err, ShouldEqual, kafka.MessageSizeTooLarge)
+		case <-time.After(time.Second):
+			So("no error", ShouldBeEmpty)
+		}
+		So(w.attempts, ShouldEqual, 1)
+		So(p.Close(context.Background()), ShouldBeNil)
+	})
+
+	Convey("Errors should be sent to the errors channel once retries are exhausted", t, func() {
+		err := kafka.LeaderNotAvailable
+		w := &fakeWriter{errors: []error{err, err, err}}
+		p := testProducer(w)
+		p.MaxRetries = 2
+
+		p.Output() <- []byte("a")
+		So(p.Close(context.Background()), ShouldBeNil)
+		So(w.attempts, ShouldEqual, 3)
+		So(<-p.Errors(), ShouldEqual, err)
+	})
+
+	Convey("Close should abandon unsent messages when the context is done", t, func() {
+		w := &fakeWriter{block: true}
+		p := testProducer(w)
+
+		p.Output() <- []byte("a")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		So(p.Close(ctx) == context.DeadlineExceeded, ShouldBeTrue)
+		So(w.closed, ShouldBeTrue)
+	})
+
+	Convey("Messages which failed in a batch should be retried", t, func() {
+		w := &fakeWriter{errors: []error{kafka.WriteErrors{nil, kafka.LeaderNotAvailable}}}
+		p := testProducer(w)
+
+		err := p.write(context.Background(), kafka.Message{Value: []byte("a")}, kafka.Message{Value: []byte("b")})
+		So(err, ShouldBeNil)
+		So(w.attempts, ShouldEqual, 2)
+		So(w.written, ShouldResemble, []string{"a", "b"})
+		So(p.Close(context.Background()), ShouldBeNil)
+	})
+
+	Convey("Batches with permanent errors should not be retried", t, func() {
+		w := &fakeWriter{errors: []error{kafka.WriteErrors{kafka.MessageSizeTooLarge, kafka.LeaderNotAvailable}}}
+		p := testProducer(w)
+
+		err := p.write(context.Background(), kafka.Message{Value: []byte("a")}, kafka.Message{Value: []byte("b")})
+		So(err, ShouldNotBeNil)
+		So(w.attempts, ShouldEqual, 1)
+		So(p.Close(context.Background()), ShouldBeNil)
+	})
+
+	Convey("temporary should identify transient errors", t, func() {
+		So(temporary(kafka.LeaderNotAvailable), ShouldBeTrue)
+		So(temporary(kafka.MessageSizeTooLarge), ShouldBeFalse)
+		So(temporary(errors.New("test")), ShouldBeFalse)
+		So(temporary(kafka.WriteErrors{nil, kafka.LeaderNotAvailable}), ShouldBeTrue)
+		So(temporary(kafka.WriteErrors{kafka.MessageSizeTooLarge, kafka.LeaderNotAvailable}), ShouldBeFalse)
+		So(temporary(kafka.WriteErrors{nil}), ShouldBeFalse)
+		So(temporary(fmt.Errorf("wrapped: %w", kafka.WriteErrors{kafka.LeaderNotAvailable})), ShouldBeTrue)
+	})
+}
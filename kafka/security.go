@@ -0,0 +1,66 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	kafka "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// SASL mechanisms
+const (
+	SASLPlain       = "PLAIN"
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	SASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+// SecurityConfig configures TLS and SASL authentication with the brokers
+type SecurityConfig struct {
+	// TLS enables TLS connections to the brokers
+	TLS *tls.Config
+	// SASLMechanism is SASLPlain, SASLScramSHA256 or SASLScramSHA512, or
+	// empty to disable SASL authentication. PLAIN sends the password in
+	// clear text, so it should only be used with TLS.
+	SASLMechanism string
+	Username      string
+	Password      string
+}
+
+// dialer returns a kafka dialer with the security settings, or nil to use
+// the default dialer
+func (c SecurityConfig) dialer() (*kafka.Dialer, error) {
+	if c.TLS == nil && len(c.SASLMechanism) == 0 {
+		return nil, nil
+	}
+
+	d := &kafka.Dialer{
+		Timeout:   10 * time.Second,
+		DualStack: true,
+		TLS:       c.TLS,
+	}
+
+	if len(c.SASLMechanism) > 0 {
+		m, err := c.mechanism()
+		if err != nil {
+			return nil, err
+		}
+		d.SASLMechanism = m
+	}
+	return d, nil
+}
+
+func (c SecurityConfig) mechanism() (sasl.Mechanism, error) {
+	switch c.SASLMechanism {
+	case SASLPlain:
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, c.Username, c.Password)
+	case SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, c.Username, c.Password)
+	}
+	return nil, fmt.Errorf("kafka: unsupported SASL mechanism %q", c.SASLMechanism)
+}
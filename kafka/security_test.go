@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/segmentio/kafka-go/sasl/plain"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSecurityConfig(t *testing.T) {
+	Convey("dialer should use the default dialer without TLS or SASL", t, func() {
+		d, err := SecurityConfig{}.dialer()
+		So(err, ShouldBeNil)
+		So(d, ShouldBeNil)
+	})
+
+	Convey("dialer should configure TLS", t, func() {
+		cfg := &tls.Config{ServerName: "broker"}
+		d, err := SecurityConfig{TLS: cfg}.dialer()
+		So(err, ShouldBeNil)
+		So(d.TLS, ShouldEqual, cfg)
+		So(d.SASLMechanism, ShouldBeNil)
+	})
+
+	Convey("dialer should configure SASL mechanisms", t, func() {
+		d, err := SecurityConfig{SASLMechanism: SASLPlain, Username: "user", Password: "secret"}.dialer()
+		So(err, ShouldBeNil)
+		So(d.SASLMechanism, ShouldResemble, plain.Mechanism{Username: "user", Password: "secret"})
+
+		for _, m := range []string{SASLScramSHA256, SASLScramSHA512} {
+			d, err := SecurityConfig{SASLMechanism: m, Username: "user", Password: "secret"}.dialer()
+			So(err, ShouldBeNil)
+			So(d.SASLMechanism.Name(), ShouldEqual, m)
+		}
+	})
+
+	Convey("constructors should reject unsupported SASL mechanisms", t, func() {
+		sec := SecurityConfig{SASLMechanism: "GSSAPI"}
+		_, err := NewProducerWithSecurity([]string{"localhost:9092"}, "topic", 10, sec)
+		So(err, ShouldNotBeNil)
+		_, err = NewConsumerGroupWithSecurity([]string{"localhost:9092"}, "topic", "group", sec)
+		So(err, ShouldNotBeNil)
+		_, err = NewLogSink([]string{"localhost:9092"}, "topic", LogSinkConfig{Security: sec})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,165 @@
+package kafka
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/ONSdigital/go-ns/log"
+	kafka "github.com/segmentio/kafka-go"
+)
+
+// IdempotencyKeyHeader is the message header holding a unique key for
+// each message from an idempotent producer or a transaction
+const IdempotencyKeyHeader = "idempotency-key"
+
+// ErrTransactionDone is returned when a transaction which has already been
+// committed or aborted is used
+var ErrTransactionDone = errors.New("kafka: transaction has already been committed or aborted")
+
+// Transaction groups messages and consumer offsets which are committed
+// together, for consume-transform-produce pipelines. Messages are buffered
+// until Commit writes them in a single batch, and the offsets of consumed
+// messages are only committed once the messages have been written.
+//
+// The kafka client doesn't implement the Kafka transactions protocol, so
+// this isn't broker-side exactly-once delivery: consumers can see messages
+// before the offsets are committed, and a failure between writing the
+// messages and committing the offsets redelivers the consumed messages.
+// Each message has an IdempotencyKeyHeader derived from the transaction,
+// so consumers can discard the duplicates which are written when the
+// transaction is retried.
+type Transaction struct {
+	p  *Producer
+	id string
+
+	mutex    sync.Mutex
+	messages []kafka.Message
+	offsets  []Message
+	done     bool
+}
+
+// Begin starts a transaction
+func (p *Producer) Begin() *Transaction {
+	t := &Transaction{p: p, id: newID()}
+	t.event("begin", nil)
+	return t
+}
+
+// ID returns the transaction ID
+func (t *Transaction) ID() string {
+	return t.id
+}
+
+// Send adds a message to the transaction, keyed for partitioning by key
+func (t *Transaction) Send(key, value []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.done {
+		return ErrTransactionDone
+	}
+
+	idempotencyKey := t.id + "-" + strconv.Itoa(len(t.messages))
+	t.messages = append(t.messages, kafka.Message{
+		Key:     key,
+		Value:   value,
+		Headers: []kafka.Header{{Key: IdempotencyKeyHeader, Value: []byte(idempotencyKey)}},
+	})
+	return nil
+}
+
+// CommitOffsets adds consumed messages whose offsets are committed when the
+// transaction commits
+func (t *Transaction) CommitOffsets(msgs ...Message) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.done {
+		return ErrTransactionDone
+	}
+	t.offsets = append(t.offsets, msgs...)
+	return nil
+}
+
+// Commit writes the transaction's messages, then commits the offsets of
+// its consumed messages. If writing fails, the offsets aren't committed
+// and the transaction can be retried by calling Commit again.
+func (t *Transaction) Commit(ctx context.Context) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.done {
+		return ErrTransactionDone
+	}
+
+	if len(t.messages) > 0 {
+		if err := t.p.write(ctx, t.messages...); err != nil {
+			t.event("failed", log.Data{"error": err.Error()})
+			return err
+		}
+	}
+
+	// the messages have been written, so retrying would duplicate them
+	t.done = true
+	if err := commitOffsets(ctx, t.offsets); err != nil {
+		t.event("failed", log.Data{"error": err.Error(), "messages_written": true})
+		return err
+	}
+
+	t.event("commit", nil)
+	return nil
+}
+
+// Abort discards the transaction's messages without committing offsets,
+// so the consumed messages are redelivered
+func (t *Transaction) Abort() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.done {
+		return ErrTransactionDone
+	}
+	t.done = true
+	t.event("abort", nil)
+	return nil
+}
+
+// event logs a transaction lifecycle event
+func (t *Transaction) event(state string, data log.Data) {
+	if data == nil {
+		data = log.Data{}
+	}
+	data["transaction_id"] = t.id
+	data["topic"] = t.p.topic
+	data["state"] = state
+	data["messages"] = len(t.messages)
+	data["offsets"] = len(t.offsets)
+	log.Event("kafka_transaction", "", data)
+}
+
+// commitOffsets commits consumed messages, grouped by consumer group
+func commitOffsets(ctx context.Context, msgs []Message) error {
+	groups := map[*ConsumerGroup][]kafka.Message{}
+	var order []*ConsumerGroup
+	for _, m := range msgs {
+		if _, ok := groups[m.cg]; !ok {
+			order = append(order, m.cg)
+		}
+		groups[m.cg] = append(groups[m.cg], m.msg)
+	}
+
+	for _, cg := range order {
+		if err := cg.reader.CommitMessages(ctx, groups[cg]...); err != nil {
+			log.ErrorC(cg.group, err, log.Data{"topic": cg.topic, "messages": len(groups[cg])})
+			return err
+		}
+	}
+	return nil
+}
+
+// newID returns a random hex encoded ID
+func newID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,103 @@
+package kafka
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	kafka "github.com/segmentio/kafka-go"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTransaction(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var mutex sync.Mutex
+	var states []string
+	log.SetEvent(func(name string, context string, data log.Data) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if name == "kafka_transaction" {
+			states = append(states, data["state"].(string))
+		}
+	})
+
+	testProducer := func(w *fakeWriter) *Producer {
+		p := newProducer(w, "output", 10)
+		p.RetryTime = time.Millisecond
+		return p
+	}
+
+	r := newFakeReader()
+	cg := newConsumerGroup(r, "input", "group")
+	defer cg.Close(context.Background())
+	consumed := []Message{
+		{msg: kafka.Message{Partition: 0, Offset: 10}, cg: cg},
+		{msg: kafka.Message{Partition: 1, Offset: 20}, cg: cg},
+	}
+
+	Convey("Commit should write messages and then commit offsets", t, func() {
+		states, r.committed = nil, nil
+		w := &fakeWriter{}
+		p := testProducer(w)
+		defer p.Close(context.Background())
+
+		tx := p.Begin()
+		So(tx.Send([]byte("k1"), []byte("a")), ShouldBeNil)
+		So(tx.Send([]byte("k2"), []byte("b")), ShouldBeNil)
+		So(tx.CommitOffsets(consumed...), ShouldBeNil)
+		So(w.written, ShouldBeEmpty)
+
+		So(tx.Commit(context.Background()), ShouldBeNil)
+		So(w.attempts, ShouldEqual, 1)
+		So(w.written, ShouldResemble, []string{"a", "b"})
+		So(w.keys, ShouldResemble, []string{"k1", "k2"})
+		So(w.idempotencyKeys, ShouldResemble, []string{tx.ID() + "-0", tx.ID() + "-1"})
+		So(r.committed, ShouldHaveLength, 2)
+		So(states, ShouldResemble, []string{"begin", "commit"})
+
+		So(tx.Commit(context.Background()), ShouldEqual, ErrTransactionDone)
+		So(tx.Send(nil, []byte("c")), ShouldEqual, ErrTransactionDone)
+	})
+
+	Convey("Commit should not commit offsets if writing fails", t, func() {
+		states, r.committed = nil, nil
+		w := &fakeWriter{errors: []error{kafka.MessageSizeTooLarge}}
+		p := testProducer(w)
+		defer p.Close(context.Background())
+
+		tx := p.Begin()
+		So(tx.Send(nil, []byte("a")), ShouldBeNil)
+		So(tx.CommitOffsets(consumed...), ShouldBeNil)
+
+		So(tx.Commit(context.Background()), ShouldEqual, kafka.MessageSizeTooLarge)
+		So(r.committed, ShouldBeEmpty)
+		So(states, ShouldResemble, []string{"begin", "failed"})
+
+		Convey("and allow it to be retried with the same idempotency keys", func() {
+			So(tx.Commit(context.Background()), ShouldBeNil)
+			So(w.written, ShouldResemble, []string{"a"})
+			So(w.idempotencyKeys, ShouldResemble, []string{tx.ID() + "-0", tx.ID() + "-0"})
+			So(r.committed, ShouldHaveLength, 2)
+		})
+	})
+
+	Convey("Abort should discard messages without committing offsets", t, func() {
+		states, r.committed = nil, nil
+		w := &fakeWriter{}
+		p := testProducer(w)
+		defer p.Close(context.Background())
+
+		tx := p.Begin()
+		So(tx.Send(nil, []byte("a")), ShouldBeNil)
+		So(tx.CommitOffsets(consumed...), ShouldBeNil)
+		So(tx.Abort(), ShouldBeNil)
+
+		So(w.written, ShouldBeEmpty)
+		So(r.committed, ShouldBeEmpty)
+		So(states, ShouldResemble, []string{"begin", "abort"})
+		So(tx.Commit(context.Background()), ShouldEqual, ErrTransactionDone)
+	})
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// CombinedLogFormat is the Apache Combined Log Format
+const CombinedLogFormat = `{{dash .RemoteHost}} - {{.User | escape | dash}} [{{.Time.Format "02/Jan/2006:15:04:05 -0700"}}] "{{escape .Method}} {{escape .URI}} {{escape .Proto}}" {{.Status}} {{if .Bytes}}{{.Bytes}}{{else}}-{{end}} "{{.Referer | escape | dash}}" "{{.UserAgent | escape | dash}}"`
+
+// AccessLogEntry is the data an access log format template is executed with
+type AccessLogEntry struct {
+	RemoteHost string
+	User       string
+	Time       time.Time
+	Method     string
+	URI        string
+	Proto      string
+	Status     int
+	Bytes      int64
+	Referer    string
+	UserAgent  string
+	Duration   time.Duration
+	Context    string
+	Request    *http.Request
+}
+
+var (
+	accessLog      *template.Template
+	accessLogMutex sync.RWMutex
+)
+
+var accessLogFuncs = template.FuncMap{
+	// dash replaces an empty value with "-", as in Apache logs
+	"dash": func(s string) string {
+		if len(s) == 0 {
+			return "-"
+		}
+		return s
+	},
+	"escape": escapeLogItem,
+}
+
+// escapeLogItem escapes quotes, backslashes and non-printable bytes as
+// Apache does, so client supplied values can't forge lines or break parsers
+// of quoted fields
+func escapeLogItem(s string) string {
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c >= 0x20 && c < 0x7f && c != '"' && c != '\\' {
+			if b != nil {
+				b = append(b, c)
+			}
+			continue
+		}
+		if b == nil {
+			b = append(make([]byte, 0, len(s)+8), s[:i]...)
+		}
+		switch c {
+		case '"', '\\':
+			b = append(b, '\\', c)
+		case '\b':
+			b = append(b, `\b`...)
+		case '\n':
+			b = append(b, `\n`...)
+		case '\r':
+			b = append(b, `\r`...)
+		case '\t':
+			b = append(b, `\t`...)
+		case '\v':
+			b = append(b, `\v`...)
+		default:
+			b = append(b, fmt.Sprintf(`\x%02x`, c)...)
+		}
+	}
+	if b == nil {
+		return s
+	}
+	return string(b)
+}
+
+// SetAccessLogFormat sets a text/template, e.g. CombinedLogFormat, which
+// Handler uses to write a line for each request to the output instead of
+// the JSON request event. The template is executed with an AccessLogEntry,
+// and can use the dash and escape functions to format fields as Apache does.
+// Sinks still receive the request event. An empty format restores the JSON
+// request event.
+func SetAccessLogFormat(format string) error {
+	var tmpl *template.Template
+	if len(format) > 0 {
+		var err error
+		if tmpl, err = template.New("access").Funcs(accessLogFuncs).Parse(format); err != nil {
+			return err
+		}
+	}
+
+	accessLogMutex.Lock()
+	defer accessLogMutex.Unlock()
+	accessLog = tmpl
+	return nil
+}
+
+func getAccessLog() *template.Template {
+	accessLogMutex.RLock()
+	defer accessLogMutex.RUnlock()
+	return accessLog
+}
+
+// configureAccessLog sets the access log format from LOG_ACCESS_FORMAT,
+// which is either "combined" or a template
+func configureAccessLog() {
+	format := os.Getenv("LOG_ACCESS_FORMAT")
+	if format == "combined" {
+		format = CombinedLogFormat
+	}
+	if err := SetAccessLogFormat(format); err != nil {
+		printLogError("", err)
+	}
+}
+
+// accessLogLine executes the access log template for a request, returning
+// nil if no format is set or the template fails. Sensitive query parameters
+// are masked in the URI, as in the request event.
+func accessLogLine(req *http.Request, rc *responseCapture, start time.Time, d time.Duration, redactParams []string) []byte {
+	tmpl := getAccessLog()
+	if tmpl == nil {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	user, _, _ := req.BasicAuth()
+	if req.URL.User != nil {
+		user = req.URL.User.Username()
+	}
+	u := *req.URL
+	if len(u.RawQuery) > 0 {
+		u.RawQuery = redactQuery(u.RawQuery, redactParams)
+	}
+	uri := u.RequestURI()
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, AccessLogEntry{
+		RemoteHost: host,
+		User:       user,
+		Time:       start,
+		Method:     req.Method,
+		URI:        uri,
+		Proto:      req.Proto,
+		Status:     rc.Status(),
+		Bytes:      rc.bytes,
+		Referer:    req.Referer(),
+		UserAgent:  req.UserAgent(),
+		Duration:   d,
+		Context:    Context(req),
+		Request:    req,
+	})
+	if err != nil {
+		printLogError(Context(req), err)
+		return nil
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
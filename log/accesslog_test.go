@@ -0,0 +1,107 @@
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAccessLog(t *testing.T) {
+	defer func() {
+		SetAccessLogFormat("")
+		SetOutput(nil)
+		Close()
+	}()
+	SetHumanReadable(false)
+
+	serve := func() {
+		req, _ := http.NewRequest("GET", "/datasets?q=cpih", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Referer", "https://www.ons.gov.uk/")
+		req.Header.Set("User-Agent", "curl/7.58.0")
+		req.SetBasicAuth("florence", "secret")
+
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("hello"))
+		})).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	Convey("Handler should write combined log format lines when configured", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		So(SetAccessLogFormat(CombinedLogFormat), ShouldBeNil)
+
+		sink := &testSink{}
+		AddSink(sink)
+		defer Close()
+
+		serve()
+
+		line := regexp.MustCompile(regexp.QuoteMeta(`10.0.0.1 - florence [`) +
+			`\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}` +
+			regexp.QuoteMeta(`] "GET /datasets?q=cpih HTTP/1.1" 201 5 "https://www.ons.gov.uk/" "curl/7.58.0"`) + `\n$`)
+		So(line.MatchString(buf.String()), ShouldBeTrue)
+
+		So(sink.records, ShouldHaveLength, 1)
+		So(sink.records[0].Event, ShouldEqual, "request")
+		So(sink.records[0].Data["status"], ShouldEqual, 201)
+	})
+
+	Convey("combined log format lines should escape client supplied values", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		So(SetAccessLogFormat(CombinedLogFormat), ShouldBeNil)
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("User-Agent", `evil" "agent\`)
+		req.SetBasicAuth("florence\n10.0.0.2 - admin", "secret")
+		Handler(dummyHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(buf.String(), ShouldStartWith, `10.0.0.1 - florence\n10.0.0.2 - admin [`)
+		So(buf.String(), ShouldEndWith, `"-" "evil\" \"agent\\"`+"\n")
+		So(escapeLogItem("caf\xc3\xa9\x00"), ShouldEqual, `caf\xc3\xa9\x00`)
+	})
+
+	Convey("combined log format lines should mask sensitive query parameters", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		So(SetAccessLogFormat(CombinedLogFormat), ShouldBeNil)
+
+		req, _ := http.NewRequest("GET", "/datasets?q=cpih&api_key=abc&token=xyz", nil)
+		HandlerWithOptions(HandlerOptions{RedactQueryParams: []string{"api_key"}})(dummyHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(buf.String(), ShouldContainSubstring, `"GET /datasets?q=cpih&api_key=[REDACTED]&token=[REDACTED] HTTP/1.1"`)
+		So(buf.String(), ShouldNotContainSubstring, "abc")
+		So(buf.String(), ShouldNotContainSubstring, "xyz")
+	})
+
+	Convey("Handler should execute a user supplied template", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		So(SetAccessLogFormat(`{{.Method}} {{.Request.URL.Path}} {{.Status}} {{dash .Context}}`), ShouldBeNil)
+
+		serve()
+
+		So(buf.String(), ShouldEqual, "GET /datasets 201 -\n")
+	})
+
+	Convey("SetAccessLogFormat should reject an invalid template", t, func() {
+		So(SetAccessLogFormat(`{{.Method`), ShouldNotBeNil)
+	})
+
+	Convey("Handler should write the JSON request event when no format is set", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		So(SetAccessLogFormat(""), ShouldBeNil)
+
+		serve()
+
+		So(buf.String(), ShouldContainSubstring, `"event":"request"`)
+	})
+}
@@ -0,0 +1,287 @@
+package log
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// The JSON encoder appends events to pooled buffers instead of building a
+// map and calling json.Marshal, which allocates for every field. Output
+// matches json.Marshal: keys are sorted and HTML characters are escaped.
+
+// maxPooledBuffer is the capacity above which buffers aren't returned to
+// the pool, so a single large event doesn't hold on to memory
+const maxPooledBuffer = 64 << 10
+
+// encodeState is the scratch space for encoding a single event
+type encodeState struct {
+	buf  []byte
+	keys []string
+}
+
+var encodeStatePool = sync.Pool{
+	New: func() interface{} {
+		return &encodeState{buf: make([]byte, 0, 1024)}
+	},
+}
+
+func getEncodeState() *encodeState {
+	e := encodeStatePool.Get().(*encodeState)
+	e.buf = e.buf[:0]
+	e.keys = e.keys[:0]
+	return e
+}
+
+func putEncodeState(e *encodeState) {
+	if cap(e.buf) > maxPooledBuffer {
+		return
+	}
+	encodeStatePool.Put(e)
+}
+
+// appendEncoder is implemented by encoders which can append to a buffer,
+// avoiding an allocation for each event written to the output
+type appendEncoder interface {
+	appendEncode(e *encodeState, r Record) error
+}
+
+// appendLayout appends the fields for a Record in the current output Mode
+func (e *encodeState) appendLayout(r Record) error {
+	if getConfig().mode != DefaultMode || len(getFieldMap()) > 0 {
+		m := r.layout()
+		return e.appendMarshal(&m)
+	}
+	return e.appendRecord(r)
+}
+
+// appendRecord appends a Record in DefaultMode, i.e.
+// {"context":..,"created":..,"data":..,"event":..,"namespace":..}
+func (e *encodeState) appendRecord(r Record) error {
+	e.buf = append(e.buf, '{')
+	if len(r.Context) > 0 {
+		e.buf = append(e.buf, `"context":`...)
+		e.buf = appendString(e.buf, r.Context)
+		e.buf = append(e.buf, ',')
+	}
+
+	e.buf = append(e.buf, `"created":`...)
+	e.buf = appendTime(e.buf, r.Created)
+
+	if r.Data != nil {
+		e.buf = append(e.buf, `,"data":`...)
+		if err := e.appendMap(r.Data); err != nil {
+			return err
+		}
+	}
+
+	e.buf = append(e.buf, `,"event":`...)
+	e.buf = appendString(e.buf, r.Event)
+
+	e.buf = append(e.buf, ',')
+	if c := getConfig(); c.namespaceJSON != nil && r.Namespace == c.namespace {
+		e.buf = append(e.buf, c.namespaceJSON...)
+	} else {
+		e.buf = appendNamespace(e.buf, r.Namespace)
+	}
+
+	e.buf = append(e.buf, '}')
+	return nil
+}
+
+// appendNamespace appends the "namespace" field
+func appendNamespace(b []byte, namespace string) []byte {
+	b = append(b, `"namespace":`...)
+	return appendString(b, namespace)
+}
+
+// appendTime appends the "created" value in the configured format
+func appendTime(b []byte, t time.Time) []byte {
+	format := getConfig().timeFormat
+	switch format {
+	case TimeEpochMillis:
+		return strconv.AppendInt(b, t.UnixNano()/int64(time.Millisecond), 10)
+	case TimeRFC3339Nano, TimeRFC3339:
+		b = append(b, '"')
+		b = t.AppendFormat(b, format)
+		return append(b, '"')
+	}
+	return appendString(b, t.Format(format))
+}
+
+// appendMap appends a map with its keys sorted
+func (e *encodeState) appendMap(m map[string]interface{}) error {
+	start := len(e.keys)
+	for k := range m {
+		e.keys = append(e.keys, k)
+	}
+	sort.Strings(e.keys[start:])
+
+	e.buf = append(e.buf, '{')
+	for i := start; i < len(e.keys); i++ {
+		// e.keys is only appended to by nested maps, so indexing it is safe
+		k := e.keys[i]
+		if i > start {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = appendString(e.buf, k)
+		e.buf = append(e.buf, ':')
+		if err := e.appendValue(m[k]); err != nil {
+			return err
+		}
+	}
+	e.buf = append(e.buf, '}')
+
+	e.keys = e.keys[:start]
+	return nil
+}
+
+// appendValue appends common value types directly, falling back to
+// json.Marshal for anything else
+func (e *encodeState) appendValue(v interface{}) error {
+	switch t := v.(type) {
+	case nil:
+		e.buf = append(e.buf, "null"...)
+	case string:
+		e.buf = appendString(e.buf, t)
+	case bool:
+		e.buf = strconv.AppendBool(e.buf, t)
+	case int:
+		e.buf = strconv.AppendInt(e.buf, int64(t), 10)
+	case int8:
+		e.buf = strconv.AppendInt(e.buf, int64(t), 10)
+	case int16:
+		e.buf = strconv.AppendInt(e.buf, int64(t), 10)
+	case int32:
+		e.buf = strconv.AppendInt(e.buf, int64(t), 10)
+	case int64:
+		e.buf = strconv.AppendInt(e.buf, t, 10)
+	case uint:
+		e.buf = strconv.AppendUint(e.buf, uint64(t), 10)
+	case uint8:
+		e.buf = strconv.AppendUint(e.buf, uint64(t), 10)
+	case uint16:
+		e.buf = strconv.AppendUint(e.buf, uint64(t), 10)
+	case uint32:
+		e.buf = strconv.AppendUint(e.buf, uint64(t), 10)
+	case uint64:
+		e.buf = strconv.AppendUint(e.buf, t, 10)
+	case float32:
+		return e.appendFloat(float64(t), 32)
+	case float64:
+		return e.appendFloat(t, 64)
+	case time.Duration:
+		e.buf = strconv.AppendInt(e.buf, int64(t), 10)
+	case time.Time:
+		if y := t.Year(); y < 0 || y >= 10000 {
+			return e.appendMarshal(v)
+		}
+		e.buf = append(e.buf, '"')
+		e.buf = t.AppendFormat(e.buf, time.RFC3339Nano)
+		e.buf = append(e.buf, '"')
+	case Data:
+		if t == nil {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		return e.appendMap(t)
+	case map[string]interface{}:
+		if t == nil {
+			e.buf = append(e.buf, "null"...)
+			return nil
+		}
+		return e.appendMap(t)
+	default:
+		return e.appendMarshal(v)
+	}
+	return nil
+}
+
+func (e *encodeState) appendMarshal(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	e.buf = append(e.buf, b...)
+	return nil
+}
+
+// appendFloat formats a float the same way as encoding/json
+func (e *encodeState) appendFloat(f float64, bits int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return &json.UnsupportedValueError{Str: strconv.FormatFloat(f, 'g', -1, bits)}
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	e.buf = strconv.AppendFloat(e.buf, f, format, -1, bits)
+	if format == 'e' {
+		// clean up e-09 to e-9
+		if n := len(e.buf); n >= 4 && e.buf[n-4] == 'e' && e.buf[n-3] == '-' && e.buf[n-2] == '0' {
+			e.buf[n-2] = e.buf[n-1]
+			e.buf = e.buf[:n-1]
+		}
+	}
+	return nil
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendString appends a quoted JSON string, escaping HTML characters,
+// U+2028 and U+2029 and replacing invalid UTF-8 like encoding/json
+func appendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if c >= 0x20 && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+		} else if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hexDigits[r&0xf])
+		} else {
+			i += size
+			continue
+		}
+		i += size
+		start = i
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
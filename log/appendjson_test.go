@@ -0,0 +1,158 @@
+package log
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func benchmarkRecord() Record {
+	return Record{
+		Created:   time.Now(),
+		Event:     "request",
+		Namespace: GetNamespace(),
+		Context:   "a8f3c2d1-request",
+		Data: Data{
+			"method":         "GET",
+			"path":           "/datasets/cpih01/editions/time-series",
+			"status":         200,
+			"duration":       12 * time.Millisecond,
+			"bytes_sent":     int64(2048),
+			"bytes_received": int64(0),
+			"remote_addr":    "10.0.0.1:51234",
+		},
+	}
+}
+
+func TestAppendJSON(t *testing.T) {
+	defer func() {
+		SetTimeFormat(TimeRFC3339Nano)
+		SetNamespace(defaultNamespace)
+	}()
+
+	marshal := func(r Record) string {
+		m := r.layout()
+		b, err := json.Marshal(&m)
+		So(err, ShouldBeNil)
+		return string(b)
+	}
+	appended := func(r Record) string {
+		b, err := r.MarshalJSON()
+		So(err, ShouldBeNil)
+		return string(b)
+	}
+
+	created := time.Date(2018, 3, 1, 12, 30, 15, 123456789, time.FixedZone("", 3600))
+
+	Convey("appendRecord should match json.Marshal", t, func() {
+		records := []Record{
+			{Created: created, Event: "test", Namespace: "namespace"},
+			{Created: created, Event: "test", Namespace: "namespace", Context: "context", Data: Data{}},
+			benchmarkRecord(),
+			{Created: created, Event: "escape", Namespace: "<ns>", Data: Data{
+				"html":     "<a href=\"x\">&</a>",
+				"control":  "line\nbreak\ttab\r\b\f\x00\x1f",
+				"unicode":  "héllo ☃ \u2028\u2029",
+				"invalid":  "bad \xff utf8",
+				"back\\sl": `C:\path`,
+			}},
+			{Created: created, Event: "types", Namespace: "namespace", Data: Data{
+				"nil":      nil,
+				"bool":     true,
+				"int8":     int8(-8),
+				"uint16":   uint16(16),
+				"int32":    int32(-32),
+				"uint":     uint(7),
+				"uint64":   uint64(math.MaxUint64),
+				"float32":  float32(1.1),
+				"float64":  3.14159,
+				"small":    1e-7,
+				"large":    1e21,
+				"zero":     0.0,
+				"time":     created,
+				"error":    errors.New("failed"),
+				"slice":    []string{"a", "b"},
+				"headers":  map[string]string{"b": "2", "a": "1"},
+				"nested":   Data{"z": 1, "a": map[string]interface{}{"y": "x", "b": nil}},
+				"nil_data": Data(nil),
+				"struct":   struct{ Name string }{"name"},
+			}},
+		}
+
+		for _, r := range records {
+			So(appended(r), ShouldEqual, marshal(r))
+		}
+	})
+
+	Convey("appendRecord should match json.Marshal for each time format", t, func() {
+		r := Record{Created: created, Event: "test", Namespace: "namespace"}
+		for _, format := range []string{TimeRFC3339Nano, TimeRFC3339, TimeEpochMillis, "2006-01-02 <15:04>"} {
+			SetTimeFormat(format)
+			So(appended(r), ShouldEqual, marshal(r))
+		}
+		SetTimeFormat(TimeRFC3339Nano)
+	})
+
+	Convey("appendRecord should use the preserialized namespace", t, func() {
+		SetNamespace("dp-<api>")
+		So(string(getConfig().namespaceJSON), ShouldEqual, `"namespace":"dp-\u003capi\u003e"`)
+
+		r := Record{Created: created, Event: "test", Namespace: GetNamespace()}
+		So(appended(r), ShouldEqual, marshal(r))
+
+		r.Namespace = "other"
+		So(appended(r), ShouldEqual, marshal(r))
+	})
+
+	Convey("appendRecord should fail on unsupported values like json.Marshal", t, func() {
+		r := Record{Created: created, Event: "test", Data: Data{"nan": math.NaN()}}
+		_, err := r.MarshalJSON()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("JSONEncoder should not allocate when appending to a buffer", t, func() {
+		r := benchmarkRecord()
+		e := &encodeState{}
+		allocs := testing.AllocsPerRun(100, func() {
+			e.buf = e.buf[:0]
+			JSONEncoder{}.appendEncode(e, r)
+		})
+		So(allocs, ShouldEqual, 0)
+	})
+}
+
+// BenchmarkMarshalMap encodes events by building a map and calling
+// json.Marshal, as the JSON encoder did previously
+func BenchmarkMarshalMap(b *testing.B) {
+	r := benchmarkRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := r.envelope()
+		json.Marshal(&m)
+	}
+}
+
+func BenchmarkJSONEncoder(b *testing.B) {
+	r := benchmarkRecord()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		e := getEncodeState()
+		JSONEncoder{}.appendEncode(e, r)
+		putEncodeState(e)
+	}
+}
+
+func BenchmarkEvent(b *testing.B) {
+	SetOutput(ioutil.Discard)
+	defer SetOutput(nil)
+	data := benchmarkRecord().Data
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		event("request", "context", data)
+	}
+}
@@ -0,0 +1,249 @@
+package log
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy controls what happens when the async queue is full
+type Policy int
+
+// Available policies. Error and fatal events are never dropped; callers
+// block until there is space for them regardless of the policy.
+const (
+	// Block waits for space in the queue
+	Block Policy = iota
+	// DropNewest discards the event being logged
+	DropNewest
+	// DropOldest discards the oldest queued event which isn't an error to
+	// make space
+	DropOldest
+)
+
+// AsyncConfig configures asynchronous logging
+type AsyncConfig struct {
+	// QueueSize is the maximum number of queued events, defaults to 1024
+	QueueSize int
+	// Policy applies when the queue is full, defaults to Block
+	Policy Policy
+	// Policies override Policy for events at a level, e.g. to drop DEBUG
+	// events but block for WARN. Policies for ERROR and above are ignored.
+	Policies map[Level]Policy
+}
+
+// AsyncStats counts the outcomes of queueing events
+type AsyncStats struct {
+	// Queued is the number of events queued without waiting
+	Queued uint64
+	// Blocked is the number of events which waited for space in the queue
+	Blocked uint64
+	// Dropped is the number of events discarded because the queue was full
+	Dropped uint64
+}
+
+type queued struct {
+	w io.Writer
+	r Record
+}
+
+// asyncWriter queues events for a background goroutine, which is the only
+// one to write them, so events are written in order and writers needn't be
+// safe for concurrent use
+type asyncWriter struct {
+	policy   Policy
+	policies map[Level]Policy
+	size     int
+	done     chan struct{}
+
+	// cond is broadcast whenever the queue or pending count changes
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queue   []queued
+	closed  bool
+	pending int
+
+	queuedCount  uint64
+	blockedCount uint64
+	droppedCount uint64
+}
+
+var (
+	async      *asyncWriter
+	asyncMutex sync.RWMutex
+)
+
+// EnableAsync queues events to be serialized and written by a background
+// goroutine, so logging doesn't block on slow output or sinks. Data passed
+// to log functions mustn't be modified after logging while enabled. Use
+// Flush or DisableAsync to drain the queue before exiting.
+func EnableAsync(cfg AsyncConfig) {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+
+	DisableAsync()
+
+	policies := make(map[Level]Policy, len(cfg.Policies))
+	for l, p := range cfg.Policies {
+		policies[l] = p
+	}
+
+	a := &asyncWriter{
+		policy:   cfg.Policy,
+		policies: policies,
+		size:     cfg.QueueSize,
+		done:     make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mutex)
+	go a.run()
+
+	asyncMutex.Lock()
+	async = a
+	asyncMutex.Unlock()
+}
+
+// DisableAsync writes any queued events and returns to synchronous logging
+func DisableAsync() {
+	asyncMutex.Lock()
+	a := async
+	async = nil
+	asyncMutex.Unlock()
+
+	if a != nil {
+		a.close()
+		<-a.done
+	}
+}
+
+// GetAsyncStats returns the queueing outcomes since async logging was
+// enabled, or zero if it isn't
+func GetAsyncStats() AsyncStats {
+	asyncMutex.RLock()
+	defer asyncMutex.RUnlock()
+	if async == nil {
+		return AsyncStats{}
+	}
+	return AsyncStats{
+		Queued:  atomic.LoadUint64(&async.queuedCount),
+		Blocked: atomic.LoadUint64(&async.blockedCount),
+		Dropped: atomic.LoadUint64(&async.droppedCount),
+	}
+}
+
+// enqueue queues an event if async logging is enabled, returning false if
+// it isn't and the event should be written synchronously
+func enqueue(w io.Writer, r Record) bool {
+	asyncMutex.RLock()
+	defer asyncMutex.RUnlock()
+	if async == nil {
+		return false
+	}
+	async.enqueue(queued{w, r})
+	return true
+}
+
+func (a *asyncWriter) enqueue(q queued) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if len(a.queue) < a.size {
+		a.push(q)
+		atomic.AddUint64(&a.queuedCount, 1)
+		return
+	}
+
+	switch a.policyFor(q.r.Level()) {
+	case DropNewest:
+		atomic.AddUint64(&a.droppedCount, 1)
+		return
+	case DropOldest:
+		// errors can't be discarded, so drop the oldest event which isn't
+		// one, or wait for the writer if they all are
+		for i, old := range a.queue {
+			if old.r.Level() < ERROR {
+				a.queue = append(a.queue[:i], a.queue[i+1:]...)
+				a.pending--
+				atomic.AddUint64(&a.droppedCount, 1)
+				a.push(q)
+				atomic.AddUint64(&a.queuedCount, 1)
+				return
+			}
+		}
+	}
+
+	atomic.AddUint64(&a.blockedCount, 1)
+	for len(a.queue) >= a.size {
+		a.cond.Wait()
+	}
+	a.push(q)
+}
+
+// push adds an event to the queue, with the mutex held
+func (a *asyncWriter) push(q queued) {
+	a.queue = append(a.queue, q)
+	a.pending++
+	a.cond.Broadcast()
+}
+
+// close stops the writer once the queue is empty
+func (a *asyncWriter) close() {
+	a.mutex.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mutex.Unlock()
+}
+
+// policyFor returns the policy for events at a level, which is always Block
+// for errors
+func (a *asyncWriter) policyFor(l Level) Policy {
+	if l >= ERROR {
+		return Block
+	}
+	if p, ok := a.policies[l]; ok {
+		return p
+	}
+	return a.policy
+}
+
+func (a *asyncWriter) run() {
+	defer close(a.done)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for {
+		for len(a.queue) == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if len(a.queue) == 0 {
+			return
+		}
+
+		q := a.queue[0]
+		a.queue[0] = queued{}
+		a.queue = a.queue[1:]
+		a.cond.Broadcast()
+
+		a.mutex.Unlock()
+		emit(q.w, q.r)
+		a.mutex.Lock()
+
+		a.pending--
+		a.cond.Broadcast()
+	}
+}
+
+// drained returns a channel which is closed once all queued events have
+// been written
+func (a *asyncWriter) drained() <-chan struct{} {
+	c := make(chan struct{})
+	go func() {
+		a.mutex.Lock()
+		for a.pending > 0 {
+			a.cond.Wait()
+		}
+		a.mutex.Unlock()
+		close(c)
+	}()
+	return c
+}
@@ -0,0 +1,259 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// blockingWriter blocks writes until released
+type blockingWriter struct {
+	mutex   sync.Mutex
+	buf     bytes.Buffer
+	release chan struct{}
+	started chan struct{}
+	once    sync.Once
+}
+
+func newBlockingWriter() *blockingWriter {
+	return &blockingWriter{release: make(chan struct{}), started: make(chan struct{})}
+}
+
+func (w *blockingWriter) Write(b []byte) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	<-w.release
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Write(b)
+}
+
+func (w *blockingWriter) messages() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var messages []string
+	for _, line := range strings.Split(strings.TrimSpace(w.buf.String()), "\n") {
+		if i := strings.Index(line, `"message":"`); i >= 0 {
+			messages = append(messages, strings.SplitN(line[i+11:], `"`, 2)[0])
+		}
+	}
+	return messages
+}
+
+// concurrencyWriter records whether it's written to concurrently
+type concurrencyWriter struct {
+	writing    int32
+	concurrent bool
+}
+
+func (w *concurrencyWriter) Write(b []byte) (int, error) {
+	if !atomic.CompareAndSwapInt32(&w.writing, 0, 1) {
+		w.concurrent = true
+		return len(b), nil
+	}
+	time.Sleep(time.Microsecond)
+	atomic.StoreInt32(&w.writing, 0)
+	return len(b), nil
+}
+
+func TestAsync(t *testing.T) {
+	defer func() {
+		DisableAsync()
+		SetOutput(nil)
+	}()
+	SetHumanReadable(false)
+
+	Convey("Async logging should write events in the background", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		EnableAsync(AsyncConfig{})
+
+		Debug("one", nil)
+		Debug("two", nil)
+		So(Flush(time.Second), ShouldBeNil)
+		So(strings.Count(buf.String(), "\n"), ShouldEqual, 2)
+		So(GetAsyncStats(), ShouldResemble, AsyncStats{Queued: 2})
+
+		DisableAsync()
+		So(GetAsyncStats(), ShouldResemble, AsyncStats{})
+	})
+
+	Convey("DropNewest should discard new events when the queue is full", t, func() {
+		w := newBlockingWriter()
+		SetOutput(w)
+		EnableAsync(AsyncConfig{QueueSize: 1, Policy: DropNewest})
+
+		Debug("first", nil)
+		<-w.started
+		Debug("queued", nil)
+		Debug("dropped", nil)
+		So(GetAsyncStats().Dropped, ShouldEqual, 1)
+
+		close(w.release)
+		DisableAsync()
+		So(w.messages(), ShouldResemble, []string{"first", "queued"})
+	})
+
+	Convey("DropOldest should discard queued events when the queue is full", t, func() {
+		w := newBlockingWriter()
+		SetOutput(w)
+		EnableAsync(AsyncConfig{QueueSize: 1, Policy: DropOldest})
+
+		Debug("first", nil)
+		<-w.started
+		Debug("dropped", nil)
+		Debug("queued", nil)
+		So(GetAsyncStats().Dropped, ShouldEqual, 1)
+
+		close(w.release)
+		DisableAsync()
+		So(w.messages(), ShouldResemble, []string{"first", "queued"})
+	})
+
+	Convey("DropOldest should wait instead of discarding queued errors", t, func() {
+		w := newBlockingWriter()
+		SetOutput(w)
+		EnableAsync(AsyncConfig{QueueSize: 1, Policy: DropOldest})
+
+		Debug("first", nil)
+		<-w.started
+		Error(errors.New("queued"), nil)
+
+		done := make(chan struct{})
+		go func() {
+			Debug("blocked", nil)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("event should block while an error is queued")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(w.release)
+		<-done
+		So(GetAsyncStats().Dropped, ShouldEqual, 0)
+		DisableAsync()
+		So(w.messages(), ShouldResemble, []string{"first", "queued", "blocked"})
+	})
+
+	Convey("DropOldest should skip queued errors and keep events in order", t, func() {
+		w := newBlockingWriter()
+		SetOutput(w)
+		EnableAsync(AsyncConfig{QueueSize: 2, Policy: DropOldest})
+
+		Debug("first", nil)
+		<-w.started
+		Error(errors.New("error"), nil)
+		Debug("dropped", nil)
+		Debug("queued", nil)
+		So(GetAsyncStats().Dropped, ShouldEqual, 1)
+
+		close(w.release)
+		DisableAsync()
+		So(w.messages(), ShouldResemble, []string{"first", "error", "queued"})
+	})
+
+	Convey("Events should only be written by the background goroutine", t, func() {
+		w := &concurrencyWriter{}
+		SetOutput(w)
+		EnableAsync(AsyncConfig{QueueSize: 1, Policy: DropOldest})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 50; j++ {
+					Debug("debug", nil)
+					Error(errors.New("error"), nil)
+				}
+			}()
+		}
+		wg.Wait()
+		DisableAsync()
+		So(w.concurrent, ShouldBeFalse)
+	})
+
+	Convey("Errors should never be dropped", t, func() {
+		w := newBlockingWriter()
+		SetOutput(w)
+		EnableAsync(AsyncConfig{QueueSize: 1, Policy: DropNewest})
+
+		Debug("first", nil)
+		<-w.started
+		Error(errors.New("queued"), nil)
+
+		done := make(chan struct{})
+		go func() {
+			Error(errors.New("blocked"), nil)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("error event should block while the queue is full")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(w.release)
+		<-done
+		DisableAsync()
+		So(w.messages(), ShouldResemble, []string{"first", "queued", "blocked"})
+	})
+
+	Convey("Policies should override the policy by level", t, func() {
+		w := newBlockingWriter()
+		SetOutput(w)
+		EnableAsync(AsyncConfig{QueueSize: 1, Policy: Block, Policies: map[Level]Policy{DEBUG: DropNewest, ERROR: DropNewest}})
+
+		Info("first", nil)
+		<-w.started
+		Info("queued", nil)
+		Debug("dropped", nil)
+		So(GetAsyncStats().Dropped, ShouldEqual, 1)
+
+		done := make(chan struct{})
+		go func() {
+			Warn("blocked", nil)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("warning should block with the default policy")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(w.release)
+		<-done
+		DisableAsync()
+		So(w.messages(), ShouldResemble, []string{"first", "queued", "blocked"})
+		So(GetAsyncStats(), ShouldResemble, AsyncStats{})
+	})
+
+	Convey("Policies should not allow errors to be dropped", t, func() {
+		a := &asyncWriter{policy: DropOldest, policies: map[Level]Policy{ERROR: DropNewest, FATAL: DropOldest}}
+		So(a.policyFor(ERROR), ShouldEqual, Block)
+		So(a.policyFor(FATAL), ShouldEqual, Block)
+		So(a.policyFor(INFO), ShouldEqual, DropOldest)
+	})
+
+	Convey("Flush should time out if the queue can't be drained", t, func() {
+		w := newBlockingWriter()
+		SetOutput(w)
+		EnableAsync(AsyncConfig{})
+
+		Debug("stuck", nil)
+		So(Flush(10*time.Millisecond), ShouldEqual, ErrFlushTimeout)
+
+		close(w.release)
+		So(Flush(time.Second), ShouldBeNil)
+	})
+}
@@ -0,0 +1,64 @@
+package log
+
+import (
+	"net/http"
+
+	"github.com/ONSdigital/go-ns/baggage"
+)
+
+// BaggageKeys lists the W3C baggage entries which are added to events.
+//
+// Deprecated: use SetBaggageKeys. BaggageKeys is read once, when the first
+// event is logged.
+var BaggageKeys []string
+
+// SetBaggageKeys sets the W3C baggage entries which are added to the
+// "baggage" field of request events and events logged with a request
+func SetBaggageKeys(keys []string) {
+	keys = copyStrings(keys)
+	updateConfig(func(c *config) {
+		c.baggageKeys = keys
+	})
+}
+
+// withBaggage adds the selected baggage entries from a request to data
+func withBaggage(req *http.Request, data Data) Data {
+	if len(getConfig().baggageKeys) == 0 {
+		return data
+	}
+
+	b := baggage.FromContext(req.Context())
+	if b.Len() == 0 {
+		if header := req.Header.Get(baggage.Header); len(header) > 0 {
+			b, _ = baggage.Parse(header)
+		}
+	}
+
+	entries := selectBaggage(b)
+	if entries == nil {
+		return data
+	}
+
+	if data == nil {
+		data = Data{}
+	}
+	if _, ok := data["baggage"]; !ok {
+		data["baggage"] = entries
+	}
+	return data
+}
+
+// selectBaggage returns the entries listed by SetBaggageKeys, or nil if
+// there are none
+func selectBaggage(b baggage.Baggage) map[string]string {
+	var entries map[string]string
+	for _, key := range getConfig().baggageKeys {
+		if value, ok := b.Get(key); ok {
+			if entries == nil {
+				entries = map[string]string{}
+			}
+			entries[key] = value
+		}
+	}
+	return entries
+}
@@ -0,0 +1,55 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/baggage"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBaggage(t *testing.T) {
+	defer func() {
+		SetEvent(nil)
+		SetBaggageKeys(nil)
+	}()
+
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventData = data
+	})
+
+	Convey("Request events should include selected baggage entries", t, func() {
+		SetBaggageKeys([]string{"tenant", "missing"})
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set(baggage.Header, "tenant=ons,secret=x")
+		Handler(dummyHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(eventData["baggage"], ShouldResemble, map[string]string{"tenant": "ons"})
+	})
+
+	Convey("Request scoped events should use baggage from the request context", t, func() {
+		SetBaggageKeys([]string{"tenant"})
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req = req.WithContext(baggage.Set(req.Context(), "tenant", "ons"))
+		DebugR(req, "test", nil)
+
+		So(eventData["baggage"], ShouldResemble, map[string]string{"tenant": "ons"})
+	})
+
+	Convey("Baggage should not be added when no keys are selected", t, func() {
+		SetBaggageKeys(nil)
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set(baggage.Header, "tenant=ons")
+		TraceR(req, "test", nil)
+
+		So(eventData, ShouldNotContainKey, "baggage")
+	})
+}
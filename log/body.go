@@ -0,0 +1,57 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// defaultMaxBodyBytes is the default cap on captured request bodies
+const defaultMaxBodyBytes = 4096
+
+// capturedBody is the start of a request body read by captureBody
+type capturedBody struct {
+	data      []byte
+	truncated bool
+}
+
+// replayBody replays the captured start of a request body, then reads the
+// rest from the original body
+type replayBody struct {
+	io.Reader
+	io.Closer
+}
+
+// captureBody reads up to max bytes of a request body, and replaces it with
+// one which replays them before reading the rest of the original. Only the
+// captured bytes are held in memory, however large the body is.
+func captureBody(req *http.Request, max int) *capturedBody {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+
+	b, _ := io.ReadAll(io.LimitReader(req.Body, int64(max)+1))
+	req.Body = replayBody{io.MultiReader(bytes.NewReader(b), req.Body), req.Body}
+
+	if len(b) > max {
+		return &capturedBody{data: b[:max], truncated: true}
+	}
+	return &capturedBody{data: b}
+}
+
+// value returns the captured body for the request event. Complete JSON
+// bodies are decoded, so their sensitive keys are redacted like any other
+// event data; other bodies are logged as text.
+func (c *capturedBody) value(req *http.Request) interface{} {
+	if !c.truncated {
+		if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "application/json" {
+			var v interface{}
+			if err := json.Unmarshal(c.data, &v); err == nil {
+				return v
+			}
+		}
+	}
+	return string(c.data)
+}
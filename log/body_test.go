@@ -0,0 +1,85 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCaptureBody(t *testing.T) {
+	defer SetEvent(nil)
+
+	var events []Data
+	SetEvent(func(name string, context string, data Data) {
+		if name == "request" {
+			events = append(events, data)
+		}
+	})
+
+	Convey("Captured JSON bodies should still be decoded by the handler", t, func() {
+		events = nil
+		var decoded map[string]interface{}
+		h := HandlerWithOptions(HandlerOptions{CaptureBody: true})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			So(json.NewDecoder(req.Body).Decode(&decoded), ShouldBeNil)
+		}))
+
+		req := httptest.NewRequest("POST", "/datasets", strings.NewReader(`{"title":"CPIH","password":"secret"}`))
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(decoded["title"], ShouldEqual, "CPIH")
+		So(events, ShouldHaveLength, 1)
+		So(events[0], ShouldNotContainKey, "request_body_truncated")
+
+		data := redact(events[0])
+		So(data["request_body"], ShouldResemble, map[string]interface{}{"title": "CPIH", "password": Redacted})
+	})
+
+	Convey("Bodies over the cap should be truncated in the event but not for the handler", t, func() {
+		events = nil
+		body := strings.Repeat("a", 100)
+		var read string
+		h := HandlerWithOptions(HandlerOptions{CaptureBody: true, MaxBodyBytes: 10})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			b, err := io.ReadAll(req.Body)
+			So(err, ShouldBeNil)
+			read = string(b)
+		}))
+
+		req := httptest.NewRequest("POST", "/datasets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(read, ShouldEqual, body)
+		So(events, ShouldHaveLength, 1)
+		So(events[0]["request_body"], ShouldEqual, "aaaaaaaaaa")
+		So(events[0]["request_body_truncated"], ShouldBeTrue)
+	})
+
+	Convey("Other bodies should be captured as text", t, func() {
+		events = nil
+		h := HandlerWithOptions(HandlerOptions{CaptureBody: true})(dummyHandler)
+
+		req := httptest.NewRequest("POST", "/datasets", strings.NewReader("title=CPIH"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(events, ShouldHaveLength, 1)
+		So(events[0]["request_body"], ShouldEqual, "title=CPIH")
+	})
+
+	Convey("Bodies should not be captured by default or when they're empty", t, func() {
+		events = nil
+		req := httptest.NewRequest("POST", "/datasets", strings.NewReader("title=CPIH"))
+		Handler(dummyHandler).ServeHTTP(httptest.NewRecorder(), req)
+		HandlerWithOptions(HandlerOptions{CaptureBody: true})(dummyHandler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+		So(events, ShouldHaveLength, 2)
+		So(events[0], ShouldNotContainKey, "request_body")
+		So(events[1], ShouldNotContainKey, "request_body")
+	})
+}
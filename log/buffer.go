@@ -0,0 +1,95 @@
+package log
+
+import (
+	"bufio"
+	"time"
+)
+
+// BufferConfig configures buffered output
+type BufferConfig struct {
+	// Size is the buffer size in bytes, defaults to 64KB
+	Size int
+	// FlushInterval is the maximum time events are buffered, defaults to 1s
+	FlushInterval time.Duration
+}
+
+type outputBuffer struct {
+	*bufio.Writer
+	stop chan struct{}
+	done chan struct{}
+}
+
+// buffer is guarded by outputMutex
+var buffer *outputBuffer
+
+// EnableBuffering buffers the output, reducing the number of writes under
+// high event rates. Buffered events are written when the buffer is full,
+// every FlushInterval, and by Flush, Close and the Fatal and Panic
+// functions, so call Flush or Close before exiting.
+func EnableBuffering(cfg BufferConfig) {
+	if cfg.Size <= 0 {
+		cfg.Size = 64 << 10
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+
+	DisableBuffering()
+
+	b := &outputBuffer{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	outputMutex.Lock()
+	b.Writer = bufio.NewWriterSize(orStdout(output), cfg.Size)
+	buffer = b
+	outputMutex.Unlock()
+
+	go b.run(cfg.FlushInterval)
+}
+
+// DisableBuffering writes any buffered events and returns to writing each
+// event as it's logged
+func DisableBuffering() {
+	outputMutex.Lock()
+	b := buffer
+	buffer = nil
+	if b != nil {
+		b.Flush()
+	}
+	outputMutex.Unlock()
+
+	if b != nil {
+		close(b.stop)
+		<-b.done
+	}
+}
+
+// flushOutput writes any buffered events
+func flushOutput() error {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	if buffer == nil {
+		return nil
+	}
+	return buffer.Flush()
+}
+
+func (b *outputBuffer) run(interval time.Duration) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+			outputMutex.Lock()
+			b.Flush()
+			outputMutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuffering(t *testing.T) {
+	SetHumanReadable(false)
+	defer func() {
+		DisableBuffering()
+		SetOutput(nil)
+	}()
+
+	Convey("EnableBuffering should hold events until Flush", t, func() {
+		out := &lockedBuffer{}
+		SetOutput(out)
+		EnableBuffering(BufferConfig{FlushInterval: time.Hour})
+		defer DisableBuffering()
+
+		Debug("buffered", nil)
+		out.mutex.Lock()
+		So(out.buf.Len(), ShouldEqual, 0)
+		out.mutex.Unlock()
+
+		So(Flush(time.Second), ShouldBeNil)
+		out.mutex.Lock()
+		So(out.buf.String(), ShouldContainSubstring, `"message":"buffered"`)
+		out.mutex.Unlock()
+	})
+
+	Convey("buffered events should be written when the buffer is full", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		EnableBuffering(BufferConfig{Size: 256, FlushInterval: time.Hour})
+		defer DisableBuffering()
+
+		for i := 0; i < 10; i++ {
+			Debug("filling the buffer", nil)
+		}
+		outputMutex.Lock()
+		So(buf.Len(), ShouldBeGreaterThan, 0)
+		outputMutex.Unlock()
+	})
+
+	Convey("buffered events should be written every FlushInterval", t, func() {
+		out := &lockedBuffer{}
+		SetOutput(out)
+		EnableBuffering(BufferConfig{FlushInterval: 10 * time.Millisecond})
+		defer DisableBuffering()
+
+		Debug("interval", nil)
+
+		deadline := time.Now().Add(time.Second)
+		written := false
+		for !written && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+			out.mutex.Lock()
+			written = out.buf.Len() > 0
+			out.mutex.Unlock()
+		}
+		So(written, ShouldBeTrue)
+	})
+
+	Convey("SetOutput should flush buffered events to the previous writer", t, func() {
+		var first, second bytes.Buffer
+		SetOutput(&first)
+		EnableBuffering(BufferConfig{FlushInterval: time.Hour})
+		defer DisableBuffering()
+
+		Debug("first", nil)
+		SetOutput(&second)
+		Debug("second", nil)
+		DisableBuffering()
+
+		So(first.String(), ShouldContainSubstring, `"message":"first"`)
+		So(first.String(), ShouldNotContainSubstring, `"message":"second"`)
+		So(second.String(), ShouldContainSubstring, `"message":"second"`)
+	})
+
+	Convey("Fatal should write buffered events before exiting", t, func() {
+		defer SetExitFunc(nil)
+
+		var buf bytes.Buffer
+		var exited string
+		SetExitFunc(func(code int) {
+			exited = buf.String()
+		})
+
+		SetOutput(&buf)
+		EnableBuffering(BufferConfig{FlushInterval: time.Hour})
+		defer DisableBuffering()
+
+		Debug("before", nil)
+		Fatal(errors.New("fatal"), nil)
+
+		So(strings.Count(exited, "\n"), ShouldEqual, 2)
+		So(exited, ShouldContainSubstring, `"severity":"fatal"`)
+	})
+
+	Convey("Close should write buffered events and disable buffering", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		EnableBuffering(BufferConfig{FlushInterval: time.Hour})
+
+		Debug("closing", nil)
+		So(Close(), ShouldBeNil)
+		So(buf.String(), ShouldContainSubstring, `"message":"closing"`)
+
+		outputMutex.Lock()
+		So(buffer, ShouldBeNil)
+		outputMutex.Unlock()
+	})
+}
+
+func BenchmarkBufferedEvent(b *testing.B) {
+	SetOutput(ioutil.Discard)
+	EnableBuffering(BufferConfig{})
+	defer func() {
+		DisableBuffering()
+		SetOutput(nil)
+	}()
+
+	data := benchmarkRecord().Data
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		event("request", "context", data)
+	}
+}
@@ -0,0 +1,76 @@
+package log
+
+import (
+	"os"
+	"strconv"
+)
+
+// IncludeCaller, if true, adds the source of each log call to events.
+//
+// Deprecated: use SetIncludeCaller. IncludeCaller is read once, when the
+// first event is logged.
+var IncludeCaller bool
+
+// CallerSkip is the number of additional frames to skip when finding the
+// caller.
+//
+// Deprecated: use SetCallerSkip. CallerSkip is read once, when the first
+// event is logged.
+var CallerSkip int
+
+// SetIncludeCaller, if true, adds the file, line and function of each log
+// call to the "source" field of the event. It defaults to LOG_CALLER.
+func SetIncludeCaller(includeCaller bool) {
+	updateConfig(func(c *config) {
+		c.includeCaller = includeCaller
+	})
+}
+
+// GetIncludeCaller reports whether the source of log calls is added to events
+func GetIncludeCaller() bool {
+	return getConfig().includeCaller
+}
+
+// SetCallerSkip sets the number of additional frames to skip when finding
+// the caller, for applications which wrap the log functions in their own
+// helpers. Frames inside the log package are always skipped.
+func SetCallerSkip(skip int) {
+	updateConfig(func(c *config) {
+		c.callerSkip = skip
+	})
+}
+
+// Source is the location of a log call
+type Source struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Function string `json:"function"`
+}
+
+func configureCaller() {
+	includeCaller, _ := strconv.ParseBool(os.Getenv("LOG_CALLER"))
+	SetIncludeCaller(includeCaller)
+}
+
+// withCaller adds the source of the log call to data
+func withCaller(data Data) Data {
+	c := getConfig()
+	if !c.includeCaller {
+		return data
+	}
+	if _, ok := data["source"]; ok {
+		return data
+	}
+
+	frames := callerFrames(c.callerSkip + 1)
+	if len(frames) <= c.callerSkip {
+		return data
+	}
+	f := frames[c.callerSkip]
+
+	if data == nil {
+		data = Data{}
+	}
+	data["source"] = Source{File: f.File, Line: f.Line, Function: f.Function}
+	return data
+}
@@ -0,0 +1,87 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// wrappedDebug is an application helper wrapping the log functions
+func wrappedDebug(message string) {
+	Debug(message, nil)
+}
+
+func TestCaller(t *testing.T) {
+	defer func() {
+		os.Unsetenv("LOG_CALLER")
+		configureCaller()
+		SetCallerSkip(0)
+		SetMode(DefaultMode)
+		SetOutput(nil)
+	}()
+
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	SetHumanReadable(false)
+
+	source := func() map[string]interface{} {
+		defer buf.Reset()
+		var m map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &m), ShouldBeNil)
+		src, _ := m["data"].(map[string]interface{})["source"].(map[string]interface{})
+		return src
+	}
+
+	Convey("LOG_CALLER environment variable should configure caller annotation", t, func() {
+		So(GetIncludeCaller(), ShouldBeFalse)
+
+		os.Setenv("LOG_CALLER", "true")
+		configureCaller()
+		So(GetIncludeCaller(), ShouldBeTrue)
+	})
+
+	Convey("Events should include the source of the log call", t, func() {
+		SetIncludeCaller(true)
+		Debug("test", nil)
+		src := source()
+		So(src["file"], ShouldEndWith, "caller_test.go")
+		So(src["line"], ShouldBeGreaterThan, 0)
+		So(src["function"], ShouldStartWith, "github.com/ONSdigital/go-ns/log.TestCaller.func")
+	})
+
+	Convey("CallerSkip should skip application wrappers", t, func() {
+		SetIncludeCaller(true)
+
+		wrappedDebug("test")
+		So(source()["function"], ShouldEqual, "github.com/ONSdigital/go-ns/log.wrappedDebug")
+
+		SetCallerSkip(1)
+		wrappedDebug("test")
+		So(source()["function"], ShouldStartWith, "github.com/ONSdigital/go-ns/log.TestCaller.func")
+		SetCallerSkip(0)
+	})
+
+	Convey("Events should not include the source unless enabled", t, func() {
+		SetIncludeCaller(false)
+		Debug("test", nil)
+		So(source(), ShouldBeNil)
+	})
+
+	Convey("GCPMode should use the Cloud Logging source location field", t, func() {
+		SetIncludeCaller(true)
+		SetMode(GCPMode)
+		Debug("test", nil)
+		SetMode(DefaultMode)
+
+		var m map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &m), ShouldBeNil)
+		buf.Reset()
+		loc := m["logging.googleapis.com/sourceLocation"].(map[string]interface{})
+		So(loc["file"], ShouldEndWith, "caller_test.go")
+		So(loc["line"], ShouldHaveSameTypeAs, "")
+		So(m, ShouldNotContainKey, "data")
+	})
+}
@@ -0,0 +1,408 @@
+// Package cloudwatch provides a log.Sink which ships events to AWS CloudWatch Logs
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// PutLogEvents limits, see
+// https://docs.aws.amazon.com/AmazonCloudWatchLogs/latest/APIReference/API_PutLogEvents.html
+const (
+	maxBatchEvents = 10000
+	maxBatchBytes  = 1048576
+	maxEventBytes  = 262144
+	eventOverhead  = 26
+	maxBatchSpan   = 24 * time.Hour
+)
+
+// truncated is appended to events cut short to fit maxEventBytes
+const truncated = "...(truncated)"
+
+// Config configures a CloudWatch Logs sink
+type Config struct {
+	LogGroup  string
+	LogStream string
+
+	// Region and Credentials are resolved from the default AWS chain if not
+	// set, i.e. environment variables, shared config files, web identity
+	// tokens and the ECS or EC2 instance roles
+	Region      string
+	Credentials aws.CredentialsProvider
+
+	// Endpoint overrides the regional CloudWatch Logs endpoint
+	Endpoint string
+	// FlushInterval is the maximum time events are buffered, defaults to 5s
+	FlushInterval time.Duration
+	// MaxRetries is the number of retries on throttling or server errors, defaults to 5
+	MaxRetries int
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Encoder formats each event, defaults to log.JSONEncoder
+	Encoder log.Encoder
+}
+
+// Sink buffers log events and ships them to CloudWatch Logs in batches
+type Sink struct {
+	cfg    Config
+	signer *v4.Signer
+
+	mutex       sync.Mutex
+	buffer      []inputLogEvent
+	bufferBytes int
+	err         error
+
+	sendMutex     sync.Mutex
+	sequenceToken *string
+
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type inputLogEvent struct {
+	Message   string `json:"message"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type apiError struct {
+	Type                  string  `json:"__type"`
+	Message               string  `json:"message"`
+	ExpectedSequenceToken *string `json:"expectedSequenceToken"`
+	status                int
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("cloudwatch: %s (%d): %s", e.Type, e.status, e.Message)
+}
+
+func (e *apiError) is(name string) bool {
+	return e.Type == name || e.Type == "com.amazonaws.logs#"+name
+}
+
+func (e *apiError) retryable() bool {
+	return e.status >= 500 || e.is("ThrottlingException") || e.is("ServiceUnavailableException")
+}
+
+// loadDefaultConfig resolves the region and credentials from the default AWS chain
+var loadDefaultConfig = func(ctx context.Context, region string) (aws.Config, error) {
+	return config.LoadDefaultConfig(ctx, config.WithRegion(region))
+}
+
+// New creates the log group and stream if they don't already exist, and
+// returns a Sink which starts shipping events in the background
+func New(cfg Config) (*Sink, error) {
+	if len(cfg.LogGroup) == 0 || len(cfg.LogStream) == 0 {
+		return nil, errors.New("cloudwatch: log group and log stream are required")
+	}
+	if len(cfg.Region) == 0 || cfg.Credentials == nil {
+		awsCfg, err := loadDefaultConfig(context.Background(), cfg.Region)
+		if err != nil {
+			return nil, fmt.Errorf("cloudwatch: loading AWS config: %v", err)
+		}
+		if len(cfg.Region) == 0 {
+			cfg.Region = awsCfg.Region
+		}
+		if cfg.Credentials == nil {
+			cfg.Credentials = awsCfg.Credentials
+		}
+	}
+	if len(cfg.Region) == 0 {
+		return nil, errors.New("cloudwatch: region is required")
+	}
+	if cfg.Credentials == nil {
+		return nil, errors.New("cloudwatch: credentials are required")
+	}
+	if len(cfg.Endpoint) == 0 {
+		cfg.Endpoint = "https://logs." + cfg.Region + ".amazonaws.com"
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 5
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Encoder == nil {
+		cfg.Encoder = log.JSONEncoder{}
+	}
+
+	s := &Sink{
+		cfg:    cfg,
+		signer: v4.NewSigner(),
+		flush:  make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	if err := s.create("CreateLogGroup", map[string]string{"logGroupName": cfg.LogGroup}); err != nil {
+		return nil, err
+	}
+	if err := s.create("CreateLogStream", map[string]string{"logGroupName": cfg.LogGroup, "logStreamName": cfg.LogStream}); err != nil {
+		return nil, err
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// Write buffers an event, returning any error from a previous background flush
+func (s *Sink) Write(r log.Record) error {
+	b, err := s.cfg.Encoder.Encode(r)
+	if err != nil {
+		return err
+	}
+	b = bytes.TrimRight(b, "\n")
+	if len(b)+eventOverhead > maxEventBytes {
+		b = truncate(b, maxEventBytes-eventOverhead)
+	}
+
+	s.mutex.Lock()
+	s.buffer = append(s.buffer, inputLogEvent{
+		Message:   string(b),
+		Timestamp: r.Created.UnixNano() / int64(time.Millisecond),
+	})
+	s.bufferBytes += len(b) + eventOverhead
+	full := len(s.buffer) >= maxBatchEvents || s.bufferBytes >= maxBatchBytes
+
+	err, s.err = s.err, nil
+	s.mutex.Unlock()
+
+	if full {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return err
+}
+
+// truncate cuts b to at most n bytes on a rune boundary, ending with the
+// truncated marker
+func truncate(b []byte, n int) []byte {
+	n -= len(truncated)
+	for n > 0 && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return append(b[:n:n], truncated...)
+}
+
+// Close stops the background goroutine and flushes any buffered events. It
+// returns the same error if it's called again.
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+
+		err := s.Flush()
+
+		s.mutex.Lock()
+		if err == nil {
+			err = s.err
+		}
+		s.err = nil
+		s.mutex.Unlock()
+
+		s.closeErr = err
+	})
+	return s.closeErr
+}
+
+// Flush sends all buffered events to CloudWatch Logs
+func (s *Sink) Flush() error {
+	s.mutex.Lock()
+	events := s.buffer
+	s.buffer = nil
+	s.bufferBytes = 0
+	s.mutex.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+
+	// events in a batch must be in chronological order
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp < events[j].Timestamp
+	})
+
+	for len(events) > 0 {
+		n := batchSize(events)
+		if err := s.putLogEvents(events[:n]); err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+func (s *Sink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		case <-s.flush:
+		}
+
+		if err := s.Flush(); err != nil {
+			s.mutex.Lock()
+			s.err = err
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// batchSize returns the number of events from the start of a sorted slice
+// which fit within the PutLogEvents limits
+func batchSize(events []inputLogEvent) int {
+	size := 0
+	for i, e := range events {
+		size += len(e.Message) + eventOverhead
+		if i >= maxBatchEvents || size > maxBatchBytes ||
+			time.Duration(e.Timestamp-events[0].Timestamp)*time.Millisecond >= maxBatchSpan {
+			return i
+		}
+	}
+	return len(events)
+}
+
+func (s *Sink) putLogEvents(events []inputLogEvent) error {
+	for {
+		req := map[string]interface{}{
+			"logGroupName":  s.cfg.LogGroup,
+			"logStreamName": s.cfg.LogStream,
+			"logEvents":     events,
+		}
+		if s.sequenceToken != nil {
+			req["sequenceToken"] = *s.sequenceToken
+		}
+
+		var resp struct {
+			NextSequenceToken *string `json:"nextSequenceToken"`
+		}
+		err := s.call("PutLogEvents", req, &resp)
+		if err == nil {
+			s.sequenceToken = resp.NextSequenceToken
+			return nil
+		}
+
+		apiErr, ok := err.(*apiError)
+		if !ok {
+			return err
+		}
+		switch {
+		case apiErr.is("DataAlreadyAcceptedException"):
+			s.sequenceToken = apiErr.ExpectedSequenceToken
+			return nil
+		case apiErr.is("InvalidSequenceTokenException") && apiErr.ExpectedSequenceToken != nil:
+			s.sequenceToken = apiErr.ExpectedSequenceToken
+		default:
+			return err
+		}
+	}
+}
+
+// create calls a Create* action, ignoring errors for resources which already exist
+func (s *Sink) create(action string, req interface{}) error {
+	err := s.call(action, req, nil)
+	if apiErr, ok := err.(*apiError); ok && apiErr.is("ResourceAlreadyExistsException") {
+		return nil
+	}
+	return err
+}
+
+// call makes a signed request to the CloudWatch Logs API, retrying with
+// exponential backoff on throttling and server errors
+func (s *Sink) call(action string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err = s.do(action, body, out)
+		apiErr, ok := err.(*apiError)
+		if err == nil || !ok || !apiErr.retryable() || attempt >= s.cfg.MaxRetries {
+			return err
+		}
+
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+		backoff *= 2
+	}
+}
+
+func (s *Sink) do(action string, body []byte, out interface{}) error {
+	ctx := context.Background()
+
+	req, err := http.NewRequest("POST", s.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+
+	creds, err := s.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return err
+	}
+
+	hash := sha256.Sum256(body)
+	if err = s.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "logs", s.cfg.Region, time.Now()); err != nil {
+		return err
+	}
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &apiError{status: resp.StatusCode}
+		json.Unmarshal(b, apiErr)
+		return apiErr
+	}
+
+	if out != nil && len(b) > 0 {
+		return json.Unmarshal(b, out)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testCredentials = aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+	return aws.Credentials{AccessKeyID: "key", SecretAccessKey: "secret"}, nil
+})
+
+type fakeCloudWatch struct {
+	mutex    sync.Mutex
+	actions  []string
+	batches  [][]inputLogEvent
+	tokens   []string
+	throttle int
+	failWith map[string]string
+}
+
+func (f *fakeCloudWatch) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	action := strings.TrimPrefix(req.Header.Get("X-Amz-Target"), "Logs_20140328.")
+	f.actions = append(f.actions, action)
+
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if f.throttle > 0 {
+		f.throttle--
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"ThrottlingException","message":"Rate exceeded"}`))
+		return
+	}
+
+	if t, ok := f.failWith[action]; ok {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"` + t + `","message":"failed","expectedSequenceToken":"expected"}`))
+		delete(f.failWith, action)
+		return
+	}
+
+	if action == "PutLogEvents" {
+		var in struct {
+			LogEvents     []inputLogEvent `json:"logEvents"`
+			SequenceToken string          `json:"sequenceToken"`
+		}
+		json.NewDecoder(req.Body).Decode(&in)
+		f.batches = append(f.batches, in.LogEvents)
+		f.tokens = append(f.tokens, in.SequenceToken)
+		w.Write([]byte(`{"nextSequenceToken":"next"}`))
+		return
+	}
+
+	w.Write([]byte(`{}`))
+}
+
+func newTestSink(f *fakeCloudWatch) (*Sink, *httptest.Server, error) {
+	server := httptest.NewServer(f)
+	s, err := New(Config{
+		Region:        "eu-west-1",
+		LogGroup:      "group",
+		LogStream:     "stream",
+		Credentials:   testCredentials,
+		Endpoint:      server.URL,
+		FlushInterval: time.Hour,
+	})
+	return s, server, err
+}
+
+func TestNew(t *testing.T) {
+	oldLoadDefaultConfig := loadDefaultConfig
+	defer func() {
+		loadDefaultConfig = oldLoadDefaultConfig
+	}()
+
+	Convey("New should require configuration", t, func() {
+		_, err := New(Config{})
+		So(err, ShouldNotBeNil)
+
+		loadDefaultConfig = func(ctx context.Context, region string) (aws.Config, error) {
+			return aws.Config{}, nil
+		}
+		_, err = New(Config{LogGroup: "group", LogStream: "stream"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "region is required")
+
+		_, err = New(Config{Region: "eu-west-1", LogGroup: "group", LogStream: "stream"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "credentials are required")
+	})
+
+	Convey("New should resolve the region and credentials from the default chain", t, func() {
+		var requestedRegion string
+		loadDefaultConfig = func(ctx context.Context, region string) (aws.Config, error) {
+			requestedRegion = region
+			return aws.Config{Region: "eu-west-2", Credentials: testCredentials}, nil
+		}
+
+		f := &fakeCloudWatch{}
+		server := httptest.NewServer(f)
+		defer server.Close()
+
+		s, err := New(Config{LogGroup: "group", LogStream: "stream", Endpoint: server.URL})
+		So(err, ShouldBeNil)
+		So(requestedRegion, ShouldBeEmpty)
+		So(s.cfg.Region, ShouldEqual, "eu-west-2")
+		So(s.Close(), ShouldBeNil)
+		So(f.actions, ShouldResemble, []string{"CreateLogGroup", "CreateLogStream"})
+	})
+
+	Convey("New should return errors loading the default chain", t, func() {
+		loadDefaultConfig = func(ctx context.Context, region string) (aws.Config, error) {
+			return aws.Config{}, errors.New("no shared config")
+		}
+		_, err := New(Config{Region: "eu-west-1", LogGroup: "group", LogStream: "stream"})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "no shared config")
+	})
+
+	Convey("New should create the log group and stream", t, func() {
+		f := &fakeCloudWatch{}
+		s, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		So(f.actions, ShouldResemble, []string{"CreateLogGroup", "CreateLogStream"})
+	})
+
+	Convey("New should ignore resources which already exist", t, func() {
+		f := &fakeCloudWatch{failWith: map[string]string{
+			"CreateLogGroup":  "ResourceAlreadyExistsException",
+			"CreateLogStream": "ResourceAlreadyExistsException",
+		}}
+		s, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+	})
+
+	Convey("New should return other errors", t, func() {
+		f := &fakeCloudWatch{failWith: map[string]string{"CreateLogGroup": "AccessDeniedException"}}
+		_, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "AccessDeniedException")
+	})
+}
+
+func TestSink(t *testing.T) {
+	Convey("Close should flush buffered events in chronological order", t, func() {
+		f := &fakeCloudWatch{}
+		s, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		So(s.Write(log.Record{Created: now, Event: "second", Namespace: "namespace"}), ShouldBeNil)
+		So(s.Write(log.Record{Created: now.Add(-time.Second), Event: "first", Namespace: "namespace"}), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		So(f.batches, ShouldHaveLength, 1)
+		So(f.batches[0], ShouldHaveLength, 2)
+		So(f.batches[0][0].Message, ShouldContainSubstring, `"event":"first"`)
+		So(f.batches[0][1].Message, ShouldContainSubstring, `"event":"second"`)
+	})
+
+	Convey("Close should be safe to call more than once", t, func() {
+		f := &fakeCloudWatch{}
+		s, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldBeNil)
+
+		So(s.Close(), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+	})
+
+	Convey("Write should truncate oversized events on a rune boundary", t, func() {
+		f := &fakeCloudWatch{}
+		s, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldBeNil)
+
+		So(s.Write(log.Record{Created: time.Now(), Event: strings.Repeat("é", maxEventBytes), Namespace: "namespace"}), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		So(f.batches, ShouldHaveLength, 1)
+		message := f.batches[0][0].Message
+		So(len(message)+eventOverhead, ShouldBeLessThanOrEqualTo, maxEventBytes)
+		So(utf8.ValidString(message), ShouldBeTrue)
+		So(strings.HasSuffix(message, truncated), ShouldBeTrue)
+	})
+
+	Convey("Flush should retry when throttled", t, func() {
+		f := &fakeCloudWatch{}
+		s, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldBeNil)
+
+		f.throttle = 2
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		So(s.Close(), ShouldBeNil)
+		So(f.batches, ShouldHaveLength, 1)
+	})
+
+	Convey("Flush should use the expected sequence token after a mismatch", t, func() {
+		f := &fakeCloudWatch{failWith: map[string]string{"PutLogEvents": "InvalidSequenceTokenException"}}
+		s, server, err := newTestSink(f)
+		defer server.Close()
+		So(err, ShouldBeNil)
+
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		So(s.Flush(), ShouldBeNil)
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		So(s.Close(), ShouldBeNil)
+
+		So(f.tokens, ShouldResemble, []string{"expected", "next"})
+	})
+}
+
+func TestBatchSize(t *testing.T) {
+	Convey("batchSize should respect the event count limit", t, func() {
+		events := make([]inputLogEvent, maxBatchEvents+5)
+		So(batchSize(events), ShouldEqual, maxBatchEvents)
+	})
+
+	Convey("batchSize should respect the byte limit", t, func() {
+		message := strings.Repeat("a", maxEventBytes-eventOverhead)
+		events := []inputLogEvent{{Message: message}, {Message: message}, {Message: message}, {Message: message}, {Message: message}}
+		So(batchSize(events), ShouldEqual, 4)
+	})
+
+	Convey("batchSize should respect the 24 hour span limit", t, func() {
+		events := []inputLogEvent{{Timestamp: 0}, {Timestamp: 1000}, {Timestamp: int64(25 * time.Hour / time.Millisecond)}}
+		So(batchSize(events), ShouldEqual, 2)
+	})
+}
@@ -0,0 +1,54 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// colour modes
+const (
+	colorAuto int32 = iota
+	colorOn
+	colorOff
+)
+
+var colorMode int32
+
+// noColor is set from the NO_COLOR environment variable (https://no-color.org)
+var noColor bool
+
+func configureColor() {
+	noColor = len(os.Getenv("NO_COLOR")) > 0
+}
+
+// Color forces ANSI colours in human readable output on or off. By default
+// colours are only written to a terminal, and not if NO_COLOR is set.
+func Color(enabled bool) {
+	if enabled {
+		atomic.StoreInt32(&colorMode, colorOn)
+	} else {
+		atomic.StoreInt32(&colorMode, colorOff)
+	}
+}
+
+// colorEnabled reports whether colours should be written to w
+func colorEnabled(w io.Writer) bool {
+	switch atomic.LoadInt32(&colorMode) {
+	case colorOn:
+		return true
+	case colorOff:
+		return false
+	}
+	return !noColor && isTerminal(w)
+}
+
+// isTerminal reports whether w is a terminal, rather than a file or pipe
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,56 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestColor(t *testing.T) {
+	defer func() {
+		atomic.StoreInt32(&colorMode, colorAuto)
+		configureColor()
+	}()
+
+	Convey("Colours should be disabled for writers which aren't terminals", t, func() {
+		r, w, err := os.Pipe()
+		So(err, ShouldBeNil)
+		defer r.Close()
+		defer w.Close()
+
+		So(colorEnabled(w), ShouldBeFalse)
+		So(colorEnabled(&bytes.Buffer{}), ShouldBeFalse)
+	})
+
+	Convey("Color should override detection", t, func() {
+		Color(true)
+		So(colorEnabled(&bytes.Buffer{}), ShouldBeTrue)
+
+		Color(false)
+		So(colorEnabled(&bytes.Buffer{}), ShouldBeFalse)
+	})
+
+	Convey("NO_COLOR should disable colours unless overridden", t, func() {
+		atomic.StoreInt32(&colorMode, colorAuto)
+
+		os.Setenv("NO_COLOR", "1")
+		defer os.Unsetenv("NO_COLOR")
+		configureColor()
+		So(noColor, ShouldBeTrue)
+
+		if f, err := os.Open("/dev/tty"); err == nil {
+			defer f.Close()
+			So(colorEnabled(f), ShouldBeFalse)
+		}
+
+		Color(true)
+		So(colorEnabled(&bytes.Buffer{}), ShouldBeTrue)
+
+		os.Setenv("NO_COLOR", "")
+		configureColor()
+		So(noColor, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,232 @@
+package log
+
+import (
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultNamespace is used until SetNamespace is called
+const defaultNamespace = "service-namespace"
+
+// config holds the settings read for every event. A stored config is never
+// modified, the setters store an updated copy instead, so events can read
+// it without locking while the logger is reconfigured.
+type config struct {
+	namespace     string
+	humanReadable bool
+	prettyPrint   bool
+	// namespaceJSON is the preserialized "namespace" field
+	namespaceJSON []byte
+	event         func(name, context string, data Data)
+
+	mode          OutputMode
+	extraFields   Data
+	timeFormat    string
+	forceUTC      bool
+	stackDepth    int
+	includeCaller bool
+	callerSkip    int
+	redactKeys    []string
+	baggageKeys   []string
+	gcpProject    string
+
+	exitFunc          func(code int)
+	panicFunc         func(v interface{})
+	fatalFlushTimeout time.Duration
+}
+
+var defaultConfig = &config{
+	namespace:         defaultNamespace,
+	namespaceJSON:     appendNamespace(nil, defaultNamespace),
+	timeFormat:        TimeRFC3339Nano,
+	stackDepth:        defaultStackDepth,
+	redactKeys:        defaultRedactKeys,
+	gcpProject:        defaultGCPProject,
+	exitFunc:          os.Exit,
+	panicFunc:         defaultPanic,
+	fatalFlushTimeout: defaultFatalFlushTimeout,
+}
+
+var (
+	currentConfig atomic.Value
+	configMutex   sync.Mutex
+)
+
+// getConfig returns the current configuration, which mustn't be modified
+func getConfig() *config {
+	if c, ok := currentConfig.Load().(*config); ok {
+		return c
+	}
+	return defaultConfig
+}
+
+// updateConfig stores a copy of the current configuration modified by f
+func updateConfig(f func(c *config)) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	c := *getConfig()
+	f(&c)
+	currentConfig.Store(&c)
+}
+
+// copyStrings returns a copy of s, so callers can't modify a stored config
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+// SetNamespace sets the service namespace added to every event
+func SetNamespace(namespace string) {
+	updateConfig(func(c *config) {
+		c.setNamespace(namespace)
+	})
+}
+
+func (c *config) setNamespace(namespace string) {
+	c.namespace = namespace
+	c.namespaceJSON = appendNamespace(nil, namespace)
+}
+
+// GetNamespace returns the service namespace
+func GetNamespace() string {
+	return getConfig().namespace
+}
+
+// SetHumanReadable selects human readable output instead of the encoder.
+// It defaults to the HUMAN_LOG environment variable.
+func SetHumanReadable(humanReadable bool) {
+	updateConfig(func(c *config) {
+		c.humanReadable = humanReadable
+	})
+}
+
+// GetHumanReadable reports whether events are output in a human readable format
+func GetHumanReadable() bool {
+	return getConfig().humanReadable
+}
+
+// SetPrettyPrint writes nested data over multiple indented lines in human
+// readable output, instead of on a single line. It's enabled by
+// HUMAN_LOG=pretty.
+func SetPrettyPrint(prettyPrint bool) {
+	updateConfig(func(c *config) {
+		c.prettyPrint = prettyPrint
+	})
+}
+
+// GetPrettyPrint reports whether nested data is pretty printed in human
+// readable output
+func GetPrettyPrint() bool {
+	return getConfig().prettyPrint
+}
+
+// SetEvent replaces the function which records events, e.g. to capture
+// events in tests. Passing nil restores the default.
+func SetEvent(f func(name, context string, data Data)) {
+	updateConfig(func(c *config) {
+		c.event = f
+	})
+}
+
+// Event records an event. It's a variable so existing code which replaces
+// it still compiles.
+//
+// Deprecated: replacing Event isn't safe while events are being logged,
+// use SetEvent instead.
+var Event = recordEvent
+
+// recordEvent records an event using the function set by SetEvent, if any
+func recordEvent(name string, context string, data Data) {
+	if f := getConfig().event; f != nil {
+		f(name, context, data)
+		return
+	}
+	event(name, context, data)
+}
+
+// Namespace is the service namespace used for logging.
+//
+// Deprecated: use SetNamespace. Namespace is read once, when the first
+// event is logged.
+var Namespace = defaultNamespace
+
+// HumanReadable, if true, outputs log events in a human readable format.
+// It's set from the HUMAN_LOG environment variable.
+//
+// Deprecated: use SetHumanReadable. HumanReadable is read once, when the
+// first event is logged.
+var HumanReadable bool
+
+// envHumanReadable is the value of HumanReadable set from HUMAN_LOG, so only
+// changes made by the service are read
+var envHumanReadable bool
+
+var readDeprecatedOnce sync.Once
+
+// readDeprecated copies deprecated variables which have been changed from
+// their defaults into the configuration. It runs when the first event is
+// logged, so variables set while a service starts still take effect.
+func readDeprecated() {
+	readDeprecatedOnce.Do(func() {
+		updateConfig(applyDeprecated)
+	})
+}
+
+func applyDeprecated(c *config) {
+	if Namespace != defaultNamespace {
+		c.setNamespace(Namespace)
+	}
+	if HumanReadable != envHumanReadable {
+		c.humanReadable = HumanReadable
+	}
+	if Mode != DefaultMode {
+		c.mode = Mode
+	}
+	if len(ExtraFields) > 0 {
+		c.extraFields = mergeData(c.extraFields, ExtraFields)
+	}
+	if TimeFormat != TimeRFC3339Nano {
+		c.timeFormat = TimeFormat
+	}
+	if ForceUTC {
+		c.forceUTC = true
+	}
+	if StackDepth != defaultStackDepth {
+		c.stackDepth = StackDepth
+	}
+	if IncludeCaller {
+		c.includeCaller = true
+	}
+	if CallerSkip != 0 {
+		c.callerSkip = CallerSkip
+	}
+	if !reflect.DeepEqual(RedactKeys, defaultRedactKeys) {
+		c.redactKeys = copyStrings(RedactKeys)
+	}
+	if BaggageKeys != nil {
+		c.baggageKeys = copyStrings(BaggageKeys)
+	}
+	if GCPProject != defaultGCPProject {
+		c.gcpProject = GCPProject
+	}
+	if ExitFunc != nil && !sameFunc(ExitFunc, os.Exit) {
+		c.exitFunc = ExitFunc
+	}
+	if PanicFunc != nil && !sameFunc(PanicFunc, defaultPanic) {
+		c.panicFunc = PanicFunc
+	}
+	if FatalFlushTimeout != defaultFatalFlushTimeout {
+		c.fatalFlushTimeout = FatalFlushTimeout
+	}
+}
+
+// sameFunc reports whether two function values have the same code, since
+// functions can't be compared directly
+func sameFunc(a, b interface{}) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
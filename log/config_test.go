@@ -0,0 +1,203 @@
+package log
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// lockedBuffer is a bytes.Buffer which is safe for concurrent writes
+type lockedBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func TestConfig(t *testing.T) {
+	defer func() {
+		SetNamespace(defaultNamespace)
+		SetHumanReadable(false)
+		SetEvent(nil)
+		SetMode(DefaultMode)
+		SetTimeFormat(TimeRFC3339Nano)
+		SetIncludeCaller(false)
+		SetRedactKeys(defaultRedactKeys)
+		SetExtraFields(nil)
+	}()
+
+	Convey("setters should update the configuration", t, func() {
+		SetNamespace("namespace")
+		SetHumanReadable(true)
+		So(GetNamespace(), ShouldEqual, "namespace")
+		So(GetHumanReadable(), ShouldBeTrue)
+
+		SetHumanReadable(false)
+		So(GetNamespace(), ShouldEqual, "namespace")
+		So(GetHumanReadable(), ShouldBeFalse)
+	})
+
+	Convey("SetEvent should replace the event function until reset", t, func() {
+		var names []string
+		SetEvent(func(name, context string, data Data) {
+			names = append(names, name)
+		})
+		Event("test", "", nil)
+		So(names, ShouldResemble, []string{"test"})
+
+		SetEvent(nil)
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		defer SetOutput(nil)
+		Event("test", "", nil)
+		So(names, ShouldHaveLength, 1)
+		So(buf.String(), ShouldContainSubstring, `"event":"test"`)
+	})
+
+	// run with -race to check events and reconfiguration don't race
+	Convey("events should be safe to emit while reconfiguring", t, func() {
+		out := &lockedBuffer{}
+		SetOutput(out)
+		defer SetOutput(nil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(2)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					Event("test", strconv.Itoa(i), Data{"n": j})
+					Debug("debug", nil)
+				}
+			}(i)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					SetNamespace("namespace-" + strconv.Itoa(j))
+					SetHumanReadable(j%2 == 0)
+					SetMode(OutputMode(j % 3))
+					SetTimeFormat(TimeRFC3339)
+					SetIncludeCaller(j%2 == 0)
+					SetRedactKeys([]string{"password"})
+					SetExtraFields(Data{"n": j})
+					if j%10 == 0 {
+						SetEvent(nil)
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		out.mutex.Lock()
+		defer out.mutex.Unlock()
+		So(out.buf.Len(), ShouldBeGreaterThan, 0)
+	})
+}
+
+func TestDeprecated(t *testing.T) {
+	defer func() {
+		Namespace = defaultNamespace
+		Mode = DefaultMode
+		StackDepth = defaultStackDepth
+		RedactKeys = copyStrings(defaultRedactKeys)
+		ExitFunc = os.Exit
+		readDeprecatedOnce = sync.Once{}
+		SetNamespace(defaultNamespace)
+		SetMode(DefaultMode)
+		SetStackDepth(defaultStackDepth)
+		SetRedactKeys(defaultRedactKeys)
+		SetExitFunc(nil)
+		SetOutput(nil)
+	}()
+
+	Convey("deprecated variables should be read when the first event is logged", t, func() {
+		readDeprecatedOnce = sync.Once{}
+		SetNamespace("configured")
+
+		Namespace = "dp-api"
+		Mode = GCPMode
+		StackDepth = 0
+		RedactKeys = append(RedactKeys, "api_key")
+		var exited bool
+		ExitFunc = func(code int) {
+			exited = true
+		}
+		So(GetNamespace(), ShouldEqual, "configured")
+
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		Event("test", "", Data{"api_key": "secret"})
+		So(GetNamespace(), ShouldEqual, "dp-api")
+		So(GetMode(), ShouldEqual, GCPMode)
+		So(getConfig().stackDepth, ShouldEqual, 0)
+		So(GetRedactKeys(), ShouldContain, "api_key")
+		So(buf.String(), ShouldNotContainSubstring, "secret")
+
+		Fatal(errors.New("test"), nil)
+		So(exited, ShouldBeTrue)
+	})
+
+	Convey("deprecated variables should only be read once", t, func() {
+		Namespace = "changed"
+		Event("test", "", nil)
+		So(GetNamespace(), ShouldEqual, "dp-api")
+	})
+
+	Convey("unchanged deprecated variables shouldn't replace the configuration", t, func() {
+		Namespace = defaultNamespace
+		Mode = DefaultMode
+		StackDepth = defaultStackDepth
+		RedactKeys = copyStrings(defaultRedactKeys)
+		ExitFunc = os.Exit
+		readDeprecatedOnce = sync.Once{}
+
+		SetNamespace("configured")
+		SetStackDepth(3)
+		Event("test", "", nil)
+		So(GetNamespace(), ShouldEqual, "configured")
+		So(getConfig().stackDepth, ShouldEqual, 3)
+	})
+
+	Convey("HumanReadable set from HUMAN_LOG shouldn't replace the configuration", t, func() {
+		defer func() {
+			os.Unsetenv("HUMAN_LOG")
+			configureHumanReadable()
+		}()
+		os.Setenv("HUMAN_LOG", "true")
+		configureHumanReadable()
+		readDeprecatedOnce = sync.Once{}
+
+		SetHumanReadable(false)
+		Event("test", "", nil)
+		So(GetHumanReadable(), ShouldBeFalse)
+
+		readDeprecatedOnce = sync.Once{}
+		HumanReadable = false
+		SetHumanReadable(true)
+		Event("test", "", nil)
+		So(GetHumanReadable(), ShouldBeFalse)
+	})
+
+	Convey("replacing Event should still capture events", t, func() {
+		old := Event
+		defer func() {
+			Event = old
+		}()
+
+		var names []string
+		Event = func(name, context string, data Data) {
+			names = append(names, name)
+		}
+		Event("test", "", nil)
+		So(names, ShouldResemble, []string{"test"})
+	})
+}
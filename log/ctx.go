@@ -0,0 +1,51 @@
+package log
+
+import "context"
+
+// RequestID returns the request ID carried by a context
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Caller returns the caller identity carried by a context
+func Caller(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
+
+// ErrorCtx is a structured error message using the request ID and trace
+// IDs from a context
+func ErrorCtx(ctx context.Context, err error, data Data) {
+	FromContext(ctx).Error(err, data)
+}
+
+// InfoCtx is a structured info message using the request ID and trace IDs
+// from a context
+func InfoCtx(ctx context.Context, message string, data Data) {
+	FromContext(ctx).Info(message, data)
+}
+
+// WarnCtx is a structured warning message using the request ID and trace
+// IDs from a context
+func WarnCtx(ctx context.Context, message string, data Data) {
+	FromContext(ctx).Warn(message, data)
+}
+
+// DebugCtx is a structured debug message using the request ID and trace
+// IDs from a context
+func DebugCtx(ctx context.Context, message string, data Data) {
+	FromContext(ctx).Debug(message, data)
+}
+
+// TraceCtx is a structured trace message using the request ID and trace
+// IDs from a context
+func TraceCtx(ctx context.Context, message string, data Data) {
+	FromContext(ctx).Trace(message, data)
+}
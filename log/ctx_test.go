@@ -0,0 +1,70 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCtx(t *testing.T) {
+	defer SetEvent(nil)
+
+	var eventName, eventContext string
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	ctx := WithRequestID(context.Background(), "worker-1")
+
+	Convey("RequestID should return the request ID from a context", t, func() {
+		So(RequestID(ctx), ShouldEqual, "worker-1")
+		So(RequestID(context.Background()), ShouldBeEmpty)
+		So(RequestID(nil), ShouldBeEmpty)
+	})
+
+	Convey("Caller should return the caller identity from a context", t, func() {
+		So(Caller(WithCaller(ctx, "user@ons.gov.uk")), ShouldEqual, "user@ons.gov.uk")
+		So(Caller(ctx), ShouldBeEmpty)
+		So(Caller(nil), ShouldBeEmpty)
+	})
+
+	Convey("ErrorCtx", t, func() {
+		ErrorCtx(ctx, errors.New("test error"), nil)
+		So(eventName, ShouldEqual, "error")
+		So(eventContext, ShouldEqual, "worker-1")
+		So(eventData["message"], ShouldEqual, "test error")
+	})
+
+	Convey("InfoCtx", t, func() {
+		InfoCtx(ctx, "test message", Data{"foo": "bar"})
+		So(eventName, ShouldEqual, "info")
+		So(eventContext, ShouldEqual, "worker-1")
+		So(eventData, ShouldResemble, Data{"message": "test message", "foo": "bar"})
+	})
+
+	Convey("WarnCtx", t, func() {
+		WarnCtx(ctx, "test message", Data{"foo": "bar"})
+		So(eventName, ShouldEqual, "warn")
+		So(eventContext, ShouldEqual, "worker-1")
+		So(eventData, ShouldResemble, Data{"message": "test message", "foo": "bar"})
+	})
+
+	Convey("DebugCtx", t, func() {
+		DebugCtx(ctx, "test message", Data{"foo": "bar"})
+		So(eventName, ShouldEqual, "debug")
+		So(eventContext, ShouldEqual, "worker-1")
+		So(eventData, ShouldResemble, Data{"message": "test message", "foo": "bar"})
+	})
+
+	Convey("TraceCtx", t, func() {
+		TraceCtx(withTrace(ctx, "trace", ""), "test message", nil)
+		So(eventName, ShouldEqual, "trace")
+		So(eventContext, ShouldEqual, "worker-1")
+		So(eventData["trace_id"], ShouldEqual, "trace")
+	})
+}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// datadogStatus maps levels to Datadog log statuses
+func datadogStatus(l Level) string {
+	switch l {
+	case TRACE, DEBUG:
+		return "debug"
+	case WARN:
+		return "warn"
+	case ERROR:
+		return "error"
+	case FATAL:
+		return "critical"
+	}
+	return "info"
+}
+
+// datadogEnvelope lays out a Record using Datadog's standard attributes, so
+// logs correlate with APM traces without a remapping pipeline
+func (r Record) datadogEnvelope() map[string]interface{} {
+	m := map[string]interface{}{
+		"status":    datadogStatus(r.Level()),
+		"timestamp": r.Created,
+		"service":   r.Namespace,
+		"event":     r.Event,
+	}
+
+	if len(r.Context) > 0 {
+		m["context"] = r.Context
+	}
+
+	if r.Data == nil {
+		return m
+	}
+
+	data := Data{}
+	for k, v := range r.Data {
+		data[k] = v
+	}
+
+	if message, ok := data["message"]; ok {
+		m["message"] = fmt.Sprintf("%s", message)
+		delete(data, "message")
+	}
+
+	if traceID, ok := data["trace_id"].(string); ok && len(traceID) > 0 {
+		spanID, _ := data["span_id"].(string)
+		traceID, spanID = datadogIDs(traceID, spanID)
+		dd := map[string]interface{}{"trace_id": traceID}
+		if len(spanID) > 0 {
+			dd["span_id"] = spanID
+		}
+		m["dd"] = dd
+	}
+
+	if duration, ok := data["duration"].(time.Duration); ok {
+		m["duration"] = duration.Nanoseconds()
+	}
+
+	if r.Event == "request" {
+		httpAttrs := map[string]interface{}{}
+		if method, ok := data["method"]; ok {
+			httpAttrs["method"] = method
+		}
+		if path, ok := data["path"]; ok {
+			httpAttrs["url"] = path
+		}
+		if status, ok := data["status"]; ok {
+			httpAttrs["status_code"] = status
+		}
+		if version, ok := data["http_version"]; ok {
+			httpAttrs["version"] = version
+		}
+		m["http"] = httpAttrs
+
+		// prefer the client IP resolved by realip, since remote_addr is the
+		// proxy behind a load balancer
+		if ip, ok := data["client_ip"].(string); ok && len(ip) > 0 {
+			m["network"] = map[string]interface{}{
+				"client": map[string]interface{}{"ip": ip},
+			}
+		} else if addr, ok := data["remote_addr"].(string); ok && len(addr) > 0 {
+			if host, _, err := net.SplitHostPort(addr); err == nil {
+				addr = host
+			}
+			m["network"] = map[string]interface{}{
+				"client": map[string]interface{}{"ip": addr},
+			}
+		}
+	}
+
+	if len(data) > 0 {
+		m["data"] = data
+	}
+
+	return m
+}
+
+// datadogIDs converts W3C trace context IDs, a 128-bit hex trace ID and
+// 64-bit hex span ID, to the decimal lower 64 bits which Datadog correlates
+// logs with. IDs from Datadog headers are already decimal, and decimal span
+// IDs from X-Cloud-Trace-Context aren't 16 hex digits unless they happen to
+// be exactly 16 digits long.
+func datadogIDs(traceID, spanID string) (string, string) {
+	if !isHex(traceID, 32) {
+		return traceID, spanID
+	}
+	traceID = hexToDecimal(traceID[16:])
+	if isHex(spanID, 16) {
+		spanID = hexToDecimal(spanID)
+	}
+	return traceID, spanID
+}
+
+// hexToDecimal converts 16 hex digits to a decimal string
+func hexToDecimal(id string) string {
+	n, err := strconv.ParseUint(id, 16, 64)
+	if err != nil {
+		return id
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// datadogTraceContext returns the trace and parent span IDs propagated by
+// Datadog tracers, which are only accepted as unsigned 64-bit integers
+func datadogTraceContext(req *http.Request) (traceID, spanID string) {
+	traceID = strings.TrimSpace(req.Header.Get("X-Datadog-Trace-Id"))
+	if _, err := strconv.ParseUint(traceID, 10, 64); err != nil {
+		return "", ""
+	}
+	spanID = strings.TrimSpace(req.Header.Get("X-Datadog-Parent-Id"))
+	if _, err := strconv.ParseUint(spanID, 10, 64); err != nil {
+		return traceID, ""
+	}
+	return traceID, spanID
+}
@@ -0,0 +1,147 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDatadogMode(t *testing.T) {
+	defer func() {
+		SetMode(DefaultMode)
+	}()
+
+	Convey("event should use Datadog attributes in DatadogMode", t, func() {
+		SetNamespace("namespace")
+		SetHumanReadable(false)
+		SetMode(DatadogMode)
+
+		stdout := captureOutput(func() {
+			event("error", "context", Data{"message": "test error", "trace_id": "123", "span_id": "456"})
+		})
+		var m map[string]interface{}
+		So(json.Unmarshal([]byte(stdout), &m), ShouldBeNil)
+
+		So(m["status"], ShouldEqual, "error")
+		So(m["service"], ShouldEqual, "namespace")
+		So(m["message"], ShouldEqual, "test error")
+		So(m, ShouldContainKey, "timestamp")
+		So(m["dd"], ShouldResemble, map[string]interface{}{"trace_id": "123", "span_id": "456"})
+		So(m["context"], ShouldEqual, "context")
+	})
+
+	Convey("request events should include http and network attributes in DatadogMode", t, func() {
+		SetMode(DatadogMode)
+
+		m := Record{Event: "request", Data: Data{
+			"method":       "POST",
+			"path":         "/foo",
+			"status":       201,
+			"duration":     2 * time.Millisecond,
+			"remote_addr":  "10.0.0.1:54321",
+			"http_version": "2.0",
+		}}.layout()
+
+		So(m["status"], ShouldEqual, "info")
+		So(m["duration"], ShouldEqual, int64(2000000))
+		So(m["http"], ShouldResemble, map[string]interface{}{
+			"method":      "POST",
+			"url":         "/foo",
+			"status_code": 201,
+			"version":     "2.0",
+		})
+		So(m["network"], ShouldResemble, map[string]interface{}{
+			"client": map[string]interface{}{"ip": "10.0.0.1"},
+		})
+
+		m = Record{Event: "request", Data: Data{
+			"client_ip":   "203.0.113.7",
+			"remote_addr": "10.0.0.1:54321",
+		}}.layout()
+		So(m["network"], ShouldResemble, map[string]interface{}{
+			"client": map[string]interface{}{"ip": "203.0.113.7"},
+		})
+	})
+}
+
+func TestDatadogIDs(t *testing.T) {
+	Convey("datadogIDs should convert W3C trace and span IDs to decimal lower 64 bits", t, func() {
+		traceID, spanID := datadogIDs("4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7")
+		So(traceID, ShouldEqual, "11803532876627986230")
+		So(spanID, ShouldEqual, "67667974448284343")
+
+		traceID, _ = datadogIDs("0000000000000000000000000000002a", "")
+		So(traceID, ShouldEqual, "42")
+
+		m := Record{Event: "error", Data: Data{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "span_id": "00f067aa0ba902b7"}}.datadogEnvelope()
+		So(m["dd"], ShouldResemble, map[string]interface{}{"trace_id": "11803532876627986230", "span_id": "67667974448284343"})
+	})
+
+	Convey("datadogIDs should leave decimal and invalid IDs unchanged", t, func() {
+		traceID, spanID := datadogIDs("1234567890123456789", "987654321")
+		So(traceID, ShouldEqual, "1234567890123456789")
+		So(spanID, ShouldEqual, "987654321")
+
+		traceID, _ = datadogIDs("4bf92f3577b34da6zzzzzzzzzzzzzzzz", "")
+		So(traceID, ShouldEqual, "4bf92f3577b34da6zzzzzzzzzzzzzzzz")
+
+		traceID, spanID = datadogIDs("105445aa7843bc8bf206b12000100000", "1")
+		So(traceID, ShouldEqual, "17439821358036942848")
+		So(spanID, ShouldEqual, "1")
+	})
+}
+
+func TestDatadogStatus(t *testing.T) {
+	Convey("datadogStatus should map levels to statuses", t, func() {
+		So(datadogStatus(FATAL), ShouldEqual, "critical")
+		So(datadogStatus(ERROR), ShouldEqual, "error")
+		So(datadogStatus(WARN), ShouldEqual, "warn")
+		So(datadogStatus(INFO), ShouldEqual, "info")
+		So(datadogStatus(DEBUG), ShouldEqual, "debug")
+		So(datadogStatus(TRACE), ShouldEqual, "debug")
+	})
+}
+
+func TestTraceContext(t *testing.T) {
+	Convey("traceContext should read Datadog trace headers", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		req.Header.Set("X-Datadog-Trace-Id", "123")
+		req.Header.Set("X-Datadog-Parent-Id", "456")
+		traceID, spanID := traceContext(req)
+		So(traceID, ShouldEqual, "123")
+		So(spanID, ShouldEqual, "456")
+	})
+
+	Convey("traceContext should ignore Datadog IDs which aren't unsigned 64-bit integers", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		for _, id := range []string{"abc", "-1", "18446744073709551616", "1 2"} {
+			req.Header.Set("X-Datadog-Trace-Id", id)
+			traceID, spanID := traceContext(req)
+			So(traceID, ShouldBeEmpty)
+			So(spanID, ShouldBeEmpty)
+		}
+
+		req.Header.Set("X-Datadog-Trace-Id", "123")
+		req.Header.Set("X-Datadog-Parent-Id", "span")
+		traceID, spanID := traceContext(req)
+		So(traceID, ShouldEqual, "123")
+		So(spanID, ShouldBeEmpty)
+	})
+
+	Convey("traceContext should prefer X-Cloud-Trace-Context", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1")
+		req.Header.Set("X-Datadog-Trace-Id", "123")
+		traceID, _ := traceContext(req)
+		So(traceID, ShouldEqual, "105445aa7843bc8bf206b12000100000")
+	})
+}
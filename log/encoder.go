@@ -0,0 +1,65 @@
+package log
+
+import "sync"
+
+// Encoder converts a Record into the bytes written for a single event,
+// including any framing (a trailing newline for line based formats)
+type Encoder interface {
+	Encode(r Record) ([]byte, error)
+}
+
+// JSONEncoder encodes events as newline delimited JSON, using the fields for
+// the current Mode
+type JSONEncoder struct{}
+
+// Encode implements Encoder
+func (j JSONEncoder) Encode(r Record) ([]byte, error) {
+	e := getEncodeState()
+	defer putEncodeState(e)
+	if err := j.appendEncode(e, r); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buf...), nil
+}
+
+func (JSONEncoder) appendEncode(e *encodeState, r Record) error {
+	if err := e.appendLayout(r); err != nil {
+		return err
+	}
+	e.buf = append(e.buf, '\n')
+	return nil
+}
+
+// encode appends an encoded Record to e.buf, without copying the output
+// of encoders which support appending
+func encode(e *encodeState, enc Encoder, r Record) error {
+	if a, ok := enc.(appendEncoder); ok {
+		return a.appendEncode(e, r)
+	}
+	b, err := enc.Encode(r)
+	e.buf = append(e.buf, b...)
+	return err
+}
+
+var (
+	encoder      Encoder = JSONEncoder{}
+	encoderMutex sync.RWMutex
+)
+
+// SetEncoder replaces the encoder used for stdout, which defaults to
+// JSONEncoder, or LogfmtEncoder if LOG_FORMAT=logfmt. It has no effect when
+// human readable output is enabled.
+func SetEncoder(e Encoder) {
+	encoderMutex.Lock()
+	defer encoderMutex.Unlock()
+	if e == nil {
+		e = JSONEncoder{}
+	}
+	encoder = e
+}
+
+func getEncoder() Encoder {
+	encoderMutex.RLock()
+	defer encoderMutex.RUnlock()
+	return encoder
+}
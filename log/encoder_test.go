@@ -0,0 +1,53 @@
+package log
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testEncoder struct{}
+
+func (testEncoder) Encode(r Record) ([]byte, error) {
+	return []byte(r.Event + "|" + r.Context + "\n"), nil
+}
+
+func TestJSONEncoder(t *testing.T) {
+	Convey("JSONEncoder should encode records using the current Mode", t, func() {
+		defer func() {
+			SetMode(DefaultMode)
+		}()
+
+		b, err := JSONEncoder{}.Encode(Record{Event: "test", Namespace: "namespace"})
+		So(err, ShouldBeNil)
+
+		var m map[string]interface{}
+		So(json.Unmarshal(b, &m), ShouldBeNil)
+		So(m["event"], ShouldEqual, "test")
+
+		SetMode(GCPMode)
+		b, err = JSONEncoder{}.Encode(Record{Event: "test", Namespace: "namespace"})
+		So(err, ShouldBeNil)
+		So(json.Unmarshal(b, &m), ShouldBeNil)
+		So(m, ShouldContainKey, "severity")
+	})
+}
+
+func TestSetEncoder(t *testing.T) {
+	Convey("event should use the configured encoder", t, func() {
+		SetHumanReadable(false)
+		SetEncoder(testEncoder{})
+		defer SetEncoder(nil)
+
+		stdout := captureOutput(func() {
+			event("test", "context", nil)
+		})
+		So(stdout, ShouldEqual, "test|context\n")
+	})
+
+	Convey("SetEncoder(nil) should restore the JSON encoder", t, func() {
+		SetEncoder(nil)
+		So(getEncoder(), ShouldHaveSameTypeAs, JSONEncoder{})
+	})
+}
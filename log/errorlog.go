@@ -0,0 +1,66 @@
+package log
+
+import (
+	"errors"
+	stdlog "log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrorLogger returns a standard library logger which records each message
+// written to it as an error event. It is intended for http.Server.ErrorLog
+// and httputil.ReverseProxy.ErrorLog, so TLS handshake failures, panics and
+// broken connections enter the structured event stream. The events have a
+// "logger" field of "error_log", so their level can be set with SetLevels.
+func ErrorLogger(context string) *stdlog.Logger {
+	return stdlog.New(&errorLogWriter{context}, "", 0)
+}
+
+type errorLogWriter struct {
+	context string
+}
+
+var (
+	remoteAddrRegexp  = regexp.MustCompile(`(?:from|serving) (\[[^\]]*\]:\d+|[^\s:]+:\d+):`)
+	acceptErrorRegexp = regexp.MustCompile(`Accept error: (.*); retrying in (\S+)$`)
+)
+
+// errorLogKinds classifies the messages written by net/http and httputil
+var errorLogKinds = []struct {
+	substr, kind string
+}{
+	{"TLS handshake error", "tls_handshake"},
+	{"panic serving", "panic"},
+	{"Accept error", "accept"},
+	{"superfluous response.WriteHeader", "superfluous_write_header"},
+	{"broken pipe", "broken_pipe"},
+	{"connection reset by peer", "connection_reset"},
+	{"proxy error", "proxy"},
+	{"ReverseProxy", "proxy"},
+}
+
+func (w *errorLogWriter) Write(b []byte) (int, error) {
+	msg := strings.TrimSpace(string(b))
+
+	data := Data{"logger": "error_log"}
+	for _, k := range errorLogKinds {
+		if strings.Contains(msg, k.substr) {
+			data["kind"] = k.kind
+			break
+		}
+	}
+	if m := remoteAddrRegexp.FindStringSubmatch(msg); m != nil {
+		data["remote_addr"] = m[1]
+	}
+	// http.Server retries temporary accept errors after a delay
+	if m := acceptErrorRegexp.FindStringSubmatch(msg); m != nil {
+		data["accept_error"] = m[1]
+		if d, err := time.ParseDuration(m[2]); err == nil {
+			data["retry_in"] = d
+		}
+	}
+
+	ErrorC(w.context, errors.New(msg), data)
+	return len(b), nil
+}
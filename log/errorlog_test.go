@@ -0,0 +1,59 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrorLogger(t *testing.T) {
+	defer SetEvent(nil)
+
+	var eventName, eventContext string
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	Convey("ErrorLogger should record messages as error events", t, func() {
+		l := ErrorLogger("server")
+		l.Printf("http: TLS handshake error from 10.0.0.1:54321: EOF")
+
+		So(eventName, ShouldEqual, "error")
+		So(eventContext, ShouldEqual, "server")
+		So(eventData["message"], ShouldEqual, "http: TLS handshake error from 10.0.0.1:54321: EOF")
+		So(eventData["kind"], ShouldEqual, "tls_handshake")
+		So(eventData["remote_addr"], ShouldEqual, "10.0.0.1:54321")
+		So(eventData["logger"], ShouldEqual, "error_log")
+		So(eventData, ShouldNotContainKey, "source")
+	})
+
+	Convey("ErrorLogger should parse IPv6 addresses and panics", t, func() {
+		l := ErrorLogger("")
+		l.Printf("http: panic serving [::1]:8080: runtime error\ngoroutine 1 [running]:")
+
+		So(eventData["kind"], ShouldEqual, "panic")
+		So(eventData["remote_addr"], ShouldEqual, "[::1]:8080")
+	})
+
+	Convey("ErrorLogger should parse accept errors", t, func() {
+		l := ErrorLogger("")
+		l.Printf("http: Accept error: accept tcp [::]:8080: accept4: too many open files; retrying in 20ms")
+
+		So(eventData["kind"], ShouldEqual, "accept")
+		So(eventData["accept_error"], ShouldEqual, "accept tcp [::]:8080: accept4: too many open files")
+		So(eventData["retry_in"], ShouldEqual, 20*time.Millisecond)
+	})
+
+	Convey("ErrorLogger should handle unrecognised messages", t, func() {
+		l := ErrorLogger("")
+		l.Printf("something else happened")
+
+		So(eventData["message"], ShouldEqual, "something else happened")
+		So(eventData, ShouldNotContainKey, "kind")
+		So(eventData, ShouldNotContainKey, "remote_addr")
+	})
+}
@@ -0,0 +1,107 @@
+// Package errors wraps errors with a message, an error code and structured
+// data, which log.Error and the other error logging functions merge into
+// the error event
+package errors
+
+import (
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Error is an error wrapped with a message, code and data
+type Error struct {
+	err  error
+	msg  string
+	code string
+	data log.Data
+}
+
+// New returns an error with a message and data
+func New(msg string, data log.Data) error {
+	return &Error{msg: msg, data: data}
+}
+
+// Wrap returns err wrapped with a message and data, or nil if err is nil.
+// The message is prefixed to the error message of err.
+func Wrap(err error, msg string, data log.Data) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{err: err, msg: msg, data: data}
+}
+
+// WithCode returns err with an error code attached, e.g. for alerting or
+// client responses, or nil if err is nil
+func WithCode(err error, code string) error {
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok && len(e.code) == 0 {
+		c := *e
+		c.code = code
+		return &c
+	}
+	return &Error{err: err, code: code}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.err == nil:
+		return e.msg
+	case len(e.msg) == 0:
+		return e.err.Error()
+	}
+	return e.msg + ": " + e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Code returns the error code attached to this error
+func (e *Error) Code() string {
+	return e.code
+}
+
+// LogData returns the data attached to this error
+func (e *Error) LogData() log.Data {
+	return e.data
+}
+
+// Code returns the outermost error code in the chain of wrapped errors
+func Code(err error) string {
+	for ; err != nil; err = unwrap(err) {
+		if e, ok := err.(*Error); ok && len(e.code) > 0 {
+			return e.code
+		}
+	}
+	return ""
+}
+
+// Data returns the data attached to the chain of wrapped errors, with
+// outer errors taking precedence, or nil if there is none
+func Data(err error) log.Data {
+	var data log.Data
+	for ; err != nil; err = unwrap(err) {
+		e, ok := err.(*Error)
+		if !ok {
+			continue
+		}
+		for k, v := range e.data {
+			if data == nil {
+				data = log.Data{}
+			}
+			if _, ok := data[k]; !ok {
+				data[k] = v
+			}
+		}
+	}
+	return data
+}
+
+func unwrap(err error) error {
+	if u, ok := err.(interface{ Unwrap() error }); ok {
+		return u.Unwrap()
+	}
+	return nil
+}
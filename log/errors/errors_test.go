@@ -0,0 +1,63 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrors(t *testing.T) {
+	Convey("Wrap should prefix the message and keep the wrapped error", t, func() {
+		cause := stderrors.New("connection refused")
+		err := Wrap(cause, "failed to get dataset", log.Data{"dataset_id": "cpih"})
+
+		So(err.Error(), ShouldEqual, "failed to get dataset: connection refused")
+		So(stderrors.Is(err, cause), ShouldBeTrue)
+		So(Data(err), ShouldResemble, log.Data{"dataset_id": "cpih"})
+	})
+
+	Convey("Wrap and WithCode should return nil for a nil error", t, func() {
+		So(Wrap(nil, "message", nil), ShouldBeNil)
+		So(WithCode(nil, "CODE"), ShouldBeNil)
+	})
+
+	Convey("WithCode should attach a code to the error", t, func() {
+		err := WithCode(New("not found", nil), "NOT_FOUND")
+		So(err.Error(), ShouldEqual, "not found")
+		So(Code(err), ShouldEqual, "NOT_FOUND")
+
+		err = WithCode(stderrors.New("timeout"), "TIMEOUT")
+		So(err.Error(), ShouldEqual, "timeout")
+		So(Code(err), ShouldEqual, "TIMEOUT")
+	})
+
+	Convey("Code and Data should search the chain with outer errors taking precedence", t, func() {
+		inner := WithCode(Wrap(stderrors.New("eof"), "read", log.Data{"id": "inner", "offset": 10}), "READ")
+		outer := Wrap(fmt.Errorf("handler: %w", inner), "request", log.Data{"id": "outer"})
+
+		So(Code(outer), ShouldEqual, "READ")
+		So(Data(outer), ShouldResemble, log.Data{"id": "outer", "offset": 10})
+		So(Code(stderrors.New("plain")), ShouldBeEmpty)
+		So(Data(stderrors.New("plain")), ShouldBeNil)
+	})
+
+	Convey("Error events should include the code and data of wrapped errors", t, func() {
+		defer log.SetEvent(nil)
+
+		var eventData log.Data
+		log.SetEvent(func(name string, context string, data log.Data) {
+			eventData = data
+		})
+
+		err := WithCode(Wrap(stderrors.New("eof"), "read", log.Data{"id": "cpih", "offset": 10}), "READ")
+		log.ErrorC("ctx", err, log.Data{"offset": 20})
+
+		So(eventData["message"], ShouldEqual, "read: eof")
+		So(eventData["code"], ShouldEqual, "READ")
+		So(eventData["id"], ShouldEqual, "cpih")
+		So(eventData["offset"], ShouldEqual, 20)
+	})
+}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"net/http"
+	"os"
+	"time"
+)
+
+const defaultFatalFlushTimeout = 5 * time.Second
+
+// ExitFunc is called by the Fatal functions after logging.
+//
+// Deprecated: use SetExitFunc. ExitFunc is read once, when the first event
+// is logged.
+var ExitFunc = os.Exit
+
+// PanicFunc is called by the Panic functions after logging.
+//
+// Deprecated: use SetPanicFunc. PanicFunc is read once, when the first
+// event is logged.
+var PanicFunc = defaultPanic
+
+// FatalFlushTimeout is the maximum time spent flushing before exiting.
+//
+// Deprecated: use SetFatalFlushTimeout. FatalFlushTimeout is read once,
+// when the first event is logged.
+var FatalFlushTimeout = defaultFatalFlushTimeout
+
+func defaultPanic(v interface{}) {
+	panic(v)
+}
+
+// SetExitFunc replaces the function called by the Fatal functions after
+// logging, e.g. in tests. Passing nil restores os.Exit.
+func SetExitFunc(f func(code int)) {
+	if f == nil {
+		f = os.Exit
+	}
+	updateConfig(func(c *config) {
+		c.exitFunc = f
+	})
+}
+
+// SetPanicFunc replaces the function called by the Panic functions after
+// logging, e.g. in tests. Passing nil restores panic.
+func SetPanicFunc(f func(v interface{})) {
+	if f == nil {
+		f = defaultPanic
+	}
+	updateConfig(func(c *config) {
+		c.panicFunc = f
+	})
+}
+
+// SetFatalFlushTimeout sets the maximum time spent flushing queued events
+// and sinks before exiting, which defaults to 5 seconds
+func SetFatalFlushTimeout(d time.Duration) {
+	updateConfig(func(c *config) {
+		c.fatalFlushTimeout = d
+	})
+}
+
+// GetFatalFlushTimeout returns the maximum time spent flushing queued
+// events and sinks before exiting
+func GetFatalFlushTimeout() time.Duration {
+	return getConfig().fatalFlushTimeout
+}
+
+// FatalC logs an error event with severity fatal, flushes the log and
+// exits with status 1
+func FatalC(context string, err error, data Data) {
+	Event("error", context, severityData("fatal", err, data))
+	readDeprecated()
+	c := getConfig()
+	Flush(c.fatalFlushTimeout)
+	c.exitFunc(1)
+}
+
+// FatalR logs an error event with severity fatal for a request, flushes
+// the log and exits with status 1
+func FatalR(req *http.Request, err error, data Data) {
+	FatalC(Context(req), err, withRequestFields(req, data))
+}
+
+// Fatal logs an error event with severity fatal, flushes the log and exits
+// with status 1
+func Fatal(err error, data Data) {
+	FatalC("", err, data)
+}
+
+// PanicC logs an error event with severity panic and then panics with err
+func PanicC(context string, err error, data Data) {
+	Event("error", context, severityData("panic", err, data))
+	readDeprecated()
+	c := getConfig()
+	Flush(c.fatalFlushTimeout)
+	c.panicFunc(err)
+}
+
+// PanicR logs an error event with severity panic for a request and then
+// panics with err
+func PanicR(req *http.Request, err error, data Data) {
+	PanicC(Context(req), err, withRequestFields(req, data))
+}
+
+// Panic logs an error event with severity panic and then panics with err
+func Panic(err error, data Data) {
+	PanicC("", err, data)
+}
+
+func severityData(severity string, err error, data Data) Data {
+	data = errorData(err, data)
+	data["severity"] = severity
+	return data
+}
@@ -0,0 +1,68 @@
+package log
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFatal(t *testing.T) {
+	defer func() {
+		SetEvent(nil)
+		SetExitFunc(nil)
+		SetPanicFunc(nil)
+	}()
+
+	var eventName, eventContext string
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	var exitCode int
+	SetExitFunc(func(code int) {
+		exitCode = code
+	})
+
+	var panicked interface{}
+	SetPanicFunc(func(v interface{}) {
+		panicked = v
+	})
+
+	Convey("Fatal should log an error event and exit", t, func() {
+		exitCode = 0
+		err := errors.New("test error")
+		Fatal(err, nil)
+		So(eventName, ShouldEqual, "error")
+		So(eventData["severity"], ShouldEqual, "fatal")
+		So(eventData["message"], ShouldEqual, "test error")
+		So(exitCode, ShouldEqual, 1)
+	})
+
+	Convey("FatalR should use the request context", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("X-Request-Id", "test-request-id")
+
+		FatalR(req, errors.New("test error"), Data{"foo": "bar"})
+		So(eventContext, ShouldEqual, "test-request-id")
+		So(eventData["foo"], ShouldEqual, "bar")
+	})
+
+	Convey("Panic should log an error event and panic with the error", t, func() {
+		err := errors.New("test error")
+		PanicC("context", err, nil)
+		So(eventName, ShouldEqual, "error")
+		So(eventContext, ShouldEqual, "context")
+		So(eventData["severity"], ShouldEqual, "panic")
+		So(panicked, ShouldEqual, err)
+	})
+
+	Convey("the panic function should panic by default", t, func() {
+		So(func() { defaultPanic("test") }, ShouldPanicWith, "test")
+	})
+}
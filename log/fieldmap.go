@@ -0,0 +1,71 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"sync"
+)
+
+// FieldMap renames the top level fields of JSON events (created, event,
+// namespace, context and data) to match an ingestion pipeline, e.g.
+//
+//	log.SetFieldMap(log.FieldMap{"created": "@timestamp", "data": "fields"})
+type FieldMap map[string]string
+
+var (
+	fieldMap      FieldMap
+	fieldMapMutex sync.RWMutex
+)
+
+// SetFieldMap replaces the field map used by the JSON encoder and
+// Record.MarshalJSON, which defaults to the LOG_FIELD_MAP environment
+// variable, e.g. "created=@timestamp,event=event.action". It only applies
+// in DefaultMode, since the GCP and Datadog modes have fixed schemas.
+func SetFieldMap(m FieldMap) {
+	c := make(FieldMap, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	fieldMapMutex.Lock()
+	defer fieldMapMutex.Unlock()
+	fieldMap = c
+}
+
+func getFieldMap() FieldMap {
+	fieldMapMutex.RLock()
+	defer fieldMapMutex.RUnlock()
+	return fieldMap
+}
+
+// configureFieldMap sets the field map from LOG_FIELD_MAP, a comma
+// separated list of field=name pairs
+func configureFieldMap() {
+	m := FieldMap{}
+	for _, item := range strings.Split(os.Getenv("LOG_FIELD_MAP"), ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		from, to := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if len(from) > 0 && len(to) > 0 {
+			m[from] = to
+		}
+	}
+	SetFieldMap(m)
+}
+
+// apply returns the fields with any mapped fields renamed
+func (fm FieldMap) apply(m map[string]interface{}) map[string]interface{} {
+	if len(fm) == 0 {
+		return m
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if name, ok := fm[k]; ok {
+			k = name
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFieldMap(t *testing.T) {
+	defer func() {
+		os.Unsetenv("LOG_FIELD_MAP")
+		configureFieldMap()
+	}()
+
+	r := Record{
+		Created:   time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		Event:     "request",
+		Namespace: "dp-api",
+		Context:   "abc",
+		Data:      Data{"status": 200},
+	}
+
+	decode := func(r Record) map[string]interface{} {
+		b, err := JSONEncoder{}.Encode(r)
+		So(err, ShouldBeNil)
+		var m map[string]interface{}
+		So(json.Unmarshal(b, &m), ShouldBeNil)
+		return m
+	}
+
+	Convey("The JSON encoder should use the default field names", t, func() {
+		SetFieldMap(nil)
+		m := decode(r)
+		So(m, ShouldContainKey, "created")
+		So(m, ShouldContainKey, "event")
+		So(m, ShouldContainKey, "data")
+	})
+
+	Convey("SetFieldMap should rename fields", t, func() {
+		SetFieldMap(FieldMap{"created": "@timestamp", "data": "fields"})
+		m := decode(r)
+		So(m, ShouldNotContainKey, "created")
+		So(m, ShouldNotContainKey, "data")
+		So(m["@timestamp"], ShouldNotBeNil)
+		So(m["fields"], ShouldResemble, map[string]interface{}{"status": float64(200)})
+		So(m["event"], ShouldEqual, "request")
+		So(m["namespace"], ShouldEqual, "dp-api")
+	})
+
+	Convey("The field map should not apply to GCP mode", t, func() {
+		SetFieldMap(FieldMap{"event": "action"})
+		SetMode(GCPMode)
+		defer func() {
+			SetMode(DefaultMode)
+		}()
+		So(decode(r), ShouldNotContainKey, "action")
+	})
+
+	Convey("LOG_FIELD_MAP environment variable should configure the field map", t, func() {
+		os.Setenv("LOG_FIELD_MAP", "created=@timestamp, event=event.action,invalid")
+		configureFieldMap()
+		So(getFieldMap(), ShouldResemble, FieldMap{"created": "@timestamp", "event": "event.action"})
+	})
+}
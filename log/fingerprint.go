@@ -0,0 +1,47 @@
+package log
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+)
+
+// fingerprintFrames is the number of stack frames included in a fingerprint
+const fingerprintFrames = 3
+
+var (
+	uuidPattern   = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	hexPattern    = regexp.MustCompile(`\b(0x)?[0-9a-fA-F]*[0-9][0-9a-fA-F]*\b`)
+	quotedPattern = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+)
+
+// normalize replaces the variable parts of an error message, such as IDs,
+// numbers and quoted values, so that identical failures compare equal
+func normalize(msg string) string {
+	msg = uuidPattern.ReplaceAllString(msg, "?")
+	msg = quotedPattern.ReplaceAllString(msg, "?")
+	return hexPattern.ReplaceAllString(msg, "?")
+}
+
+// fingerprint returns a stable hash of the error type, normalized message
+// and the calling functions outside of this package
+func fingerprint(err error) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%T\n%s\n", err, normalize(err.Error()))
+	for _, f := range callers(fingerprintFrames) {
+		fmt.Fprintf(h, "%s\n", f)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// callers returns up to n function names from the stack, skipping frames
+// inside the log package. Line numbers aren't included so a fingerprint
+// survives unrelated changes to the calling file.
+func callers(n int) []string {
+	var names []string
+	for _, f := range callerFrames(n) {
+		names = append(names, f.Function)
+	}
+	return names
+}
@@ -0,0 +1,60 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type customError struct{ msg string }
+
+func (e customError) Error() string { return e.msg }
+
+func logError(err error) Data {
+	data := Data{}
+	ErrorC("", err, data)
+	return data
+}
+
+func TestFingerprint(t *testing.T) {
+	defer SetEvent(nil)
+	SetEvent(func(name string, context string, data Data) {})
+
+	Convey("normalize should replace variable parts of a message", t, func() {
+		So(normalize(`user 123 not found`), ShouldEqual, `user ? not found`)
+		So(normalize(`dataset "cpih" version 2 not found`), ShouldEqual, `dataset ? version ? not found`)
+		So(normalize(`id 6ba7b810-9dad-11d1-80b4-00c04fd430c8 at 0xc000123abc`), ShouldEqual, `id ? at ?`)
+		So(normalize(`dial tcp 10.0.0.1:27017: connection refused`), ShouldEqual, `dial tcp ?.?.?.?:?: connection refused`)
+	})
+
+	Convey("Error events should include a fingerprint", t, func() {
+		data := logError(errors.New("test"))
+		So(data["fingerprint"], ShouldHaveLength, 16)
+	})
+
+	Convey("Identical failures with different values should share a fingerprint", t, func() {
+		var fingerprints []interface{}
+		for i := 0; i < 2; i++ {
+			fingerprints = append(fingerprints, logError(fmt.Errorf("user %d not found", i))["fingerprint"])
+		}
+		So(fingerprints[0], ShouldEqual, fingerprints[1])
+	})
+
+	Convey("Fingerprints should differ by error type, message and caller", t, func() {
+		a := logError(errors.New("user 1 not found"))["fingerprint"]
+		So(logError(customError{"user 1 not found"})["fingerprint"], ShouldNotEqual, a)
+		So(logError(errors.New("user 1 deleted"))["fingerprint"], ShouldNotEqual, a)
+
+		data := Data{}
+		ErrorC("", errors.New("user 1 not found"), data)
+		So(data["fingerprint"], ShouldNotEqual, a)
+	})
+
+	Convey("An existing fingerprint should not be replaced", t, func() {
+		data := Data{"fingerprint": "custom"}
+		ErrorC("", errors.New("test"), data)
+		So(data["fingerprint"], ShouldEqual, "custom")
+	})
+}
@@ -0,0 +1,436 @@
+// Package fluent provides a log.Sink which ships events to Fluentd or Fluent
+// Bit using the forward protocol
+package fluent
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Config configures a Fluent forward sink
+type Config struct {
+	// Address is the host:port of the forward input, defaults to localhost:24224
+	Address string
+	// Tag is the Fluent tag for events, defaults to the event namespace
+	Tag string
+	// RequireAck waits for the collector to acknowledge each chunk
+	RequireAck bool
+	// Timeout applies to connecting, writing and waiting for acks, defaults to 5s
+	Timeout time.Duration
+	// BatchSize is the number of events which triggers a send, defaults to 100
+	BatchSize int
+	// FlushInterval is the maximum time events are buffered, defaults to 1s
+	FlushInterval time.Duration
+	// BufferDir is a directory where events are written when they can't be
+	// sent, e.g. while the collector is down. They're resent, oldest first,
+	// before new events once it's reachable. Events are dropped if it isn't set.
+	BufferDir string
+	// MaxBufferSize is the maximum size in bytes of BufferDir, defaults to 64MB
+	MaxBufferSize int64
+}
+
+// ConfigFromEnv reads a Config from FLUENT_ADDR, FLUENT_TAG,
+// FLUENT_REQUIRE_ACK and FLUENT_BUFFER_DIR
+func ConfigFromEnv() Config {
+	c := Config{
+		Address:   os.Getenv("FLUENT_ADDR"),
+		Tag:       os.Getenv("FLUENT_TAG"),
+		BufferDir: os.Getenv("FLUENT_BUFFER_DIR"),
+	}
+	c.RequireAck, _ = strconv.ParseBool(os.Getenv("FLUENT_REQUIRE_ACK"))
+	return c
+}
+
+// Sink buffers log events and sends them to a forward input in batches
+type Sink struct {
+	cfg Config
+
+	mutex  sync.Mutex
+	buffer []entry
+	err    error
+
+	connMutex sync.Mutex
+	conn      net.Conn
+	spooled   int
+
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type entry struct {
+	tag    string
+	time   eventTime
+	record map[string]interface{}
+}
+
+// eventTime is the forward protocol EventTime extension (type 0), giving
+// nanosecond precision timestamps
+type eventTime time.Time
+
+func init() {
+	msgpack.RegisterExt(0, (*eventTime)(nil))
+}
+
+func (t *eventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(time.Time(*t).Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(time.Time(*t).Nanosecond()))
+	return b, nil
+}
+
+func (t *eventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return errors.New("fluent: invalid EventTime length")
+	}
+	*t = eventTime(time.Unix(int64(binary.BigEndian.Uint32(b)), int64(binary.BigEndian.Uint32(b[4:]))))
+	return nil
+}
+
+// New returns a Sink which starts sending events in the background. The
+// connection is established on the first send.
+func New(cfg Config) (*Sink, error) {
+	if len(cfg.Address) == 0 {
+		cfg.Address = "localhost:24224"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.MaxBufferSize <= 0 {
+		cfg.MaxBufferSize = 64 << 20
+	}
+	if len(cfg.BufferDir) > 0 {
+		if err := os.MkdirAll(cfg.BufferDir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Sink{
+		cfg:   cfg,
+		flush: make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// Write buffers an event, returning any error from a previous background send
+func (s *Sink) Write(r log.Record) error {
+	record, err := toRecord(r)
+	if err != nil {
+		return err
+	}
+
+	tag := s.cfg.Tag
+	if len(tag) == 0 {
+		tag = r.Namespace
+	}
+
+	s.mutex.Lock()
+	s.buffer = append(s.buffer, entry{tag: tag, time: eventTime(r.Created), record: record})
+	full := len(s.buffer) >= s.cfg.BatchSize
+
+	err, s.err = s.err, nil
+	s.mutex.Unlock()
+
+	if full {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return err
+}
+
+// Close stops the background goroutine, sends any buffered events and closes
+// the connection. It returns the same error if it's called again.
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		s.closeErr = s.close()
+	})
+	return s.closeErr
+}
+
+func (s *Sink) close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	err := s.Flush()
+
+	s.mutex.Lock()
+	if err == nil {
+		err = s.err
+	}
+	s.err = nil
+	s.mutex.Unlock()
+
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+	if s.conn != nil {
+		if e := s.conn.Close(); e != nil && err == nil {
+			err = e
+		}
+		s.conn = nil
+	}
+
+	return err
+}
+
+// Flush sends any events buffered to disk, then all buffered events, one
+// forward mode message per tag
+func (s *Sink) Flush() error {
+	s.mutex.Lock()
+	entries := s.buffer
+	s.buffer = nil
+	s.mutex.Unlock()
+
+	s.connMutex.Lock()
+	defer s.connMutex.Unlock()
+
+	tags, byTag := group(entries)
+
+	if err := s.resend(); err != nil {
+		return s.spoolTags(tags, byTag, err)
+	}
+
+	for i, tag := range tags {
+		if err := s.send(tag, byTag[tag]); err != nil {
+			s.disconnect()
+			return s.spoolTags(tags[i:], byTag, err)
+		}
+	}
+	return nil
+}
+
+// group returns the forward mode events for each tag, and the tags in the
+// order they were first seen
+func group(entries []entry) ([]string, map[string][]interface{}) {
+	var tags []string
+	byTag := map[string][]interface{}{}
+	for _, e := range entries {
+		if _, ok := byTag[e.tag]; !ok {
+			tags = append(tags, e.tag)
+		}
+		t := e.time
+		byTag[e.tag] = append(byTag[e.tag], []interface{}{&t, e.record})
+	}
+	return tags, byTag
+}
+
+// disconnect drops the connection so the next send reconnects
+func (s *Sink) disconnect() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// spoolTags writes the events for tags which couldn't be sent because of
+// sendErr to BufferDir
+func (s *Sink) spoolTags(tags []string, byTag map[string][]interface{}, sendErr error) error {
+	err := sendErr
+	for _, tag := range tags {
+		if e := s.spool(tag, byTag[tag], sendErr); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// spool writes events which couldn't be sent because of sendErr to
+// BufferDir, returning an error describing what happened to them
+func (s *Sink) spool(tag string, events []interface{}, sendErr error) error {
+	if len(s.cfg.BufferDir) == 0 {
+		return sendErr
+	}
+
+	b, err := msgpack.Marshal([]interface{}{tag, events})
+	if err != nil {
+		return err
+	}
+
+	if size, err := dirSize(s.cfg.BufferDir); err != nil {
+		return err
+	} else if size+int64(len(b)) > s.cfg.MaxBufferSize {
+		return fmt.Errorf("fluent: buffer directory full, dropped %d events: %v", len(events), sendErr)
+	}
+
+	s.spooled++
+	name := filepath.Join(s.cfg.BufferDir, fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.spooled%1000000, spoolExt))
+	// write to a temporary file first, so a partial file is never resent
+	if err := ioutil.WriteFile(name+".tmp", b, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(name+".tmp", name); err != nil {
+		return err
+	}
+	return fmt.Errorf("fluent: buffered %d events to disk: %v", len(events), sendErr)
+}
+
+// resend sends events buffered to disk, oldest first, removing each file
+// once it's been sent
+func (s *Sink) resend() error {
+	if len(s.cfg.BufferDir) == 0 {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(s.cfg.BufferDir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != spoolExt {
+			continue
+		}
+		name := filepath.Join(s.cfg.BufferDir, f.Name())
+
+		b, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		var msg []interface{}
+		if err := msgpack.Unmarshal(b, &msg); err != nil || len(msg) != 2 {
+			// a corrupt file would block every later file, so discard it
+			os.Remove(name)
+			continue
+		}
+		tag, _ := msg[0].(string)
+		events, _ := msg[1].([]interface{})
+
+		if err := s.send(tag, events); err != nil {
+			s.disconnect()
+			return err
+		}
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// spoolExt is the extension of files in BufferDir
+const spoolExt = ".msgpack"
+
+func dirSize(dir string) (int64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, f := range files {
+		size += f.Size()
+	}
+	return size, nil
+}
+
+func (s *Sink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		case <-s.flush:
+		}
+
+		if err := s.Flush(); err != nil {
+			s.mutex.Lock()
+			s.err = err
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// send writes a forward mode message, [tag, [[time, record], ...], option],
+// and waits for an ack if required
+func (s *Sink) send(tag string, events []interface{}) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout("tcp", s.cfg.Address, s.cfg.Timeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	option := map[string]interface{}{"size": len(events)}
+	var chunk string
+	if s.cfg.RequireAck {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		chunk = base64.StdEncoding.EncodeToString(b)
+		option["chunk"] = chunk
+	}
+
+	b, err := msgpack.Marshal([]interface{}{tag, events, option})
+	if err != nil {
+		return err
+	}
+
+	s.conn.SetDeadline(time.Now().Add(s.cfg.Timeout))
+	if _, err = s.conn.Write(b); err != nil {
+		return err
+	}
+
+	if !s.cfg.RequireAck {
+		return nil
+	}
+
+	var resp struct {
+		Ack string `msgpack:"ack"`
+	}
+	if err = msgpack.NewDecoder(s.conn).Decode(&resp); err != nil {
+		return err
+	}
+	if resp.Ack != chunk {
+		return fmt.Errorf("fluent: expected ack %q, got %q", chunk, resp.Ack)
+	}
+	return nil
+}
+
+// toRecord converts a log.Record to the plain map which would be encoded as
+// JSON, so Data values are represented consistently across sinks
+func toRecord(r log.Record) (map[string]interface{}, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	err = json.Unmarshal(b, &m)
+	return m, err
+}
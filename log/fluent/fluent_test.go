@@ -0,0 +1,214 @@
+package fluent
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type forwardMessage struct {
+	tag     string
+	entries []interface{}
+	option  map[string]interface{}
+}
+
+// fakeForward accepts a single connection and decodes forward mode messages,
+// acknowledging chunks if ack is set
+func fakeForward(ack bool) (net.Listener, chan forwardMessage) {
+	return fakeForwardOn("127.0.0.1:0", ack)
+}
+
+func fakeForwardOn(addr string, ack bool) (net.Listener, chan forwardMessage) {
+	l, err := net.Listen("tcp", addr)
+	So(err, ShouldBeNil)
+
+	messages := make(chan forwardMessage, 10)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		dec := msgpack.NewDecoder(conn)
+		for {
+			var msg []interface{}
+			if err := dec.Decode(&msg); err != nil {
+				return
+			}
+			m := forwardMessage{tag: msg[0].(string), entries: msg[1].([]interface{}), option: msg[2].(map[string]interface{})}
+			messages <- m
+
+			if chunk, ok := m.option["chunk"]; ok && ack {
+				b, _ := msgpack.Marshal(map[string]interface{}{"ack": chunk})
+				conn.Write(b)
+			}
+		}
+	}()
+
+	return l, messages
+}
+
+func TestSink(t *testing.T) {
+	Convey("Close should send buffered events as a forward mode message", t, func() {
+		l, messages := fakeForward(false)
+		defer l.Close()
+
+		s, err := New(Config{Address: l.Addr().String(), FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		So(s.Write(log.Record{Created: now, Event: "test", Namespace: "namespace", Data: log.Data{"foo": "bar"}}), ShouldBeNil)
+		So(s.Write(log.Record{Created: now, Event: "test", Namespace: "namespace"}), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		msg := <-messages
+		So(msg.tag, ShouldEqual, "namespace")
+		So(msg.entries, ShouldHaveLength, 2)
+		So(msg.option["size"], ShouldEqual, 2)
+		So(msg.option, ShouldNotContainKey, "chunk")
+
+		entry := msg.entries[0].([]interface{})
+		So(time.Time(*entry[0].(*eventTime)).Equal(now), ShouldBeTrue)
+		record := entry[1].(map[string]interface{})
+		So(record["event"], ShouldEqual, "test")
+		So(record["data"].(map[string]interface{})["foo"], ShouldEqual, "bar")
+	})
+
+	Convey("Flush should wait for an ack in ack mode", t, func() {
+		l, messages := fakeForward(true)
+		defer l.Close()
+
+		s, err := New(Config{Address: l.Addr().String(), Tag: "tag", RequireAck: true, FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		So(s.Flush(), ShouldBeNil)
+
+		msg := <-messages
+		So(msg.tag, ShouldEqual, "tag")
+		So(msg.option, ShouldContainKey, "chunk")
+		So(s.Close(), ShouldBeNil)
+	})
+
+	Convey("Flush should fail if an ack isn't received", t, func() {
+		l, _ := fakeForward(false)
+		defer l.Close()
+
+		s, err := New(Config{Address: l.Addr().String(), RequireAck: true, Timeout: 100 * time.Millisecond, FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		So(s.Flush(), ShouldNotBeNil)
+		So(s.conn, ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+	})
+
+	Convey("Flush should fail if the collector is unreachable", t, func() {
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		s, err := New(Config{Address: addr, FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		err = s.Close()
+		So(err, ShouldNotBeNil)
+
+		Convey("and Close should return the same error if it's called again", func() {
+			So(s.Close(), ShouldEqual, err)
+		})
+	})
+}
+
+func TestBuffer(t *testing.T) {
+	Convey("Events should be buffered to disk while the collector is down and resent", t, func() {
+		dir, err := ioutil.TempDir("", "fluent")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		addr := l.Addr().String()
+		l.Close()
+
+		s, err := New(Config{Address: addr, BufferDir: dir, FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		s.Write(log.Record{Created: now, Event: "first", Namespace: "namespace"})
+		So(s.Flush(), ShouldNotBeNil)
+		s.Write(log.Record{Created: now, Event: "second", Namespace: "namespace"})
+		So(s.Flush(), ShouldNotBeNil)
+
+		files, err := ioutil.ReadDir(dir)
+		So(err, ShouldBeNil)
+		So(files, ShouldHaveLength, 2)
+
+		l, messages := fakeForwardOn(addr, false)
+		defer l.Close()
+
+		s.Write(log.Record{Created: now, Event: "third", Namespace: "namespace"})
+		So(s.Close(), ShouldBeNil)
+
+		var events []string
+		for i := 0; i < 3; i++ {
+			msg := <-messages
+			So(msg.tag, ShouldEqual, "namespace")
+			for _, e := range msg.entries {
+				record := e.([]interface{})[1].(map[string]interface{})
+				events = append(events, record["event"].(string))
+			}
+		}
+		So(events, ShouldResemble, []string{"first", "second", "third"})
+
+		files, err = ioutil.ReadDir(dir)
+		So(err, ShouldBeNil)
+		So(files, ShouldBeEmpty)
+	})
+
+	Convey("Events should be dropped when the buffer directory is full", t, func() {
+		dir, err := ioutil.TempDir("", "fluent")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := l.Addr().String()
+		l.Close()
+
+		s, err := New(Config{Address: addr, BufferDir: dir, MaxBufferSize: 10, FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		err = s.Flush()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "dropped")
+
+		files, _ := ioutil.ReadDir(dir)
+		So(files, ShouldBeEmpty)
+		s.Close()
+	})
+
+	Convey("ConfigFromEnv should read the FLUENT variables", t, func() {
+		os.Setenv("FLUENT_ADDR", "fluentbit:24224")
+		os.Setenv("FLUENT_REQUIRE_ACK", "true")
+		os.Setenv("FLUENT_BUFFER_DIR", "/var/spool/fluent")
+		defer func() {
+			os.Unsetenv("FLUENT_ADDR")
+			os.Unsetenv("FLUENT_REQUIRE_ACK")
+			os.Unsetenv("FLUENT_BUFFER_DIR")
+		}()
+
+		c := ConfigFromEnv()
+		So(c.Address, ShouldEqual, "fluentbit:24224")
+		So(c.RequireAck, ShouldBeTrue)
+		So(c.BufferDir, ShouldEqual, "/var/spool/fluent")
+	})
+}
@@ -0,0 +1,169 @@
+package log
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultGCPProject = os.Getenv("GOOGLE_CLOUD_PROJECT")
+
+// GCPProject is the Google Cloud project ID used to build trace resource names.
+//
+// Deprecated: use SetGCPProject. GCPProject is read once, when the first
+// event is logged.
+var GCPProject = defaultGCPProject
+
+// SetGCPProject sets the Google Cloud project ID used to build trace
+// resource names. It defaults to GOOGLE_CLOUD_PROJECT.
+func SetGCPProject(project string) {
+	updateConfig(func(c *config) {
+		c.gcpProject = project
+	})
+}
+
+// gcpSeverity maps levels to Cloud Logging severities
+func gcpSeverity(l Level) string {
+	switch l {
+	case TRACE, DEBUG:
+		return "DEBUG"
+	case INFO:
+		return "INFO"
+	case WARN:
+		return "WARNING"
+	case ERROR:
+		return "ERROR"
+	case FATAL:
+		return "CRITICAL"
+	}
+	return "DEFAULT"
+}
+
+// gcpEnvelope lays out a Record using the special fields recognised by
+// Cloud Logging when parsing structured JSON from stdout
+func (r Record) gcpEnvelope() map[string]interface{} {
+	m := map[string]interface{}{
+		"severity":  gcpSeverity(r.Level()),
+		"timestamp": r.Created,
+		"event":     r.Event,
+		"namespace": r.Namespace,
+	}
+
+	if len(r.Context) > 0 {
+		m["context"] = r.Context
+	}
+
+	if r.Data == nil {
+		return m
+	}
+
+	data := Data{}
+	for k, v := range r.Data {
+		data[k] = v
+	}
+
+	if message, ok := data["message"]; ok {
+		m["message"] = fmt.Sprintf("%s", message)
+		delete(data, "message")
+	}
+
+	if traceID, ok := data["trace_id"].(string); ok && len(traceID) > 0 {
+		if project := getConfig().gcpProject; len(project) > 0 {
+			traceID = "projects/" + project + "/traces/" + traceID
+		}
+		m["logging.googleapis.com/trace"] = traceID
+		if spanID, ok := data["span_id"].(string); ok && len(spanID) > 0 {
+			m["logging.googleapis.com/spanId"] = spanID
+		}
+	}
+
+	if src, ok := data["source"].(Source); ok {
+		m["logging.googleapis.com/sourceLocation"] = map[string]interface{}{
+			"file":     src.File,
+			"line":     strconv.Itoa(src.Line),
+			"function": src.Function,
+		}
+		delete(data, "source")
+	}
+
+	if r.Event == "request" {
+		m["httpRequest"] = gcpHTTPRequest(data)
+	}
+
+	if len(data) > 0 {
+		m["data"] = data
+	}
+
+	return m
+}
+
+// gcpHTTPRequest builds a Cloud Logging HttpRequest from request event data
+func gcpHTTPRequest(data Data) map[string]interface{} {
+	h := map[string]interface{}{}
+	if method, ok := data["method"]; ok {
+		h["requestMethod"] = method
+	}
+	if path, ok := data["path"]; ok {
+		url := fmt.Sprint(path)
+		if query, ok := data["query"].(string); ok && len(query) > 0 {
+			url += "?" + query
+		}
+		h["requestUrl"] = url
+	}
+	if status, ok := data["status"]; ok {
+		h["status"] = status
+	}
+	if version, ok := data["http_version"].(string); ok {
+		h["protocol"] = "HTTP/" + version
+	}
+	// sizes are int64 values, which Cloud Logging expects as strings
+	if size, ok := data["bytes_received"]; ok {
+		h["requestSize"] = fmt.Sprint(size)
+	}
+	if size, ok := data["bytes_sent"]; ok {
+		h["responseSize"] = fmt.Sprint(size)
+	}
+	if ip, ok := data["client_ip"].(string); ok && len(ip) > 0 {
+		h["remoteIp"] = ip
+	} else if addr, ok := data["remote_addr"].(string); ok && len(addr) > 0 {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		h["remoteIp"] = addr
+	}
+	if duration, ok := data["duration"].(time.Duration); ok {
+		h["latency"] = fmt.Sprintf("%.9fs", duration.Seconds())
+	} else if duration, ok := data["stream_duration"].(time.Duration); ok {
+		h["latency"] = fmt.Sprintf("%.9fs", duration.Seconds())
+	}
+	return h
+}
+
+// cloudTraceContext parses the trace and span IDs from an
+// X-Cloud-Trace-Context header (TRACE_ID/SPAN_ID;o=TRACE_TRUE). The trace
+// ID must be 32 hex digits and the span ID decimal, since the header comes
+// from clients and is logged and used as a metric exemplar.
+func cloudTraceContext(req *http.Request) (traceID, spanID string) {
+	header := strings.TrimSpace(req.Header.Get("X-Cloud-Trace-Context"))
+	if len(header) == 0 {
+		return "", ""
+	}
+
+	if i := strings.Index(header, ";"); i >= 0 {
+		header = header[:i]
+	}
+	parts := strings.SplitN(header, "/", 2)
+	if !isHex(parts[0], 32) {
+		return "", ""
+	}
+	if len(parts) == 2 {
+		if _, err := strconv.ParseUint(parts[1], 10, 64); err == nil {
+			return parts[0], parts[1]
+		}
+	}
+	return parts[0], ""
+}
@@ -0,0 +1,130 @@
+package log
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGCPMode(t *testing.T) {
+	defer func() {
+		SetMode(DefaultMode)
+		SetGCPProject(defaultGCPProject)
+	}()
+
+	Convey("event should use Cloud Logging fields in GCPMode", t, func() {
+		SetNamespace("namespace")
+		SetHumanReadable(false)
+		SetMode(GCPMode)
+		SetGCPProject("project")
+
+		stdout := captureOutput(func() {
+			event("error", "context", Data{"message": "test error", "trace_id": "abc", "span_id": "123"})
+		})
+		var m map[string]interface{}
+		So(json.Unmarshal([]byte(stdout), &m), ShouldBeNil)
+
+		So(m["severity"], ShouldEqual, "ERROR")
+		So(m, ShouldContainKey, "timestamp")
+		So(m, ShouldNotContainKey, "created")
+		So(m["message"], ShouldEqual, "test error")
+		So(m["logging.googleapis.com/trace"], ShouldEqual, "projects/project/traces/abc")
+		So(m["logging.googleapis.com/spanId"], ShouldEqual, "123")
+		So(m["event"], ShouldEqual, "error")
+		So(m["context"], ShouldEqual, "context")
+		So(m["data"], ShouldNotContainKey, "message")
+	})
+
+	Convey("request events should include httpRequest in GCPMode", t, func() {
+		SetMode(GCPMode)
+
+		m := Record{Event: "request", Data: Data{
+			"method":   "GET",
+			"path":     "/foo",
+			"status":   200,
+			"duration": 1500 * time.Millisecond,
+		}}.layout()
+
+		So(m["severity"], ShouldEqual, "INFO")
+		So(m["httpRequest"], ShouldResemble, map[string]interface{}{
+			"requestMethod": "GET",
+			"requestUrl":    "/foo",
+			"status":        200,
+			"latency":       "1.500000000s",
+		})
+	})
+
+	Convey("httpRequest should include the query, sizes and remote IP", t, func() {
+		h := gcpHTTPRequest(Data{
+			"method":         "POST",
+			"path":           "/foo",
+			"query":          "a=1",
+			"status":         201,
+			"bytes_received": int64(10),
+			"bytes_sent":     int64(2048),
+			"remote_addr":    "10.0.0.1:51234",
+			"http_version":   "1.1",
+		})
+
+		So(h["requestUrl"], ShouldEqual, "/foo?a=1")
+		So(h["requestSize"], ShouldEqual, "10")
+		So(h["responseSize"], ShouldEqual, "2048")
+		So(h["remoteIp"], ShouldEqual, "10.0.0.1")
+		So(h["protocol"], ShouldEqual, "HTTP/1.1")
+
+		h = gcpHTTPRequest(Data{"client_ip": "203.0.113.7", "remote_addr": "10.0.0.1:51234"})
+		So(h["remoteIp"], ShouldEqual, "203.0.113.7")
+	})
+
+	Convey("httpRequest should use the stream duration as latency", t, func() {
+		h := gcpHTTPRequest(Data{"stream_duration": 2 * time.Second})
+		So(h["latency"], ShouldEqual, "2.000000000s")
+	})
+
+	Convey("severities should map from levels", t, func() {
+		So(gcpSeverity(FATAL), ShouldEqual, "CRITICAL")
+		So(gcpSeverity(ERROR), ShouldEqual, "ERROR")
+		So(gcpSeverity(WARN), ShouldEqual, "WARNING")
+		So(gcpSeverity(INFO), ShouldEqual, "INFO")
+		So(gcpSeverity(DEBUG), ShouldEqual, "DEBUG")
+		So(gcpSeverity(TRACE), ShouldEqual, "DEBUG")
+		So(gcpSeverity(Level(99)), ShouldEqual, "DEFAULT")
+	})
+}
+
+func TestCloudTraceContext(t *testing.T) {
+	Convey("cloudTraceContext should parse X-Cloud-Trace-Context", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		traceID, spanID := cloudTraceContext(req)
+		So(traceID, ShouldBeEmpty)
+		So(spanID, ShouldBeEmpty)
+
+		req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+		traceID, spanID = cloudTraceContext(req)
+		So(traceID, ShouldEqual, "105445aa7843bc8bf206b12000100000")
+		So(spanID, ShouldEqual, "1")
+	})
+
+	Convey("cloudTraceContext should reject invalid trace and span IDs", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		for _, header := range []string{strings.Repeat("a", 200) + "/1;o=1", "not-a-trace/1", "105445aa7843bc8bf206b1200010000/1"} {
+			req.Header.Set("X-Cloud-Trace-Context", header)
+			traceID, spanID := cloudTraceContext(req)
+			So(traceID, ShouldBeEmpty)
+			So(spanID, ShouldBeEmpty)
+		}
+
+		req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/abc\n;o=1")
+		traceID, spanID := cloudTraceContext(req)
+		So(traceID, ShouldEqual, "105445aa7843bc8bf206b12000100000")
+		So(spanID, ShouldBeEmpty)
+	})
+}
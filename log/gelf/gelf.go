@@ -0,0 +1,128 @@
+// Package gelf encodes log events in the Graylog Extended Log Format (GELF)
+// and provides a log.Sink which ships them to Graylog over UDP or TCP
+package gelf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// GELF levels, which are syslog severities
+const (
+	levelCrit    = 2
+	levelErr     = 3
+	levelWarning = 4
+	levelInfo    = 6
+	levelDebug   = 7
+)
+
+// invalidField matches characters which aren't allowed in field names
+var invalidField = regexp.MustCompile(`[^\w.\-]`)
+
+// Encoder encodes events as GELF 1.1 messages. It can be used for stdout
+// with log.SetEncoder, and is used by Sink.
+type Encoder struct {
+	// Host is the host field, defaults to os.Hostname
+	Host string
+}
+
+// NewEncoder returns an Encoder with the host field set from os.Hostname
+func NewEncoder() Encoder {
+	host, _ := os.Hostname()
+	return Encoder{Host: host}
+}
+
+// Encode implements log.Encoder, returning a JSON message followed by a
+// newline
+func (e Encoder) Encode(r log.Record) ([]byte, error) {
+	b, err := e.marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// marshal returns a GELF message without framing
+func (e Encoder) marshal(r log.Record) ([]byte, error) {
+	host := e.Host
+	if len(host) == 0 {
+		host = "unknown"
+	}
+
+	m := map[string]interface{}{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": shortMessage(r),
+		"timestamp":     float64(r.Created.UnixNano()) / float64(time.Second),
+		"level":         level(r.Level()),
+		"_event":        r.Event,
+		"_namespace":    r.Namespace,
+	}
+	if len(r.Context) > 0 {
+		m["_context"] = r.Context
+	}
+
+	for k, v := range r.Data {
+		if k == "message" {
+			continue
+		}
+		// _id is reserved by Graylog
+		if k = invalidField.ReplaceAllString(k, "_"); k == "id" {
+			k = "id_"
+		}
+		m["_"+k] = fieldValue(v)
+	}
+
+	return json.Marshal(m)
+}
+
+// shortMessage returns the message of an event, or its name if it has none
+func shortMessage(r log.Record) string {
+	if msg, ok := r.Data["message"]; ok {
+		if s := fmt.Sprint(msg); len(s) > 0 {
+			return s
+		}
+	}
+	return r.Event
+}
+
+// fieldValue returns a value as a string or number, since GELF additional
+// fields can't be structured
+func fieldValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return t
+	case bool:
+		return fmt.Sprint(t)
+	case time.Duration:
+		return t.Nanoseconds()
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	}
+	if b, err := json.Marshal(v); err == nil {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+// level maps a log level to a GELF level
+func level(l log.Level) int {
+	switch l {
+	case log.TRACE, log.DEBUG:
+		return levelDebug
+	case log.WARN:
+		return levelWarning
+	case log.ERROR:
+		return levelErr
+	case log.FATAL:
+		return levelCrit
+	}
+	return levelInfo
+}
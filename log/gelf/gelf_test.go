@@ -0,0 +1,91 @@
+package gelf
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testRecord = log.Record{
+	Created:   time.Date(2018, 1, 2, 3, 4, 5, 500000000, time.UTC),
+	Event:     "error",
+	Namespace: "dp-api",
+	Context:   "request-1",
+	Data: log.Data{
+		"message":  "test error",
+		"id":       "cpih",
+		"status":   500,
+		"duration": time.Millisecond,
+		"error":    errors.New("test error"),
+		"labels":   map[string]string{"a": "b"},
+		"bad key!": true,
+	},
+}
+
+func decode(b []byte) map[string]interface{} {
+	var m map[string]interface{}
+	So(json.Unmarshal(b, &m), ShouldBeNil)
+	return m
+}
+
+func TestEncoder(t *testing.T) {
+	Convey("Encoder should encode GELF 1.1 messages", t, func() {
+		b, err := Encoder{Host: "web-1"}.Encode(testRecord)
+		So(err, ShouldBeNil)
+		So(b[len(b)-1], ShouldEqual, '\n')
+
+		m := decode(b)
+		So(m["version"], ShouldEqual, "1.1")
+		So(m["host"], ShouldEqual, "web-1")
+		So(m["short_message"], ShouldEqual, "test error")
+		So(m["timestamp"], ShouldEqual, 1514862245.5)
+		So(m["level"], ShouldEqual, 3)
+		So(m["_event"], ShouldEqual, "error")
+		So(m["_namespace"], ShouldEqual, "dp-api")
+		So(m["_context"], ShouldEqual, "request-1")
+	})
+
+	Convey("Encoder should flatten data into additional fields", t, func() {
+		b, err := Encoder{Host: "web-1"}.Encode(testRecord)
+		So(err, ShouldBeNil)
+
+		m := decode(b)
+		So(m, ShouldNotContainKey, "_id")
+		So(m, ShouldNotContainKey, "_message")
+		So(m["_id_"], ShouldEqual, "cpih")
+		So(m["_status"], ShouldEqual, 500)
+		So(m["_duration"], ShouldEqual, 1000000)
+		So(m["_error"], ShouldEqual, "test error")
+		So(m["_labels"], ShouldEqual, `{"a":"b"}`)
+		So(m["_bad_key_"], ShouldEqual, "true")
+	})
+
+	Convey("Encoder should use the event name when there's no message", t, func() {
+		b, err := Encoder{}.Encode(log.Record{Event: "request", Data: log.Data{"status": 200}})
+		So(err, ShouldBeNil)
+		m := decode(b)
+		So(m["short_message"], ShouldEqual, "request")
+		So(m["host"], ShouldEqual, "unknown")
+		So(m["level"], ShouldEqual, 6)
+	})
+
+	Convey("Encoder should use the warning level for warn events", t, func() {
+		b, err := Encoder{}.Encode(log.Record{Event: "warn", Data: log.Data{"message": "low disk"}})
+		So(err, ShouldBeNil)
+		So(decode(b)["level"], ShouldEqual, 4)
+	})
+
+	Convey("Encoder should use the critical level for fatal events", t, func() {
+		b, err := Encoder{}.Encode(log.Record{Event: "error", Data: log.Data{"message": "exiting", "severity": "fatal"}})
+		So(err, ShouldBeNil)
+		So(decode(b)["level"], ShouldEqual, 2)
+	})
+
+	Convey("NewEncoder should set the host from os.Hostname", t, func() {
+		So(NewEncoder().Host, ShouldNotBeEmpty)
+	})
+}
@@ -0,0 +1,185 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// chunk header magic bytes, and the maximum number of chunks in a message
+var chunkMagic = []byte{0x1e, 0x0f}
+
+const maxChunks = 128
+
+// Config configures a GELF sink
+type Config struct {
+	// Network is "udp" or "tcp", defaults to "udp"
+	Network string
+	// Address of the Graylog GELF input, e.g. "graylog.example.com:12201"
+	Address string
+	// Host is the host field of messages, defaults to os.Hostname
+	Host string
+	// Compress gzips UDP messages. TCP messages can't be compressed.
+	Compress bool
+	// ChunkSize is the maximum UDP datagram size, defaults to 1420 so
+	// chunks fit in an Ethernet frame
+	ChunkSize int
+}
+
+// ConfigFromEnv reads a Config from GELF_NETWORK, GELF_ADDR, GELF_HOST and
+// GELF_COMPRESS
+func ConfigFromEnv() Config {
+	c := Config{
+		Network: os.Getenv("GELF_NETWORK"),
+		Address: os.Getenv("GELF_ADDR"),
+		Host:    os.Getenv("GELF_HOST"),
+	}
+	c.Compress, _ = strconv.ParseBool(os.Getenv("GELF_COMPRESS"))
+	return c
+}
+
+// Sink writes log events to a Graylog GELF input. UDP messages larger than
+// ChunkSize are chunked, and TCP connections are re-established if a write
+// fails.
+type Sink struct {
+	cfg     Config
+	encoder Encoder
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// New returns a Sink connected to the GELF input in cfg
+func New(cfg Config) (*Sink, error) {
+	if len(cfg.Network) == 0 {
+		cfg.Network = "udp"
+	}
+	if cfg.Network != "udp" && cfg.Network != "tcp" {
+		return nil, fmt.Errorf("gelf: unsupported network %q", cfg.Network)
+	}
+	if cfg.ChunkSize == 0 {
+		cfg.ChunkSize = 1420
+	}
+	// each chunk has a 12 byte header
+	if cfg.ChunkSize <= 12 {
+		return nil, fmt.Errorf("gelf: chunk size %d is too small", cfg.ChunkSize)
+	}
+
+	encoder := Encoder{Host: cfg.Host}
+	if len(encoder.Host) == 0 {
+		encoder = NewEncoder()
+	}
+
+	s := &Sink{cfg: cfg, encoder: encoder}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) connect() error {
+	conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Address, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// Write sends a log event to Graylog, reconnecting once if the write fails
+func (s *Sink) Write(r log.Record) error {
+	b, err := s.encoder.marshal(r)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn != nil {
+		if err = s.write(b); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.write(b)
+}
+
+func (s *Sink) write(b []byte) error {
+	if s.cfg.Network == "tcp" {
+		// TCP messages are delimited by a null byte
+		_, err := s.conn.Write(append(b, 0))
+		return err
+	}
+
+	if s.cfg.Compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(b)
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+
+	if len(b) <= s.cfg.ChunkSize {
+		_, err := s.conn.Write(b)
+		return err
+	}
+	return s.writeChunks(b)
+}
+
+// writeChunks sends a message as a sequence of chunked datagrams
+func (s *Sink) writeChunks(b []byte) error {
+	size := s.cfg.ChunkSize - 12
+	count := (len(b) + size - 1) / size
+	if count > maxChunks {
+		return fmt.Errorf("gelf: message of %d bytes needs more than %d chunks", len(b), maxChunks)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, 0, s.cfg.ChunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * size
+		if end > len(b) {
+			end = len(b)
+		}
+		chunk = append(chunk[:0], chunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, b[i*size:end]...)
+		if _, err := s.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connection to Graylog
+func (s *Sink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
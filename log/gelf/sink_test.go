@@ -0,0 +1,134 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func readPacket(pc net.PacketConn) []byte {
+	buf := make([]byte, 65536)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	So(err, ShouldBeNil)
+	return buf[:n]
+}
+
+func TestSink(t *testing.T) {
+	Convey("New should reject invalid configuration", t, func() {
+		_, err := New(Config{Network: "unix", Address: "/dev/null"})
+		So(err, ShouldNotBeNil)
+
+		_, err = New(Config{Address: "127.0.0.1:12201", ChunkSize: 12})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Sink should send messages over UDP", t, func() {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer pc.Close()
+
+		s, err := New(Config{Address: pc.LocalAddr().String(), Host: "web-1"})
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		So(s.Write(testRecord), ShouldBeNil)
+		m := decode(readPacket(pc))
+		So(m["host"], ShouldEqual, "web-1")
+		So(m["short_message"], ShouldEqual, "test error")
+	})
+
+	Convey("Sink should compress UDP messages", t, func() {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer pc.Close()
+
+		s, err := New(Config{Address: pc.LocalAddr().String(), Compress: true})
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		So(s.Write(testRecord), ShouldBeNil)
+		r, err := gzip.NewReader(bytes.NewReader(readPacket(pc)))
+		So(err, ShouldBeNil)
+		b, err := ioutil.ReadAll(r)
+		So(err, ShouldBeNil)
+		So(decode(b)["short_message"], ShouldEqual, "test error")
+	})
+
+	Convey("Sink should chunk large UDP messages", t, func() {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer pc.Close()
+
+		s, err := New(Config{Address: pc.LocalAddr().String(), ChunkSize: 100})
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		r := testRecord
+		r.Data = log.Data{"message": strings.Repeat("x", 500)}
+		So(s.Write(r), ShouldBeNil)
+
+		var msg []byte
+		var id []byte
+		for i := 0; ; i++ {
+			chunk := readPacket(pc)
+			So(len(chunk), ShouldBeLessThanOrEqualTo, 100)
+			So(chunk[:2], ShouldResemble, chunkMagic)
+			if id == nil {
+				id = chunk[2:10]
+			}
+			So(chunk[2:10], ShouldResemble, id)
+			So(int(chunk[10]), ShouldEqual, i)
+			msg = append(msg, chunk[12:]...)
+			if int(chunk[11]) == i+1 {
+				break
+			}
+		}
+		So(decode(msg)["short_message"], ShouldEqual, strings.Repeat("x", 500))
+	})
+
+	Convey("Sink should send null delimited messages over TCP and reconnect", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer l.Close()
+
+		received := make(chan string, 2)
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				msg, _ := bufio.NewReader(conn).ReadString(0)
+				conn.Close()
+				received <- msg
+			}
+		}()
+
+		s, err := New(Config{Network: "tcp", Address: l.Addr().String()})
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		So(s.Write(testRecord), ShouldBeNil)
+		msg := <-received
+		So(msg[len(msg)-1], ShouldEqual, 0)
+		So(decode([]byte(msg[:len(msg)-1]))["short_message"], ShouldEqual, "test error")
+
+		// the server has closed the first connection, so writes fail until
+		// the sink reconnects
+		deadline := time.Now().Add(time.Second)
+		for len(received) == 0 && time.Now().Before(deadline) {
+			s.Write(testRecord)
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(len(received), ShouldBeGreaterThan, 0)
+	})
+}
@@ -0,0 +1,35 @@
+package log
+
+import "sync"
+
+// Hook is called for each event before it's written. It can enrich or
+// replace the event data, or return false to drop the event.
+type Hook func(name, context string, data Data) (Data, bool)
+
+var (
+	hooks      []Hook
+	hooksMutex sync.RWMutex
+)
+
+// RegisterHook adds a hook which is called for every event, in the order
+// hooks were registered, e.g. to attach a build version or drop health
+// check noise. Data returned by a hook is passed to the next.
+func RegisterHook(h Hook) {
+	hooksMutex.Lock()
+	defer hooksMutex.Unlock()
+	hooks = append(hooks, h)
+}
+
+// runHooks passes an event through the registered hooks, returning false if
+// any of them drop it
+func runHooks(name, context string, data Data) (Data, bool) {
+	hooksMutex.RLock()
+	defer hooksMutex.RUnlock()
+	for _, h := range hooks {
+		var ok bool
+		if data, ok = h(name, context, data); !ok {
+			return nil, false
+		}
+	}
+	return data, true
+}
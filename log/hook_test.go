@@ -0,0 +1,74 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHooks(t *testing.T) {
+	SetHumanReadable(false)
+	defer func() {
+		hooks = nil
+	}()
+
+	Convey("hooks should enrich events in registration order", t, func() {
+		hooks = nil
+		RegisterHook(func(name, context string, data Data) (Data, bool) {
+			if data == nil {
+				data = Data{}
+			}
+			data["version"] = "1.2.3"
+			return data, true
+		})
+		RegisterHook(func(name, context string, data Data) (Data, bool) {
+			data["hooked"] = name + "/" + context
+			return data, true
+		})
+
+		var buf bytes.Buffer
+		write(&buf, "test", "context", nil)
+
+		var m map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &m), ShouldBeNil)
+		So(m["data"], ShouldResemble, map[string]interface{}{
+			"version": "1.2.3",
+			"hooked":  "test/context",
+		})
+	})
+
+	Convey("hooks should be able to drop events", t, func() {
+		hooks = nil
+		called := false
+		RegisterHook(func(name, context string, data Data) (Data, bool) {
+			return data, !(name == "request" && data["path"] == "/healthcheck")
+		})
+		RegisterHook(func(name, context string, data Data) (Data, bool) {
+			called = true
+			return data, true
+		})
+
+		var buf bytes.Buffer
+		write(&buf, "request", "", Data{"path": "/healthcheck"})
+		So(buf.Len(), ShouldEqual, 0)
+		So(called, ShouldBeFalse)
+
+		write(&buf, "request", "", Data{"path": "/datasets"})
+		So(strings.Count(buf.String(), "\n"), ShouldEqual, 1)
+		So(called, ShouldBeTrue)
+	})
+
+	Convey("fields added by hooks should be redacted", t, func() {
+		hooks = nil
+		RegisterHook(func(name, context string, data Data) (Data, bool) {
+			return Data{"password": "secret"}, true
+		})
+
+		var buf bytes.Buffer
+		write(&buf, "test", "", nil)
+		So(buf.String(), ShouldNotContainSubstring, "secret")
+	})
+}
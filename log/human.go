@@ -0,0 +1,147 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// humanTimeFormat is used for times in human readable output
+const humanTimeFormat = "2006-01-02 15:04:05.000 MST"
+
+// humanValue formats a data value for human readable output: durations
+// like 12.3ms, byte counts like 4.2KB and times in the local zone
+func humanValue(key string, v interface{}) string {
+	switch t := v.(type) {
+	case time.Duration:
+		return humanDuration(t)
+	case time.Time:
+		return t.Local().Format(humanTimeFormat)
+	case map[string]time.Duration:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		items := make([]string, 0, len(keys))
+		for _, k := range keys {
+			items = append(items, k+":"+humanDuration(t[k]))
+		}
+		return "map[" + strings.Join(items, " ") + "]"
+	}
+
+	if isByteCount(key) {
+		if n, ok := toInt64(v); ok {
+			return humanBytes(n)
+		}
+	}
+
+	return fmt.Sprintf("%+v", v)
+}
+
+// humanDuration formats a duration with one decimal place in the largest
+// unit below a minute, e.g. 12.3ms or 1.5s
+func humanDuration(d time.Duration) string {
+	abs := d
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs < time.Microsecond:
+		return d.String()
+	case abs < time.Millisecond:
+		return humanUnit(float64(d)/float64(time.Microsecond), "µs")
+	case abs < time.Second:
+		return humanUnit(float64(d)/float64(time.Millisecond), "ms")
+	case abs < time.Minute:
+		return humanUnit(float64(d)/float64(time.Second), "s")
+	}
+	return d.Round(time.Second).String()
+}
+
+// humanBytes formats a byte count using binary units, e.g. 4.2KB
+func humanBytes(n int64) string {
+	const unit = 1024
+	if n < unit && n > -unit {
+		return strconv.FormatInt(n, 10) + "B"
+	}
+	v := float64(n)
+	for _, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		v /= unit
+		if v < unit && v > -unit {
+			return humanUnit(v, suffix)
+		}
+	}
+	return humanUnit(v/unit, "PB")
+}
+
+func humanUnit(v float64, unit string) string {
+	return strings.TrimSuffix(strconv.FormatFloat(v, 'f', 1, 64), ".0") + unit
+}
+
+// isByteCount reports whether a data field holds a number of bytes, e.g.
+// bytes_sent or body_size
+func isByteCount(key string) bool {
+	return key == "bytes" || key == "size" ||
+		strings.HasPrefix(key, "bytes_") ||
+		strings.HasSuffix(key, "_bytes") ||
+		strings.HasSuffix(key, "_size")
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch t := v.(type) {
+	case int:
+		return int64(t), true
+	case int32:
+		return int64(t), true
+	case int64:
+		return t, true
+	case uint:
+		return int64(t), true
+	case uint32:
+		return int64(t), true
+	case uint64:
+		return int64(t), true
+	}
+	return 0, false
+}
+
+// writePretty writes a data value after a prefix and label, writing the
+// entries of non-empty maps and slices on the following lines at indent,
+// with their own entries indented further, e.g.
+//
+//	-> headers:
+//	     Accept: application/json
+//	-> ids:
+//	     - 1
+//	     - 2
+func writePretty(w io.Writer, prefix, indent, label, key string, v interface{}) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Len() > 0 && rv.Type().Key().Kind() == reflect.String {
+			fmt.Fprintf(w, "%s%s\n", prefix, label)
+			keys := rv.MapKeys()
+			sort.Slice(keys, func(i, j int) bool {
+				return keys[i].String() < keys[j].String()
+			})
+			for _, k := range keys {
+				writePretty(w, indent, indent+"  ", k.String()+":", k.String(), rv.MapIndex(k).Interface())
+			}
+			return
+		}
+	case reflect.Slice, reflect.Array:
+		if rv.Len() > 0 && rv.Type().Elem().Kind() != reflect.Uint8 {
+			fmt.Fprintf(w, "%s%s\n", prefix, label)
+			for i := 0; i < rv.Len(); i++ {
+				writePretty(w, indent, indent+"  ", "-", key, rv.Index(i).Interface())
+			}
+			return
+		}
+	}
+	fmt.Fprintf(w, "%s%s %s\n", prefix, label, humanValue(key, v))
+}
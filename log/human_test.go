@@ -0,0 +1,103 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHumanValue(t *testing.T) {
+	Convey("humanDuration should use one decimal place in the largest unit", t, func() {
+		So(humanDuration(850*time.Nanosecond), ShouldEqual, "850ns")
+		So(humanDuration(12345*time.Nanosecond), ShouldEqual, "12.3µs")
+		So(humanDuration(12345*time.Microsecond), ShouldEqual, "12.3ms")
+		So(humanDuration(250*time.Millisecond), ShouldEqual, "250ms")
+		So(humanDuration(1500*time.Millisecond), ShouldEqual, "1.5s")
+		So(humanDuration(125*time.Second+400*time.Millisecond), ShouldEqual, "2m5s")
+		So(humanDuration(-12345*time.Microsecond), ShouldEqual, "-12.3ms")
+	})
+
+	Convey("humanBytes should use binary units", t, func() {
+		So(humanBytes(0), ShouldEqual, "0B")
+		So(humanBytes(512), ShouldEqual, "512B")
+		So(humanBytes(1024), ShouldEqual, "1KB")
+		So(humanBytes(4300), ShouldEqual, "4.2KB")
+		So(humanBytes(5*1024*1024+512*1024), ShouldEqual, "5.5MB")
+		So(humanBytes(3<<30), ShouldEqual, "3GB")
+	})
+
+	Convey("humanValue should only format byte counts for size fields", t, func() {
+		So(humanValue("bytes_sent", int64(4300)), ShouldEqual, "4.2KB")
+		So(humanValue("response_bytes", 2048), ShouldEqual, "2KB")
+		So(humanValue("body_size", uint64(100)), ShouldEqual, "100B")
+		So(humanValue("status", 4300), ShouldEqual, "4300")
+		So(humanValue("bytes_sent", "unknown"), ShouldEqual, "unknown")
+	})
+
+	Convey("humanValue should format times in the local zone", t, func() {
+		created := time.Date(2018, 3, 1, 12, 30, 15, 123456789, time.UTC)
+		So(humanValue("start", created), ShouldEqual, created.Local().Format(humanTimeFormat))
+	})
+
+	Convey("humanValue should format timing breakdowns", t, func() {
+		v := map[string]time.Duration{"handler": 20 * time.Millisecond, "auth": 1234 * time.Microsecond}
+		So(humanValue("timings", v), ShouldEqual, "map[auth:1.2ms handler:20ms]")
+	})
+
+	Convey("human readable output should use humanised values", t, func() {
+		var buf bytes.Buffer
+		fprintHumanReadable(&buf, "request", "", Data{
+			"duration":   12345 * time.Microsecond,
+			"bytes_sent": int64(4300),
+		}, map[string]interface{}{"created": "now"})
+
+		So(buf.String(), ShouldContainSubstring, "  -> duration: 12.3ms\n")
+		So(buf.String(), ShouldContainSubstring, "  -> bytes_sent: 4.2KB\n")
+	})
+}
+
+func TestPrettyPrint(t *testing.T) {
+	defer SetPrettyPrint(false)
+
+	data := func() Data {
+		return Data{"request": map[string]interface{}{
+			"headers":  map[string]string{"X-B": "2", "Accept": "application/json"},
+			"ids":      []int{1, 2},
+			"items":    []Data{{"size": 2048}},
+			"duration": 1500 * time.Millisecond,
+			"empty":    Data{},
+		}}
+	}
+
+	Convey("pretty printing should write nested data on indented lines", t, func() {
+		SetPrettyPrint(true)
+
+		var buf bytes.Buffer
+		fprintHumanReadable(&buf, "test", "", data(), map[string]interface{}{"created": "now"})
+
+		So(buf.String(), ShouldEqual, `now test
+  -> request:
+       duration: 1.5s
+       empty: map[]
+       headers:
+         Accept: application/json
+         X-B: 2
+       ids:
+         - 1
+         - 2
+       items:
+         -
+           size: 2KB
+`)
+	})
+
+	Convey("nested data should be written on a single line by default", t, func() {
+		SetPrettyPrint(false)
+
+		var buf bytes.Buffer
+		fprintHumanReadable(&buf, "test", "", Data{"ids": []int{1, 2}}, map[string]interface{}{"created": "now"})
+		So(buf.String(), ShouldEqual, "now test\n  -> ids: [1 2]\n")
+	})
+}
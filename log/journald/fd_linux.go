@@ -0,0 +1,38 @@
+package journald
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"syscall"
+)
+
+func tooLarge(err error) bool {
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.EMSGSIZE || err == syscall.ENOBUFS
+}
+
+// sendFile writes the message to an unlinked temporary file and passes its
+// file descriptor to journald
+func sendFile(conn *net.UnixConn, addr *net.UnixAddr, b []byte) error {
+	f, err := ioutil.TempFile("/dev/shm", "journal.")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err = os.Remove(f.Name()); err != nil {
+		return err
+	}
+	if _, err = f.Write(b); err != nil {
+		return err
+	}
+
+	_, _, err = conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), addr)
+	return err
+}
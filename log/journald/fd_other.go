@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package journald
+
+import (
+	"errors"
+	"net"
+)
+
+func tooLarge(err error) bool {
+	return false
+}
+
+func sendFile(conn *net.UnixConn, addr *net.UnixAddr, b []byte) error {
+	return errors.New("journald: passing large messages is only supported on linux")
+}
@@ -0,0 +1,199 @@
+// Package journald provides a log.Sink which writes events to the systemd
+// journal using its native protocol
+package journald
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// DefaultSocket is the path of the journald native protocol socket
+const DefaultSocket = "/run/systemd/journal/socket"
+
+// syslog priorities, as understood by journald
+const (
+	priCrit    = 2
+	priErr     = 3
+	priWarning = 4
+	priInfo    = 6
+	priDebug   = 7
+)
+
+// reserved are the fields set by the sink and the fields journald or its
+// clients give a special meaning, which Data keys are prefixed to avoid
+var reserved = map[string]bool{
+	"MESSAGE":            true,
+	"MESSAGE_ID":         true,
+	"PRIORITY":           true,
+	"CODE_FILE":          true,
+	"CODE_LINE":          true,
+	"CODE_FUNC":          true,
+	"ERRNO":              true,
+	"INVOCATION_ID":      true,
+	"USER_INVOCATION_ID": true,
+	"SYSLOG_FACILITY":    true,
+	"SYSLOG_IDENTIFIER":  true,
+	"SYSLOG_PID":         true,
+	"SYSLOG_TIMESTAMP":   true,
+	"SYSLOG_RAW":         true,
+	"DOCUMENTATION":      true,
+	"TID":                true,
+	"UNIT":               true,
+	"USER_UNIT":          true,
+	"EVENT":              true,
+	"CONTEXT":            true,
+}
+
+// Sink writes log events to journald, mapping the event name to a syslog
+// priority and each Data key to its own journal field. Data keys which
+// would override a reserved field, e.g. priority, are prefixed with DATA_.
+type Sink struct {
+	conn *net.UnixConn
+	addr *net.UnixAddr
+}
+
+// New returns a Sink which writes to the journald socket at the given path,
+// or DefaultSocket if path is empty
+func New(path string) (*Sink, error) {
+	if len(path) == 0 {
+		path = DefaultSocket
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sink{
+		conn: conn,
+		addr: &net.UnixAddr{Name: path, Net: "unixgram"},
+	}, nil
+}
+
+// Write sends a log event to journald
+func (s *Sink) Write(r log.Record) error {
+	b := encode(r)
+
+	_, _, err := s.conn.WriteMsgUnix(b, nil, s.addr)
+	if err != nil && tooLarge(err) {
+		// messages too large for a single datagram must be passed to
+		// journald as a file descriptor
+		return sendFile(s.conn, s.addr, b)
+	}
+	return err
+}
+
+// Close closes the connection to journald
+func (s *Sink) Close() error {
+	return s.conn.Close()
+}
+
+func encode(r log.Record) []byte {
+	var buf bytes.Buffer
+
+	message := r.Event
+	if m, ok := r.Data["message"]; ok {
+		message = fmt.Sprintf("%s", m)
+	}
+
+	writeField(&buf, "MESSAGE", message)
+	writeField(&buf, "PRIORITY", fmt.Sprintf("%d", priority(r.Level())))
+	writeField(&buf, "SYSLOG_IDENTIFIER", r.Namespace)
+	writeField(&buf, "EVENT", r.Event)
+	if len(r.Context) > 0 {
+		writeField(&buf, "CONTEXT", r.Context)
+	}
+
+	for k, v := range r.Data {
+		if k == "message" {
+			continue
+		}
+		if name := fieldName(k); len(name) > 0 {
+			writeField(&buf, name, value(v))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// priority maps a level to a syslog priority
+func priority(l log.Level) int {
+	switch l {
+	case log.TRACE, log.DEBUG:
+		return priDebug
+	case log.WARN:
+		return priWarning
+	case log.ERROR:
+		return priErr
+	case log.FATAL:
+		return priCrit
+	}
+	return priInfo
+}
+
+// writeField appends a field using the simple KEY=value form, or the
+// length-prefixed binary form if the value contains a newline
+func writeField(buf *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		fmt.Fprintf(buf, "%s=%s\n", name, value)
+		return
+	}
+
+	buf.WriteString(name)
+	buf.WriteByte('\n')
+	binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// fieldName converts a Data key into a valid journal field name: upper case
+// letters, digits and underscores, not starting with an underscore or digit,
+// and not a reserved field
+func fieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+
+	name = strings.TrimLeft(name, "_0123456789")
+	if reserved[name] {
+		name = "DATA_" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
+
+func value(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case error:
+		return t.Error()
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return t.String()
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, bool:
+		return fmt.Sprintf("%v", t)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(b)
+}
@@ -0,0 +1,108 @@
+package journald
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPriority(t *testing.T) {
+	Convey("levels should map to syslog priorities", t, func() {
+		So(priority(log.FATAL), ShouldEqual, priCrit)
+		So(priority(log.ERROR), ShouldEqual, priErr)
+		So(priority(log.WARN), ShouldEqual, priWarning)
+		So(priority(log.INFO), ShouldEqual, priInfo)
+		So(priority(log.DEBUG), ShouldEqual, priDebug)
+		So(priority(log.TRACE), ShouldEqual, priDebug)
+	})
+}
+
+func TestFieldName(t *testing.T) {
+	Convey("Data keys should be converted to valid journal field names", t, func() {
+		So(fieldName("status"), ShouldEqual, "STATUS")
+		So(fieldName("bytes-sent"), ShouldEqual, "BYTES_SENT")
+		So(fieldName("_private"), ShouldEqual, "PRIVATE")
+		So(fieldName("1st"), ShouldEqual, "ST")
+		So(fieldName("___"), ShouldBeEmpty)
+	})
+
+	Convey("Data keys should not override reserved fields", t, func() {
+		So(fieldName("priority"), ShouldEqual, "DATA_PRIORITY")
+		So(fieldName("message_id"), ShouldEqual, "DATA_MESSAGE_ID")
+		So(fieldName("_syslog_identifier"), ShouldEqual, "DATA_SYSLOG_IDENTIFIER")
+	})
+}
+
+func TestWriteField(t *testing.T) {
+	Convey("single line values should use the KEY=value form", t, func() {
+		var buf bytes.Buffer
+		writeField(&buf, "FOO", "bar")
+		So(buf.String(), ShouldEqual, "FOO=bar\n")
+	})
+
+	Convey("multi line values should use the binary form", t, func() {
+		var buf bytes.Buffer
+		writeField(&buf, "FOO", "a\nb")
+
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], 3)
+		So(buf.String(), ShouldEqual, "FOO\n"+string(size[:])+"a\nb\n")
+	})
+}
+
+func TestSink(t *testing.T) {
+	Convey("Sink should write events to the journal socket", t, func() {
+		dir, err := ioutil.TempDir("", "journald")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "socket")
+		journal, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		So(err, ShouldBeNil)
+		defer journal.Close()
+
+		s, err := New(path)
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		err = s.Write(log.Record{
+			Created:   time.Now(),
+			Event:     "error",
+			Namespace: "namespace",
+			Context:   "context",
+			Data:      log.Data{"message": "test error", "error": errors.New("test error"), "status": 500, "priority": "high"},
+		})
+		So(err, ShouldBeNil)
+
+		b := make([]byte, 4096)
+		n, err := journal.Read(b)
+		So(err, ShouldBeNil)
+
+		msg := string(b[:n])
+		So(msg, ShouldContainSubstring, "MESSAGE=test error\n")
+		So(msg, ShouldContainSubstring, "PRIORITY=3\n")
+		So(msg, ShouldContainSubstring, "SYSLOG_IDENTIFIER=namespace\n")
+		So(msg, ShouldContainSubstring, "EVENT=error\n")
+		So(msg, ShouldContainSubstring, "CONTEXT=context\n")
+		So(msg, ShouldContainSubstring, "ERROR=test error\n")
+		So(msg, ShouldContainSubstring, "STATUS=500\n")
+		So(msg, ShouldContainSubstring, "DATA_PRIORITY=high\n")
+		So(msg, ShouldNotContainSubstring, "\nPRIORITY=high\n")
+	})
+
+	Convey("New should default to the systemd socket path", t, func() {
+		s, err := New("")
+		So(err, ShouldBeNil)
+		defer s.Close()
+		So(s.addr.Name, ShouldEqual, DefaultSocket)
+	})
+}
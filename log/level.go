@@ -0,0 +1,209 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Level is the severity of a log event
+type Level int
+
+// Levels, in increasing order of severity
+const (
+	TRACE Level = iota
+	DEBUG
+	INFO
+	WARN
+	ERROR
+	FATAL
+)
+
+var levelNames = map[Level]string{
+	TRACE: "trace",
+	DEBUG: "debug",
+	INFO:  "info",
+	WARN:  "warn",
+	ERROR: "error",
+	FATAL: "fatal",
+}
+
+// ParseLevel parses a level name, case insensitively
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for l, n := range levelNames {
+		if n == name {
+			return l, nil
+		}
+	}
+	return TRACE, fmt.Errorf("log: unknown level %q", s)
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// LevelOf returns the level of an event from its name. Sinks should use
+// Record.Level, which also recognises events from Fatal and Panic.
+func LevelOf(event string) Level {
+	switch event {
+	case "trace":
+		return TRACE
+	case "debug":
+		return DEBUG
+	case "warn", "slow_request":
+		return WARN
+	case "error":
+		return ERROR
+	}
+	return INFO
+}
+
+// eventLevel returns the level of an event, which is FATAL for events
+// logged by the Fatal and Panic functions
+func eventLevel(event string, data Data) Level {
+	switch data["severity"] {
+	case "fatal", "panic":
+		return FATAL
+	}
+	return LevelOf(event)
+}
+
+var minLevel int32
+
+func configureLevel() {
+	SetLevel(TRACE)
+	if v := os.Getenv("LOG_LEVEL"); len(v) > 0 {
+		if l, err := ParseLevel(v); err == nil {
+			SetLevel(l)
+		}
+	}
+
+	SetLevels(nil)
+	if v := os.Getenv("LOG_LEVELS"); len(v) > 0 {
+		if levels, err := ParseLevels(v); err == nil {
+			SetLevels(levels)
+		}
+	}
+}
+
+// SetLevel sets the minimum level of events which are logged. It defaults
+// to TRACE, or the LOG_LEVEL environment variable if set, and can be
+// changed at runtime.
+func SetLevel(l Level) {
+	atomic.StoreInt32(&minLevel, int32(l))
+}
+
+// GetLevel returns the minimum level of events which are logged
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&minLevel))
+}
+
+var (
+	levels      map[string]Level
+	levelsMutex sync.RWMutex
+)
+
+// ParseLevels parses level overrides in the LOG_LEVELS format, a comma
+// separated list of name=level pairs, e.g. "kafka=debug,mongo=warn"
+func ParseLevels(s string) (map[string]Level, error) {
+	m := map[string]Level{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); len(item) == 0 {
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || len(strings.TrimSpace(kv[0])) == 0 {
+			return nil, fmt.Errorf("log: invalid level override %q", item)
+		}
+		l, err := ParseLevel(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		m[strings.TrimSpace(kv[0])] = l
+	}
+	return m, nil
+}
+
+// SetLevels replaces the level overrides for named loggers and namespaces,
+// which default to the LOG_LEVELS environment variable. A logger named
+// kafka.consumer uses the override for kafka.consumer, then kafka, then the
+// namespace, then the level set by SetLevel.
+func SetLevels(overrides map[string]Level) {
+	m := make(map[string]Level, len(overrides))
+	for k, v := range overrides {
+		m[k] = v
+	}
+
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	levels = m
+}
+
+// levelFor returns the minimum level for events from a named logger
+func levelFor(logger string) Level {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+
+	if len(levels) > 0 {
+		for name := logger; len(name) > 0; {
+			if l, ok := levels[name]; ok {
+				return l
+			}
+			i := strings.LastIndexAny(name, "./")
+			if i < 0 {
+				break
+			}
+			name = name[:i]
+		}
+		if l, ok := levels[GetNamespace()]; ok {
+			return l
+		}
+	}
+
+	return GetLevel()
+}
+
+var routeLevels map[string]Level
+
+// SetRouteLevels replaces the level overrides for routes, keyed by the
+// pattern recorded with SetRoute, e.g. /healthcheck or /users/{id}. They
+// apply to events with a "route" field, i.e. the request event and events
+// logged with the *R functions once the route is known, and take
+// precedence over the overrides for named loggers.
+func SetRouteLevels(overrides map[string]Level) {
+	m := make(map[string]Level, len(overrides))
+	for k, v := range overrides {
+		m[k] = v
+	}
+
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	routeLevels = m
+}
+
+// routeLevel returns the level override for a route, if there is one
+func routeLevel(route string) (Level, bool) {
+	levelsMutex.RLock()
+	defer levelsMutex.RUnlock()
+	l, ok := routeLevels[route]
+	return l, ok
+}
+
+// enabled reports whether an event is logged at the current level for the
+// route it was logged for, from the "route" field, or the logger which
+// recorded it, from the "logger" field
+func enabled(event string, data Data) bool {
+	if route, ok := data["route"].(string); ok {
+		if l, ok := routeLevel(route); ok {
+			return eventLevel(event, data) >= l
+		}
+	}
+	logger, _ := data["logger"].(string)
+	return eventLevel(event, data) >= levelFor(logger)
+}
@@ -0,0 +1,199 @@
+package log
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLevel(t *testing.T) {
+	Convey("levels should be ordered by severity", t, func() {
+		So(TRACE < DEBUG, ShouldBeTrue)
+		So(DEBUG < INFO, ShouldBeTrue)
+		So(INFO < WARN, ShouldBeTrue)
+		So(WARN < ERROR, ShouldBeTrue)
+		So(ERROR < FATAL, ShouldBeTrue)
+	})
+
+	Convey("levels should have lower case names", t, func() {
+		So(TRACE.String(), ShouldEqual, "trace")
+		So(WARN.String(), ShouldEqual, "warn")
+		So(FATAL.String(), ShouldEqual, "fatal")
+		So(Level(99).String(), ShouldEqual, "unknown")
+	})
+
+	Convey("levelOf should map event names to levels", t, func() {
+		So(LevelOf("trace"), ShouldEqual, TRACE)
+		So(LevelOf("debug"), ShouldEqual, DEBUG)
+		So(LevelOf("info"), ShouldEqual, INFO)
+		So(LevelOf("request"), ShouldEqual, INFO)
+		So(LevelOf("warn"), ShouldEqual, WARN)
+		So(LevelOf("slow_request"), ShouldEqual, WARN)
+		So(LevelOf("error"), ShouldEqual, ERROR)
+		So(LevelOf("anything"), ShouldEqual, INFO)
+	})
+}
+
+func TestSetLevel(t *testing.T) {
+	defer func() {
+		os.Unsetenv("LOG_LEVEL")
+		configureLevel()
+	}()
+
+	Convey("ParseLevel should parse level names", t, func() {
+		l, err := ParseLevel("WARN")
+		So(err, ShouldBeNil)
+		So(l, ShouldEqual, WARN)
+
+		l, err = ParseLevel(" debug ")
+		So(err, ShouldBeNil)
+		So(l, ShouldEqual, DEBUG)
+
+		_, err = ParseLevel("verbose")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("LOG_LEVEL environment variable should configure the level", t, func() {
+		So(GetLevel(), ShouldEqual, TRACE)
+
+		os.Setenv("LOG_LEVEL", "error")
+		configureLevel()
+		So(GetLevel(), ShouldEqual, ERROR)
+
+		os.Setenv("LOG_LEVEL", "invalid")
+		configureLevel()
+		So(GetLevel(), ShouldEqual, TRACE)
+	})
+
+	Convey("Events below the level should be dropped", t, func() {
+		SetHumanReadable(false)
+		SetLevel(INFO)
+
+		stdout := captureOutput(func() {
+			Debug("dropped", nil)
+			Trace("dropped", nil)
+			Info("kept", nil)
+			Event("request", "", nil)
+			Error(errors.New("kept"), nil)
+		})
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		So(lines, ShouldHaveLength, 3)
+		So(lines[0], ShouldContainSubstring, `"event":"info"`)
+		So(lines[1], ShouldContainSubstring, `"event":"request"`)
+		So(lines[2], ShouldContainSubstring, `"event":"error"`)
+	})
+
+	Convey("Fatal and Panic events should be logged at the FATAL level", t, func() {
+		defer func() {
+			SetExitFunc(nil)
+			SetPanicFunc(nil)
+		}()
+		SetExitFunc(func(int) {})
+		SetPanicFunc(func(interface{}) {})
+		SetHumanReadable(false)
+		SetLevel(FATAL)
+
+		stdout := captureOutput(func() {
+			Error(errors.New("dropped"), nil)
+			Fatal(errors.New("fatal"), nil)
+			Panic(errors.New("panic"), nil)
+		})
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		So(lines, ShouldHaveLength, 2)
+		So(lines[0], ShouldContainSubstring, `"severity":"fatal"`)
+		So(lines[1], ShouldContainSubstring, `"severity":"panic"`)
+	})
+
+	Convey("Record.Level should recognise Fatal and Panic events", t, func() {
+		So(Record{Event: "error", Data: Data{"severity": "fatal"}}.Level(), ShouldEqual, FATAL)
+		So(Record{Event: "error", Data: Data{"severity": "panic"}}.Level(), ShouldEqual, FATAL)
+		So(Record{Event: "error"}.Level(), ShouldEqual, ERROR)
+		So(Record{Event: "request", Data: Data{"severity": "low"}}.Level(), ShouldEqual, INFO)
+	})
+}
+
+func TestSetLevels(t *testing.T) {
+	defer func() {
+		os.Unsetenv("LOG_LEVELS")
+		configureLevel()
+	}()
+
+	Convey("ParseLevels should parse name=level pairs", t, func() {
+		levels, err := ParseLevels("kafka=debug, mongo=WARN,")
+		So(err, ShouldBeNil)
+		So(levels, ShouldResemble, map[string]Level{"kafka": DEBUG, "mongo": WARN})
+
+		_, err = ParseLevels("kafka")
+		So(err, ShouldNotBeNil)
+
+		_, err = ParseLevels("kafka=verbose")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("levelFor should resolve overrides hierarchically", t, func() {
+		SetLevel(INFO)
+		SetLevels(map[string]Level{"kafka": DEBUG, "kafka.producer": ERROR, GetNamespace(): WARN})
+
+		So(levelFor("kafka"), ShouldEqual, DEBUG)
+		So(levelFor("kafka.consumer"), ShouldEqual, DEBUG)
+		So(levelFor("kafka.producer.retry"), ShouldEqual, ERROR)
+		So(levelFor("mongo"), ShouldEqual, WARN)
+		So(levelFor(""), ShouldEqual, WARN)
+
+		SetLevels(nil)
+		So(levelFor("kafka"), ShouldEqual, INFO)
+	})
+
+	Convey("LOG_LEVELS environment variable should configure overrides", t, func() {
+		os.Setenv("LOG_LEVELS", "kafka=error")
+		configureLevel()
+		So(levelFor("kafka.consumer"), ShouldEqual, ERROR)
+		So(levelFor("mongo"), ShouldEqual, TRACE)
+	})
+
+	Convey("Named loggers should use their level override", t, func() {
+		SetHumanReadable(false)
+		SetLevel(INFO)
+		SetLevels(map[string]Level{"kafka": DEBUG, "mongo": ERROR})
+
+		stdout := captureOutput(func() {
+			Named("kafka").Named("consumer").Debug("kept", nil)
+			Named("mongo").Debug("dropped", nil)
+			Named("mongo").Event("request", nil)
+			Debug("dropped", nil)
+		})
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		So(lines, ShouldHaveLength, 1)
+		So(lines[0], ShouldContainSubstring, `"logger":"kafka.consumer"`)
+		So(lines[0], ShouldContainSubstring, `"message":"kept"`)
+	})
+
+	Convey("Route level overrides should apply to events for the route", t, func() {
+		defer SetRouteLevels(nil)
+		SetHumanReadable(false)
+		SetLevel(INFO)
+		SetLevels(map[string]Level{GetNamespace(): DEBUG})
+		SetRouteLevels(map[string]Level{"/healthcheck": WARN})
+
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			SetRoute(req, req.URL.Path)
+			InfoR(req, "handling", nil)
+		}))
+		stdout := captureOutput(func() {
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/healthcheck", nil))
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/datasets", nil))
+			Debug("kept", nil)
+		})
+		lines := strings.Split(strings.TrimSpace(stdout), "\n")
+		So(lines, ShouldHaveLength, 3)
+		So(lines[0], ShouldContainSubstring, `"route":"/datasets"`)
+		So(lines[0], ShouldContainSubstring, `"message":"handling"`)
+		So(lines[1], ShouldContainSubstring, `"event":"request"`)
+		So(lines[2], ShouldContainSubstring, `"message":"kept"`)
+	})
+}
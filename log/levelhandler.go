@@ -0,0 +1,102 @@
+package log
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// LevelHandler serves the current log level as JSON on GET, and sets it on
+// PUT or POST from a "level" query parameter or the request body, e.g.
+//
+//	curl -X PUT localhost:8080/debug/loglevel -d debug
+//
+// It should only be exposed on an internal or authenticated route.
+func LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPut, http.MethodPost:
+			value := req.URL.Query().Get("level")
+			if len(value) == 0 {
+				b, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, 64))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				value = string(b)
+			}
+			l, err := ParseLevel(value)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			changeLevel(l, "http", Context(req))
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"level": GetLevel().String()})
+	})
+}
+
+// ReloadLevelOnSIGHUP sets the log level from the contents of a file, e.g.
+// a mounted config map, whenever the process receives SIGHUP. It returns a
+// function which stops handling the signal.
+func ReloadLevelOnSIGHUP(path string) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGHUP)
+
+	go func() {
+		for {
+			select {
+			case <-signals:
+				b, err := ioutil.ReadFile(path)
+				if err != nil {
+					printLogError("", err)
+					continue
+				}
+				l, err := ParseLevel(strings.TrimSpace(string(b)))
+				if err != nil {
+					printLogError("", err)
+					continue
+				}
+				changeLevel(l, "sighup", "")
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// changeLevel sets the log level and records a "log_level" event. The event
+// bypasses the level filter, so the change is visible whatever the level.
+func changeLevel(l Level, source, context string) {
+	from := GetLevel()
+	SetLevel(l)
+
+	r := Record{
+		Created:   now(),
+		Event:     "log_level",
+		Namespace: GetNamespace(),
+		Context:   context,
+		Data:      Data{"from": from.String(), "to": l.String(), "source": source},
+	}
+	if enqueue(nil, r) {
+		return
+	}
+	emit(nil, r)
+}
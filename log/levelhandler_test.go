@@ -0,0 +1,95 @@
+package log
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLevelHandler(t *testing.T) {
+	defer func() {
+		SetLevel(TRACE)
+		SetOutput(nil)
+	}()
+	SetHumanReadable(false)
+
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, target, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		LevelHandler().ServeHTTP(w, req)
+		return w
+	}
+
+	Convey("LevelHandler should serve the current level", t, func() {
+		SetLevel(INFO)
+		w := serve("GET", "/debug/loglevel", "")
+		So(w.Code, ShouldEqual, 200)
+		So(w.Body.String(), ShouldEqual, `{"level":"info"}`+"\n")
+	})
+
+	Convey("LevelHandler should set the level and log the change", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		SetLevel(DEBUG)
+
+		w := serve("PUT", "/debug/loglevel", "error\n")
+		So(w.Code, ShouldEqual, 200)
+		So(w.Body.String(), ShouldEqual, `{"level":"error"}`+"\n")
+		So(GetLevel(), ShouldEqual, ERROR)
+
+		So(buf.String(), ShouldContainSubstring, `"event":"log_level"`)
+		So(buf.String(), ShouldContainSubstring, `"from":"debug"`)
+		So(buf.String(), ShouldContainSubstring, `"to":"error"`)
+
+		w = serve("POST", "/debug/loglevel?level=trace", "")
+		So(w.Code, ShouldEqual, 200)
+		So(GetLevel(), ShouldEqual, TRACE)
+	})
+
+	Convey("LevelHandler should reject unknown levels and methods", t, func() {
+		SetLevel(INFO)
+		So(serve("PUT", "/debug/loglevel", "verbose").Code, ShouldEqual, http.StatusBadRequest)
+		So(serve("DELETE", "/debug/loglevel", "").Code, ShouldEqual, http.StatusMethodNotAllowed)
+		So(GetLevel(), ShouldEqual, INFO)
+	})
+}
+
+func TestReloadLevelOnSIGHUP(t *testing.T) {
+	defer func() {
+		SetLevel(TRACE)
+		SetOutput(nil)
+	}()
+
+	Convey("ReloadLevelOnSIGHUP should set the level from a file on SIGHUP", t, func() {
+		SetOutput(ioutil.Discard)
+		SetLevel(INFO)
+
+		dir, err := ioutil.TempDir("", "loglevel")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "level")
+		So(ioutil.WriteFile(path, []byte("warn\n"), 0644), ShouldBeNil)
+
+		stop := ReloadLevelOnSIGHUP(path)
+		defer stop()
+
+		p, err := os.FindProcess(os.Getpid())
+		So(err, ShouldBeNil)
+		So(p.Signal(syscall.SIGHUP), ShouldBeNil)
+
+		deadline := time.Now().Add(time.Second)
+		for GetLevel() != WARN && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		So(GetLevel(), ShouldEqual, WARN)
+	})
+}
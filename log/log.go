@@ -1,153 +1,669 @@
 package log
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 	"time"
+)
 
-	"github.com/mgutz/ansi"
+// OutputMode controls the field layout of JSON log events
+type OutputMode int
+
+// Available output modes
+const (
+	// DefaultMode uses the created, event, namespace, context and data fields
+	DefaultMode OutputMode = iota
+	// GCPMode uses the fields expected by Google Cloud Logging
+	GCPMode
+	// DatadogMode uses Datadog's standard attribute names
+	DatadogMode
 )
 
-// Namespace is the service namespace used for logging
-var Namespace = "service-namespace"
+// Mode is the field layout used for JSON log events.
+//
+// Deprecated: use SetMode. Mode is read once, when the first event is logged.
+var Mode = DefaultMode
+
+// SetMode sets the field layout used for JSON log events
+func SetMode(mode OutputMode) {
+	updateConfig(func(c *config) {
+		c.mode = mode
+	})
+}
+
+// GetMode returns the field layout used for JSON log events
+func GetMode() OutputMode {
+	return getConfig().mode
+}
+
+// ExtraFields are added to the data of every event.
+//
+// Deprecated: use SetExtraFields. ExtraFields is read once, when the first
+// event is logged.
+var ExtraFields Data
+
+// SetExtraFields sets fields which are added to the data of every event,
+// without replacing fields set by the event itself. They default to
+// LOG_EXTRA_FIELDS, e.g. LOG_EXTRA_FIELDS=region=eu-west-1,team=data
+func SetExtraFields(fields Data) {
+	fields = mergeData(nil, fields)
+	updateConfig(func(c *config) {
+		c.extraFields = fields
+	})
+}
 
-// HumanReadable, if true, outputs log events in a human readable format
-var HumanReadable bool
+// GetExtraFields returns a copy of the fields added to every event
+func GetExtraFields() Data {
+	return mergeData(nil, getConfig().extraFields)
+}
+
+// mergeData returns a copy of a overlaid with b, or nil if both are empty
+func mergeData(a, b Data) Data {
+	if len(a)+len(b) == 0 {
+		return nil
+	}
+	m := make(Data, len(a)+len(b))
+	for k, v := range a {
+		m[k] = v
+	}
+	for k, v := range b {
+		m[k] = v
+	}
+	return m
+}
 
 func init() {
 	configureHumanReadable()
+	configureExtraFields()
+	configureLevel()
+	configureCaller()
+	configureColor()
+	configureTheme()
+	configureTime()
+	configureFormat()
+	configureAccessLog()
+	configureFieldMap()
+	configureOutput()
 }
 
+// configureHumanReadable reads HUMAN_LOG, which is a boolean, or "pretty"
+// for human readable output with nested data pretty printed
 func configureHumanReadable() {
-	HumanReadable, _ = strconv.ParseBool(os.Getenv("HUMAN_LOG"))
+	v := os.Getenv("HUMAN_LOG")
+	pretty := strings.EqualFold(strings.TrimSpace(v), "pretty")
+	humanReadable, _ := strconv.ParseBool(v)
+	SetHumanReadable(humanReadable || pretty)
+	SetPrettyPrint(pretty)
+
+	// mirrored for existing code which reads the deprecated variable
+	HumanReadable = humanReadable || pretty
+	envHumanReadable = HumanReadable
+}
+
+func configureExtraFields() {
+	var fields Data
+	for _, field := range strings.Split(os.Getenv("LOG_EXTRA_FIELDS"), ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		key := strings.TrimSpace(kv[0])
+		if len(key) == 0 {
+			continue
+		}
+		if fields == nil {
+			fields = Data{}
+		}
+		fields[key] = strings.TrimSpace(kv[1])
+	}
+	SetExtraFields(fields)
+}
+
+// withExtraFields returns a copy of data including the extra fields
+func withExtraFields(data Data) Data {
+	extra := getConfig().extraFields
+	if len(extra) == 0 {
+		return data
+	}
+	return mergeData(extra, data)
 }
 
 // Data contains structured log data
 type Data map[string]interface{}
 
-// Context returns a context ID from a request (using X-Request-Id)
+// Context returns a context ID from a request (using X-Request-Id). Trace
+// IDs are logged separately, as trace_id and span_id.
 func Context(req *http.Request) string {
 	return req.Header.Get("X-Request-Id")
 }
 
+// TraceID returns the trace ID from a W3C traceparent header or any
+// supported vendor trace header on a request, or "" if there isn't one
+func TraceID(req *http.Request) string {
+	traceID, _ := traceContext(req)
+	return traceID
+}
+
+// traceContext returns the trace and span IDs from a W3C traceparent header
+// or any supported vendor trace header on a request
+func traceContext(req *http.Request) (traceID, spanID string) {
+	if traceID, spanID = traceparentContext(req); len(traceID) > 0 {
+		return
+	}
+	if traceID, spanID = cloudTraceContext(req); len(traceID) > 0 {
+		return
+	}
+	return datadogTraceContext(req)
+}
+
+// withRequestFields adds the trace IDs, selected baggage and route from a
+// request to data, so every event logged for a request can be correlated
+func withRequestFields(req *http.Request, data Data) Data {
+	return withRoute(req, withBaggage(req, withTraceFields(req, data)))
+}
+
+// HandlerOptions configures request logging
+type HandlerOptions struct {
+	// SkipPaths are path.Match globs for requests which don't log a request
+	// event, e.g. /healthcheck or /debug/*
+	SkipPaths []string
+	// Skip returns true for requests which don't log a request event. It's
+	// called after the request is handled, so e.g. only successful health
+	// checks can be skipped.
+	Skip func(req *http.Request, status int) bool
+	// RequestHeaders and ResponseHeaders list headers which are added to
+	// the request event as "request_headers" and "response_headers"
+	RequestHeaders  []string
+	ResponseHeaders []string
+	// Query adds the query string to the request event as "query", with the
+	// values of RedactQueryParams and RedactKeys masked
+	Query bool
+	// RedactQueryParams lists query parameters whose values are masked,
+	// e.g. api_key
+	RedactQueryParams []string
+	// SlowThreshold logs an additional "slow_request" warning event for
+	// requests which take longer, if it's set. Event streams are excluded.
+	SlowThreshold time.Duration
+	// CaptureBody adds the start of the request body to the request event
+	// as "request_body", with "request_body_truncated" set if it's longer
+	// than MaxBodyBytes. The captured bytes are replayed to the wrapped
+	// handler, followed by the rest of the body, which isn't buffered.
+	// Complete JSON bodies have their RedactKeys masked, but other bodies
+	// are logged as they are.
+	CaptureBody bool
+	// MaxBodyBytes caps the captured body, defaulting to 4096 bytes
+	MaxBodyBytes int
+}
+
 // Handler wraps a http.Handler and logs the status code and total response time
 func Handler(h http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		rc := &responseCapture{w, 0}
-
-		s := time.Now()
-		h.ServeHTTP(rc, req)
-		e := time.Now()
-		d := e.Sub(s)
-
-		Event("request", Context(req), Data{
-			"start":    s,
-			"end":      e,
-			"duration": d,
-			"status":   rc.statusCode,
-			"method":   req.Method,
-			"path":     req.URL.Path,
+	return HandlerWithOptions(HandlerOptions{})(h)
+}
+
+// HandlerWithOptions logs the status code and total response time of
+// requests which aren't skipped, with any selected headers. Skipped
+// requests still have a request ID and trace context for other events.
+func HandlerWithOptions(opts HandlerOptions) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			rc := &responseCapture{ResponseWriter: w}
+			req, t := withTimings(withRequestContext(req))
+			req, rd := withRequestData(req)
+
+			var body *capturedBody
+			if opts.CaptureBody {
+				max := opts.MaxBodyBytes
+				if max <= 0 {
+					max = defaultMaxBodyBytes
+				}
+				body = captureBody(req, max)
+			}
+
+			s := time.Now()
+			h.ServeHTTP(rc, req)
+			e := time.Now()
+
+			skip := opts.skip(req, rc.Status())
+			if skip && !hasObservers() {
+				return
+			}
+
+			data := withRequestFields(req, requestEventData(req, rc, t, rd, s, e))
+
+			// observers see every request, before events are filtered
+			observeRequest(Record{
+				Created:   e,
+				Event:     "request",
+				Namespace: GetNamespace(),
+				Context:   Context(req),
+				Data:      data,
+			})
+			if skip {
+				return
+			}
+
+			if d := e.Sub(s); opts.SlowThreshold > 0 && d > opts.SlowThreshold && !rc.stream {
+				route, ok := data["route"]
+				if !ok {
+					route = req.URL.Path
+				}
+				Event("slow_request", Context(req), withRequestFields(req, Data{
+					"method":    req.Method,
+					"path":      req.URL.Path,
+					"route":     route,
+					"status":    rc.statusCode,
+					"duration":  d,
+					"threshold": opts.SlowThreshold,
+				}))
+			}
+
+			if headers := selectHeaders(req.Header, opts.RequestHeaders); headers != nil {
+				data["request_headers"] = headers
+			}
+			if headers := selectHeaders(rc.Header(), opts.ResponseHeaders); headers != nil {
+				data["response_headers"] = headers
+			}
+			if opts.Query && len(req.URL.RawQuery) > 0 {
+				data["query"] = redactQuery(req.URL.RawQuery, opts.RedactQueryParams)
+			}
+			if body != nil {
+				data["request_body"] = body.value(req)
+				if body.truncated {
+					data["request_body_truncated"] = true
+				}
+			}
+
+			if line := accessLogLine(req, rc, s, e.Sub(s), opts.RedactQueryParams); line != nil {
+				writeLine(nil, "request", Context(req), data, line)
+				return
+			}
+
+			Event("request", Context(req), data)
 		})
-	})
+	}
+}
+
+// skip reports whether a request shouldn't log a request event
+func (opts HandlerOptions) skip(req *http.Request, status int) bool {
+	for _, pattern := range opts.SkipPaths {
+		if ok, _ := path.Match(pattern, req.URL.Path); ok {
+			return true
+		}
+	}
+	return opts.Skip != nil && opts.Skip(req, status)
+}
+
+// selectHeaders returns the named headers which are set, or nil if there
+// are none
+func selectHeaders(h http.Header, names []string) map[string]string {
+	var headers map[string]string
+	for _, name := range names {
+		if values, ok := h[http.CanonicalHeaderKey(name)]; ok {
+			if headers == nil {
+				headers = map[string]string{}
+			}
+			headers[http.CanonicalHeaderKey(name)] = strings.Join(values, ", ")
+		}
+	}
+	return headers
+}
+
+// requestEventData returns the data for a request event
+func requestEventData(req *http.Request, rc *responseCapture, t *timings, rd *requestData, s, e time.Time) Data {
+	d := e.Sub(s)
+
+	data := Data{
+		"start":      s,
+		"end":        e,
+		"status":     rc.statusCode,
+		"method":     req.Method,
+		"path":       req.URL.Path,
+		"bytes_sent": rc.bytes,
+	}
+
+	// the negotiated protocol, e.g. 1.1, or 2.0 for HTTP/2 with TLS or h2c
+	if len(req.Proto) > 0 {
+		data["http_version"] = strings.TrimPrefix(req.Proto, "HTTP/")
+	}
+
+	// ContentLength is -1 when unknown, e.g. for chunked requests
+	if req.ContentLength >= 0 {
+		data["bytes_received"] = req.ContentLength
+	}
+
+	// A server-sent event stream lasts until the client disconnects, so
+	// its duration isn't comparable with other requests
+	if rc.stream {
+		data["stream_duration"] = d
+		data["events_sent"] = rc.events
+		data["client_disconnected"] = req.Context().Err() != nil
+	} else {
+		data["duration"] = d
+	}
+
+	rd.copyTo(data)
+
+	if breakdown := t.breakdown(); breakdown != nil {
+		data["timings"] = breakdown
+	}
+
+	if len(req.RemoteAddr) > 0 {
+		data["remote_addr"] = req.RemoteAddr
+	}
+
+	if state := tracestate(req); len(state) > 0 {
+		data["tracestate"] = state
+	}
+
+	return data
+}
+
+// CapturedResponse is a ResponseWriter which records the status code and
+// number of bytes written, as used by Handler for request events
+type CapturedResponse interface {
+	http.ResponseWriter
+	Status() int
+	BytesWritten() int64
+}
+
+// CaptureResponse wraps a ResponseWriter to record the response status and
+// size. Flusher, Hijacker, Pusher and ReaderFrom are passed through.
+func CaptureResponse(w http.ResponseWriter) CapturedResponse {
+	return &responseCapture{ResponseWriter: w}
 }
 
 type responseCapture struct {
 	http.ResponseWriter
 	statusCode int
+	bytes      int64
+
+	// server-sent event stream state
+	detected bool
+	stream   bool
+	events   int
+	newline  bool
+}
+
+// Status returns the status code written, or 200 if the handler didn't
+// write one
+func (r *responseCapture) Status() int {
+	if r.statusCode == 0 {
+		return http.StatusOK
+	}
+	return r.statusCode
+}
+
+// BytesWritten returns the number of body bytes written
+func (r *responseCapture) BytesWritten() int64 {
+	return r.bytes
 }
 
 func (r *responseCapture) WriteHeader(status int) {
 	r.statusCode = status
+	r.detectStream()
 	r.ResponseWriter.WriteHeader(status)
 }
 
+func (r *responseCapture) Write(b []byte) (int, error) {
+	r.detectStream()
+	if r.stream {
+		r.countEvents(b)
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// detectStream checks for a text/event-stream response when the headers
+// are written, and disables proxy buffering if so
+func (r *responseCapture) detectStream() {
+	if r.detected {
+		return
+	}
+	r.detected = true
+
+	if strings.HasPrefix(r.Header().Get("Content-Type"), "text/event-stream") {
+		r.stream = true
+		r.Header().Set("X-Accel-Buffering", "no")
+		r.Header().Del("Content-Length")
+	}
+}
+
+// countEvents counts events, which are terminated by a blank line
+func (r *responseCapture) countEvents(b []byte) {
+	for _, c := range b {
+		switch c {
+		case '\n':
+			if r.newline {
+				r.events++
+			}
+			r.newline = !r.newline
+		case '\r':
+		default:
+			r.newline = false
+		}
+	}
+}
+
 func (r *responseCapture) Flush() {
 	if f, ok := r.ResponseWriter.(http.Flusher); ok {
 		f.Flush()
 	}
 }
 
-// Event records an event
-var Event = event
+// Hijack passes through to the underlying ResponseWriter, e.g. for WebSocket upgrades
+func (r *responseCapture) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("log: ResponseWriter does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && r.statusCode == 0 {
+		r.statusCode = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Push passes through to the underlying ResponseWriter for HTTP/2 server push
+func (r *responseCapture) Push(target string, opts *http.PushOptions) error {
+	if p, ok := r.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// ReadFrom passes through to the underlying ResponseWriter, so it can use
+// sendfile when serving files
+func (r *responseCapture) ReadFrom(src io.Reader) (int64, error) {
+	r.detectStream()
+	if rf, ok := r.ResponseWriter.(io.ReaderFrom); ok && !r.stream {
+		n, err := rf.ReadFrom(src)
+		r.bytes += n
+		return n, err
+	}
+	// hide ReadFrom from io.Copy so it doesn't call back into this method
+	return io.Copy(struct{ io.Writer }{r}, src)
+}
+
+// CloseNotify passes through to the underlying ResponseWriter
+func (r *responseCapture) CloseNotify() <-chan bool {
+	if c, ok := r.ResponseWriter.(http.CloseNotifier); ok {
+		return c.CloseNotify()
+	}
+	return make(chan bool)
+}
 
 func event(name string, context string, data Data) {
-	m := map[string]interface{}{
-		"created":   time.Now(),
-		"event":     name,
-		"namespace": Namespace,
+	write(nil, name, context, data)
+}
+
+// write records an event, queueing it if async logging is enabled
+func write(w io.Writer, name string, context string, data Data) {
+	writeLine(w, name, context, data, nil)
+}
+
+// writeLine records an event, writing line to the output instead of the
+// encoded event if it's set
+func writeLine(w io.Writer, name string, context string, data Data, line []byte) {
+	readDeprecated()
+
+	if !enabled(name, data) {
+		return
 	}
 
-	if len(context) > 0 {
-		m["context"] = context
+	data, ok := runHooks(name, context, data)
+	if !ok {
+		return
 	}
 
-	if data != nil {
-		m["data"] = data
+	keep, dropped := sample(eventLevel(name, data), data)
+	if !keep {
+		return
+	}
+
+	data = redact(withCaller(withExtraFields(data)))
+	if dropped > 0 {
+		if data == nil {
+			data = Data{}
+		}
+		data["sampled"] = dropped
+	}
+
+	r := Record{
+		Created:   now(),
+		Event:     name,
+		Namespace: GetNamespace(),
+		Context:   context,
+		Data:      data,
+		line:      line,
+	}
+
+	if enqueue(w, r) {
+		return
+	}
+	emit(w, r)
+}
+
+// emit serializes and writes an event to the sinks and to w, or the
+// configured output if w is nil
+func emit(w io.Writer, r Record) {
+	writeSinks(r)
+
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	if w == nil {
+		w = getOutput(r.Level())
+	}
+
+	if r.line != nil {
+		w.Write(r.line)
+		return
 	}
 
-	if HumanReadable {
-		printHumanReadable(name, context, data, m)
+	if GetHumanReadable() {
+		fprintHumanReadable(w, r.Event, r.Context, r.Data, r.envelope())
 		return
 	}
 
-	b, err := json.Marshal(&m)
+	e := getEncodeState()
+	defer putEncodeState(e)
+
+	err := encode(e, getEncoder(), r)
 	if err != nil {
 		// This should never happen
 		// We'll log the error (which for our purposes, can't fail), which
 		// gives us an indication we have something to investigate
-		b, _ = json.Marshal(map[string]interface{}{
-			"created":   time.Now(),
-			"event":     "log_error",
-			"namespace": Namespace,
-			"context":   context,
-			"data":      map[string]interface{}{"error": err.Error()},
-		})
+		fprintLogError(w, r.Context, err)
+		return
 	}
 
-	fmt.Fprintf(os.Stdout, "%s\n", b)
+	w.Write(e.buf)
+}
+
+// printLogError writes a log_error event directly to the output, bypassing sinks
+func printLogError(context string, err error) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fprintLogError(getOutput(ERROR), context, err)
+}
+
+func fprintLogError(w io.Writer, context string, err error) {
+	b, _ := json.Marshal(map[string]interface{}{
+		"created":   formatTime(now()),
+		"event":     "log_error",
+		"namespace": GetNamespace(),
+		"context":   context,
+		"data":      map[string]interface{}{"error": err.Error()},
+	})
+
+	fmt.Fprintf(w, "%s\n", b)
 }
 
 func printHumanReadable(name, context string, data Data, m map[string]interface{}) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	fprintHumanReadable(getOutput(eventLevel(name, data)), name, context, data, m)
+}
+
+func fprintHumanReadable(w io.Writer, name, context string, data Data, m map[string]interface{}) {
 	ctx := ""
 	if len(context) > 0 {
 		ctx = "[" + context + "] "
 	}
-	msg := ""
+	// the field used as the message isn't repeated below it, but data isn't
+	// modified, since sinks may have kept a reference to it
+	msg, msgKey := "", ""
 	if message, ok := data["message"]; ok {
-		msg = ": " + fmt.Sprintf("%s", message)
-		delete(data, "message")
+		msg, msgKey = ": "+fmt.Sprintf("%s", message), "message"
 	}
 	if name == "error" && len(msg) == 0 {
 		if err, ok := data["error"]; ok {
-			msg = ": " + fmt.Sprintf("%s", err)
-			delete(data, "error")
+			msg, msgKey = ": "+fmt.Sprintf("%s", err), "error"
 		}
 	}
-	col := ansi.DefaultFG
-	switch name {
-	case "error":
-		col = ansi.LightRed
-	case "trace":
-		col = ansi.Blue
-	case "debug":
-		col = ansi.Green
-	case "request":
-		col = ansi.Cyan
+	col, reset := "", ""
+	if colorEnabled(w) {
+		col, reset = styleOf(name).codes()
 	}
 
-	fmt.Fprintf(os.Stdout, "%s%s %s%s%s%s\n", col, m["created"], ctx, name, msg, ansi.DefaultFG)
+	fmt.Fprintf(w, "%s%v %s%s%s%s\n", col, m["created"], ctx, name, msg, reset)
 	if data != nil {
 		for k, v := range data {
-			fmt.Fprintf(os.Stdout, "  -> %s: %+v\n", k, v)
+			if k == msgKey {
+				continue
+			}
+			if frames, ok := v.([]StackFrame); ok {
+				fmt.Fprintf(w, "  -> %s:\n", k)
+				for _, f := range frames {
+					fmt.Fprintf(w, "       %s\n         %s:%d\n", f.Function, f.File, f.Line)
+				}
+				continue
+			}
+			if GetPrettyPrint() {
+				writePretty(w, "  -> ", "       ", k+":", k, v)
+				continue
+			}
+			fmt.Fprintf(w, "  -> %s: %s\n", k, humanValue(k, v))
 		}
 	}
 }
 
 // ErrorC is a structured error message with context
 func ErrorC(context string, err error, data Data) {
+	Event("error", context, errorData(err, data))
+}
+
+// errorData adds an error, its message and fingerprint to data
+func errorData(err error, data Data) Data {
 	if data == nil {
 		data = Data{}
 	}
@@ -155,12 +671,61 @@ func ErrorC(context string, err error, data Data) {
 		data["message"] = err.Error()
 		data["error"] = err
 	}
-	Event("error", context, data)
+	if _, ok := data["fingerprint"]; !ok && err != nil {
+		data["fingerprint"] = fingerprint(err)
+	}
+	if depth := getConfig().stackDepth; depth > 0 {
+		if _, ok := data["stack"]; !ok {
+			data["stack"] = stack(err, depth)
+		}
+	}
+	return withErrorFields(err, data)
+}
+
+// codedError and dataError are implemented by errors which carry an error
+// code or data for error events, such as those from the log/errors package
+type codedError interface {
+	Code() string
+}
+
+type dataError interface {
+	LogData() Data
+}
+
+// withErrorFields adds the code and data attached to err, or any error it
+// wraps, to data. Outer errors and existing fields take precedence.
+func withErrorFields(err error, data Data) Data {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if e, ok := err.(codedError); ok {
+			if _, exists := data["code"]; !exists && len(e.Code()) > 0 {
+				data["code"] = e.Code()
+			}
+		}
+		if e, ok := err.(dataError); ok {
+			for k, v := range e.LogData() {
+				if _, exists := data[k]; !exists {
+					data[k] = v
+				}
+			}
+		}
+	}
+	return data
+}
+
+// messageData adds a message to data
+func messageData(message string, data Data) Data {
+	if data == nil {
+		data = Data{}
+	}
+	if _, ok := data["message"]; !ok {
+		data["message"] = message
+	}
+	return data
 }
 
 // ErrorR is a structured error message for a request
 func ErrorR(req *http.Request, err error, data Data) {
-	ErrorC(Context(req), err, data)
+	ErrorC(Context(req), err, withRequestFields(req, data))
 }
 
 // Error is a structured error message
@@ -168,20 +733,46 @@ func Error(err error, data Data) {
 	ErrorC("", err, data)
 }
 
+// InfoC is a structured info message with context, for lifecycle events such
+// as a service starting or loading its config
+func InfoC(context string, message string, data Data) {
+	Event("info", context, messageData(message, data))
+}
+
+// InfoR is a structured info message for a request
+func InfoR(req *http.Request, message string, data Data) {
+	InfoC(Context(req), message, withRequestFields(req, data))
+}
+
+// Info is a structured info message
+func Info(message string, data Data) {
+	InfoC("", message, data)
+}
+
+// WarnC is a structured warning message with context, for conditions which
+// aren't errors but should stand out
+func WarnC(context string, message string, data Data) {
+	Event("warn", context, messageData(message, data))
+}
+
+// WarnR is a structured warning message for a request
+func WarnR(req *http.Request, message string, data Data) {
+	WarnC(Context(req), message, withRequestFields(req, data))
+}
+
+// Warn is a structured warning message
+func Warn(message string, data Data) {
+	WarnC("", message, data)
+}
+
 // DebugC is a structured debug message with context
 func DebugC(context string, message string, data Data) {
-	if data == nil {
-		data = Data{}
-	}
-	if _, ok := data["message"]; !ok {
-		data["message"] = message
-	}
-	Event("debug", context, data)
+	Event("debug", context, messageData(message, data))
 }
 
 // DebugR is a structured debug message for a request
 func DebugR(req *http.Request, message string, data Data) {
-	DebugC(Context(req), message, data)
+	DebugC(Context(req), message, withRequestFields(req, data))
 }
 
 // Debug is a structured trace message
@@ -191,18 +782,12 @@ func Debug(message string, data Data) {
 
 // TraceC is a structured trace message with context
 func TraceC(context string, message string, data Data) {
-	if data == nil {
-		data = Data{}
-	}
-	if _, ok := data["message"]; !ok {
-		data["message"] = message
-	}
-	Event("trace", context, data)
+	Event("trace", context, messageData(message, data))
 }
 
 // TraceR is a structured trace message for a request
 func TraceR(req *http.Request, message string, data Data) {
-	TraceC(Context(req), message, data)
+	TraceC(Context(req), message, withRequestFields(req, data))
 }
 
 // Trace is a structured trace message
@@ -1,14 +1,18 @@
 package log
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -46,22 +50,67 @@ func captureOutput(f func()) string {
 func TestHumanLog(t *testing.T) {
 	Convey("HUMAN_LOG environment variable should configure human log output", t, func() {
 		So(HumanReadable, ShouldBeFalse)
+		So(GetHumanReadable(), ShouldBeFalse)
 
 		os.Setenv("HUMAN_LOG", "true")
 		configureHumanReadable()
 		So(HumanReadable, ShouldBeTrue)
+		So(GetHumanReadable(), ShouldBeTrue)
 
 		os.Setenv("HUMAN_LOG", "false")
 		configureHumanReadable()
 		So(HumanReadable, ShouldBeFalse)
+		So(GetHumanReadable(), ShouldBeFalse)
 
 		os.Setenv("HUMAN_LOG", "1")
 		configureHumanReadable()
 		So(HumanReadable, ShouldBeTrue)
+		So(GetHumanReadable(), ShouldBeTrue)
+
+		os.Setenv("HUMAN_LOG", "pretty")
+		configureHumanReadable()
+		So(HumanReadable, ShouldBeTrue)
+		So(GetHumanReadable(), ShouldBeTrue)
+		So(GetPrettyPrint(), ShouldBeTrue)
 
 		os.Setenv("HUMAN_LOG", "")
 		configureHumanReadable()
 		So(HumanReadable, ShouldBeFalse)
+		So(GetHumanReadable(), ShouldBeFalse)
+		So(GetPrettyPrint(), ShouldBeFalse)
+	})
+}
+
+func TestExtraFields(t *testing.T) {
+	defer func() {
+		os.Unsetenv("LOG_EXTRA_FIELDS")
+		configureExtraFields()
+	}()
+
+	Convey("LOG_EXTRA_FIELDS environment variable should configure extra fields", t, func() {
+		So(GetExtraFields(), ShouldBeNil)
+
+		os.Setenv("LOG_EXTRA_FIELDS", "region=eu-west-1, team = data,invalid,=empty,url=http://x?a=b")
+		configureExtraFields()
+		So(GetExtraFields(), ShouldResemble, Data{"region": "eu-west-1", "team": "data", "url": "http://x?a=b"})
+
+		os.Setenv("LOG_EXTRA_FIELDS", "")
+		configureExtraFields()
+		So(GetExtraFields(), ShouldBeNil)
+	})
+
+	Convey("Extra fields should be added to events without replacing event data", t, func() {
+		SetHumanReadable(false)
+		SetExtraFields(Data{"region": "eu-west-1", "team": "data"})
+
+		data := Data{"team": "web"}
+		stdout := captureOutput(func() {
+			event("test", "", data)
+		})
+		var m map[string]interface{}
+		So(json.Unmarshal([]byte(stdout), &m), ShouldBeNil)
+		So(m["data"], ShouldResemble, map[string]interface{}{"region": "eu-west-1", "team": "web"})
+		So(data, ShouldResemble, Data{"team": "web"})
 	})
 }
 
@@ -86,20 +135,17 @@ func TestHandler(t *testing.T) {
 	})
 
 	Convey("Handler should capture stuff", t, func() {
-		oldEvent := Event
-		defer func() {
-			Event = oldEvent
-		}()
+		defer SetEvent(nil)
 
 		wrapped := Handler(dummyHandler)
 
 		var eventName, eventContext string
 		var eventData Data
-		Event = func(name string, context string, data Data) {
+		SetEvent(func(name string, context string, data Data) {
 			eventName = name
 			eventContext = context
 			eventData = data
-		}
+		})
 
 		req, err := http.NewRequest("GET", "/", nil)
 		So(err, ShouldBeNil)
@@ -122,13 +168,173 @@ func TestHandler(t *testing.T) {
 		So(eventData["method"], ShouldEqual, "GET")
 		So(eventData, ShouldContainKey, "path")
 		So(eventData["path"], ShouldEqual, "/")
+		So(eventData, ShouldContainKey, "bytes_sent")
+		So(eventData["bytes_sent"], ShouldEqual, 0)
+		So(eventData, ShouldContainKey, "bytes_received")
+		So(eventData["bytes_received"], ShouldEqual, 0)
+		So(eventData["http_version"], ShouldEqual, "1.1")
+	})
+
+	Convey("Handler should capture request and response sizes", t, func() {
+		defer SetEvent(nil)
+
+		var eventData Data
+		SetEvent(func(name string, context string, data Data) {
+			eventData = data
+		})
+
+		wrapped := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte("hello "))
+			w.Write([]byte("world"))
+		}))
+
+		req, err := http.NewRequest("POST", "/", strings.NewReader("request body"))
+		So(err, ShouldBeNil)
+		wrapped.ServeHTTP(httptest.NewRecorder(), req)
+		So(eventData["bytes_sent"], ShouldEqual, 11)
+		So(eventData["bytes_received"], ShouldEqual, 12)
+
+		req.ContentLength = -1
+		wrapped.ServeHTTP(httptest.NewRecorder(), req)
+		So(eventData, ShouldNotContainKey, "bytes_received")
+	})
+}
+
+func TestHandlerWithOptions(t *testing.T) {
+	defer SetEvent(nil)
+
+	var events []Data
+	SetEvent(func(name string, context string, data Data) {
+		if name == "request" {
+			events = append(events, data)
+		}
+	})
+
+	serve := func(h http.Handler, path string) {
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", "test")
+		req.Header.Set("Accept", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	Convey("HandlerWithOptions should not log requests matching skip paths", t, func() {
+		events = nil
+		h := HandlerWithOptions(HandlerOptions{SkipPaths: []string{"/healthcheck", "/metrics/*"}})(dummyHandler)
+
+		serve(h, "/healthcheck")
+		serve(h, "/metrics/prometheus")
+		So(events, ShouldBeEmpty)
+
+		serve(h, "/datasets")
+		So(events, ShouldHaveLength, 1)
+		So(events[0]["path"], ShouldEqual, "/datasets")
+	})
+
+	Convey("HandlerWithOptions should not log requests matching the skip predicate", t, func() {
+		events = nil
+		var requestID string
+		h := HandlerWithOptions(HandlerOptions{
+			Skip: func(req *http.Request, status int) bool {
+				return req.URL.Path == "/health" && status == http.StatusOK
+			},
+		})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requestID = RequestID(req.Context())
+			if req.URL.Query().Get("fail") == "true" {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}))
+
+		req, _ := http.NewRequest("GET", "/health", nil)
+		req.Header.Set("X-Request-Id", "skipped")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		So(events, ShouldBeEmpty)
+		So(requestID, ShouldEqual, "skipped")
+
+		serve(h, "/health?fail=true")
+		So(events, ShouldHaveLength, 1)
+		So(events[0]["status"], ShouldEqual, 500)
+	})
+
+	Convey("HandlerWithOptions should add allowed request and response headers", t, func() {
+		events = nil
+		h := HandlerWithOptions(HandlerOptions{
+			RequestHeaders:  []string{"user-agent", "X-Missing"},
+			ResponseHeaders: []string{"Content-Type"},
+		})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Other", "ignored")
+		}))
+
+		serve(h, "/")
+		So(events, ShouldHaveLength, 1)
+		So(events[0]["request_headers"], ShouldResemble, map[string]string{"User-Agent": "test"})
+		So(events[0]["response_headers"], ShouldResemble, map[string]string{"Content-Type": "application/json"})
+	})
+
+	Convey("HandlerWithOptions should add the query string with sensitive values masked", t, func() {
+		events = nil
+		h := HandlerWithOptions(HandlerOptions{Query: true, RedactQueryParams: []string{"api_key"}})(dummyHandler)
+
+		serve(h, "/datasets?q=cpih&api_key=secret&token=secret")
+		serve(h, "/datasets")
+		So(events, ShouldHaveLength, 2)
+		So(events[0]["query"], ShouldEqual, "q=cpih&api_key=[REDACTED]&token=[REDACTED]")
+		So(events[1], ShouldNotContainKey, "query")
+	})
+
+	Convey("Handler should not add headers by default", t, func() {
+		events = nil
+		serve(Handler(dummyHandler), "/")
+		So(events, ShouldHaveLength, 1)
+		So(events[0], ShouldNotContainKey, "request_headers")
+		So(events[0], ShouldNotContainKey, "response_headers")
+	})
+
+	Convey("HandlerWithOptions should log a slow_request event for slow requests", t, func() {
+		var slow []Data
+		SetEvent(func(name string, context string, data Data) {
+			if name == "slow_request" {
+				slow = append(slow, data)
+			}
+		})
+		defer func() {
+			SetEvent(func(name string, context string, data Data) {
+				if name == "request" {
+					events = append(events, data)
+				}
+			})
+		}()
+
+		h := HandlerWithOptions(HandlerOptions{SlowThreshold: 5 * time.Millisecond})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/slow" {
+				AddRequestData(req, Data{"route": "/{name}"})
+				time.Sleep(10 * time.Millisecond)
+			}
+		}))
+
+		serve(h, "/fast")
+		So(slow, ShouldBeEmpty)
+
+		serve(h, "/slow")
+		So(slow, ShouldHaveLength, 1)
+		So(slow[0]["path"], ShouldEqual, "/slow")
+		So(slow[0]["route"], ShouldEqual, "/{name}")
+		So(slow[0]["threshold"], ShouldEqual, 5*time.Millisecond)
+		So(slow[0]["duration"], ShouldBeGreaterThan, 5*time.Millisecond)
+	})
+
+	Convey("Handler should not add the query string by default", t, func() {
+		events = nil
+		serve(Handler(dummyHandler), "/datasets?q=cpih")
+		So(events, ShouldHaveLength, 1)
+		So(events[0], ShouldNotContainKey, "query")
 	})
 }
 
 func TestResponseCapture(t *testing.T) {
 	Convey("responseCapture should capture a response status code", t, func() {
 		w := httptest.NewRecorder()
-		c := responseCapture{w, 0}
+		c := responseCapture{ResponseWriter: w}
 		So(c.statusCode, ShouldEqual, 0)
 
 		c.WriteHeader(200)
@@ -137,27 +343,135 @@ func TestResponseCapture(t *testing.T) {
 
 	Convey("responseCapture should pass through a Flush call", t, func() {
 		w := httptest.NewRecorder()
-		c := responseCapture{w, 0}
+		c := responseCapture{ResponseWriter: w}
 		So(w.Flushed, ShouldBeFalse)
 
 		c.Flush()
 		So(w.Flushed, ShouldBeTrue)
 	})
+
+	Convey("responseCapture should pass through a Hijack call", t, func() {
+		w := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+		c := responseCapture{ResponseWriter: w}
+
+		_, _, err := c.Hijack()
+		So(err, ShouldBeNil)
+		So(w.hijacked, ShouldBeTrue)
+		So(c.statusCode, ShouldEqual, http.StatusSwitchingProtocols)
+
+		c = responseCapture{ResponseWriter: httptest.NewRecorder()}
+		_, _, err = c.Hijack()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("responseCapture should return ErrNotSupported for Push if unsupported", t, func() {
+		c := responseCapture{ResponseWriter: httptest.NewRecorder()}
+		So(c.Push("/style.css", nil), ShouldEqual, http.ErrNotSupported)
+	})
+
+	Convey("responseCapture should count bytes written by ReadFrom", t, func() {
+		w := httptest.NewRecorder()
+		c := responseCapture{ResponseWriter: w}
+
+		n, err := c.ReadFrom(strings.NewReader("hello world"))
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 11)
+		So(c.bytes, ShouldEqual, 11)
+		So(w.Body.String(), ShouldEqual, "hello world")
+	})
+
+	Convey("responseCapture should return a CloseNotify channel", t, func() {
+		c := responseCapture{ResponseWriter: httptest.NewRecorder()}
+		So(c.CloseNotify(), ShouldNotBeNil)
+	})
+
+	Convey("responseCapture should count server-sent events", t, func() {
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Content-Length", "100")
+		c := responseCapture{ResponseWriter: w}
+
+		c.Write([]byte("event: a\ndata: 1\n\n"))
+		c.Write([]byte("data: 2\r\n\r"))
+		c.Write([]byte("\n"))
+		c.Write([]byte("data: 3\n"))
+
+		So(c.stream, ShouldBeTrue)
+		So(c.events, ShouldEqual, 2)
+		So(w.Header().Get("X-Accel-Buffering"), ShouldEqual, "no")
+		So(w.Header().Get("Content-Length"), ShouldBeEmpty)
+	})
+
+	Convey("responseCapture should not count events for other responses", t, func() {
+		w := httptest.NewRecorder()
+		c := responseCapture{ResponseWriter: w}
+		c.WriteHeader(200)
+		c.Write([]byte("a\n\nb"))
+
+		So(c.stream, ShouldBeFalse)
+		So(c.events, ShouldEqual, 0)
+	})
+
+	Convey("CaptureResponse should report the status and bytes written", t, func() {
+		c := CaptureResponse(httptest.NewRecorder())
+		So(c.Status(), ShouldEqual, 200)
+
+		c.WriteHeader(404)
+		c.Write([]byte("not found"))
+		So(c.Status(), ShouldEqual, 404)
+		So(c.BytesWritten(), ShouldEqual, 9)
+	})
+}
+
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (h *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h.hijacked = true
+	return nil, nil, nil
+}
+
+func TestEventStream(t *testing.T) {
+	defer SetEvent(nil)
+
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventData = data
+	})
+
+	Convey("Handler should log stream duration and events sent for event streams", t, func() {
+		wrapped := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(200)
+			for i := 0; i < 3; i++ {
+				fmt.Fprintf(w, "data: %d\n\n", i)
+				w.(http.Flusher).Flush()
+			}
+		}))
+
+		req, err := http.NewRequest("GET", "/events", nil)
+		So(err, ShouldBeNil)
+		wrapped.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(eventData, ShouldNotContainKey, "duration")
+		So(eventData, ShouldContainKey, "stream_duration")
+		So(eventData["events_sent"], ShouldEqual, 3)
+		So(eventData["client_disconnected"], ShouldBeFalse)
+	})
 }
 
 func TestError(t *testing.T) {
-	oldEvent := Event
-	defer func() {
-		Event = oldEvent
-	}()
+	defer SetEvent(nil)
 
 	var eventName, eventContext string
 	var eventData Data
-	Event = func(name string, context string, data Data) {
+	SetEvent(func(name string, context string, data Data) {
 		eventName = name
 		eventContext = context
 		eventData = data
-	}
+	})
 
 	Convey("Error", t, func() {
 		Error(errors.New("test error"), nil)
@@ -198,19 +512,98 @@ func TestError(t *testing.T) {
 	})
 }
 
+func TestInfo(t *testing.T) {
+	defer SetEvent(nil)
+
+	var eventName, eventContext string
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	Convey("Info", t, func() {
+		Info("test message", nil)
+		So(eventName, ShouldEqual, "info")
+		So(eventContext, ShouldEqual, "")
+		So(eventData, ShouldContainKey, "message")
+		So(eventData["message"], ShouldEqual, "test message")
+	})
+
+	Convey("InfoC", t, func() {
+		InfoC("context", "test message", nil)
+		So(eventName, ShouldEqual, "info")
+		So(eventContext, ShouldEqual, "context")
+		So(eventData, ShouldContainKey, "message")
+		So(eventData["message"], ShouldEqual, "test message")
+	})
+
+	Convey("InfoR", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		req.Header.Set("X-Request-Id", "test-request-id")
+
+		InfoR(req, "test message", nil)
+		So(eventName, ShouldEqual, "info")
+		So(eventContext, ShouldEqual, "test-request-id")
+		So(eventData, ShouldContainKey, "message")
+		So(eventData["message"], ShouldEqual, "test message")
+	})
+}
+
+func TestWarn(t *testing.T) {
+	defer SetEvent(nil)
+
+	var eventName, eventContext string
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	Convey("Warn", t, func() {
+		Warn("test message", nil)
+		So(eventName, ShouldEqual, "warn")
+		So(eventContext, ShouldEqual, "")
+		So(eventData, ShouldContainKey, "message")
+		So(eventData["message"], ShouldEqual, "test message")
+	})
+
+	Convey("WarnC", t, func() {
+		WarnC("context", "test message", nil)
+		So(eventName, ShouldEqual, "warn")
+		So(eventContext, ShouldEqual, "context")
+		So(eventData, ShouldContainKey, "message")
+		So(eventData["message"], ShouldEqual, "test message")
+	})
+
+	Convey("WarnR", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+
+		req.Header.Set("X-Request-Id", "test-request-id")
+
+		WarnR(req, "test message", nil)
+		So(eventName, ShouldEqual, "warn")
+		So(eventContext, ShouldEqual, "test-request-id")
+		So(eventData, ShouldContainKey, "message")
+		So(eventData["message"], ShouldEqual, "test message")
+	})
+}
+
 func TestDebug(t *testing.T) {
-	oldEvent := Event
-	defer func() {
-		Event = oldEvent
-	}()
+	defer SetEvent(nil)
 
 	var eventName, eventContext string
 	var eventData Data
-	Event = func(name string, context string, data Data) {
+	SetEvent(func(name string, context string, data Data) {
 		eventName = name
 		eventContext = context
 		eventData = data
-	}
+	})
 
 	Convey("Debug", t, func() {
 		Debug("test message", nil)
@@ -243,18 +636,15 @@ func TestDebug(t *testing.T) {
 }
 
 func TestTrace(t *testing.T) {
-	oldEvent := Event
-	defer func() {
-		Event = oldEvent
-	}()
+	defer SetEvent(nil)
 
 	var eventName, eventContext string
 	var eventData Data
-	Event = func(name string, context string, data Data) {
+	SetEvent(func(name string, context string, data Data) {
 		eventName = name
 		eventContext = context
 		eventData = data
-	}
+	})
 
 	Convey("Trace", t, func() {
 		Trace("test message", nil)
@@ -288,7 +678,7 @@ func TestTrace(t *testing.T) {
 
 func TestEvent(t *testing.T) {
 	Convey("event should output JSON", t, func() {
-		Namespace = "namespace"
+		SetNamespace("namespace")
 
 		stdout := captureOutput(func() {
 			event("test", "context", Data{"foo": "bar"})
@@ -310,8 +700,8 @@ func TestEvent(t *testing.T) {
 	})
 
 	Convey("event with invalid data value should fail", t, func() {
-		Namespace = "namespace"
-		HumanReadable = false
+		SetNamespace("namespace")
+		SetHumanReadable(false)
 
 		stdout := captureOutput(func() {
 			event("test", "context", Data{"foo": func() {}})
@@ -375,9 +765,12 @@ func TestPrintHumanReadable(t *testing.T) {
 		},
 	}
 
+	defer atomic.StoreInt32(&colorMode, colorAuto)
+	Color(true)
+
 	Convey("printHumanReadable should output human readable log messages", t, func() {
-		Namespace = "namespace"
-		HumanReadable = true
+		SetNamespace("namespace")
+		SetHumanReadable(true)
 
 		for _, test := range tests {
 			stdout := captureOutput(func() {
@@ -387,13 +780,21 @@ func TestPrintHumanReadable(t *testing.T) {
 		}
 	})
 
-	Convey("event should call printHumanReadable if HumanReadable is set", t, func() {
-		Namespace = "namespace"
-		HumanReadable = true
+	Convey("event should call printHumanReadable if human readable output is enabled", t, func() {
+		SetNamespace("namespace")
+		SetHumanReadable(true)
 		stdout := captureOutput(func() {
 			event("debug", "context", Data{"message": "test message"})
 		})
 		endWith := fmt.Sprintf("[%s] %s: %s%s\n", "context", "debug", "test message", ansi.DefaultFG)
 		So(stdout, ShouldEndWith, endWith)
 	})
+
+	Convey("printHumanReadable should not output colours if disabled", t, func() {
+		Color(false)
+		stdout := captureOutput(func() {
+			printHumanReadable("debug", "context", Data{"message": "test message"}, map[string]interface{}{"created": now})
+		})
+		So(stdout, ShouldEqual, fmt.Sprintf("%s [%s] %s: %s\n", now, "context", "debug", "test message"))
+	})
 }
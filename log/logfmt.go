@@ -0,0 +1,118 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LogfmtEncoder encodes events as logfmt key=value pairs, one per line.
+// Data fields are prefixed with "data." and nested maps are flattened using
+// dotted keys, e.g. data.timings.auth=10ms.
+type LogfmtEncoder struct{}
+
+// Encode implements Encoder
+func (LogfmtEncoder) Encode(r Record) ([]byte, error) {
+	var buf bytes.Buffer
+
+	m := r.envelope()
+	for _, k := range []string{"created", "event", "namespace", "context"} {
+		if v, ok := m[k]; ok {
+			writeLogfmt(&buf, k, v)
+		}
+	}
+	if r.Data != nil {
+		writeLogfmt(&buf, "data", map[string]interface{}(r.Data))
+	}
+
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// configureFormat selects the encoder from LOG_FORMAT (json, logfmt or gcp).
+// gcp writes JSON in GCPMode, so events are parsed as structured entries by
+// Cloud Logging.
+func configureFormat() {
+	switch strings.ToLower(os.Getenv("LOG_FORMAT")) {
+	case "logfmt":
+		SetEncoder(LogfmtEncoder{})
+	case "json":
+		SetEncoder(JSONEncoder{})
+	case "gcp":
+		SetMode(GCPMode)
+		SetEncoder(JSONEncoder{})
+	}
+}
+
+func writeLogfmt(buf *bytes.Buffer, key string, v interface{}) {
+	switch t := v.(type) {
+	case Data:
+		writeLogfmt(buf, key, map[string]interface{}(t))
+		return
+	case map[string]interface{}:
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			writeLogfmt(buf, key+"."+k, t[k])
+		}
+		return
+	}
+
+	if buf.Len() > 0 {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(logfmtKey(key))
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(v))
+}
+
+// logfmtKey replaces characters which aren't valid in a key
+func logfmtKey(k string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, k)
+}
+
+// logfmtValue formats a value, quoting it if necessary
+func logfmtValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		s = t
+	case time.Time:
+		s = t.Format(time.RFC3339Nano)
+	case error:
+		s = t.Error()
+	case fmt.Stringer:
+		s = t.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(t)
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			s = fmt.Sprintf("%+v", t)
+		} else {
+			s = string(b)
+		}
+	}
+
+	if len(s) == 0 || strings.IndexFunc(s, func(r rune) bool {
+		return r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f
+	}) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
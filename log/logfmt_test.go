@@ -0,0 +1,83 @@
+package log
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	defer func() {
+		SetTimeFormat(TimeRFC3339Nano)
+	}()
+
+	Convey("LogfmtEncoder should encode events as key=value pairs", t, func() {
+		SetTimeFormat(TimeRFC3339)
+		r := Record{
+			Created:   time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+			Event:     "request",
+			Namespace: "dp-api",
+			Context:   "request-1",
+			Data: Data{
+				"status":   200,
+				"path":     "/datasets",
+				"duration": 10 * time.Millisecond,
+				"message":  `said "hello world"`,
+				"empty":    "",
+				"ok":       true,
+				"timings":  map[string]interface{}{"auth": "1ms"},
+				"error":    errors.New("a=b"),
+				"ids":      []int{1, 2},
+				"none":     nil,
+			},
+		}
+
+		b, err := LogfmtEncoder{}.Encode(r)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, `created=2018-01-02T03:04:05Z event=request namespace=dp-api context=request-1 `+
+			`data.duration=10ms data.empty="" data.error="a=b" data.ids=[1,2] data.message="said \"hello world\"" `+
+			`data.none=null data.ok=true data.path=/datasets data.status=200 data.timings.auth=1ms`+"\n")
+	})
+
+	Convey("LogfmtEncoder should omit an empty context and data", t, func() {
+		SetTimeFormat(TimeEpochMillis)
+		b, err := LogfmtEncoder{}.Encode(Record{Created: time.Unix(1, 0), Event: "test", Namespace: "ns"})
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "created=1000 event=test namespace=ns\n")
+	})
+
+	Convey("logfmtKey should replace invalid characters", t, func() {
+		So(logfmtKey(`a b=c"d`), ShouldEqual, "a_b_c_d")
+	})
+
+	Convey("configureFormat should select the encoder from LOG_FORMAT", t, func() {
+		defer func() {
+			os.Unsetenv("LOG_FORMAT")
+			SetEncoder(nil)
+		}()
+
+		os.Setenv("LOG_FORMAT", "logfmt")
+		configureFormat()
+		So(getEncoder(), ShouldHaveSameTypeAs, LogfmtEncoder{})
+
+		os.Setenv("LOG_FORMAT", "json")
+		configureFormat()
+		So(getEncoder(), ShouldHaveSameTypeAs, JSONEncoder{})
+	})
+
+	Convey("configureFormat should select GCPMode from LOG_FORMAT=gcp", t, func() {
+		defer func() {
+			os.Unsetenv("LOG_FORMAT")
+			SetEncoder(nil)
+			SetMode(DefaultMode)
+		}()
+
+		os.Setenv("LOG_FORMAT", "gcp")
+		configureFormat()
+		So(getEncoder(), ShouldHaveSameTypeAs, JSONEncoder{})
+		So(GetMode(), ShouldEqual, GCPMode)
+	})
+}
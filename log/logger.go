@@ -0,0 +1,191 @@
+package log
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/ONSdigital/go-ns/baggage"
+)
+
+// Logger logs events with a bound context ID and data
+type Logger struct {
+	context string
+	data    Data
+	out     io.Writer
+}
+
+// Enricher returns data to bind to loggers created from a context
+type Enricher func(ctx context.Context) Data
+
+var (
+	enrichers      []Enricher
+	enrichersMutex sync.RWMutex
+)
+
+// RegisterEnricher adds an enricher which is called by FromContext
+func RegisterEnricher(e Enricher) {
+	enrichersMutex.Lock()
+	defer enrichersMutex.Unlock()
+	enrichers = append(enrichers, e)
+}
+
+type requestIDKey struct{}
+type traceKey struct{}
+type callerKey struct{}
+
+type trace struct {
+	traceID string
+	spanID  string
+}
+
+// WithRequestID returns a context carrying a request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// WithCaller returns a context carrying the identity of the caller
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func withTrace(ctx context.Context, traceID, spanID string) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace{traceID, spanID})
+}
+
+// withRequestContext adds the request ID and trace IDs from a request to its context
+func withRequestContext(req *http.Request) *http.Request {
+	ctx := req.Context()
+	if requestID := Context(req); len(requestID) > 0 {
+		ctx = WithRequestID(ctx, requestID)
+	}
+	if traceID, spanID := traceContext(req); len(traceID) > 0 {
+		ctx = withTrace(ctx, traceID, spanID)
+	}
+	return req.WithContext(ctx)
+}
+
+// FromContext returns a logger bound to the request ID, trace IDs, caller
+// identity, selected baggage and any registered enrichment from a context
+func FromContext(ctx context.Context) *Logger {
+	l := &Logger{data: Data{}}
+	if ctx == nil {
+		return l
+	}
+
+	l.context = RequestID(ctx)
+
+	if t, ok := ctx.Value(traceKey{}).(trace); ok {
+		l.data["trace_id"] = t.traceID
+		if len(t.spanID) > 0 {
+			l.data["span_id"] = t.spanID
+		}
+	}
+
+	if caller := Caller(ctx); len(caller) > 0 {
+		l.data["caller"] = caller
+	}
+
+	if entries := selectBaggage(baggage.FromContext(ctx)); entries != nil {
+		l.data["baggage"] = entries
+	}
+
+	enrichersMutex.RLock()
+	defer enrichersMutex.RUnlock()
+	for _, e := range enrichers {
+		for k, v := range e(ctx) {
+			l.data[k] = v
+		}
+	}
+
+	return l
+}
+
+// With returns a logger with a field bound, for chaining, e.g.
+//
+//	log.With("service", "api").With("version", v).Debug("started", nil)
+func With(key string, value interface{}) *Logger {
+	return (&Logger{}).With(key, value)
+}
+
+// With returns a copy of the logger with an additional bound field
+func (l *Logger) With(key string, value interface{}) *Logger {
+	return l.WithData(Data{key: value})
+}
+
+// WithData returns a copy of the logger with additional bound data
+func (l *Logger) WithData(data Data) *Logger {
+	return &Logger{context: l.context, data: l.merge(data), out: l.out}
+}
+
+// Named returns a logger with a name, e.g. the component logging, which is
+// added to events as "logger" and used to select level overrides. Naming a
+// named logger appends to its name, e.g. kafka.consumer.
+func Named(name string) *Logger {
+	return (&Logger{}).Named(name)
+}
+
+// Named returns a copy of the logger with a name appended to its name
+func (l *Logger) Named(name string) *Logger {
+	if parent, ok := l.data["logger"].(string); ok && len(parent) > 0 {
+		name = parent + "." + name
+	}
+	return l.With("logger", name)
+}
+
+// WithOutput returns a copy of the logger which writes events to w instead
+// of the package output. Events are still written to sinks.
+func (l *Logger) WithOutput(w io.Writer) *Logger {
+	return &Logger{context: l.context, data: l.data, out: w}
+}
+
+// merge returns the bound data overlaid with data
+func (l *Logger) merge(data Data) Data {
+	m := make(Data, len(l.data)+len(data))
+	for k, v := range l.data {
+		m[k] = v
+	}
+	for k, v := range data {
+		m[k] = v
+	}
+	return m
+}
+
+func (l *Logger) emit(name string, data Data) {
+	if l.out == nil {
+		Event(name, l.context, data)
+		return
+	}
+	write(l.out, name, l.context, data)
+}
+
+// Event records an event
+func (l *Logger) Event(name string, data Data) {
+	l.emit(name, l.merge(data))
+}
+
+// Error is a structured error message
+func (l *Logger) Error(err error, data Data) {
+	l.emit("error", errorData(err, l.merge(data)))
+}
+
+// Info is a structured info message
+func (l *Logger) Info(message string, data Data) {
+	l.emit("info", messageData(message, l.merge(data)))
+}
+
+// Warn is a structured warning message
+func (l *Logger) Warn(message string, data Data) {
+	l.emit("warn", messageData(message, l.merge(data)))
+}
+
+// Debug is a structured debug message
+func (l *Logger) Debug(message string, data Data) {
+	l.emit("debug", messageData(message, l.merge(data)))
+}
+
+// Trace is a structured trace message
+func (l *Logger) Trace(message string, data Data) {
+	l.emit("trace", messageData(message, l.merge(data)))
+}
@@ -0,0 +1,118 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/baggage"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFromContext(t *testing.T) {
+	defer func() {
+		SetEvent(nil)
+		SetBaggageKeys(nil)
+		enrichers = nil
+	}()
+
+	var eventName, eventContext string
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventName = name
+		eventContext = context
+		eventData = data
+	})
+
+	Convey("FromContext should return an unbound logger for an empty context", t, func() {
+		FromContext(context.Background()).Debug("test", nil)
+		So(eventName, ShouldEqual, "debug")
+		So(eventContext, ShouldBeEmpty)
+		So(eventData, ShouldResemble, Data{"message": "test"})
+	})
+
+	Convey("FromContext should bind values from the context", t, func() {
+		SetBaggageKeys([]string{"tenant"})
+		ctx := WithRequestID(context.Background(), "abc")
+		ctx = WithCaller(ctx, "user@ons.gov.uk")
+		ctx = withTrace(ctx, "trace", "span")
+		ctx = baggage.Set(ctx, "tenant", "ons")
+
+		err := errors.New("test")
+		FromContext(ctx).Error(err, Data{"foo": "bar"})
+		So(eventName, ShouldEqual, "error")
+		So(eventContext, ShouldEqual, "abc")
+		So(eventData["fingerprint"], ShouldNotBeEmpty)
+		So(eventData["stack"], ShouldNotBeEmpty)
+		delete(eventData, "fingerprint")
+		delete(eventData, "stack")
+		So(eventData, ShouldResemble, Data{
+			"message":  "test",
+			"error":    err,
+			"foo":      "bar",
+			"caller":   "user@ons.gov.uk",
+			"trace_id": "trace",
+			"span_id":  "span",
+			"baggage":  map[string]string{"tenant": "ons"},
+		})
+	})
+
+	Convey("FromContext should include registered enrichment", t, func() {
+		RegisterEnricher(func(ctx context.Context) Data {
+			return Data{"enriched": true}
+		})
+		FromContext(context.Background()).Trace("test", nil)
+		So(eventName, ShouldEqual, "trace")
+		So(eventData["enriched"], ShouldBeTrue)
+	})
+
+	Convey("WithData should not modify the original logger", t, func() {
+		enrichers = nil
+		l := FromContext(context.Background())
+		l.WithData(Data{"foo": "bar"}).Event("custom", nil)
+		So(eventName, ShouldEqual, "custom")
+		So(eventData, ShouldResemble, Data{"foo": "bar"})
+
+		l.Event("custom", nil)
+		So(eventData, ShouldResemble, Data{})
+	})
+
+	Convey("With should chain fields which are merged with per-call data", t, func() {
+		l := With("service", "api").With("version", "1.0.0")
+		l.Debug("started", Data{"version": "1.0.1", "port": 8080})
+		So(eventName, ShouldEqual, "debug")
+		So(eventContext, ShouldBeEmpty)
+		So(eventData, ShouldResemble, Data{"service": "api", "version": "1.0.1", "port": 8080, "message": "started"})
+
+		l.Info("ready", nil)
+		So(eventName, ShouldEqual, "info")
+		So(eventData, ShouldResemble, Data{"service": "api", "version": "1.0.0", "message": "ready"})
+
+		l.Warn("degraded", nil)
+		So(eventName, ShouldEqual, "warn")
+		So(eventData, ShouldResemble, Data{"service": "api", "version": "1.0.0", "message": "degraded"})
+
+		l.With("extra", true).Trace("test", nil)
+		l.Trace("test", nil)
+		So(eventData, ShouldNotContainKey, "extra")
+	})
+
+	Convey("Handler should bind the request ID and trace IDs to the request context", t, func() {
+		var l *Logger
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			l = FromContext(req.Context())
+		}))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("X-Request-Id", "abc")
+		req.Header.Set("X-Cloud-Trace-Context", "105445aa7843bc8bf206b12000100000/1;o=1")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(l.context, ShouldEqual, "abc")
+		So(l.data["trace_id"], ShouldEqual, "105445aa7843bc8bf206b12000100000")
+		So(l.data["span_id"], ShouldEqual, "1")
+	})
+}
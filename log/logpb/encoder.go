@@ -0,0 +1,102 @@
+// Package logpb provides a protobuf encoding of log events, for high volume
+// services where JSON serialisation cost and size dominate.
+//
+// Events are written as length-delimited Event messages (a varint size
+// followed by the message), and can be read back using protodelim.UnmarshalFrom.
+package logpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative event.proto
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Encoder is a log.Encoder producing length-delimited Event messages
+type Encoder struct{}
+
+// Encode implements log.Encoder
+func (Encoder) Encode(r log.Record) ([]byte, error) {
+	b, err := proto.Marshal(NewEvent(r))
+	if err != nil {
+		return nil, err
+	}
+
+	size := uint64(len(b))
+	buf := make([]byte, 0, protowire.SizeVarint(size)+len(b))
+	buf = protowire.AppendVarint(buf, size)
+	return append(buf, b...), nil
+}
+
+// NewEvent converts a log.Record to an Event message
+func NewEvent(r log.Record) *Event {
+	e := &Event{
+		Created:   timestamppb.New(r.Created),
+		Event:     r.Event,
+		Namespace: r.Namespace,
+		Context:   r.Context,
+	}
+
+	if len(r.Data) > 0 {
+		e.Data = make(map[string]*Value, len(r.Data))
+		for k, v := range r.Data {
+			e.Data[k] = NewValue(v)
+		}
+	}
+
+	return e
+}
+
+// NewValue converts a Data value to a Value message, falling back to JSON for
+// types with no direct representation
+func NewValue(v interface{}) *Value {
+	switch t := v.(type) {
+	case string:
+		return &Value{Kind: &Value_StringValue{t}}
+	case bool:
+		return &Value{Kind: &Value_BoolValue{t}}
+	case int:
+		return &Value{Kind: &Value_IntValue{int64(t)}}
+	case int8:
+		return &Value{Kind: &Value_IntValue{int64(t)}}
+	case int16:
+		return &Value{Kind: &Value_IntValue{int64(t)}}
+	case int32:
+		return &Value{Kind: &Value_IntValue{int64(t)}}
+	case int64:
+		return &Value{Kind: &Value_IntValue{t}}
+	case uint:
+		return &Value{Kind: &Value_UintValue{uint64(t)}}
+	case uint8:
+		return &Value{Kind: &Value_UintValue{uint64(t)}}
+	case uint16:
+		return &Value{Kind: &Value_UintValue{uint64(t)}}
+	case uint32:
+		return &Value{Kind: &Value_UintValue{uint64(t)}}
+	case uint64:
+		return &Value{Kind: &Value_UintValue{t}}
+	case float32:
+		return &Value{Kind: &Value_DoubleValue{float64(t)}}
+	case float64:
+		return &Value{Kind: &Value_DoubleValue{t}}
+	case time.Time:
+		return &Value{Kind: &Value_TimeValue{timestamppb.New(t)}}
+	case time.Duration:
+		return &Value{Kind: &Value_DurationValue{durationpb.New(t)}}
+	case error:
+		return &Value{Kind: &Value_StringValue{t.Error()}}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return &Value{Kind: &Value_StringValue{fmt.Sprintf("%+v", v)}}
+	}
+	return &Value{Kind: &Value_JsonValue{string(b)}}
+}
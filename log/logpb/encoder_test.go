@@ -0,0 +1,70 @@
+package logpb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+func TestEncoder(t *testing.T) {
+	Convey("Encoder should write length-delimited Event messages", t, func() {
+		now := time.Now()
+
+		var buf bytes.Buffer
+		for _, name := range []string{"first", "second"} {
+			b, err := Encoder{}.Encode(log.Record{
+				Created:   now,
+				Event:     name,
+				Namespace: "namespace",
+				Context:   "context",
+				Data:      log.Data{"status": 200, "path": "/"},
+			})
+			So(err, ShouldBeNil)
+			buf.Write(b)
+		}
+
+		r := bufio.NewReader(&buf)
+		for _, name := range []string{"first", "second"} {
+			var e Event
+			So(protodelim.UnmarshalFrom(r, &e), ShouldBeNil)
+			So(e.Event, ShouldEqual, name)
+			So(e.Namespace, ShouldEqual, "namespace")
+			So(e.Context, ShouldEqual, "context")
+			So(e.Created.AsTime().Equal(now), ShouldBeTrue)
+			So(e.Data["status"].GetIntValue(), ShouldEqual, 200)
+			So(e.Data["path"].GetStringValue(), ShouldEqual, "/")
+		}
+	})
+
+	Convey("Encoder should be usable with a WriterSink", t, func() {
+		var buf bytes.Buffer
+		s := log.NewWriterSink(&buf, Encoder{})
+		So(s.Write(log.Record{Event: "test"}), ShouldBeNil)
+
+		var e Event
+		So(protodelim.UnmarshalFrom(bufio.NewReader(&buf), &e), ShouldBeNil)
+		So(e.Event, ShouldEqual, "test")
+	})
+}
+
+func TestNewValue(t *testing.T) {
+	Convey("NewValue should map Go types to Value kinds", t, func() {
+		now := time.Now()
+
+		So(NewValue("foo").GetStringValue(), ShouldEqual, "foo")
+		So(NewValue(true).GetBoolValue(), ShouldBeTrue)
+		So(NewValue(int32(-5)).GetIntValue(), ShouldEqual, -5)
+		So(NewValue(uint16(5)).GetUintValue(), ShouldEqual, 5)
+		So(NewValue(1.5).GetDoubleValue(), ShouldEqual, 1.5)
+		So(NewValue(now).GetTimeValue().AsTime().Equal(now), ShouldBeTrue)
+		So(NewValue(time.Second).GetDurationValue().AsDuration(), ShouldEqual, time.Second)
+		So(NewValue(errors.New("test error")).GetStringValue(), ShouldEqual, "test error")
+		So(NewValue(map[string]int{"a": 1}).GetJsonValue(), ShouldEqual, `{"a":1}`)
+	})
+}
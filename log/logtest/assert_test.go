@@ -0,0 +1,62 @@
+package logtest
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// fakeT records testify failures
+type fakeT struct {
+	failures []string
+}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertions(t *testing.T) {
+	errNotFound := errors.New("not found")
+
+	rec := NewRecorder()
+	rec.Install()
+	log.Info("dataset published", log.Data{"dataset_id": "cpih01"})
+	log.Error(fmt.Errorf("getting dataset: %w", errNotFound), log.Data{"dataset_id": "cpih01"})
+	rec.Uninstall()
+
+	Convey("Find should return events matching all matchers", t, func() {
+		So(rec.Find("info", MessageContains("published")), ShouldHaveLength, 1)
+		So(rec.Find("info", MessageContains("published"), HasFields(log.Data{"dataset_id": "cpi"})), ShouldBeEmpty)
+		So(rec.Find("error", ErrorIs(errNotFound)), ShouldHaveLength, 1)
+		So(rec.Find("error", ErrorIs(errors.New("not found"))), ShouldBeEmpty)
+	})
+
+	Convey("ShouldHaveLoggedEvent should match events by name, fields and matchers", t, func() {
+		So(rec, ShouldHaveLoggedEvent, "info")
+		So(rec, ShouldHaveLoggedEvent, "info", log.Data{"dataset_id": "cpih01"})
+		So(rec, ShouldHaveLoggedEvent, "error", ErrorIs(errNotFound), log.Data{"dataset_id": "cpih01"})
+		So(rec, ShouldNotHaveLoggedEvent, "warn")
+		So(rec, ShouldNotHaveLoggedEvent, "info", MessageContains("deleted"))
+
+		So(ShouldHaveLoggedEvent(rec, "warn"), ShouldContainSubstring, "recorded events:")
+		So(ShouldHaveLoggedEvent(NewRecorder(), "warn"), ShouldContainSubstring, "no events were recorded")
+		So(ShouldNotHaveLoggedEvent(rec, "info"), ShouldNotBeEmpty)
+		So(ShouldHaveLoggedEvent(nil, "info"), ShouldNotBeEmpty)
+		So(ShouldHaveLoggedEvent(rec), ShouldNotBeEmpty)
+		So(ShouldHaveLoggedEvent(rec, "info", 1), ShouldNotBeEmpty)
+	})
+
+	Convey("AssertErrorLogged should pass or fail a testify test", t, func() {
+		ft := &fakeT{}
+		So(rec.AssertErrorLogged(ft, ErrorIs(errNotFound)), ShouldBeTrue)
+		So(rec.AssertEventLogged(ft, "info", HasFields(log.Data{"dataset_id": "cpih01"})), ShouldBeTrue)
+		So(ft.failures, ShouldBeEmpty)
+
+		So(rec.AssertErrorLogged(ft, MessageContains("timeout")), ShouldBeFalse)
+		So(ft.failures, ShouldHaveLength, 1)
+		So(ft.failures[0], ShouldContainSubstring, `Expected a matching "error" event to have been logged`)
+	})
+}
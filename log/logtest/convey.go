@@ -0,0 +1,66 @@
+package logtest
+
+import (
+	"fmt"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// ShouldHaveLoggedEvent is a GoConvey assertion that a Recorder has
+// recorded an event with a name. Further arguments are Matchers, or
+// log.Data fields the event must include, e.g.
+//
+//	So(rec, logtest.ShouldHaveLoggedEvent, "request", log.Data{"status": 200})
+func ShouldHaveLoggedEvent(actual interface{}, expected ...interface{}) string {
+	r, name, matchers, msg := conveyArgs(actual, expected)
+	if len(msg) > 0 {
+		return msg
+	}
+	if len(r.Find(name, matchers...)) == 0 {
+		return fmt.Sprintf("Expected a matching %q event to have been logged, but %s", name, r.summary())
+	}
+	return ""
+}
+
+// ShouldNotHaveLoggedEvent is a GoConvey assertion that a Recorder hasn't
+// recorded a matching event, taking the same arguments as
+// ShouldHaveLoggedEvent
+func ShouldNotHaveLoggedEvent(actual interface{}, expected ...interface{}) string {
+	r, name, matchers, msg := conveyArgs(actual, expected)
+	if len(msg) > 0 {
+		return msg
+	}
+	if events := r.Find(name, matchers...); len(events) > 0 {
+		return fmt.Sprintf("Expected no matching %q event to have been logged, but found %v", name, events[0].Data)
+	}
+	return ""
+}
+
+func conveyArgs(actual interface{}, expected []interface{}) (*Recorder, string, []Matcher, string) {
+	r, ok := actual.(*Recorder)
+	if !ok {
+		return nil, "", nil, fmt.Sprintf("Expected a *logtest.Recorder, got %T", actual)
+	}
+	if len(expected) == 0 {
+		return nil, "", nil, "Expected an event name"
+	}
+	name, ok := expected[0].(string)
+	if !ok {
+		return nil, "", nil, fmt.Sprintf("Expected an event name, got %T", expected[0])
+	}
+
+	var matchers []Matcher
+	for _, e := range expected[1:] {
+		switch m := e.(type) {
+		case Matcher:
+			matchers = append(matchers, m)
+		case func(Event) bool:
+			matchers = append(matchers, m)
+		case log.Data:
+			matchers = append(matchers, HasFields(m))
+		default:
+			return nil, "", nil, fmt.Sprintf("Expected a logtest.Matcher or log.Data, got %T", e)
+		}
+	}
+	return r, name, matchers, ""
+}
@@ -0,0 +1,118 @@
+// Package logtest captures log events in memory so tests can make
+// assertions about them, e.g.
+//
+//	rec := logtest.NewRecorder()
+//	rec.Install()
+//	defer rec.Uninstall()
+//
+//	handler.ServeHTTP(w, req)
+//	So(rec.EventsOf("request"), ShouldHaveLength, 1)
+//	So(rec, logtest.ShouldHaveLoggedEvent, "request", log.Data{"status": 200})
+//
+// or with testify
+//
+//	rec.AssertErrorLogged(t, logtest.ErrorIs(sql.ErrNoRows))
+package logtest
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Event is a recorded log event
+type Event struct {
+	Name    string
+	Context string
+	Data    log.Data
+}
+
+// Recorder records events logged through log.Event and the functions and
+// middleware built on it
+type Recorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Install records events instead of writing them, until Uninstall is called
+func (r *Recorder) Install() {
+	log.SetEvent(r.Record)
+}
+
+// Uninstall restores the default event function
+func (r *Recorder) Uninstall() {
+	log.SetEvent(nil)
+}
+
+// Record records an event. It has the signature of log.Event, so it can
+// also be called from a replacement event function.
+func (r *Recorder) Record(name, context string, data log.Data) {
+	// copy the data so later changes by the caller aren't recorded
+	var d log.Data
+	if data != nil {
+		d = make(log.Data, len(data))
+		for k, v := range data {
+			d[k] = v
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, Event{Name: name, Context: context, Data: d})
+}
+
+// Events returns all recorded events, oldest first
+func (r *Recorder) Events() []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+// LastEvent returns the most recently recorded event, or false if none
+// have been recorded
+func (r *Recorder) LastEvent() (Event, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.events) == 0 {
+		return Event{}, false
+	}
+	return r.events[len(r.events)-1], true
+}
+
+// EventsOf returns the recorded events with a name, oldest first
+func (r *Recorder) EventsOf(name string) []Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var events []Event
+	for _, e := range r.events {
+		if e.Name == name {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// Contains reports whether any recorded event has a data field equal to value
+func (r *Recorder) Contains(field string, value interface{}) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, e := range r.events {
+		if v, ok := e.Data[field]; ok && reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reset discards all recorded events
+func (r *Recorder) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = nil
+}
@@ -0,0 +1,80 @@
+package logtest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecorder(t *testing.T) {
+	Convey("Recorder should capture events while installed", t, func() {
+		rec := NewRecorder()
+		rec.Install()
+
+		log.Debug("first", log.Data{"n": 1})
+		log.ErrorC("context", errors.New("failed"), nil)
+		log.Named("kafka").Event("consumed", log.Data{"n": 2})
+
+		rec.Uninstall()
+		log.SetOutput(ioutil.Discard)
+		defer log.SetOutput(nil)
+		log.Debug("not recorded", nil)
+
+		events := rec.Events()
+		So(events, ShouldHaveLength, 3)
+		So(events[0].Name, ShouldEqual, "debug")
+		So(events[0].Data["message"], ShouldEqual, "first")
+		So(events[1].Context, ShouldEqual, "context")
+
+		last, ok := rec.LastEvent()
+		So(ok, ShouldBeTrue)
+		So(last.Name, ShouldEqual, "consumed")
+		So(last.Data["logger"], ShouldEqual, "kafka")
+
+		So(rec.EventsOf("error"), ShouldHaveLength, 1)
+		So(rec.EventsOf("request"), ShouldBeEmpty)
+
+		So(rec.Contains("n", 2), ShouldBeTrue)
+		So(rec.Contains("n", 3), ShouldBeFalse)
+		So(rec.Contains("message", "not recorded"), ShouldBeFalse)
+	})
+
+	Convey("Recorder should capture request events from log.Handler", t, func() {
+		rec := NewRecorder()
+		rec.Install()
+		defer rec.Uninstall()
+
+		h := log.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+		req := httptest.NewRequest("GET", "/teapot", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(rec.EventsOf("request"), ShouldHaveLength, 1)
+		So(rec.Contains("status", http.StatusTeapot), ShouldBeTrue)
+	})
+
+	Convey("Recorder should copy event data", t, func() {
+		rec := NewRecorder()
+		data := log.Data{"n": 1}
+		rec.Record("test", "", data)
+		data["n"] = 2
+
+		So(rec.Contains("n", 1), ShouldBeTrue)
+	})
+
+	Convey("LastEvent should return false and Reset should discard events", t, func() {
+		rec := NewRecorder()
+		_, ok := rec.LastEvent()
+		So(ok, ShouldBeFalse)
+
+		rec.Record("test", "", nil)
+		rec.Reset()
+		So(rec.Events(), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,75 @@
+package logtest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Matcher reports whether a recorded event matches
+type Matcher func(e Event) bool
+
+// ErrorIs matches error events whose error is target or wraps it
+func ErrorIs(target error) Matcher {
+	return func(e Event) bool {
+		err, ok := e.Data["error"].(error)
+		return ok && errors.Is(err, target)
+	}
+}
+
+// MessageContains matches events whose message contains substr
+func MessageContains(substr string) Matcher {
+	return func(e Event) bool {
+		message, ok := e.Data["message"].(string)
+		return ok && strings.Contains(message, substr)
+	}
+}
+
+// HasFields matches events whose data includes each of fields
+func HasFields(fields log.Data) Matcher {
+	return func(e Event) bool {
+		for k, want := range fields {
+			if got, ok := e.Data[k]; !ok || !reflect.DeepEqual(got, want) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Find returns the recorded events with a name which match all of
+// matchers, oldest first
+func (r *Recorder) Find(name string, matchers ...Matcher) []Event {
+	var events []Event
+	for _, e := range r.EventsOf(name) {
+		if matchAll(e, matchers) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func matchAll(e Event, matchers []Matcher) bool {
+	for _, m := range matchers {
+		if !m(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// summary describes the recorded events for assertion failures
+func (r *Recorder) summary() string {
+	events := r.Events()
+	if len(events) == 0 {
+		return "no events were recorded"
+	}
+	lines := make([]string, len(events))
+	for i, e := range events {
+		lines[i] = fmt.Sprintf("  %s %v", e.Name, e.Data)
+	}
+	return "recorded events:\n" + strings.Join(lines, "\n")
+}
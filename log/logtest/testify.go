@@ -0,0 +1,28 @@
+package logtest
+
+import (
+	"github.com/stretchr/testify/assert"
+)
+
+// AssertEventLogged is a testify assertion that an event with a name has
+// been recorded which matches all of matchers
+func (r *Recorder) AssertEventLogged(t assert.TestingT, name string, matchers ...Matcher) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	if len(r.Find(name, matchers...)) > 0 {
+		return true
+	}
+	return assert.Fail(t, "Expected a matching \""+name+"\" event to have been logged", r.summary())
+}
+
+// AssertErrorLogged is a testify assertion that an error event has been
+// recorded which matches matcher, e.g.
+//
+//	rec.AssertErrorLogged(t, logtest.ErrorIs(sql.ErrNoRows))
+func (r *Recorder) AssertErrorLogged(t assert.TestingT, matcher Matcher) bool {
+	if h, ok := t.(interface{ Helper() }); ok {
+		h.Helper()
+	}
+	return r.AssertEventLogged(t, "error", matcher)
+}
@@ -0,0 +1,326 @@
+// Package loki provides a log.Sink which pushes events to Grafana Loki
+package loki
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// Config configures a Loki sink
+type Config struct {
+	// URL is the push API endpoint, e.g. http://loki:3100/loki/api/v1/push
+	URL string
+	// Labels are added to every stream, overriding the default namespace,
+	// level and host labels
+	Labels map[string]string
+	// TenantID is sent as X-Scope-OrgID for multi-tenant Loki installations
+	TenantID string
+	// BatchSize is the number of events which triggers a push, defaults to 1000
+	BatchSize int
+	// FlushInterval is the maximum time events are buffered, defaults to 1s
+	FlushInterval time.Duration
+	// MaxRetries is the number of retries on rate limiting or server errors, defaults to 5
+	MaxRetries int
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Encoder formats each event, defaults to log.JSONEncoder
+	Encoder log.Encoder
+}
+
+// Sink buffers log events and pushes them to Loki in batches
+type Sink struct {
+	cfg  Config
+	host string
+
+	mutex  sync.Mutex
+	buffer []entry
+	err    error
+
+	// pushes are serialized by the run goroutine, so batches are sent in
+	// order and a Flush during a background push waits for it
+	full  chan struct{}
+	flush chan chan error
+	done  chan struct{}
+	wg    sync.WaitGroup
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+type entry struct {
+	labels map[string]string
+	ts     time.Time
+	line   string
+}
+
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// pushError is returned for non-2xx responses from Loki
+type pushError struct {
+	status int
+	body   string
+}
+
+func (e *pushError) Error() string {
+	return fmt.Sprintf("loki: push failed (%d): %s", e.status, e.body)
+}
+
+func (e *pushError) retryable() bool {
+	return e.status == http.StatusTooManyRequests || e.status >= 500
+}
+
+// New returns a Sink which starts pushing events in the background
+func New(cfg Config) (*Sink, error) {
+	if len(cfg.URL) == 0 {
+		return nil, errors.New("loki: push URL is required")
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Second
+	}
+	if cfg.MaxRetries <= 0 {
+		cfg.MaxRetries = 5
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Encoder == nil {
+		cfg.Encoder = log.JSONEncoder{}
+	}
+
+	host, _ := os.Hostname()
+
+	s := &Sink{
+		cfg:   cfg,
+		host:  host,
+		full:  make(chan struct{}, 1),
+		flush: make(chan chan error),
+		done:  make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// Write buffers an event, returning any error from a previous background push
+func (s *Sink) Write(r log.Record) error {
+	b, err := s.cfg.Encoder.Encode(r)
+	if err != nil {
+		return err
+	}
+	b = bytes.TrimRight(b, "\n")
+
+	s.mutex.Lock()
+	s.buffer = append(s.buffer, entry{labels: s.labels(r), ts: r.Created, line: string(b)})
+	full := len(s.buffer) >= s.cfg.BatchSize
+
+	err, s.err = s.err, nil
+	s.mutex.Unlock()
+
+	if full {
+		select {
+		case s.full <- struct{}{}:
+		default:
+		}
+	}
+
+	return err
+}
+
+// Close pushes any buffered events and stops the background goroutine. It
+// returns the same error if it's called again.
+func (s *Sink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+
+		s.mutex.Lock()
+		s.closeErr, s.err = s.err, nil
+		s.mutex.Unlock()
+	})
+	return s.closeErr
+}
+
+// Flush pushes all buffered events to Loki
+func (s *Sink) Flush() error {
+	reply := make(chan error)
+	select {
+	case s.flush <- reply:
+		return <-reply
+	case <-s.done:
+		return nil
+	}
+}
+
+// send pushes the buffered events, retrying on rate limiting or server
+// errors. It's only called by the run goroutine.
+func (s *Sink) send() error {
+	s.mutex.Lock()
+	entries := s.buffer
+	s.buffer = nil
+	s.mutex.Unlock()
+
+	if len(entries) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"streams": streams(entries)})
+	if err != nil {
+		return err
+	}
+
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err = s.push(body)
+		pushErr, ok := err.(*pushError)
+		if err == nil || !ok || !pushErr.retryable() || attempt >= s.cfg.MaxRetries {
+			return err
+		}
+
+		time.Sleep(backoff + time.Duration(rand.Int63n(int64(backoff))))
+		backoff *= 2
+	}
+}
+
+func (s *Sink) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case reply := <-s.flush:
+			reply <- s.send()
+			continue
+		case <-ticker.C:
+		case <-s.full:
+		case <-s.done:
+			s.keep(s.send())
+			return
+		}
+		s.keep(s.send())
+	}
+}
+
+// keep stores an error from a background push, to be returned by the next
+// Write or Close
+func (s *Sink) keep(err error) {
+	if err == nil {
+		return
+	}
+	s.mutex.Lock()
+	s.err = err
+	s.mutex.Unlock()
+}
+
+func (s *Sink) labels(r log.Record) map[string]string {
+	labels := map[string]string{
+		"namespace": r.Namespace,
+		"level":     level(r.Level()),
+	}
+	if len(s.host) > 0 {
+		labels["host"] = s.host
+	}
+	for k, v := range s.cfg.Labels {
+		labels[k] = v
+	}
+	return labels
+}
+
+func (s *Sink) push(body []byte) error {
+	req, err := http.NewRequest("POST", s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.cfg.TenantID) > 0 {
+		req.Header.Set("X-Scope-OrgID", s.cfg.TenantID)
+	}
+
+	resp, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return &pushError{status: resp.StatusCode, body: strings.TrimSpace(string(b))}
+	}
+	return nil
+}
+
+// level maps levels to the level label values Grafana recognises
+func level(l log.Level) string {
+	switch l {
+	case log.TRACE:
+		return "trace"
+	case log.DEBUG:
+		return "debug"
+	case log.WARN:
+		return "warn"
+	case log.ERROR:
+		return "error"
+	case log.FATAL:
+		return "critical"
+	}
+	return "info"
+}
+
+// streams groups entries by label set, in timestamp order within each stream
+func streams(entries []entry) []stream {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].ts.Before(entries[j].ts)
+	})
+
+	var result []stream
+	index := map[string]int{}
+
+	for _, e := range entries {
+		key := labelKey(e.labels)
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			result = append(result, stream{Stream: e.labels})
+		}
+		result[i].Values = append(result[i].Values, [2]string{strconv.FormatInt(e.ts.UnixNano(), 10), e.line})
+	}
+
+	return result
+}
+
+func labelKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "%s=%q,", k, labels[k])
+	}
+	return buf.String()
+}
@@ -0,0 +1,149 @@
+package loki
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeLoki struct {
+	mutex    sync.Mutex
+	pushes   [][]stream
+	tenants  []string
+	failWith []int
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.failWith) > 0 {
+		w.WriteHeader(f.failWith[0])
+		f.failWith = f.failWith[1:]
+		return
+	}
+
+	var body struct {
+		Streams []stream `json:"streams"`
+	}
+	json.NewDecoder(req.Body).Decode(&body)
+	f.pushes = append(f.pushes, body.Streams)
+	f.tenants = append(f.tenants, req.Header.Get("X-Scope-OrgID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func TestNew(t *testing.T) {
+	Convey("New should require a URL", t, func() {
+		_, err := New(Config{})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestSink(t *testing.T) {
+	Convey("Close should push buffered events grouped into streams", t, func() {
+		f := &fakeLoki{}
+		server := httptest.NewServer(f)
+		defer server.Close()
+
+		s, err := New(Config{URL: server.URL, Labels: map[string]string{"env": "test"}, TenantID: "tenant", FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		now := time.Now()
+		So(s.Write(log.Record{Created: now, Event: "debug", Namespace: "namespace"}), ShouldBeNil)
+		So(s.Write(log.Record{Created: now, Event: "error", Namespace: "namespace"}), ShouldBeNil)
+		So(s.Write(log.Record{Created: now.Add(-time.Second), Event: "debug", Namespace: "namespace"}), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+
+		So(f.pushes, ShouldHaveLength, 1)
+		So(f.tenants[0], ShouldEqual, "tenant")
+
+		streams := f.pushes[0]
+		So(streams, ShouldHaveLength, 2)
+		So(streams[0].Stream["level"], ShouldEqual, "debug")
+		So(streams[0].Stream["namespace"], ShouldEqual, "namespace")
+		So(streams[0].Stream["env"], ShouldEqual, "test")
+		So(streams[0].Values, ShouldHaveLength, 2)
+		So(streams[0].Values[0][0] < streams[0].Values[1][0], ShouldBeTrue)
+		So(streams[1].Stream["level"], ShouldEqual, "error")
+	})
+
+	Convey("Flush should retry on rate limiting", t, func() {
+		f := &fakeLoki{failWith: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}}
+		server := httptest.NewServer(f)
+		defer server.Close()
+
+		s, err := New(Config{URL: server.URL, FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		So(s.Close(), ShouldBeNil)
+		So(f.pushes, ShouldHaveLength, 1)
+	})
+
+	Convey("Flush should not retry client errors", t, func() {
+		f := &fakeLoki{failWith: []int{http.StatusBadRequest}}
+		server := httptest.NewServer(f)
+		defer server.Close()
+
+		s, err := New(Config{URL: server.URL, FlushInterval: time.Hour})
+		So(err, ShouldBeNil)
+
+		s.Write(log.Record{Created: time.Now(), Event: "test"})
+		err = s.Close()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "400")
+		So(f.pushes, ShouldBeEmpty)
+	})
+
+	Convey("Flush and Close should be safe to call concurrently and repeatedly", t, func() {
+		f := &fakeLoki{}
+		server := httptest.NewServer(f)
+		defer server.Close()
+
+		s, err := New(Config{URL: server.URL, BatchSize: 2, FlushInterval: time.Millisecond})
+		So(err, ShouldBeNil)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				s.Write(log.Record{Created: time.Now(), Event: "test"})
+			}()
+			go func() {
+				defer wg.Done()
+				s.Flush()
+			}()
+		}
+		wg.Wait()
+
+		So(s.Close(), ShouldBeNil)
+		So(s.Close(), ShouldBeNil)
+		So(s.Flush(), ShouldBeNil)
+
+		var values int
+		for _, push := range f.pushes {
+			for _, stream := range push {
+				values += len(stream.Values)
+			}
+		}
+		So(values, ShouldEqual, 10)
+	})
+}
+
+func TestLevel(t *testing.T) {
+	Convey("levels should map to level labels", t, func() {
+		So(level(log.FATAL), ShouldEqual, "critical")
+		So(level(log.ERROR), ShouldEqual, "error")
+		So(level(log.WARN), ShouldEqual, "warn")
+		So(level(log.INFO), ShouldEqual, "info")
+		So(level(log.DEBUG), ShouldEqual, "debug")
+		So(level(log.TRACE), ShouldEqual, "trace")
+	})
+}
@@ -0,0 +1,38 @@
+package log
+
+import "sync"
+
+// RequestObserver is called by Handler with the request event for every
+// request, before it's filtered by level, hooks, sampling or skip options,
+// e.g. to maintain request metrics. The record mustn't be modified.
+type RequestObserver func(r Record)
+
+var (
+	observers      []RequestObserver
+	observersMutex sync.RWMutex
+)
+
+// RegisterRequestObserver adds an observer which is called for every
+// request handled by Handler
+func RegisterRequestObserver(o RequestObserver) {
+	observersMutex.Lock()
+	defer observersMutex.Unlock()
+	observers = append(observers, o)
+}
+
+// hasObservers reports whether any request observers are registered, so
+// Handler can skip building events for requests which aren't logged
+func hasObservers() bool {
+	observersMutex.RLock()
+	defer observersMutex.RUnlock()
+	return len(observers) > 0
+}
+
+// observeRequest passes a request event to the registered observers
+func observeRequest(r Record) {
+	observersMutex.RLock()
+	defer observersMutex.RUnlock()
+	for _, o := range observers {
+		o(r)
+	}
+}
@@ -0,0 +1,45 @@
+package log
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRequestObservers(t *testing.T) {
+	defer func() {
+		observers = nil
+		SetLevel(TRACE)
+		SetOutput(nil)
+	}()
+
+	Convey("observers should see every request before events are filtered", t, func() {
+		observers = nil
+		var records []Record
+		RegisterRequestObserver(func(r Record) {
+			records = append(records, r)
+		})
+
+		var buf bytes.Buffer
+		SetOutput(&buf)
+		SetLevel(ERROR)
+
+		h := HandlerWithOptions(HandlerOptions{SkipPaths: []string{"/healthcheck"}})(dummyHandler)
+		for _, path := range []string{"/datasets", "/healthcheck"} {
+			req, err := http.NewRequest("GET", path, nil)
+			So(err, ShouldBeNil)
+			req.Header.Set("X-Request-Id", "request-id")
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		So(buf.Len(), ShouldEqual, 0)
+		So(records, ShouldHaveLength, 2)
+		So(records[0].Event, ShouldEqual, "request")
+		So(records[0].Context, ShouldEqual, "request-id")
+		So(records[0].Data["path"], ShouldEqual, "/datasets")
+		So(records[1].Data["path"], ShouldEqual, "/healthcheck")
+	})
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	output       io.Writer
+	levelOutputs map[Level]io.Writer
+	outputMutex  sync.Mutex
+)
+
+// SetOutput sets the writer events are written to. Passing nil restores
+// the default of os.Stdout.
+func SetOutput(w io.Writer) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+	if buffer != nil {
+		buffer.Flush()
+		buffer.Reset(orStdout(w))
+	}
+	output = w
+}
+
+// SetLevelOutput sets the writer for events at a level instead of the
+// output set by SetOutput, e.g. to write errors to stderr. Passing nil
+// removes the override. Events written to a level output aren't buffered.
+func SetLevelOutput(l Level, w io.Writer) {
+	outputMutex.Lock()
+	defer outputMutex.Unlock()
+
+	m := make(map[Level]io.Writer, len(levelOutputs)+1)
+	for k, v := range levelOutputs {
+		m[k] = v
+	}
+	if w == nil {
+		delete(m, l)
+	} else {
+		m[l] = w
+	}
+	levelOutputs = m
+}
+
+// configureOutput routes events at or above LOG_STDERR_LEVEL to stderr,
+// e.g. LOG_STDERR_LEVEL=error writes errors to stderr and everything else
+// to stdout. By default all events are written to stdout.
+func configureOutput() {
+	outputMutex.Lock()
+	levelOutputs = nil
+	outputMutex.Unlock()
+
+	v := os.Getenv("LOG_STDERR_LEVEL")
+	if len(strings.TrimSpace(v)) == 0 {
+		return
+	}
+	min, err := ParseLevel(v)
+	if err != nil {
+		return
+	}
+	for l := range levelNames {
+		if l >= min {
+			SetLevelOutput(l, os.Stderr)
+		}
+	}
+}
+
+// getOutput returns the writer for events at a level: its level output if
+// set, then the output buffer if buffering is enabled, otherwise the
+// configured writer, or os.Stdout if none is set. It must be called with
+// outputMutex held.
+func getOutput(l Level) io.Writer {
+	if w, ok := levelOutputs[l]; ok {
+		return w
+	}
+	if buffer != nil {
+		return buffer
+	}
+	return orStdout(output)
+}
+
+func orStdout(w io.Writer) io.Writer {
+	if w == nil {
+		return os.Stdout
+	}
+	return w
+}
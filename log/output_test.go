@@ -0,0 +1,116 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSetOutput(t *testing.T) {
+	defer SetOutput(nil)
+
+	Convey("SetOutput should redirect events to a writer", t, func() {
+		SetHumanReadable(false)
+		var buf bytes.Buffer
+		SetOutput(&buf)
+
+		stdout := captureOutput(func() {
+			Debug("test", nil)
+		})
+		So(stdout, ShouldBeEmpty)
+
+		var m map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &m), ShouldBeNil)
+		So(m["event"], ShouldEqual, "debug")
+	})
+
+	Convey("SetOutput should redirect human readable and log_error output", t, func() {
+		var buf bytes.Buffer
+		SetOutput(&buf)
+
+		SetHumanReadable(true)
+		Trace("human", nil)
+		SetHumanReadable(false)
+		So(buf.String(), ShouldContainSubstring, "trace: human")
+
+		buf.Reset()
+		event("test", "", Data{"foo": func() {}})
+		So(buf.String(), ShouldContainSubstring, `"event":"log_error"`)
+	})
+
+	Convey("SetOutput(nil) should restore stdout", t, func() {
+		SetOutput(nil)
+		stdout := captureOutput(func() {
+			Debug("test", nil)
+		})
+		So(stdout, ShouldContainSubstring, `"event":"debug"`)
+	})
+}
+
+func TestSetLevelOutput(t *testing.T) {
+	defer func() {
+		SetOutput(nil)
+		configureOutput()
+	}()
+
+	Convey("SetLevelOutput should route events at a level to their own writer", t, func() {
+		SetHumanReadable(false)
+		var stdout, stderr bytes.Buffer
+		SetOutput(&stdout)
+		SetLevelOutput(ERROR, &stderr)
+
+		Debug("debug", nil)
+		Error(errors.New("failed"), nil)
+		So(stdout.String(), ShouldContainSubstring, `"event":"debug"`)
+		So(stdout.String(), ShouldNotContainSubstring, `"event":"error"`)
+		So(stderr.String(), ShouldContainSubstring, `"event":"error"`)
+
+		stdout.Reset()
+		SetLevelOutput(ERROR, nil)
+		Error(errors.New("failed"), nil)
+		So(stdout.String(), ShouldContainSubstring, `"event":"error"`)
+	})
+
+	Convey("configureOutput should route events at LOG_STDERR_LEVEL and above to stderr", t, func() {
+		defer os.Unsetenv("LOG_STDERR_LEVEL")
+
+		configureOutput()
+		So(levelOutputs, ShouldBeEmpty)
+
+		os.Setenv("LOG_STDERR_LEVEL", "warn")
+		configureOutput()
+		So(levelOutputs, ShouldHaveLength, 3)
+		So(levelOutputs[WARN], ShouldEqual, os.Stderr)
+		So(levelOutputs[FATAL], ShouldEqual, os.Stderr)
+		So(levelOutputs, ShouldNotContainKey, INFO)
+
+		os.Setenv("LOG_STDERR_LEVEL", "verbose")
+		configureOutput()
+		So(levelOutputs, ShouldBeEmpty)
+	})
+}
+
+func TestLoggerWithOutput(t *testing.T) {
+	Convey("WithOutput should write a logger's events to its own writer", t, func() {
+		SetHumanReadable(false)
+		var buf bytes.Buffer
+		l := FromContext(WithRequestID(context.Background(), "abc")).WithOutput(&buf).WithData(Data{"foo": "bar"})
+
+		stdout := captureOutput(func() {
+			l.Error(errors.New("test"), nil)
+		})
+		So(stdout, ShouldBeEmpty)
+
+		var m map[string]interface{}
+		So(json.Unmarshal(buf.Bytes(), &m), ShouldBeNil)
+		So(m["event"], ShouldEqual, "error")
+		So(m["context"], ShouldEqual, "abc")
+		So(m["data"].(map[string]interface{})["foo"], ShouldEqual, "bar")
+		So(m["data"].(map[string]interface{})["message"], ShouldEqual, "test")
+	})
+}
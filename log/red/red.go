@@ -0,0 +1,140 @@
+// Package red maintains rate, errors and duration (RED) metrics for each
+// route from the requests handled by log.Handler. Metrics are registered as
+// a request observer, so they count every request, even if its request
+// event is dropped by the log level, a hook or sampling:
+//
+//	m, err := red.New(red.Config{})
+//	...
+//	log.RegisterRequestObserver(m.Observe)
+package red
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Config configures RED metrics
+type Config struct {
+	// Registerer defaults to prometheus.DefaultRegisterer
+	Registerer prometheus.Registerer
+	// Buckets are the duration histogram buckets in seconds, defaults to
+	// prometheus.DefBuckets
+	Buckets []float64
+	// Route returns the route label for a request event, defaults to the
+	// "route" field or "other" if it isn't set. Raw paths shouldn't be used
+	// as they can have unbounded cardinality.
+	Route func(r log.Record) string
+}
+
+// Metrics are updated from request events
+type Metrics struct {
+	route    func(r log.Record) string
+	requests *prometheus.CounterVec
+	errors   *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+// New creates and registers RED metrics
+func New(cfg Config) (*Metrics, error) {
+	if cfg.Registerer == nil {
+		cfg.Registerer = prometheus.DefaultRegisterer
+	}
+	if cfg.Buckets == nil {
+		cfg.Buckets = prometheus.DefBuckets
+	}
+	if cfg.Route == nil {
+		cfg.Route = route
+	}
+
+	m := &Metrics{
+		route: cfg.Route,
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests.",
+		}, []string{"method", "route", "status"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_errors_total",
+			Help: "Total number of HTTP requests which returned a 5xx status.",
+		}, []string{"method", "route"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests in seconds.",
+			Buckets: cfg.Buckets,
+		}, []string{"method", "route"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.requests, m.errors, m.duration} {
+		if err := cfg.Registerer.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// Observe updates the metrics from a request event, ignoring other events.
+// It's a log.RequestObserver.
+func (m *Metrics) Observe(r log.Record) {
+	if r.Event != "request" {
+		return
+	}
+
+	method, _ := r.Data["method"].(string)
+	route := m.route(r)
+
+	status, _ := r.Data["status"].(int)
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	if status >= 500 {
+		m.errors.WithLabelValues(method, route).Inc()
+	}
+	if d, ok := r.Data["duration"].(time.Duration); ok {
+		observer := m.duration.WithLabelValues(method, route)
+		if traceID, ok := r.Data["trace_id"].(string); ok && exemplarTraceID(traceID) {
+			observer.(prometheus.ExemplarObserver).ObserveWithExemplar(d.Seconds(), prometheus.Labels{"trace_id": traceID})
+		} else {
+			observer.Observe(d.Seconds())
+		}
+	}
+}
+
+// exemplarTraceID reports whether a trace ID can be used as an exemplar
+// label. Trace IDs come from request headers, and ObserveWithExemplar
+// panics on labels over 128 runes or which aren't valid UTF-8, so only
+// hex or decimal IDs of a sensible length are used.
+func exemplarTraceID(traceID string) bool {
+	if len(traceID) == 0 || len(traceID) > 64 {
+		return false
+	}
+	for _, c := range traceID {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') && (c < 'A' || c > 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func route(r log.Record) string {
+	if route, ok := r.Data["route"].(string); ok && len(route) > 0 {
+		return route
+	}
+	return "other"
+}
+
+// Handler serves the metrics from a gatherer, defaulting to
+// prometheus.DefaultGatherer. OpenMetrics is enabled so that trace ID
+// exemplars on the duration histogram are exposed.
+func Handler(g prometheus.Gatherer) http.Handler {
+	if g == nil {
+		g = prometheus.DefaultGatherer
+	}
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{EnableOpenMetrics: true})
+}
@@ -0,0 +1,134 @@
+package red
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func request(method, route string, status int, d time.Duration) log.Record {
+	data := log.Data{"method": method, "status": status, "duration": d}
+	if len(route) > 0 {
+		data["route"] = route
+	}
+	return log.Record{Event: "request", Data: data}
+}
+
+func TestMetrics(t *testing.T) {
+	Convey("Request events should update the RED metrics", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		m.Observe(request("GET", "/users/{id}", 200, 10*time.Millisecond))
+		m.Observe(request("GET", "/users/{id}", 0, 20*time.Millisecond))
+		m.Observe(request("POST", "/users", 503, time.Second))
+		m.Observe(request("GET", "", 404, time.Millisecond))
+		m.Observe(log.Record{Event: "debug", Data: log.Data{"method": "GET"}})
+
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "/users/{id}", "200")), ShouldEqual, 2)
+		So(testutil.ToFloat64(m.requests.WithLabelValues("POST", "/users", "503")), ShouldEqual, 1)
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "other", "404")), ShouldEqual, 1)
+		So(testutil.ToFloat64(m.errors.WithLabelValues("POST", "/users")), ShouldEqual, 1)
+		So(testutil.CollectAndCount(m.errors), ShouldEqual, 1)
+		So(testutil.CollectAndCount(m.duration), ShouldEqual, 3)
+	})
+
+	Convey("Requests should be counted when their events are dropped", t, func() {
+		defer log.SetLevel(log.TRACE)
+
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+		log.RegisterRequestObserver(m.Observe)
+
+		var buf bytes.Buffer
+		log.SetOutput(&buf)
+		defer log.SetOutput(nil)
+		log.SetLevel(log.WARN)
+
+		h := log.HandlerWithOptions(log.HandlerOptions{SkipPaths: []string{"/healthcheck"}})(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if strings.HasPrefix(req.URL.Path, "/users/") {
+				log.SetRoute(req, "/users/{id}")
+			}
+		}))
+		for _, path := range []string{"/users/1", "/users/2", "/healthcheck"} {
+			req, err := http.NewRequest("GET", path, nil)
+			So(err, ShouldBeNil)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		So(buf.Len(), ShouldEqual, 0)
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "/users/{id}", "200")), ShouldEqual, 2)
+		So(testutil.ToFloat64(m.requests.WithLabelValues("GET", "other", "200")), ShouldEqual, 1)
+	})
+
+	Convey("New should fail if the metrics are already registered", t, func() {
+		reg := prometheus.NewRegistry()
+		_, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+		_, err = New(Config{Registerer: reg})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Handler should serve the gathered metrics", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg, Route: func(r log.Record) string { return "fixed" }})
+		So(err, ShouldBeNil)
+		m.Observe(request("GET", "/", 200, time.Millisecond))
+
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		So(err, ShouldBeNil)
+		w := httptest.NewRecorder()
+		Handler(reg).ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, 200)
+		So(strings.Contains(w.Body.String(), `http_requests_total{method="GET",route="fixed",status="200"} 1`), ShouldBeTrue)
+	})
+
+	Convey("Duration observations should include trace ID exemplars", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		r := request("GET", "/users/{id}", 200, 10*time.Millisecond)
+		r.Data["trace_id"] = "4bf92f3577b34da6a3ce929d0e0e4736"
+		m.Observe(r)
+
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		w := httptest.NewRecorder()
+		Handler(reg).ServeHTTP(w, req)
+
+		So(w.Code, ShouldEqual, 200)
+		So(w.Body.String(), ShouldContainSubstring, `# {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 0.01`)
+	})
+	Convey("Invalid trace IDs should be observed without an exemplar", t, func() {
+		reg := prometheus.NewRegistry()
+		m, err := New(Config{Registerer: reg})
+		So(err, ShouldBeNil)
+
+		for _, traceID := range []string{strings.Repeat("a", 200), "\xff\xfe", "not a trace id"} {
+			r := request("GET", "/users/{id}", 200, 10*time.Millisecond)
+			r.Data["trace_id"] = traceID
+			So(func() { m.Observe(r) }, ShouldNotPanic)
+		}
+		So(testutil.CollectAndCount(m.duration), ShouldEqual, 1)
+
+		req, err := http.NewRequest("GET", "/metrics", nil)
+		So(err, ShouldBeNil)
+		req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+		w := httptest.NewRecorder()
+		Handler(reg).ServeHTTP(w, req)
+		So(w.Body.String(), ShouldNotContainSubstring, "trace_id")
+	})
+}
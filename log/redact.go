@@ -0,0 +1,205 @@
+package log
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces the values of redacted fields
+const Redacted = "[REDACTED]"
+
+var defaultRedactKeys = []string{"password", "token", "authorization", "cookie", "set-cookie"}
+
+// RedactKeys lists the Data keys and header names whose values are masked.
+//
+// Deprecated: use SetRedactKeys. RedactKeys is read once, when the first
+// event is logged.
+var RedactKeys = copyStrings(defaultRedactKeys)
+
+// SetRedactKeys sets the Data keys and header names whose values are
+// masked, matched case insensitively at any depth. They default to
+// password, token, authorization, cookie and set-cookie.
+func SetRedactKeys(keys []string) {
+	keys = copyStrings(keys)
+	updateConfig(func(c *config) {
+		c.redactKeys = keys
+	})
+}
+
+// GetRedactKeys returns a copy of the keys whose values are masked
+func GetRedactKeys() []string {
+	return copyStrings(getConfig().redactKeys)
+}
+
+// Redactor scrubs sensitive values from event data before it's written
+type Redactor func(Data) Data
+
+var (
+	redactors      []Redactor
+	redactorsMutex sync.RWMutex
+)
+
+// RegisterRedactor adds a redactor which is called for every event after
+// the keys set by SetRedactKeys are masked
+func RegisterRedactor(r Redactor) {
+	redactorsMutex.Lock()
+	defer redactorsMutex.Unlock()
+	redactors = append(redactors, r)
+}
+
+// redact masks the redacted keys and applies registered redactors
+func redact(data Data) Data {
+	if data == nil {
+		return nil
+	}
+
+	if len(getConfig().redactKeys) > 0 {
+		m, _ := redactMap(data)
+		data = Data(m)
+	}
+
+	redactorsMutex.RLock()
+	defer redactorsMutex.RUnlock()
+	for _, r := range redactors {
+		data = r(data)
+	}
+	return data
+}
+
+func sensitive(key string) bool {
+	for _, k := range getConfig().redactKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactURL returns u as a string with any password and the values of
+// query parameters listed in params or the redacted keys masked, e.g. for
+// logging outbound requests
+func RedactURL(u *url.URL, params ...string) string {
+	if u == nil {
+		return ""
+	}
+	c := *u
+	if len(c.RawQuery) > 0 {
+		c.RawQuery = redactQuery(c.RawQuery, params)
+	}
+	return c.Redacted()
+}
+
+// redactQuery masks the values of query parameters listed in params or the
+// redacted keys, preserving the order of the query string
+func redactQuery(query string, params []string) string {
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		key, err := url.QueryUnescape(kv[0])
+		if err != nil {
+			key = kv[0]
+		}
+		if len(kv) < 2 || !(sensitive(key) || redactParam(key, params)) {
+			continue
+		}
+		pairs[i] = kv[0] + "=" + Redacted
+	}
+	return strings.Join(pairs, "&")
+}
+
+func redactParam(key string, params []string) bool {
+	for _, p := range params {
+		if strings.EqualFold(p, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactMap returns a copy of m with sensitive values masked, or m itself
+// if nothing is masked, so unaffected events aren't copied
+func redactMap(m map[string]interface{}) (map[string]interface{}, bool) {
+	var out map[string]interface{}
+	for k, v := range m {
+		r, changed := redactValue(k, v)
+		if !changed {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]interface{}, len(m))
+			for k2, v2 := range m {
+				out[k2] = v2
+			}
+		}
+		out[k] = r
+	}
+	if out == nil {
+		return m, false
+	}
+	return out, true
+}
+
+func redactValue(key string, v interface{}) (interface{}, bool) {
+	if sensitive(key) {
+		return Redacted, true
+	}
+
+	switch t := v.(type) {
+	case Data:
+		m, changed := redactMap(t)
+		return Data(m), changed
+	case map[string]interface{}:
+		return redactMap(t)
+	case http.Header:
+		return redactHeader(t)
+	case map[string][]string:
+		h, changed := redactHeader(http.Header(t))
+		return map[string][]string(h), changed
+	case map[string]string:
+		return redactStrings(t)
+	}
+	return v, false
+}
+
+// redactHeader returns a copy of h with sensitive header values masked
+func redactHeader(h http.Header) (http.Header, bool) {
+	var out http.Header
+	for k := range h {
+		if !sensitive(k) {
+			continue
+		}
+		if out == nil {
+			out = make(http.Header, len(h))
+			for k2, v2 := range h {
+				out[k2] = v2
+			}
+		}
+		out[k] = []string{Redacted}
+	}
+	if out == nil {
+		return h, false
+	}
+	return out, true
+}
+
+func redactStrings(m map[string]string) (map[string]string, bool) {
+	var out map[string]string
+	for k := range m {
+		if !sensitive(k) {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]string, len(m))
+			for k2, v2 := range m {
+				out[k2] = v2
+			}
+		}
+		out[k] = Redacted
+	}
+	if out == nil {
+		return m, false
+	}
+	return out, true
+}
@@ -0,0 +1,116 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+type requestDataKey struct{}
+
+// requestData holds fields added to the request event by inner handlers
+type requestData struct {
+	mutex sync.Mutex
+	data  Data
+	route string
+}
+
+func withRequestData(req *http.Request) (*http.Request, *requestData) {
+	d := &requestData{data: Data{}}
+	return req.WithContext(context.WithValue(req.Context(), requestDataKey{}, d)), d
+}
+
+func getRequestData(req *http.Request) (*requestData, bool) {
+	d, ok := req.Context().Value(requestDataKey{}).(*requestData)
+	return d, ok
+}
+
+// AddRequestData adds fields to the request event logged by log.Handler.
+// It has no effect if the request isn't wrapped by log.Handler.
+func AddRequestData(req *http.Request, data Data) {
+	d, ok := getRequestData(req)
+	if !ok {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for k, v := range data {
+		d.data[k] = v
+	}
+}
+
+// RequestData returns a copy of the fields added with AddRequestData, or nil
+// if the request isn't wrapped by log.Handler
+func RequestData(req *http.Request) Data {
+	d, ok := getRequestData(req)
+	if !ok {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	data := make(Data, len(d.data))
+	for k, v := range d.data {
+		data[k] = v
+	}
+	return data
+}
+
+// SetRoute records the route pattern a router matched for a request, e.g.
+// /users/{id}, which is added to the request event as "route" alongside
+// the raw "path". Aggregating by route rather than path keeps the number
+// of distinct values bounded. Nested routers can call it again to replace
+// a partial pattern. It has no effect if the request isn't wrapped by
+// log.Handler.
+func SetRoute(req *http.Request, pattern string) {
+	d, ok := getRequestData(req)
+	if !ok {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.route = pattern
+}
+
+// Route returns the route pattern recorded by SetRoute, or an empty string
+// if none has been recorded
+func Route(req *http.Request) string {
+	d, ok := getRequestData(req)
+	if !ok {
+		return ""
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.route
+}
+
+// withRoute adds the route recorded by SetRoute to data as "route", so
+// events logged for a request once its route is known can be aggregated
+// and configured by route
+func withRoute(req *http.Request, data Data) Data {
+	route := Route(req)
+	if len(route) == 0 {
+		return data
+	}
+	if data == nil {
+		data = Data{}
+	}
+	if _, ok := data["route"]; !ok {
+		data["route"] = route
+	}
+	return data
+}
+
+// copyTo adds the request data and route to data, without replacing
+// existing fields
+func (d *requestData) copyTo(data Data) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := data["route"]; !ok && len(d.route) > 0 {
+		data["route"] = d.route
+	}
+	for k, v := range d.data {
+		if _, ok := data[k]; !ok {
+			data[k] = v
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAddRequestData(t *testing.T) {
+	defer SetEvent(nil)
+
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventData = data
+	})
+
+	Convey("AddRequestData should add fields to the request event", t, func() {
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			AddRequestData(req, Data{"caller": "user", "status": 999})
+		}))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(eventData["caller"], ShouldEqual, "user")
+		So(eventData["status"], ShouldEqual, 0)
+	})
+
+	Convey("AddRequestData should do nothing outside of Handler", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		So(func() { AddRequestData(req, Data{"caller": "user"}) }, ShouldNotPanic)
+		So(RequestData(req), ShouldBeNil)
+	})
+
+	Convey("RequestData should return the fields added by inner handlers", t, func() {
+		var data Data
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			inner := req.WithContext(req.Context())
+			AddRequestData(inner, Data{"operation_id": "getDataset"})
+			data = RequestData(req)
+		}))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(data, ShouldResemble, Data{"operation_id": "getDataset"})
+	})
+
+	Convey("SetRoute should add the route to the request event alongside the path", t, func() {
+		var route string
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			SetRoute(req, "/users")
+			SetRoute(req, "/users/{id}")
+			route = Route(req)
+		}))
+
+		req, err := http.NewRequest("GET", "/users/123", nil)
+		So(err, ShouldBeNil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(route, ShouldEqual, "/users/{id}")
+		So(eventData["route"], ShouldEqual, "/users/{id}")
+		So(eventData["path"], ShouldEqual, "/users/123")
+	})
+
+	Convey("Events logged for a request should include the route once it's set", t, func() {
+		var before, after Data
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			InfoR(req, "before", nil)
+			before = eventData
+			SetRoute(req, "/users/{id}")
+			InfoR(req, "after", nil)
+			after = eventData
+		}))
+
+		req, err := http.NewRequest("GET", "/users/123", nil)
+		So(err, ShouldBeNil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(before, ShouldNotContainKey, "route")
+		So(after["route"], ShouldEqual, "/users/{id}")
+	})
+
+	Convey("Request events should only include a route if one was set", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		Handler(dummyHandler).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(eventData, ShouldNotContainKey, "route")
+	})
+
+	Convey("SetRoute and Route should do nothing outside of Handler", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		So(func() { SetRoute(req, "/") }, ShouldNotPanic)
+		So(Route(req), ShouldBeEmpty)
+	})
+}
@@ -0,0 +1,249 @@
+// Package rotate provides a file writer which rotates files when they reach
+// a maximum size, for use as the log output, e.g.
+//
+//	w, err := rotate.New(rotate.Config{Filename: "/var/log/app/app.log", MaxBackups: 5, Compress: true})
+//	log.SetOutput(w)
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSize is the size at which files are rotated if MaxSize isn't set
+const DefaultMaxSize = 100 * 1024 * 1024
+
+// backupTimeFormat is used in the names of rotated files
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Config configures rotation
+type Config struct {
+	Filename string
+	// MaxSize in bytes, defaults to DefaultMaxSize
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept, or all if zero
+	MaxBackups int
+	// MaxAge is how long rotated files are kept, or forever if zero
+	MaxAge time.Duration
+	// Compress gzips rotated files
+	Compress bool
+}
+
+// Writer writes to a file, rotating it when it reaches the maximum size
+type Writer struct {
+	cfg Config
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+
+	// rotated files are compressed and removed in the background
+	mill sync.WaitGroup
+}
+
+// New returns a writer for cfg.Filename, creating its directory if needed
+func New(cfg Config) (*Writer, error) {
+	if len(cfg.Filename) == 0 {
+		return nil, errors.New("rotate: no filename")
+	}
+	if cfg.MaxSize <= 0 {
+		cfg.MaxSize = DefaultMaxSize
+	}
+
+	w := &Writer{cfg: cfg}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.cfg.Filename), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(w.cfg.Filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	w.file = f
+	w.size = fi.Size()
+	return nil
+}
+
+// Write writes p to the file, first rotating it if p would take it over
+// the maximum size. Each write is kept whole in a single file.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if w.size > 0 && w.size+int64(len(p)) > w.cfg.MaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it with a timestamp and opens a
+// new file, e.g. in response to SIGHUP
+func (w *Writer) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.rotate()
+}
+
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+		w.file = nil
+	}
+
+	backup := w.backupName(time.Now())
+	if err := os.Rename(w.cfg.Filename, backup); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := w.open(); err != nil {
+		return err
+	}
+
+	w.mill.Add(1)
+	go func() {
+		defer w.mill.Done()
+		w.millBackups(backup)
+	}()
+	return nil
+}
+
+// backupName returns the name of a rotated file, e.g. app-2018-01-02T03-04-05.000.log
+func (w *Writer) backupName(t time.Time) string {
+	dir, prefix, ext := w.parts()
+	return filepath.Join(dir, prefix+t.UTC().Format(backupTimeFormat)+ext)
+}
+
+func (w *Writer) parts() (dir, prefix, ext string) {
+	dir = filepath.Dir(w.cfg.Filename)
+	base := filepath.Base(w.cfg.Filename)
+	ext = filepath.Ext(base)
+	prefix = strings.TrimSuffix(base, ext) + "-"
+	return
+}
+
+// millBackups compresses the newly rotated file, then removes old backups
+func (w *Writer) millBackups(backup string) {
+	if w.cfg.Compress {
+		if err := compress(backup); err == nil {
+			os.Remove(backup)
+		}
+	}
+
+	if w.cfg.MaxBackups <= 0 && w.cfg.MaxAge <= 0 {
+		return
+	}
+
+	backups := w.backups()
+	cutoff := time.Now().Add(-w.cfg.MaxAge)
+	for i, b := range backups {
+		if (w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups) || (w.cfg.MaxAge > 0 && b.t.Before(cutoff)) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+type backup struct {
+	path string
+	t    time.Time
+}
+
+// backups returns the rotated files, newest first
+func (w *Writer) backups() []backup {
+	dir, prefix, ext := w.parts()
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var backups []backup
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{filepath.Join(dir, e.Name()), t})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].t.After(backups[j].t)
+	})
+	return backups
+}
+
+func compress(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err == nil {
+		err = gz.Close()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+	}
+	return err
+}
+
+// Close closes the file, waiting for rotated files to be compressed
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mill.Wait()
+	return err
+}
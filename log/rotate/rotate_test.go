@@ -0,0 +1,122 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWriter(t *testing.T) {
+	Convey("New should require a filename", t, func() {
+		_, err := New(Config{})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Writer should create the file and its directory", t, func() {
+		dir, _ := ioutil.TempDir("", "rotate")
+		defer os.RemoveAll(dir)
+
+		w, err := New(Config{Filename: filepath.Join(dir, "logs", "app.log")})
+		So(err, ShouldBeNil)
+		w.Write([]byte("line 1\n"))
+		So(w.Close(), ShouldBeNil)
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "logs", "app.log"))
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "line 1\n")
+	})
+
+	Convey("Writer should rotate files when they reach the maximum size", t, func() {
+		dir, _ := ioutil.TempDir("", "rotate")
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "app.log")
+
+		w, err := New(Config{Filename: filename, MaxSize: 10})
+		So(err, ShouldBeNil)
+
+		w.Write([]byte("123456\n"))
+		w.Write([]byte("abc\n"))
+		So(w.Close(), ShouldBeNil)
+
+		b, _ := ioutil.ReadFile(filename)
+		So(string(b), ShouldEqual, "abc\n")
+
+		backups := w.backups()
+		So(backups, ShouldHaveLength, 1)
+		So(filepath.Base(backups[0].path), ShouldStartWith, "app-")
+		So(backups[0].path, ShouldEndWith, ".log")
+		b, _ = ioutil.ReadFile(backups[0].path)
+		So(string(b), ShouldEqual, "123456\n")
+	})
+
+	Convey("Writer should append to an existing file", t, func() {
+		dir, _ := ioutil.TempDir("", "rotate")
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "app.log")
+		ioutil.WriteFile(filename, []byte("123456\n"), 0644)
+
+		w, err := New(Config{Filename: filename, MaxSize: 10})
+		So(err, ShouldBeNil)
+		w.Write([]byte("abc\n"))
+		So(w.Close(), ShouldBeNil)
+		So(w.backups(), ShouldHaveLength, 1)
+	})
+
+	Convey("Writer should compress rotated files", t, func() {
+		dir, _ := ioutil.TempDir("", "rotate")
+		defer os.RemoveAll(dir)
+
+		w, err := New(Config{Filename: filepath.Join(dir, "app.log"), Compress: true})
+		So(err, ShouldBeNil)
+		w.Write([]byte("compressed\n"))
+		So(w.Rotate(), ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+
+		backups := w.backups()
+		So(backups, ShouldHaveLength, 1)
+		So(backups[0].path, ShouldEndWith, ".log.gz")
+
+		f, err := os.Open(backups[0].path)
+		So(err, ShouldBeNil)
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		So(err, ShouldBeNil)
+		b, _ := ioutil.ReadAll(gz)
+		So(string(b), ShouldEqual, "compressed\n")
+	})
+
+	Convey("Writer should remove backups beyond MaxBackups and MaxAge", t, func() {
+		dir, _ := ioutil.TempDir("", "rotate")
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "app.log")
+
+		w, err := New(Config{Filename: filename, MaxBackups: 2, MaxAge: 24 * time.Hour})
+		So(err, ShouldBeNil)
+
+		old := w.backupName(time.Now().Add(-48 * time.Hour))
+		ioutil.WriteFile(old, []byte("old\n"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "other.log"), []byte("other\n"), 0644)
+
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("line\n"))
+			So(w.Rotate(), ShouldBeNil)
+			w.mill.Wait()
+			time.Sleep(2 * time.Millisecond)
+		}
+		So(w.Close(), ShouldBeNil)
+
+		backups := w.backups()
+		So(backups, ShouldHaveLength, 2)
+		for _, b := range backups {
+			So(strings.Contains(b.path, filepath.Base(old)), ShouldBeFalse)
+		}
+		_, err = os.Stat(filepath.Join(dir, "other.log"))
+		So(err, ShouldBeNil)
+	})
+}
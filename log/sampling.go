@@ -0,0 +1,117 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// Sampling limits the number of events logged at a level. In each Tick the
+// first First events are logged, then every Thereafter'th event. The next
+// event logged after any are dropped has a "sampled" field with the number
+// dropped.
+type Sampling struct {
+	First      int
+	Thereafter int
+	// Tick defaults to one second
+	Tick time.Duration
+}
+
+type sampler struct {
+	Sampling
+
+	mutex   sync.Mutex
+	start   time.Time
+	count   int
+	dropped int
+}
+
+type routeLevelKey struct {
+	route string
+	level Level
+}
+
+var (
+	samplers      = map[Level]*sampler{}
+	routeSamplers = map[routeLevelKey]*sampler{}
+	samplersMutex sync.RWMutex
+)
+
+// SetSampling samples events at a level, e.g.
+//
+//	log.SetSampling(log.DEBUG, log.Sampling{First: 100, Thereafter: 10})
+//
+// logs the first 100 debug events each second, then 1 in 10. A zero
+// Sampling disables sampling for the level.
+func SetSampling(l Level, s Sampling) {
+	samplersMutex.Lock()
+	defer samplersMutex.Unlock()
+
+	if s == (Sampling{}) {
+		delete(samplers, l)
+		return
+	}
+	if s.Tick <= 0 {
+		s.Tick = time.Second
+	}
+	samplers[l] = &sampler{Sampling: s}
+}
+
+// SetRouteSampling samples events at a level for a route, keyed by the
+// pattern recorded with SetRoute, e.g.
+//
+//	log.SetRouteSampling("/healthcheck", log.INFO, log.Sampling{First: 1, Thereafter: 100})
+//
+// It applies to events with a "route" field, and replaces the sampling set
+// by SetSampling for the level. A zero Sampling removes it.
+func SetRouteSampling(route string, l Level, s Sampling) {
+	samplersMutex.Lock()
+	defer samplersMutex.Unlock()
+
+	key := routeLevelKey{route, l}
+	if s == (Sampling{}) {
+		delete(routeSamplers, key)
+		return
+	}
+	if s.Tick <= 0 {
+		s.Tick = time.Second
+	}
+	routeSamplers[key] = &sampler{Sampling: s}
+}
+
+// sample reports whether an event should be logged, and the number of
+// events dropped since the last event logged at its level, for its route
+// if it has one
+func sample(l Level, data Data) (bool, int) {
+	samplersMutex.RLock()
+	s, ok := samplers[l]
+	if route, isRoute := data["route"].(string); isRoute && len(routeSamplers) > 0 {
+		if rs, found := routeSamplers[routeLevelKey{route, l}]; found {
+			s, ok = rs, true
+		}
+	}
+	samplersMutex.RUnlock()
+	if !ok {
+		return true, 0
+	}
+	return s.sample(time.Now())
+}
+
+func (s *sampler) sample(t time.Time) (bool, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if t.Sub(s.start) >= s.Tick {
+		s.start = t
+		s.count = 0
+	}
+	s.count++
+
+	if s.count > s.First && (s.Thereafter <= 0 || (s.count-s.First)%s.Thereafter != 0) {
+		s.dropped++
+		return false, 0
+	}
+
+	dropped := s.dropped
+	s.dropped = 0
+	return true, dropped
+}
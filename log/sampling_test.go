@@ -0,0 +1,94 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSampling(t *testing.T) {
+	Convey("sampler should log the first events in each tick, then 1 in M", t, func() {
+		s := &sampler{Sampling: Sampling{First: 2, Thereafter: 3, Tick: time.Second}}
+		start := time.Now()
+
+		var kept []int
+		var dropped []int
+		for i := 1; i <= 8; i++ {
+			if keep, d := s.sample(start); keep {
+				kept = append(kept, i)
+				dropped = append(dropped, d)
+			}
+		}
+		So(kept, ShouldResemble, []int{1, 2, 5, 8})
+		So(dropped, ShouldResemble, []int{0, 0, 2, 2})
+
+		keep, d := s.sample(start.Add(time.Second))
+		So(keep, ShouldBeTrue)
+		So(d, ShouldEqual, 0)
+	})
+
+	Convey("sampler should drop all events after the first if Thereafter is zero", t, func() {
+		s := &sampler{Sampling: Sampling{First: 1, Tick: time.Second}}
+		start := time.Now()
+
+		keep, _ := s.sample(start)
+		So(keep, ShouldBeTrue)
+		for i := 0; i < 5; i++ {
+			keep, _ = s.sample(start)
+			So(keep, ShouldBeFalse)
+		}
+
+		keep, d := s.sample(start.Add(time.Second))
+		So(keep, ShouldBeTrue)
+		So(d, ShouldEqual, 5)
+	})
+
+	Convey("Sampled events should include the number dropped", t, func() {
+		defer SetSampling(DEBUG, Sampling{})
+		SetSampling(DEBUG, Sampling{First: 1, Thereafter: 2})
+		SetHumanReadable(false)
+
+		var buf bytes.Buffer
+		l := (&Logger{}).WithOutput(&buf)
+		for i := 0; i < 3; i++ {
+			l.Debug("test", nil)
+		}
+		l.Trace("not sampled", nil)
+
+		var events []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var m map[string]interface{}
+			So(json.Unmarshal([]byte(line), &m), ShouldBeNil)
+			events = append(events, m["data"].(map[string]interface{}))
+		}
+
+		So(events, ShouldHaveLength, 3)
+		So(events[0], ShouldNotContainKey, "sampled")
+		So(events[1]["sampled"], ShouldEqual, 1)
+		So(events[2]["message"], ShouldEqual, "not sampled")
+	})
+
+	Convey("Route sampling should replace the sampling for the level", t, func() {
+		defer SetSampling(INFO, Sampling{})
+		defer SetRouteSampling("/healthcheck", INFO, Sampling{})
+		SetSampling(INFO, Sampling{First: 100})
+		SetRouteSampling("/healthcheck", INFO, Sampling{First: 1})
+
+		keep, _ := sample(INFO, Data{"route": "/healthcheck"})
+		So(keep, ShouldBeTrue)
+		keep, _ = sample(INFO, Data{"route": "/healthcheck"})
+		So(keep, ShouldBeFalse)
+		keep, _ = sample(INFO, Data{"route": "/datasets"})
+		So(keep, ShouldBeTrue)
+		keep, _ = sample(WARN, Data{"route": "/healthcheck"})
+		So(keep, ShouldBeTrue)
+
+		SetRouteSampling("/healthcheck", INFO, Sampling{})
+		keep, _ = sample(INFO, Data{"route": "/healthcheck"})
+		So(keep, ShouldBeTrue)
+	})
+}
@@ -0,0 +1,225 @@
+package log
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// Record is a single log event, as passed to a Sink
+type Record struct {
+	Created   time.Time
+	Event     string
+	Namespace string
+	Context   string
+	Data      Data
+
+	// line is written to the output instead of the encoded event if set,
+	// e.g. an access log line
+	line []byte
+}
+
+// MarshalJSON encodes a Record in the same format used for stdout
+func (r Record) MarshalJSON() ([]byte, error) {
+	e := getEncodeState()
+	defer putEncodeState(e)
+	if err := e.appendLayout(r); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), e.buf...), nil
+}
+
+// layout returns the fields for a Record in the current output mode
+func (r Record) layout() map[string]interface{} {
+	switch getConfig().mode {
+	case GCPMode:
+		return r.gcpEnvelope()
+	case DatadogMode:
+		return r.datadogEnvelope()
+	}
+	return getFieldMap().apply(r.envelope())
+}
+
+// Level returns the level of a record, for sinks which map levels to their
+// own severities
+func (r Record) Level() Level {
+	return eventLevel(r.Event, r.Data)
+}
+
+func (r Record) envelope() map[string]interface{} {
+	m := map[string]interface{}{
+		"created":   formatTime(r.Created),
+		"event":     r.Event,
+		"namespace": r.Namespace,
+	}
+
+	if len(r.Context) > 0 {
+		m["context"] = r.Context
+	}
+
+	if r.Data != nil {
+		m["data"] = r.Data
+	}
+
+	return m
+}
+
+// Sink is an additional destination for log events
+type Sink interface {
+	Write(r Record) error
+	Close() error
+}
+
+// registeredSink is a sink and the minimum level of event it receives
+type registeredSink struct {
+	Sink
+	level Level
+}
+
+var (
+	sinks      []registeredSink
+	sinksMutex sync.RWMutex
+)
+
+// AddSink registers a sink which will receive every event
+func AddSink(s Sink) {
+	AddSinkLevel(s, TRACE)
+}
+
+// AddSinkLevel registers a sink which will only receive events at or above
+// the given level, e.g. to send only errors to an expensive destination
+func AddSinkLevel(s Sink, level Level) {
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+	sinks = append(sinks, registeredSink{s, level})
+}
+
+// Close writes any queued async and buffered events, then closes and
+// removes all registered sinks, returning the first error encountered
+func Close() error {
+	DisableAsync()
+	DisableBuffering()
+
+	sinksMutex.Lock()
+	defer sinksMutex.Unlock()
+
+	var err error
+	for _, s := range sinks {
+		if e := s.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	sinks = nil
+	return err
+}
+
+// Flusher is implemented by sinks which buffer events
+type Flusher interface {
+	Flush() error
+}
+
+// ErrFlushTimeout is returned by Flush if sinks don't finish flushing in time
+var ErrFlushTimeout = errors.New("log: timed out flushing sinks")
+
+// Flush writes any queued async and buffered events and then flushes all
+// buffered sinks concurrently, waiting at most timeout. It is intended for
+// use before a process exits, so that the events explaining why aren't lost.
+func Flush(timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	asyncMutex.RLock()
+	a := async
+	asyncMutex.RUnlock()
+	if a != nil {
+		select {
+		case <-a.drained():
+		case <-deadline.C:
+			return ErrFlushTimeout
+		}
+	}
+
+	outputErr := flushOutput()
+
+	sinksMutex.RLock()
+	var flushers []Flusher
+	for _, s := range sinks {
+		if f, ok := s.Sink.(Flusher); ok {
+			flushers = append(flushers, f)
+		}
+	}
+	sinksMutex.RUnlock()
+
+	errs := make(chan error, len(flushers))
+	for _, f := range flushers {
+		go func(f Flusher) {
+			errs <- f.Flush()
+		}(f)
+	}
+
+	err := outputErr
+	for range flushers {
+		select {
+		case e := <-errs:
+			if e != nil && err == nil {
+				err = e
+			}
+		case <-deadline.C:
+			return ErrFlushTimeout
+		}
+	}
+	return err
+}
+
+func writeSinks(r Record) {
+	sinksMutex.RLock()
+	defer sinksMutex.RUnlock()
+
+	level := r.Level()
+	for _, s := range sinks {
+		if level < s.level {
+			continue
+		}
+		if err := s.Write(r); err != nil {
+			printLogError(r.Context, err)
+		}
+	}
+}
+
+// WriterSink is a Sink which writes encoded events to an io.Writer, allowing
+// a different Encoder to be used for each destination
+type WriterSink struct {
+	w       io.Writer
+	encoder Encoder
+	mutex   sync.Mutex
+}
+
+// NewWriterSink returns a WriterSink using the given Encoder, or JSONEncoder if nil
+func NewWriterSink(w io.Writer, e Encoder) *WriterSink {
+	if e == nil {
+		e = JSONEncoder{}
+	}
+	return &WriterSink{w: w, encoder: e}
+}
+
+// Write encodes and writes an event
+func (s *WriterSink) Write(r Record) error {
+	b, err := s.encoder.Encode(r)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.w.Write(b)
+	return err
+}
+
+// Close closes the underlying writer if it is an io.Closer
+func (s *WriterSink) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testSink struct {
+	records  []Record
+	writeErr error
+	closed   bool
+}
+
+func (s *testSink) Write(r Record) error {
+	s.records = append(s.records, r)
+	return s.writeErr
+}
+
+func (s *testSink) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestRecord(t *testing.T) {
+	Convey("Record should marshal to the stdout JSON format", t, func() {
+		b, err := json.Marshal(Record{Event: "test", Namespace: "namespace", Data: Data{"foo": "bar"}})
+		So(err, ShouldBeNil)
+
+		var m map[string]interface{}
+		So(json.Unmarshal(b, &m), ShouldBeNil)
+		So(m, ShouldContainKey, "created")
+		So(m["event"], ShouldEqual, "test")
+		So(m["namespace"], ShouldEqual, "namespace")
+		So(m, ShouldNotContainKey, "context")
+		So(m["data"].(map[string]interface{})["foo"], ShouldEqual, "bar")
+	})
+}
+
+func TestSinks(t *testing.T) {
+	Convey("registered sinks should receive every event", t, func() {
+		SetNamespace("namespace")
+		SetHumanReadable(false)
+
+		s := &testSink{}
+		AddSink(s)
+		defer Close()
+
+		captureOutput(func() {
+			event("test", "context", Data{"foo": "bar"})
+		})
+
+		So(s.records, ShouldHaveLength, 1)
+		So(s.records[0].Event, ShouldEqual, "test")
+		So(s.records[0].Namespace, ShouldEqual, "namespace")
+		So(s.records[0].Context, ShouldEqual, "context")
+		So(s.records[0].Data["foo"], ShouldEqual, "bar")
+		So(s.records[0].Created.IsZero(), ShouldBeFalse)
+	})
+
+	Convey("human readable output shouldn't modify the data sinks receive", t, func() {
+		SetHumanReadable(true)
+		defer SetHumanReadable(false)
+
+		s := &testSink{}
+		AddSink(s)
+		defer Close()
+
+		stdout := captureOutput(func() {
+			Info("hello", nil)
+			ErrorC("", errors.New("failed"), nil)
+		})
+
+		So(stdout, ShouldContainSubstring, "info: hello")
+		So(s.records, ShouldHaveLength, 2)
+		So(s.records[0].Data["message"], ShouldEqual, "hello")
+		So(s.records[1].Data["message"], ShouldEqual, "failed")
+		So(s.records[1].Data, ShouldContainKey, "error")
+	})
+
+	Convey("sink errors should be logged as log_error events", t, func() {
+		SetNamespace("namespace")
+		SetHumanReadable(false)
+
+		AddSink(&testSink{writeErr: errors.New("sink error")})
+		defer Close()
+
+		stdout := captureOutput(func() {
+			event("test", "context", nil)
+		})
+
+		var lines []map[string]interface{}
+		dec := json.NewDecoder(strings.NewReader(stdout))
+		for dec.More() {
+			var m map[string]interface{}
+			So(dec.Decode(&m), ShouldBeNil)
+			lines = append(lines, m)
+		}
+
+		So(lines, ShouldHaveLength, 2)
+		So(lines[0]["event"], ShouldEqual, "log_error")
+		So(lines[0]["data"].(map[string]interface{})["error"], ShouldEqual, "sink error")
+		So(lines[1]["event"], ShouldEqual, "test")
+	})
+
+	Convey("sinks should only receive events at or above their level", t, func() {
+		SetNamespace("namespace")
+		SetHumanReadable(false)
+
+		all := &testSink{}
+		errorSink := &testSink{}
+		AddSink(all)
+		AddSinkLevel(errorSink, ERROR)
+		defer Close()
+
+		captureOutput(func() {
+			event("trace", "", nil)
+			event("request", "", nil)
+			event("error", "", nil)
+		})
+
+		So(all.records, ShouldHaveLength, 3)
+		So(errorSink.records, ShouldHaveLength, 1)
+		So(errorSink.records[0].Event, ShouldEqual, "error")
+	})
+
+	Convey("Close should close and remove all sinks", t, func() {
+		s := &testSink{}
+		AddSink(s)
+
+		So(Close(), ShouldBeNil)
+		So(s.closed, ShouldBeTrue)
+		So(sinks, ShouldBeEmpty)
+	})
+}
+
+func TestWriterSink(t *testing.T) {
+	Convey("WriterSink should write encoded events", t, func() {
+		var buf bytes.Buffer
+		s := NewWriterSink(&buf, testEncoder{})
+		So(s.Write(Record{Event: "test", Context: "context"}), ShouldBeNil)
+		So(buf.String(), ShouldEqual, "test|context\n")
+		So(s.Close(), ShouldBeNil)
+	})
+
+	Convey("WriterSink should default to JSON", t, func() {
+		var buf bytes.Buffer
+		s := NewWriterSink(&buf, nil)
+		So(s.Write(Record{Event: "test"}), ShouldBeNil)
+		So(buf.String(), ShouldStartWith, "{")
+		So(buf.String(), ShouldEndWith, "}\n")
+	})
+}
+
+type flushSink struct {
+	testSink
+	delay   time.Duration
+	flushed bool
+}
+
+func (s *flushSink) Flush() error {
+	time.Sleep(s.delay)
+	s.flushed = true
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	Convey("Flush should flush buffered sinks", t, func() {
+		s := &flushSink{}
+		AddSink(s)
+		AddSink(&testSink{})
+		defer Close()
+
+		So(Flush(time.Second), ShouldBeNil)
+		So(s.flushed, ShouldBeTrue)
+	})
+
+	Convey("Flush should give up after the timeout", t, func() {
+		AddSink(&flushSink{delay: time.Second})
+		defer Close()
+
+		So(Flush(10*time.Millisecond), ShouldEqual, ErrFlushTimeout)
+	})
+}
@@ -0,0 +1,108 @@
+package log
+
+import (
+	"errors"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+const defaultStackDepth = 10
+
+// StackDepth is the maximum number of frames included in the "stack" field
+// of error events.
+//
+// Deprecated: use SetStackDepth. StackDepth is read once, when the first
+// event is logged.
+var StackDepth = defaultStackDepth
+
+// SetStackDepth sets the maximum number of frames included in the "stack"
+// field of error events, which defaults to 10. Setting it to 0 disables
+// stack traces.
+func SetStackDepth(depth int) {
+	updateConfig(func(c *config) {
+		c.stackDepth = depth
+	})
+}
+
+// StackFrame is a single frame of a stack trace
+type StackFrame struct {
+	Function string `json:"function"`
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// logPackage is the import path of this package, including any vendor prefix
+var logPackage = strings.TrimSuffix(runtime.FuncForPC(reflect.ValueOf(normalize).Pointer()).Name(), ".normalize")
+
+func isLogFrame(f runtime.Frame) bool {
+	return strings.HasPrefix(f.Function, logPackage+".") && !strings.HasSuffix(f.File, "_test.go")
+}
+
+// callerFrames returns up to n frames from the current stack, skipping
+// frames inside the log package
+func callerFrames(n int) []runtime.Frame {
+	pc := make([]uintptr, n+32)
+	pc = pc[:runtime.Callers(2, pc)]
+
+	var frames []runtime.Frame
+	iter := runtime.CallersFrames(pc)
+	for len(frames) < n {
+		f, more := iter.Next()
+		if !isLogFrame(f) {
+			frames = append(frames, f)
+		}
+		if !more {
+			break
+		}
+	}
+	return frames
+}
+
+// errorStack returns the program counters recorded when an error was
+// created, for errors with a StackTrace method returning a slice of
+// program counters (as in github.com/pkg/errors). The innermost error with
+// a stack is used since it's closest to the origin of the failure.
+func errorStack(err error) []uintptr {
+	var pc []uintptr
+	for ; err != nil; err = errors.Unwrap(err) {
+		m := reflect.ValueOf(err).MethodByName("StackTrace")
+		if !m.IsValid() || m.Type().NumIn() != 0 || m.Type().NumOut() != 1 {
+			continue
+		}
+		out := m.Type().Out(0)
+		if out.Kind() != reflect.Slice || out.Elem().Kind() != reflect.Uintptr {
+			continue
+		}
+		v := m.Call(nil)[0]
+		pc = make([]uintptr, v.Len())
+		for i := range pc {
+			pc[i] = uintptr(v.Index(i).Uint())
+		}
+	}
+	return pc
+}
+
+// stack returns the stack trace for an error event, using the stack
+// recorded by the error if it has one, or the caller of the log function
+func stack(err error, depth int) []StackFrame {
+	var frames []runtime.Frame
+	if pc := errorStack(err); len(pc) > 0 {
+		iter := runtime.CallersFrames(pc)
+		for len(frames) < depth {
+			f, more := iter.Next()
+			frames = append(frames, f)
+			if !more {
+				break
+			}
+		}
+	} else {
+		frames = callerFrames(depth)
+	}
+
+	s := make([]StackFrame, 0, len(frames))
+	for _, f := range frames {
+		s = append(s, StackFrame{Function: f.Function, File: f.File, Line: f.Line})
+	}
+	return s
+}
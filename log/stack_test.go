@@ -0,0 +1,102 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// stackError records a stack when created, like github.com/pkg/errors
+type stackError struct {
+	msg string
+	pc  []uintptr
+}
+
+type stackFrame uintptr
+
+func newStackError(msg string) error {
+	pc := make([]uintptr, 8)
+	return &stackError{msg, pc[:runtime.Callers(2, pc)]}
+}
+
+func (e *stackError) Error() string { return e.msg }
+
+func (e *stackError) StackTrace() []stackFrame {
+	frames := make([]stackFrame, len(e.pc))
+	for i, pc := range e.pc {
+		frames[i] = stackFrame(pc)
+	}
+	return frames
+}
+
+func createError() error {
+	return newStackError("created")
+}
+
+func TestStack(t *testing.T) {
+	defer func() {
+		SetEvent(nil)
+		SetStackDepth(10)
+		SetOutput(nil)
+	}()
+
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventData = data
+	})
+
+	Convey("Error events should include the stack of the log call", t, func() {
+		Error(errors.New("test"), nil)
+		frames := eventData["stack"].([]StackFrame)
+		So(frames, ShouldNotBeEmpty)
+		So(len(frames), ShouldBeLessThanOrEqualTo, 10)
+		So(frames[0].Function, ShouldStartWith, "github.com/ONSdigital/go-ns/log.TestStack.func")
+		So(frames[0].File, ShouldEndWith, "stack_test.go")
+		So(frames[0].Line, ShouldBeGreaterThan, 0)
+	})
+
+	Convey("Error events should use the stack recorded by the error", t, func() {
+		Error(fmt.Errorf("wrapped: %w", createError()), nil)
+		frames := eventData["stack"].([]StackFrame)
+		So(frames[0].Function, ShouldEqual, "github.com/ONSdigital/go-ns/log.createError")
+	})
+
+	Convey("StackDepth should limit or disable stack traces", t, func() {
+		SetStackDepth(1)
+		Error(errors.New("test"), nil)
+		So(eventData["stack"], ShouldHaveLength, 1)
+
+		SetStackDepth(0)
+		Error(errors.New("test"), nil)
+		So(eventData, ShouldNotContainKey, "stack")
+	})
+
+	Convey("Stacks should be JSON arrays and indented in human readable output", t, func() {
+		SetEvent(nil)
+		SetStackDepth(2)
+		var buf bytes.Buffer
+		SetOutput(&buf)
+
+		SetHumanReadable(false)
+		Error(errors.New("test"), nil)
+		var m struct {
+			Data struct {
+				Stack []StackFrame `json:"stack"`
+			} `json:"data"`
+		}
+		So(json.Unmarshal(buf.Bytes(), &m), ShouldBeNil)
+		So(m.Data.Stack, ShouldHaveLength, 2)
+
+		buf.Reset()
+		SetHumanReadable(true)
+		Error(errors.New("test"), nil)
+		SetHumanReadable(false)
+		So(buf.String(), ShouldContainSubstring, "\n         /")
+		So(buf.String(), ShouldContainSubstring, "stack_test.go:")
+	})
+}
@@ -0,0 +1,213 @@
+// Package syslog provides a log.Sink which writes events to a local or
+// remote syslog server using the RFC 5424 format
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// syslog severities
+const (
+	sevCrit    = 2
+	sevErr     = 3
+	sevWarning = 4
+	sevInfo    = 6
+	sevDebug   = 7
+)
+
+// DefaultFacility is local0
+const DefaultFacility = 16
+
+// localSockets are the usual paths of the local syslog socket
+var localSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Config configures the syslog connection
+type Config struct {
+	// Network is "udp", "tcp" or "unix", or empty for the local syslog socket
+	Network string
+	// Address of the syslog server, e.g. "logs.example.com:514"
+	Address string
+	// Tag is the APP-NAME of each message, defaulting to the event namespace
+	Tag string
+	// Facility defaults to DefaultFacility
+	Facility int
+}
+
+// ConfigFromEnv reads a Config from SYSLOG_NETWORK, SYSLOG_ADDR, SYSLOG_TAG
+// and SYSLOG_FACILITY
+func ConfigFromEnv() Config {
+	c := Config{
+		Network: os.Getenv("SYSLOG_NETWORK"),
+		Address: os.Getenv("SYSLOG_ADDR"),
+		Tag:     os.Getenv("SYSLOG_TAG"),
+	}
+	if f, err := strconv.Atoi(os.Getenv("SYSLOG_FACILITY")); err == nil {
+		c.Facility = f
+	}
+	return c
+}
+
+// Sink writes log events to syslog, mapping the event name to a severity
+type Sink struct {
+	cfg      Config
+	hostname string
+
+	mutex sync.Mutex
+	conn  net.Conn
+}
+
+// New returns a Sink connected to the syslog server in cfg
+func New(cfg Config) (*Sink, error) {
+	if cfg.Facility == 0 {
+		cfg.Facility = DefaultFacility
+	}
+	if cfg.Facility < 0 || cfg.Facility > 23 {
+		return nil, fmt.Errorf("syslog: invalid facility %d", cfg.Facility)
+	}
+
+	hostname, _ := os.Hostname()
+	s := &Sink{cfg: cfg, hostname: hostname}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Sink) connect() error {
+	if len(s.cfg.Network) > 0 {
+		conn, err := net.DialTimeout(s.cfg.Network, s.cfg.Address, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		return nil
+	}
+
+	for _, path := range localSockets {
+		for _, network := range []string{"unixgram", "unix"} {
+			if conn, err := net.Dial(network, path); err == nil {
+				s.conn = conn
+				return nil
+			}
+		}
+	}
+	return errors.New("syslog: no local syslog socket found")
+}
+
+// stream reports whether messages need framing, as for TCP
+func (s *Sink) stream() bool {
+	switch s.conn.(type) {
+	case *net.UDPConn:
+		return false
+	case *net.UnixConn:
+		return s.conn.RemoteAddr().Network() == "unix"
+	}
+	return true
+}
+
+// Write sends a log event to syslog, reconnecting once if the write fails
+func (s *Sink) Write(r log.Record) error {
+	b, err := s.format(r)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn != nil {
+		if err = s.write(b); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+
+	if err := s.connect(); err != nil {
+		return err
+	}
+	return s.write(b)
+}
+
+func (s *Sink) write(b []byte) error {
+	if s.stream() {
+		// octet counting framing, from RFC 6587
+		b = append([]byte(strconv.Itoa(len(b))+" "), b...)
+	}
+	_, err := s.conn.Write(b)
+	return err
+}
+
+// format returns an RFC 5424 message with the JSON encoded event as its body
+func (s *Sink) format(r log.Record) ([]byte, error) {
+	body, err := r.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+
+	tag := s.cfg.Tag
+	if len(tag) == 0 {
+		tag = r.Namespace
+	}
+
+	header := fmt.Sprintf("<%d>1 %s %s %s %d %s - ",
+		s.cfg.Facility*8+severity(r.Level()),
+		r.Created.Format(time.RFC3339Nano),
+		nilValue(s.hostname),
+		nilValue(tag),
+		os.Getpid(),
+		nilValue(r.Event),
+	)
+	return append([]byte(header), body...), nil
+}
+
+// severity maps a level to a syslog severity. Debug events are logged as
+// informational, leaving the debug severity for trace events.
+func severity(l log.Level) int {
+	switch l {
+	case log.TRACE:
+		return sevDebug
+	case log.WARN:
+		return sevWarning
+	case log.ERROR:
+		return sevErr
+	case log.FATAL:
+		return sevCrit
+	}
+	return sevInfo
+}
+
+// nilValue returns a header field with non printable characters and spaces
+// replaced, or the RFC 5424 NILVALUE if it's empty
+func nilValue(s string) string {
+	if len(s) == 0 {
+		return "-"
+	}
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Close closes the connection to syslog
+func (s *Sink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,115 @@
+package syslog
+
+import (
+	"bufio"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testRecord = log.Record{
+	Created:   time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+	Event:     "error",
+	Namespace: "dp-api",
+	Context:   "request-1",
+	Data:      log.Data{"message": "test error"},
+}
+
+func TestSink(t *testing.T) {
+	Convey("Sink should send RFC 5424 messages over UDP", t, func() {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer pc.Close()
+
+		s, err := New(Config{Network: "udp", Address: pc.LocalAddr().String(), Tag: "test-app"})
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		So(s.Write(testRecord), ShouldBeNil)
+
+		buf := make([]byte, 4096)
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		So(err, ShouldBeNil)
+
+		msg := string(buf[:n])
+		So(msg, ShouldStartWith, "<131>1 2018-01-02T03:04:05Z ")
+		So(msg, ShouldContainSubstring, " test-app ")
+		So(msg, ShouldContainSubstring, " error - {")
+		So(msg, ShouldContainSubstring, `"message":"test error"`)
+	})
+
+	Convey("Sink should frame messages over TCP", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer l.Close()
+
+		received := make(chan string, 1)
+		go func() {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			length, _ := r.ReadString(' ')
+			received <- length
+		}()
+
+		s, err := New(Config{Network: "tcp", Address: l.Addr().String()})
+		So(err, ShouldBeNil)
+		defer s.Close()
+
+		So(s.Write(testRecord), ShouldBeNil)
+		b, _ := s.format(testRecord)
+
+		select {
+		case length := <-received:
+			So(strings.TrimSpace(length), ShouldEqual, strconv.Itoa(len(b)))
+		case <-time.After(time.Second):
+			So("no message received", ShouldBeEmpty)
+		}
+	})
+
+	Convey("New should return an error for an invalid facility or unreachable server", t, func() {
+		_, err := New(Config{Network: "udp", Address: "127.0.0.1:514", Facility: 24})
+		So(err, ShouldNotBeNil)
+
+		_, err = New(Config{Network: "tcp", Address: "127.0.0.1:1"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("severity should map levels to syslog severities", t, func() {
+		So(severity(log.FATAL), ShouldEqual, sevCrit)
+		So(severity(log.ERROR), ShouldEqual, sevErr)
+		So(severity(log.WARN), ShouldEqual, sevWarning)
+		So(severity(log.INFO), ShouldEqual, sevInfo)
+		So(severity(log.DEBUG), ShouldEqual, sevInfo)
+		So(severity(log.TRACE), ShouldEqual, sevDebug)
+	})
+
+	Convey("nilValue should sanitize header fields", t, func() {
+		So(nilValue(""), ShouldEqual, "-")
+		So(nilValue("my app"), ShouldEqual, "my_app")
+	})
+
+	Convey("ConfigFromEnv should read the syslog environment variables", t, func() {
+		defer func() {
+			for _, k := range []string{"SYSLOG_NETWORK", "SYSLOG_ADDR", "SYSLOG_TAG", "SYSLOG_FACILITY"} {
+				os.Unsetenv(k)
+			}
+		}()
+
+		os.Setenv("SYSLOG_NETWORK", "tcp")
+		os.Setenv("SYSLOG_ADDR", "logs:514")
+		os.Setenv("SYSLOG_TAG", "dp-api")
+		os.Setenv("SYSLOG_FACILITY", "1")
+		So(ConfigFromEnv(), ShouldResemble, Config{Network: "tcp", Address: "logs:514", Tag: "dp-api", Facility: 1})
+	})
+}
@@ -0,0 +1,124 @@
+package log
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mgutz/ansi"
+)
+
+// Style is the colour and attributes of events in human readable output.
+// Color is an ANSI colour name, with +h for high intensity, e.g. "red+h",
+// or a 256 colour number. An empty Color uses the terminal's default.
+type Style struct {
+	Color     string
+	Bold      bool
+	Underline bool
+}
+
+// codes returns the escape sequences written before and after an event
+func (s Style) codes() (start, reset string) {
+	if !s.Bold && !s.Underline {
+		if len(s.Color) == 0 {
+			return ansi.DefaultFG, ansi.DefaultFG
+		}
+		return ansi.ColorCode(s.Color), ansi.DefaultFG
+	}
+
+	color, attrs := s.Color, ""
+	if i := strings.Index(color, "+"); i >= 0 {
+		color, attrs = color[:i], color[i+1:]
+	}
+	if len(color) == 0 {
+		color = "default"
+	}
+	if s.Bold {
+		attrs += "b"
+	}
+	if s.Underline {
+		attrs += "u"
+	}
+	return ansi.ColorCode(color + "+" + attrs), ansi.Reset
+}
+
+// Theme sets the styles of events in human readable output. Events are
+// styled by name if they're in Events, then by level, otherwise they use
+// the terminal's default colour.
+type Theme struct {
+	Events map[string]Style
+	Levels map[Level]Style
+}
+
+// DarkTheme is the default theme, for terminals with a dark background
+var DarkTheme = Theme{
+	Events: map[string]Style{
+		"request": {Color: "cyan"},
+	},
+	Levels: map[Level]Style{
+		TRACE: {Color: "blue"},
+		DEBUG: {Color: "green"},
+		WARN:  {Color: "yellow"},
+		ERROR: {Color: "red+h"},
+	},
+}
+
+// LightTheme uses darker colours for terminals with a light background
+var LightTheme = Theme{
+	Events: map[string]Style{
+		"request": {Color: "magenta"},
+	},
+	Levels: map[Level]Style{
+		TRACE: {Color: "blue"},
+		DEBUG: {Color: "green"},
+		WARN:  {Color: "130"},
+		ERROR: {Color: "red", Bold: true},
+	},
+}
+
+var (
+	theme      *Theme
+	themeMutex sync.RWMutex
+)
+
+// SetTheme replaces the theme used for human readable output, which
+// defaults to DarkTheme, or LightTheme if LOG_THEME=light
+func SetTheme(t Theme) {
+	c := Theme{
+		Events: make(map[string]Style, len(t.Events)),
+		Levels: make(map[Level]Style, len(t.Levels)),
+	}
+	for k, v := range t.Events {
+		c.Events[k] = v
+	}
+	for k, v := range t.Levels {
+		c.Levels[k] = v
+	}
+
+	themeMutex.Lock()
+	defer themeMutex.Unlock()
+	theme = &c
+}
+
+func configureTheme() {
+	if strings.EqualFold(os.Getenv("LOG_THEME"), "light") {
+		SetTheme(LightTheme)
+		return
+	}
+	SetTheme(DarkTheme)
+}
+
+// styleOf returns the style for an event in the current theme
+func styleOf(event string) Style {
+	themeMutex.RLock()
+	t := theme
+	themeMutex.RUnlock()
+	if t == nil {
+		t = &DarkTheme
+	}
+
+	if s, ok := t.Events[event]; ok {
+		return s
+	}
+	return t.Levels[LevelOf(event)]
+}
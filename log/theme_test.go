@@ -0,0 +1,75 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mgutz/ansi"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTheme(t *testing.T) {
+	defer SetTheme(DarkTheme)
+
+	Convey("styleOf should match events by name, then level", t, func() {
+		SetTheme(Theme{
+			Events: map[string]Style{"request": {Color: "cyan"}, "error": {Color: "magenta"}},
+			Levels: map[Level]Style{ERROR: {Color: "red"}, DEBUG: {Color: "green"}},
+		})
+
+		So(styleOf("request"), ShouldResemble, Style{Color: "cyan"})
+		So(styleOf("error"), ShouldResemble, Style{Color: "magenta"})
+		So(styleOf("debug"), ShouldResemble, Style{Color: "green"})
+		So(styleOf("other"), ShouldResemble, Style{})
+	})
+
+	Convey("SetTheme should copy the theme", t, func() {
+		th := Theme{Levels: map[Level]Style{DEBUG: {Color: "green"}}}
+		SetTheme(th)
+		th.Levels[DEBUG] = Style{Color: "red"}
+
+		So(styleOf("debug"), ShouldResemble, Style{Color: "green"})
+	})
+
+	Convey("codes should include attributes and reset them", t, func() {
+		start, reset := Style{}.codes()
+		So(start, ShouldEqual, ansi.DefaultFG)
+		So(reset, ShouldEqual, ansi.DefaultFG)
+
+		start, reset = Style{Color: "red+h"}.codes()
+		So(start, ShouldEqual, ansi.LightRed)
+		So(reset, ShouldEqual, ansi.DefaultFG)
+
+		start, reset = Style{Color: "red+h", Bold: true, Underline: true}.codes()
+		So(start, ShouldEqual, ansi.ColorCode("red+hbu"))
+		So(reset, ShouldEqual, ansi.Reset)
+
+		start, _ = Style{Bold: true}.codes()
+		So(start, ShouldEqual, ansi.ColorCode("default+b"))
+	})
+
+	Convey("human readable output should use the theme", t, func() {
+		Color(true)
+		defer atomic.StoreInt32(&colorMode, colorAuto)
+		SetTheme(Theme{Events: map[string]Style{"audit": {Color: "yellow", Underline: true}}})
+
+		var buf bytes.Buffer
+		fprintHumanReadable(&buf, "audit", "", nil, map[string]interface{}{"created": "now"})
+		So(buf.String(), ShouldEqual, ansi.ColorCode("yellow+u")+"now audit"+ansi.Reset+"\n")
+	})
+
+	Convey("configureTheme should select the theme from LOG_THEME", t, func() {
+		defer os.Unsetenv("LOG_THEME")
+
+		os.Setenv("LOG_THEME", "light")
+		configureTheme()
+		So(styleOf("error"), ShouldResemble, LightTheme.Levels[ERROR])
+
+		os.Setenv("LOG_THEME", "")
+		configureTheme()
+		So(styleOf("error"), ShouldResemble, DarkTheme.Levels[ERROR])
+		So(styleOf("warn"), ShouldResemble, DarkTheme.Levels[WARN])
+	})
+}
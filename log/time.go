@@ -0,0 +1,87 @@
+package log
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp formats for the "created" field
+const (
+	TimeRFC3339Nano = time.RFC3339Nano
+	TimeRFC3339     = time.RFC3339
+	// TimeEpochMillis writes the number of milliseconds since the Unix epoch
+	TimeEpochMillis = "epoch_millis"
+)
+
+// TimeFormat is the format of the "created" field.
+//
+// Deprecated: use SetTimeFormat. TimeFormat is read once, when the first
+// event is logged.
+var TimeFormat = TimeRFC3339Nano
+
+// ForceUTC converts event times to UTC.
+//
+// Deprecated: use SetForceUTC. ForceUTC is read once, when the first event
+// is logged.
+var ForceUTC bool
+
+// SetTimeFormat sets the format of the "created" field, which is either one
+// of the Time constants or a time.Format layout. It defaults to RFC3339Nano,
+// or the LOG_TIME_FORMAT environment variable (rfc3339nano, rfc3339 or
+// epoch_millis) if set.
+func SetTimeFormat(format string) {
+	updateConfig(func(c *config) {
+		c.timeFormat = format
+	})
+}
+
+// GetTimeFormat returns the format of the "created" field
+func GetTimeFormat() string {
+	return getConfig().timeFormat
+}
+
+// SetForceUTC converts event times to UTC. It defaults to the LOG_TIME_UTC
+// environment variable.
+func SetForceUTC(forceUTC bool) {
+	updateConfig(func(c *config) {
+		c.forceUTC = forceUTC
+	})
+}
+
+// GetForceUTC reports whether event times are converted to UTC
+func GetForceUTC() bool {
+	return getConfig().forceUTC
+}
+
+func configureTime() {
+	format := TimeRFC3339Nano
+	switch strings.ToLower(os.Getenv("LOG_TIME_FORMAT")) {
+	case "rfc3339":
+		format = TimeRFC3339
+	case "epoch_millis":
+		format = TimeEpochMillis
+	}
+	SetTimeFormat(format)
+
+	forceUTC, _ := strconv.ParseBool(os.Getenv("LOG_TIME_UTC"))
+	SetForceUTC(forceUTC)
+}
+
+// now returns the time of an event
+func now() time.Time {
+	if getConfig().forceUTC {
+		return time.Now().UTC()
+	}
+	return time.Now()
+}
+
+// formatTime formats the time of an event using the configured format
+func formatTime(t time.Time) interface{} {
+	format := getConfig().timeFormat
+	if format == TimeEpochMillis {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+	return t.Format(format)
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimeFormat(t *testing.T) {
+	defer func() {
+		os.Unsetenv("LOG_TIME_FORMAT")
+		os.Unsetenv("LOG_TIME_UTC")
+		configureTime()
+	}()
+
+	created := time.Date(2018, 1, 2, 3, 4, 5, 6000000, time.FixedZone("BST", 3600))
+
+	Convey("configureTime should read LOG_TIME_FORMAT and LOG_TIME_UTC", t, func() {
+		configureTime()
+		So(GetTimeFormat(), ShouldEqual, TimeRFC3339Nano)
+		So(GetForceUTC(), ShouldBeFalse)
+
+		os.Setenv("LOG_TIME_FORMAT", "RFC3339")
+		os.Setenv("LOG_TIME_UTC", "true")
+		configureTime()
+		So(GetTimeFormat(), ShouldEqual, TimeRFC3339)
+		So(GetForceUTC(), ShouldBeTrue)
+
+		os.Setenv("LOG_TIME_FORMAT", "epoch_millis")
+		configureTime()
+		So(GetTimeFormat(), ShouldEqual, TimeEpochMillis)
+	})
+
+	Convey("formatTime should use TimeFormat", t, func() {
+		SetTimeFormat(TimeRFC3339Nano)
+		So(formatTime(created), ShouldEqual, "2018-01-02T03:04:05.006+01:00")
+
+		SetTimeFormat(TimeRFC3339)
+		So(formatTime(created), ShouldEqual, "2018-01-02T03:04:05+01:00")
+
+		SetTimeFormat(TimeEpochMillis)
+		So(formatTime(created), ShouldEqual, int64(1514858645006))
+
+		SetTimeFormat("2006-01-02")
+		So(formatTime(created), ShouldEqual, "2018-01-02")
+	})
+
+	Convey("ForceUTC should convert event times to UTC", t, func() {
+		SetForceUTC(true)
+		So(now().Location(), ShouldEqual, time.UTC)
+		SetForceUTC(false)
+	})
+
+	Convey("The created field should be formatted in JSON and human readable output", t, func() {
+		SetTimeFormat(TimeEpochMillis)
+		r := Record{Created: created, Event: "test"}
+
+		b, err := json.Marshal(r)
+		So(err, ShouldBeNil)
+		var m map[string]interface{}
+		So(json.Unmarshal(b, &m), ShouldBeNil)
+		So(m["created"], ShouldEqual, 1514858645006)
+
+		var buf bytes.Buffer
+		fprintHumanReadable(&buf, r.Event, r.Context, r.Data, r.envelope())
+		So(buf.String(), ShouldStartWith, "1514858645006 test")
+	})
+}
@@ -0,0 +1,101 @@
+package log
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type timingsKey struct{}
+
+// timings accumulates the time spent in each named middleware for a request
+type timings struct {
+	mutex sync.Mutex
+	d     map[string]time.Duration
+}
+
+func (t *timings) add(name string, d time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.d[name] += d
+}
+
+func (t *timings) breakdown() map[string]time.Duration {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.d) == 0 {
+		return nil
+	}
+	m := make(map[string]time.Duration, len(t.d))
+	for k, v := range t.d {
+		m[k] = v
+	}
+	return m
+}
+
+func withTimings(req *http.Request) (*http.Request, *timings) {
+	t := &timings{d: map[string]time.Duration{}}
+	return req.WithContext(context.WithValue(req.Context(), timingsKey{}, t)), t
+}
+
+func timingsFrom(req *http.Request) *timings {
+	t, _ := req.Context().Value(timingsKey{}).(*timings)
+	return t
+}
+
+// frame records the time spent in handlers nested inside a timed
+// middleware. nested is accessed atomically, since middleware such as
+// timeout calls the next handler in another goroutine.
+type frame struct {
+	nested int64
+}
+
+type frameKey struct {
+	name string
+}
+
+// Timed wraps a named middleware so the time spent in it, excluding the
+// handlers it calls, is included in the "timings" breakdown of the request
+// event. Timed middleware must be inside log.Handler.
+func Timed(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		key := &frameKey{name}
+
+		wrapped := mw(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			f, _ := req.Context().Value(key).(*frame)
+
+			s := time.Now()
+			next.ServeHTTP(w, req)
+			if f != nil {
+				atomic.AddInt64(&f.nested, int64(time.Since(s)))
+			}
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			t := timingsFrom(req)
+			if t == nil {
+				wrapped.ServeHTTP(w, req)
+				return
+			}
+
+			f := &frame{}
+			s := time.Now()
+			wrapped.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), key, f)))
+			t.add(name, time.Since(s)-time.Duration(atomic.LoadInt64(&f.nested)))
+		})
+	}
+}
+
+// TimedHandler wraps the final handler so its duration is included in the
+// "timings" breakdown of the request event
+func TimedHandler(name string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s := time.Now()
+		h.ServeHTTP(w, req)
+		if t := timingsFrom(req); t != nil {
+			t.add(name, time.Since(s))
+		}
+	})
+}
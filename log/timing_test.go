@@ -0,0 +1,91 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func sleepMiddleware(d time.Duration) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(d)
+			h.ServeHTTP(w, req)
+		})
+	}
+}
+
+func TestTimed(t *testing.T) {
+	defer SetEvent(nil)
+
+	var eventData Data
+	SetEvent(func(name string, context string, data Data) {
+		eventData = data
+	})
+
+	Convey("Handler should include a timing breakdown for timed middleware", t, func() {
+		final := TimedHandler("handler", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+		}))
+		auth := Timed("auth", sleepMiddleware(10*time.Millisecond))
+		router := Timed("router", sleepMiddleware(0))
+
+		wrapped := Handler(auth(router(final)))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		wrapped.ServeHTTP(httptest.NewRecorder(), req)
+
+		So(eventData, ShouldContainKey, "timings")
+		breakdown := eventData["timings"].(map[string]time.Duration)
+		So(breakdown, ShouldContainKey, "auth")
+		So(breakdown, ShouldContainKey, "router")
+		So(breakdown, ShouldContainKey, "handler")
+		So(breakdown["auth"], ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+		So(breakdown["router"], ShouldBeLessThan, breakdown["auth"])
+		So(breakdown["handler"], ShouldBeGreaterThanOrEqualTo, 20*time.Millisecond)
+	})
+
+	Convey("Handler should omit timings if nothing is timed", t, func() {
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		Handler(dummyHandler).ServeHTTP(httptest.NewRecorder(), req)
+		So(eventData, ShouldNotContainKey, "timings")
+	})
+
+	Convey("Timed should allow middleware to call the next handler in another goroutine", t, func() {
+		done := make(chan struct{})
+		async := Timed("async", func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				// e.g. a timeout which returns before the handler does
+				go h.ServeHTTP(w, req)
+			})
+		})
+		wrapped := Handler(async(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			time.Sleep(time.Millisecond)
+			close(done)
+		})))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		wrapped.ServeHTTP(httptest.NewRecorder(), req)
+		<-done
+
+		So(eventData, ShouldContainKey, "timings")
+	})
+
+	Convey("Timed middleware should work outside of Handler", t, func() {
+		called := false
+		wrapped := Timed("auth", sleepMiddleware(0))(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			called = true
+		}))
+
+		req, err := http.NewRequest("GET", "/", nil)
+		So(err, ShouldBeNil)
+		wrapped.ServeHTTP(httptest.NewRecorder(), req)
+		So(called, ShouldBeTrue)
+	})
+}
@@ -0,0 +1,69 @@
+package log
+
+import (
+	"net/http"
+	"strings"
+)
+
+// traceparentContext returns the trace and parent span IDs from a W3C
+// traceparent header (https://www.w3.org/TR/trace-context/), e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func traceparentContext(req *http.Request) (traceID, spanID string) {
+	parts := strings.Split(strings.TrimSpace(req.Header.Get("traceparent")), "-")
+	if len(parts) < 4 {
+		return "", ""
+	}
+
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	// later versions may append fields, but version 00 must have exactly four
+	if !isHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", ""
+	}
+	if !isHex(traceID, 32) || !isHex(spanID, 16) || !isHex(flags, 2) {
+		return "", ""
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(spanID, "0") == "" {
+		return "", ""
+	}
+	return traceID, spanID
+}
+
+// tracestate returns the W3C tracestate header, which is only meaningful
+// alongside a valid traceparent
+func tracestate(req *http.Request) string {
+	if traceID, _ := traceparentContext(req); len(traceID) == 0 {
+		return ""
+	}
+	return strings.Join(req.Header["Tracestate"], ",")
+}
+
+// isHex reports whether s is n lower case hex digits
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// withTraceFields adds the trace and span IDs from a request to data
+func withTraceFields(req *http.Request, data Data) Data {
+	traceID, spanID := traceContext(req)
+	if len(traceID) == 0 {
+		return data
+	}
+	if data == nil {
+		data = Data{}
+	}
+	if _, ok := data["trace_id"]; !ok {
+		data["trace_id"] = traceID
+		if len(spanID) > 0 {
+			data["span_id"] = spanID
+		}
+	}
+	return data
+}
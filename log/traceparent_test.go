@@ -0,0 +1,107 @@
+package log
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestTraceparent(t *testing.T) {
+	Convey("traceparentContext should parse a valid traceparent header", t, func() {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("traceparent", testTraceparent)
+		traceID, spanID := traceparentContext(req)
+		So(traceID, ShouldEqual, "4bf92f3577b34da6a3ce929d0e0e4736")
+		So(spanID, ShouldEqual, "00f067aa0ba902b7")
+	})
+
+	Convey("traceparentContext should allow extra fields for later versions", t, func() {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("traceparent", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra")
+		traceID, _ := traceparentContext(req)
+		So(traceID, ShouldEqual, "4bf92f3577b34da6a3ce929d0e0e4736")
+	})
+
+	Convey("traceparentContext should reject invalid headers", t, func() {
+		for _, header := range []string{
+			"",
+			"garbage",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		} {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("traceparent", header)
+			traceID, spanID := traceparentContext(req)
+			So(traceID, ShouldBeEmpty)
+			So(spanID, ShouldBeEmpty)
+		}
+	})
+
+	Convey("Context should use X-Request-Id even with a traceparent header", t, func() {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("traceparent", testTraceparent)
+		So(Context(req), ShouldBeEmpty)
+
+		req.Header.Set("X-Request-Id", "request-id")
+		So(Context(req), ShouldEqual, "request-id")
+		So(RequestID(withRequestContext(req).Context()), ShouldEqual, "request-id")
+	})
+}
+
+func TestTraceparentEvents(t *testing.T) {
+	defer SetEvent(nil)
+
+	var events []Data
+	SetEvent(func(name string, context string, data Data) {
+		events = append(events, data)
+	})
+
+	Convey("Events logged with a traceparent request should include trace fields", t, func() {
+		events = nil
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("traceparent", testTraceparent)
+		req.Header.Set("tracestate", "vendor=value")
+
+		Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			DebugR(req, "handling", nil)
+		})).ServeHTTP(httptest.NewRecorder(), req)
+
+		So(events, ShouldHaveLength, 2)
+		for _, data := range events {
+			So(data["trace_id"], ShouldEqual, "4bf92f3577b34da6a3ce929d0e0e4736")
+			So(data["span_id"], ShouldEqual, "00f067aa0ba902b7")
+		}
+		So(events[1]["tracestate"], ShouldEqual, "vendor=value")
+	})
+
+	Convey("Explicit trace fields should not be overwritten", t, func() {
+		events = nil
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("traceparent", testTraceparent)
+		ErrorR(req, errors.New("failed"), Data{"trace_id": "explicit"})
+
+		So(events, ShouldHaveLength, 1)
+		So(events[0]["trace_id"], ShouldEqual, "explicit")
+	})
+
+	Convey("Events without trace headers should not include trace fields", t, func() {
+		events = nil
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		TraceR(req, "handling", nil)
+
+		So(events, ShouldHaveLength, 1)
+		So(events[0], ShouldNotContainKey, "trace_id")
+	})
+}
@@ -0,0 +1,283 @@
+// Package mongo creates MongoDB clients with sane timeouts, reports their
+// health and disconnects them once in-flight operations have completed.
+//
+// It uses the official driver, which supports MongoDB 3.6 and later.
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/healthcheck"
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/ONSdigital/go-ns/vault"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// DefaultDatabase is used when the URI doesn't name a database
+const DefaultDatabase = "test"
+
+// Timeouts applied to new clients
+var (
+	DialTimeout   = 5 * time.Second
+	SocketTimeout = 30 * time.Second
+)
+
+// ErrClosed is returned for operations after Close has been called
+var ErrClosed = errors.New("mongo: client closed")
+
+// client is the subset of *mongo.Client used by Client
+type client interface {
+	Database(name string, opts ...*options.DatabaseOptions) *mongo.Database
+	Ping(ctx context.Context, rp *readpref.ReadPref) error
+	Disconnect(ctx context.Context) error
+}
+
+// Client holds a MongoDB client
+type Client struct {
+	database string
+	hosts    []string
+	watcher  *vault.Watcher
+
+	mutex   sync.Mutex
+	client  client
+	active  int
+	closing bool
+	idle    chan struct{}
+}
+
+// Dial connects to MongoDB, e.g. "mongodb://localhost:27017/datasets"
+func Dial(uri string) (*Client, error) {
+	cs, err := connstring.ParseAndValidate(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	mc, err := connect(uri, cs.Hosts, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(mc, database(cs), cs.Hosts), nil
+}
+
+// DialWithVault connects to MongoDB with the "username" and "password"
+// fields of a Vault secret, e.g. a database secrets engine role at
+// database/creds/datasets. The secret is watched, and when it's rotated a
+// new connection is made with the new credentials. Operations in flight on
+// the old connection have SocketTimeout to complete before it's closed.
+func DialWithVault(uri string, v *vault.Client, path string) (*Client, error) {
+	cs, err := connstring.ParseAndValidate(uri)
+	if err != nil {
+		return nil, err
+	}
+	c := newClient(nil, database(cs), cs.Hosts)
+
+	c.watcher, err = v.Watch(path, 0, func(s *vault.Secret) {
+		mc, err := connect(uri, cs.Hosts, s)
+		if err != nil {
+			// the old credentials are kept until they expire
+			return
+		}
+		c.rotate(mc)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mc, err := connect(uri, cs.Hosts, c.watcher.Secret())
+	if err != nil {
+		c.watcher.Close()
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	if c.client == nil {
+		c.client = mc
+		mc = nil
+	}
+	c.mutex.Unlock()
+	if mc != nil {
+		// already rotated while connecting
+		mc.Disconnect(context.Background())
+	}
+
+	return c, nil
+}
+
+func database(cs *connstring.ConnString) string {
+	if len(cs.Database) == 0 {
+		return DefaultDatabase
+	}
+	return cs.Database
+}
+
+// connect connects to MongoDB, with the credentials from a Vault secret if
+// it's not nil
+func connect(uri string, hosts []string, secret *vault.Secret) (*mongo.Client, error) {
+	opts := options.Client().
+		ApplyURI(uri).
+		SetConnectTimeout(DialTimeout).
+		SetServerSelectionTimeout(DialTimeout).
+		SetSocketTimeout(SocketTimeout)
+
+	if secret != nil {
+		// keep the auth source and mechanism from the URI
+		var auth options.Credential
+		if opts.Auth != nil {
+			auth = *opts.Auth
+		}
+		auth.Username = secret.String("username")
+		auth.Password = secret.String("password")
+		auth.PasswordSet = true
+		opts.SetAuth(auth)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), DialTimeout)
+	defer cancel()
+
+	mc, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		log.Error(err, log.Data{"hosts": hosts})
+		return nil, err
+	}
+	// Connect doesn't wait for a server, so ping to fail fast like a dial
+	if err = mc.Ping(ctx, readpref.Primary()); err != nil {
+		log.Error(err, log.Data{"hosts": hosts})
+		mc.Disconnect(context.Background())
+		return nil, err
+	}
+
+	log.Debug("connected to mongo", log.Data{"hosts": hosts})
+	return mc, nil
+}
+
+func newClient(mc client, database string, hosts []string) *Client {
+	return &Client{client: mc, database: database, hosts: hosts}
+}
+
+// rotate replaces the client with one using rotated credentials, closing
+// the old one once its operations have completed or SocketTimeout has
+// elapsed
+func (c *Client) rotate(mc client) {
+	c.mutex.Lock()
+	if c.closing {
+		c.mutex.Unlock()
+		mc.Disconnect(context.Background())
+		return
+	}
+	old := c.client
+	c.client = mc
+	c.mutex.Unlock()
+
+	log.Info("rotated mongo credentials", log.Data{"hosts": c.hosts})
+
+	if old != nil {
+		go func() {
+			// the driver waits for connections in use until the deadline
+			ctx, cancel := context.WithTimeout(context.Background(), SocketTimeout)
+			defer cancel()
+			if err := old.Disconnect(ctx); err != nil {
+				log.Error(err, log.Data{"hosts": c.hosts})
+			}
+		}()
+	}
+}
+
+func (c *Client) current() client {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.client
+}
+
+// Do calls fn with the database named in the URI. Close waits for calls to
+// Do to complete.
+func (c *Client) Do(fn func(db *mongo.Database) error) error {
+	if !c.begin() {
+		return ErrClosed
+	}
+	defer c.end()
+
+	return fn(c.current().Database(c.database))
+}
+
+func (c *Client) begin() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closing {
+		return false
+	}
+	c.active++
+	return true
+}
+
+func (c *Client) end() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.active--
+	if c.active == 0 && c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+// Check pings MongoDB, implementing healthcheck.Checker. It's critical once
+// the client is closing, or if ctx is done before the ping completes.
+func (c *Client) Check(ctx context.Context) (healthcheck.Status, string) {
+	if !c.begin() {
+		return healthcheck.StatusCritical, ErrClosed.Error()
+	}
+	defer c.end()
+
+	if err := c.current().Ping(ctx, readpref.Primary()); err != nil {
+		return healthcheck.StatusCritical, err.Error()
+	}
+	return healthcheck.StatusOK, "mongo is ok"
+}
+
+// Close waits for in-flight operations to complete, or for ctx to be done,
+// then disconnects the client
+func (c *Client) Close(ctx context.Context) error {
+	c.mutex.Lock()
+	if c.closing {
+		c.mutex.Unlock()
+		return ErrClosed
+	}
+	c.closing = true
+	var idle chan struct{}
+	if c.active > 0 {
+		idle = make(chan struct{})
+		c.idle = idle
+	}
+	active := c.active
+	c.mutex.Unlock()
+
+	if c.watcher != nil {
+		c.watcher.Close()
+	}
+
+	var err error
+	if idle != nil {
+		log.Debug("waiting for mongo operations to complete", log.Data{"hosts": c.hosts, "active": active})
+		select {
+		case <-idle:
+		case <-ctx.Done():
+			err = ctx.Err()
+			log.Error(err, log.Data{"hosts": c.hosts})
+		}
+	}
+
+	// once ctx is done the driver closes connections still in use
+	if derr := c.current().Disconnect(ctx); derr != nil {
+		log.Error(derr, log.Data{"hosts": c.hosts})
+		if err == nil {
+			err = derr
+		}
+	}
+	log.Debug("mongo client disconnected", log.Data{"hosts": c.hosts})
+	return err
+}
@@ -0,0 +1,198 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/healthcheck"
+	"github.com/ONSdigital/go-ns/vault"
+	. "github.com/smartystreets/goconvey/convey"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type fakeClient struct {
+	pingErr      error
+	database     string
+	closed       bool
+	disconnected chan struct{}
+}
+
+func (c *fakeClient) Database(name string, opts ...*options.DatabaseOptions) *mongo.Database {
+	c.database = name
+	return nil
+}
+
+func (c *fakeClient) Ping(ctx context.Context, rp *readpref.ReadPref) error {
+	if c.pingErr != nil {
+		return c.pingErr
+	}
+	return ctx.Err()
+}
+
+func (c *fakeClient) Disconnect(ctx context.Context) error {
+	if c.disconnected != nil {
+		close(c.disconnected)
+		return nil
+	}
+	c.closed = true
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	Convey("Dial should return an error for an invalid URI or unreachable server", t, func() {
+		_, err := Dial("mongodb://localhost:27017/?connectTimeoutMS=invalid")
+		So(err, ShouldNotBeNil)
+
+		oldTimeout := DialTimeout
+		DialTimeout = 10 * time.Millisecond
+		defer func() {
+			DialTimeout = oldTimeout
+		}()
+
+		_, err = Dial("mongodb://127.0.0.1:1/datasets")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Check should report the mongo status", t, func() {
+		mc := &fakeClient{}
+		var checker healthcheck.Checker = newClient(mc, "datasets", nil)
+
+		status, message := checker.Check(context.Background())
+		So(status, ShouldEqual, healthcheck.StatusOK)
+		So(message, ShouldEqual, "mongo is ok")
+
+		mc.pingErr = errors.New("no reachable servers")
+		status, message = checker.Check(context.Background())
+		So(status, ShouldEqual, healthcheck.StatusCritical)
+		So(message, ShouldEqual, "no reachable servers")
+	})
+
+	Convey("Check should be critical once the client is closed", t, func() {
+		mc := &fakeClient{}
+		c := newClient(mc, "datasets", nil)
+		So(c.Close(context.Background()), ShouldBeNil)
+
+		status, message := c.Check(context.Background())
+		So(status, ShouldEqual, healthcheck.StatusCritical)
+		So(message, ShouldEqual, ErrClosed.Error())
+	})
+
+	Convey("Check should pass its context to the ping", t, func() {
+		c := newClient(&fakeClient{}, "datasets", nil)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		status, message := c.Check(ctx)
+		So(status, ShouldEqual, healthcheck.StatusCritical)
+		So(message, ShouldEqual, context.Canceled.Error())
+	})
+
+	Convey("Do should use the database from the URI", t, func() {
+		mc := &fakeClient{}
+		c := newClient(mc, "datasets", nil)
+		So(c.Do(func(db *mongo.Database) error { return nil }), ShouldBeNil)
+		So(mc.database, ShouldEqual, "datasets")
+	})
+
+	Convey("Close should wait for in-flight operations", t, func() {
+		mc := &fakeClient{}
+		c := newClient(mc, "datasets", nil)
+		So(c.begin(), ShouldBeTrue)
+
+		closed := make(chan error)
+		go func() {
+			closed <- c.Close(context.Background())
+		}()
+
+		select {
+		case <-closed:
+			So("closed with operations in flight", ShouldBeEmpty)
+		case <-time.After(20 * time.Millisecond):
+		}
+		So(c.begin(), ShouldBeFalse)
+
+		c.end()
+		So(<-closed, ShouldBeNil)
+		So(mc.closed, ShouldBeTrue)
+	})
+
+	Convey("Close should stop waiting when the context is done", t, func() {
+		mc := &fakeClient{}
+		c := newClient(mc, "datasets", nil)
+		So(c.begin(), ShouldBeTrue)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		So(c.Close(ctx), ShouldNotBeNil)
+		So(mc.closed, ShouldBeTrue)
+	})
+
+	Convey("Do should return ErrClosed once closed", t, func() {
+		c := newClient(&fakeClient{}, "datasets", nil)
+		So(c.Close(context.Background()), ShouldBeNil)
+		So(c.Do(func(db *mongo.Database) error { return nil }), ShouldEqual, ErrClosed)
+		So(c.Close(context.Background()), ShouldEqual, ErrClosed)
+	})
+
+	Convey("rotate should replace the client and disconnect the old one", t, func() {
+		old := &fakeClient{disconnected: make(chan struct{})}
+		c := newClient(old, "datasets", nil)
+
+		rotated := &fakeClient{}
+		c.rotate(rotated)
+		So(c.Do(func(db *mongo.Database) error { return nil }), ShouldBeNil)
+		So(rotated.database, ShouldEqual, "datasets")
+		So(old.database, ShouldBeEmpty)
+
+		select {
+		case <-old.disconnected:
+		case <-time.After(time.Second):
+			So("old client wasn't disconnected", ShouldBeEmpty)
+		}
+	})
+
+	Convey("rotate should disconnect the new client once closed", t, func() {
+		c := newClient(&fakeClient{}, "datasets", nil)
+		So(c.Close(context.Background()), ShouldBeNil)
+
+		rotated := &fakeClient{}
+		c.rotate(rotated)
+		So(rotated.closed, ShouldBeTrue)
+	})
+
+	Convey("DialWithVault should return an error if the credentials can't be read", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+		v, err := vault.New(vault.Config{Address: server.URL, Token: "token"})
+		So(err, ShouldBeNil)
+
+		_, err = DialWithVault("mongodb://127.0.0.1:1/datasets", v, "database/creds/datasets")
+		So(err, ShouldHaveSameTypeAs, &vault.Error{})
+	})
+
+	Convey("DialWithVault should return an error for an unreachable server", t, func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write([]byte(`{"lease_duration":3600,"data":{"username":"user","password":"secret"}}`))
+		}))
+		defer server.Close()
+		v, err := vault.New(vault.Config{Address: server.URL, Token: "token"})
+		So(err, ShouldBeNil)
+
+		oldTimeout := DialTimeout
+		DialTimeout = 10 * time.Millisecond
+		defer func() {
+			DialTimeout = oldTimeout
+		}()
+
+		_, err = DialWithVault("mongodb://127.0.0.1:1/datasets", v, "database/creds/datasets")
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,257 @@
+package rchttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/baggage"
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// server responds with each status in turn, then 200
+type server struct {
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   []string
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	b, _ := ioutil.ReadAll(req.Body)
+	s.requests = append(s.requests, req)
+	s.bodies = append(s.bodies, string(b))
+
+	status := 200
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func testClient() *Client {
+	c := NewClient()
+	c.RetryTime = time.Millisecond
+	return c
+}
+
+func TestClient(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var events []log.Data
+	var contexts []string
+	log.SetEvent(func(name string, context string, data log.Data) {
+		if name == "http_client" {
+			events = append(events, data)
+			contexts = append(contexts, context)
+		}
+	})
+
+	Convey("Do should retry retryable statuses", t, func() {
+		events, contexts = nil, nil
+		s := &server{statuses: []int{503, 500}}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		res, err := testClient().Get(context.Background(), ts.URL)
+		So(err, ShouldBeNil)
+		So(res.StatusCode, ShouldEqual, 200)
+		So(s.requests, ShouldHaveLength, 3)
+
+		So(events, ShouldHaveLength, 3)
+		So(events[0]["attempt"], ShouldEqual, 1)
+		So(events[0]["status"], ShouldEqual, 503)
+		So(events[0], ShouldContainKey, "retry_in")
+		So(events[2]["attempt"], ShouldEqual, 3)
+		So(events[2]["status"], ShouldEqual, 200)
+		So(events[2], ShouldNotContainKey, "retry_in")
+	})
+
+	Convey("Do should return the last response once retries are exhausted", t, func() {
+		s := &server{statuses: []int{503, 503, 503, 503, 503}}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		c := testClient()
+		c.MaxRetries = 2
+		res, err := c.Get(context.Background(), ts.URL)
+		So(err, ShouldBeNil)
+		So(res.StatusCode, ShouldEqual, 503)
+		So(s.requests, ShouldHaveLength, 3)
+	})
+
+	Convey("Do should not retry other statuses", t, func() {
+		s := &server{statuses: []int{404}}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		res, err := testClient().Get(context.Background(), ts.URL)
+		So(err, ShouldBeNil)
+		So(res.StatusCode, ShouldEqual, 404)
+		So(s.requests, ShouldHaveLength, 1)
+	})
+
+	Convey("Do should retry connection errors", t, func() {
+		events = nil
+		ts := httptest.NewServer(http.NotFoundHandler())
+		ts.Close()
+
+		c := testClient()
+		c.MaxRetries = 1
+		_, err := c.Get(context.Background(), ts.URL)
+		So(err, ShouldNotBeNil)
+		So(events, ShouldHaveLength, 2)
+		So(events[0], ShouldContainKey, "error")
+	})
+
+	Convey("Do should only retry connection errors for idempotent requests", t, func() {
+		events = nil
+		ts := httptest.NewServer(http.NotFoundHandler())
+		ts.Close()
+
+		c := testClient()
+		c.MaxRetries = 1
+		_, err := c.Post(context.Background(), ts.URL, "text/plain", strings.NewReader("payload"))
+		So(err, ShouldNotBeNil)
+		So(events, ShouldHaveLength, 1)
+
+		events = nil
+		req, err := http.NewRequest("POST", ts.URL, strings.NewReader("payload"))
+		So(err, ShouldBeNil)
+		req.Header.Set("Idempotency-Key", "abc")
+		_, err = c.Do(context.Background(), req)
+		So(err, ShouldNotBeNil)
+		So(events, ShouldHaveLength, 2)
+	})
+
+	Convey("Do should resend the request body on retries", t, func() {
+		s := &server{statuses: []int{502}}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		req, err := http.NewRequest("POST", ts.URL, ioutil.NopCloser(strings.NewReader("payload")))
+		So(err, ShouldBeNil)
+		res, err := testClient().Do(context.Background(), req)
+		So(err, ShouldBeNil)
+		So(res.StatusCode, ShouldEqual, 200)
+		So(s.bodies, ShouldResemble, []string{"payload", "payload"})
+	})
+
+	Convey("Do should forward the request ID from the context", t, func() {
+		contexts = nil
+		s := &server{}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		ctx := log.WithRequestID(context.Background(), "test-request-id")
+		_, err := testClient().Get(ctx, ts.URL)
+		So(err, ShouldBeNil)
+		So(s.requests[0].Header.Get(RequestIDHeader), ShouldEqual, "test-request-id")
+		So(contexts[0], ShouldEqual, "test-request-id")
+	})
+
+	Convey("Do should set headers on a copy of the request", t, func() {
+		s := &server{}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		ctx := log.WithRequestID(context.Background(), "test-request-id")
+		ctx = baggage.Set(ctx, "tenant", "ons")
+		req, err := http.NewRequest("GET", ts.URL, nil)
+		So(err, ShouldBeNil)
+		_, err = testClient().Do(ctx, req)
+		So(err, ShouldBeNil)
+		So(s.requests[0].Header.Get(baggage.Header), ShouldEqual, "tenant=ons")
+		So(s.requests[0].Header.Get(RequestIDHeader), ShouldEqual, "test-request-id")
+		So(req.Header, ShouldBeEmpty)
+	})
+
+	Convey("Do should mask sensitive query parameters in logged URLs", t, func() {
+		events = nil
+		s := &server{}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		c := testClient()
+		c.RedactQueryParams = []string{"api_key"}
+		_, err := c.Get(context.Background(), ts.URL+"/search?q=cpih&api_key=abc&token=xyz")
+		So(err, ShouldBeNil)
+		So(s.requests[0].URL.RawQuery, ShouldEqual, "q=cpih&api_key=abc&token=xyz")
+		So(events[0]["url"], ShouldEqual, ts.URL+"/search?q=cpih&api_key=[REDACTED]&token=[REDACTED]")
+	})
+
+	Convey("Do should send requests through per-host proxies", t, func() {
+		events = nil
+		var proxied []*http.Request
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			proxied = append(proxied, req)
+		}))
+		defer proxy.Close()
+
+		u, err := url.Parse(proxy.URL)
+		So(err, ShouldBeNil)
+		u.User = url.UserPassword("user", "secret")
+
+		c := testClient()
+		c.ProxyHosts = map[string]*url.URL{"service.internal": u}
+		res, err := c.Get(context.Background(), "http://service.internal/datasets")
+		So(err, ShouldBeNil)
+		So(res.StatusCode, ShouldEqual, 200)
+
+		So(proxied, ShouldHaveLength, 1)
+		So(proxied[0].URL.String(), ShouldEqual, "http://service.internal/datasets")
+		So(proxied[0].Header.Get("Proxy-Authorization"), ShouldEqual, "Basic dXNlcjpzZWNyZXQ=")
+		So(events[0]["proxy"], ShouldEqual, "http://user:xxxxx@"+u.Host)
+
+		Convey("unless the override is nil", func() {
+			events = nil
+			s := &server{}
+			ts := httptest.NewServer(s)
+			defer ts.Close()
+
+			c.ProxyHosts["127.0.0.1"] = nil
+			_, err := c.Get(context.Background(), ts.URL)
+			So(err, ShouldBeNil)
+			So(s.requests, ShouldHaveLength, 1)
+			So(events[0], ShouldNotContainKey, "proxy")
+		})
+	})
+
+	Convey("Do should stop retrying when the context is cancelled", t, func() {
+		s := &server{statuses: []int{503, 503, 503, 503}}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		c := testClient()
+		c.RetryTime = time.Second
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := c.Get(ctx, ts.URL)
+		So(err == context.DeadlineExceeded, ShouldBeTrue)
+		So(s.requests, ShouldHaveLength, 1)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	Convey("backoff should increase exponentially with jitter, up to a maximum", t, func() {
+		c := &Client{RetryTime: 100 * time.Millisecond, MaxRetryTime: time.Second}
+
+		for i := 0; i < 10; i++ {
+			So(c.backoff(0), ShouldBeBetweenOrEqual, 50*time.Millisecond, 100*time.Millisecond)
+			So(c.backoff(2), ShouldBeBetweenOrEqual, 200*time.Millisecond, 400*time.Millisecond)
+			So(c.backoff(10), ShouldBeBetweenOrEqual, 500*time.Millisecond, time.Second)
+			So(c.backoff(100), ShouldBeBetweenOrEqual, 500*time.Millisecond, time.Second)
+		}
+	})
+}
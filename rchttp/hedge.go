@@ -0,0 +1,171 @@
+package rchttp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// latencySamples is the number of recent latencies kept to calculate
+	// the hedging delay
+	latencySamples = 100
+	// minLatencySamples are needed before the percentile is used
+	minLatencySamples = 20
+)
+
+// idempotent methods can be hedged, and retried after connection errors,
+// as sending them twice has the same effect as sending them once
+var idempotent = map[string]bool{
+	"GET":     true,
+	"HEAD":    true,
+	"OPTIONS": true,
+	"TRACE":   true,
+	"PUT":     true,
+	"DELETE":  true,
+}
+
+// latencies records recent response latencies
+type latencies struct {
+	mutex   sync.Mutex
+	samples []time.Duration
+	next    int
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if len(l.samples) < latencySamples {
+		l.samples = append(l.samples, d)
+		return
+	}
+	l.samples[l.next] = d
+	l.next = (l.next + 1) % latencySamples
+}
+
+// percentile returns the latency at percentile p, or false if too few
+// latencies have been recorded
+func (l *latencies) percentile(p float64) (time.Duration, bool) {
+	l.mutex.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	l.mutex.Unlock()
+
+	if len(sorted) < minLatencySamples {
+		return 0, false
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(p * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i], true
+}
+
+// hedgeDelay returns how long to wait for a response before sending a
+// duplicate request, or false if the request isn't hedged
+func (c *Client) hedgeDelay(req *http.Request) (time.Duration, bool) {
+	if c.HedgePercentile <= 0 || !idempotent[req.Method] {
+		return 0, false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return 0, false
+	}
+	d, ok := c.latencies.percentile(c.HedgePercentile)
+	if !ok || d < c.HedgeDelay {
+		d = c.HedgeDelay
+	}
+	return d, d > 0
+}
+
+// result is the outcome of one of the hedged requests
+type result struct {
+	res *http.Response
+	err error
+	n   int
+}
+
+// hedge sends a request, and a duplicate if there's no response after
+// delay, returning the first successful response. The other request is
+// cancelled. It also returns which request won, and whether the duplicate
+// was sent.
+func (c *Client) hedge(client *http.Client, req *http.Request, delay time.Duration) (*http.Response, int, bool, error) {
+	results := make(chan result, 2)
+	cancels := map[int]context.CancelFunc{}
+	send := func(n int) error {
+		r := req
+		if n > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+		ctx, cancel := context.WithCancel(req.Context())
+		cancels[n] = cancel
+		r = r.WithContext(ctx)
+		go func() {
+			res, err := client.Do(r)
+			results <- result{res, err, n}
+		}()
+		return nil
+	}
+
+	send(1)
+	pending, hedged := 1, false
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var first result
+wait:
+	for {
+		select {
+		case <-timer.C:
+			if err := send(2); err == nil {
+				pending++
+				hedged = true
+			}
+		case first = <-results:
+			pending--
+			// an error is only returned once no request is pending
+			if first.err == nil || pending == 0 {
+				break wait
+			}
+		}
+	}
+
+	for n, cancel := range cancels {
+		if n != first.n || first.err != nil {
+			cancel()
+		}
+	}
+	// the loser's response is discarded
+	go func(pending int) {
+		for ; pending > 0; pending-- {
+			if r := <-results; r.res != nil {
+				r.res.Body.Close()
+			}
+		}
+	}(pending)
+
+	if first.err != nil {
+		return nil, first.n, hedged, first.err
+	}
+	first.res.Body = &cancelBody{first.res.Body, cancels[first.n]}
+	return first.res, first.n, hedged, nil
+}
+
+// cancelBody cancels a request's context once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
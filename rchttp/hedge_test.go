@@ -0,0 +1,134 @@
+package rchttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// slowServer delays its first response until the request is cancelled
+type slowServer struct {
+	mutex     sync.Mutex
+	requests  int
+	cancelled chan struct{}
+}
+
+func (s *slowServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	s.requests++
+	n := s.requests
+	s.mutex.Unlock()
+
+	if n == 1 {
+		select {
+		case <-req.Context().Done():
+			close(s.cancelled)
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+	w.Write([]byte("response " + string(rune('0'+n))))
+}
+
+func TestHedging(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var mutex sync.Mutex
+	var events []log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if name == "http_client" {
+			events = append(events, data)
+		}
+	})
+
+	hedgingClient := func() *Client {
+		c := testClient()
+		c.HedgePercentile = 0.95
+		c.HedgeDelay = 20 * time.Millisecond
+		return c
+	}
+
+	Convey("Do should send a duplicate request if there's no response within the hedge delay", t, func() {
+		events = nil
+		s := &slowServer{cancelled: make(chan struct{})}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		res, err := hedgingClient().Get(context.Background(), ts.URL)
+		So(err, ShouldBeNil)
+		b, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "response 2")
+
+		select {
+		case <-s.cancelled:
+		case <-time.After(time.Second):
+			So("the original request wasn't cancelled", ShouldBeEmpty)
+		}
+
+		So(events, ShouldHaveLength, 1)
+		So(events[0]["hedge_winner"], ShouldEqual, 2)
+		So(events[0]["hedge_delay"], ShouldEqual, 20*time.Millisecond)
+	})
+
+	Convey("Do should not hedge requests which respond in time", t, func() {
+		events = nil
+		s := &server{}
+		ts := httptest.NewServer(s)
+		defer ts.Close()
+
+		c := hedgingClient()
+		c.HedgeDelay = time.Second
+		_, err := c.Get(context.Background(), ts.URL)
+		So(err, ShouldBeNil)
+		So(s.requests, ShouldHaveLength, 1)
+		So(events[0], ShouldNotContainKey, "hedge_winner")
+	})
+
+	Convey("Do should not hedge requests which aren't idempotent", t, func() {
+		c := hedgingClient()
+		req, err := http.NewRequest("POST", "http://localhost", nil)
+		So(err, ShouldBeNil)
+		_, ok := c.hedgeDelay(req)
+		So(ok, ShouldBeFalse)
+
+		req.Method = "PUT"
+		_, ok = c.hedgeDelay(req)
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey("hedgeDelay should use the latency percentile once there are enough samples", t, func() {
+		c := hedgingClient()
+		req, err := http.NewRequest("GET", "http://localhost", nil)
+		So(err, ShouldBeNil)
+
+		for i := 1; i <= 10; i++ {
+			c.latencies.add(time.Duration(i) * 100 * time.Millisecond)
+		}
+		d, _ := c.hedgeDelay(req)
+		So(d, ShouldEqual, 20*time.Millisecond)
+
+		for i := 11; i <= 100; i++ {
+			c.latencies.add(time.Duration(i) * 100 * time.Millisecond)
+		}
+		d, _ = c.hedgeDelay(req)
+		So(d, ShouldEqual, 9600*time.Millisecond)
+
+		// older latencies are replaced
+		for i := 0; i < 100; i++ {
+			c.latencies.add(time.Millisecond)
+		}
+		d, _ = c.hedgeDelay(req)
+		So(d, ShouldEqual, 20*time.Millisecond)
+	})
+}
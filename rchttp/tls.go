@@ -0,0 +1,238 @@
+package rchttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/ONSdigital/go-ns/vault"
+)
+
+// TLSConfig configures client certificates and trusted CAs for mutual TLS
+type TLSConfig struct {
+	// CertFile and KeyFile are the PEM encoded client certificate and key.
+	// They're reloaded when either file changes, so certificates can be
+	// rotated without restarting.
+	CertFile string
+	KeyFile  string
+	// Vault watches a secret with the PEM encoded client certificate and
+	// key in "certificate" and "private_key" fields, as issued by Vault's
+	// PKI secrets engine. It's used instead of CertFile and KeyFile, and
+	// the certificate is replaced when the secret is rotated.
+	Vault *vault.Watcher
+	// CAFile is a PEM encoded bundle of CAs which are trusted instead of
+	// the system roots
+	CAFile string
+	// RootCAs are trusted instead of the system roots, and take precedence
+	// over CAFile
+	RootCAs *x509.CertPool
+}
+
+// CertificateError is returned when a request fails because of a
+// certificate, e.g. a client certificate which can't be loaded or a
+// server certificate which can't be verified. These aren't retried.
+type CertificateError struct {
+	Err error
+}
+
+func (e *CertificateError) Error() string {
+	return "certificate error: " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *CertificateError) Unwrap() error {
+	return e.Err
+}
+
+// SetTLS configures mutual TLS on the client's transport, which must be
+// a *http.Transport, such as the one created by NewClient
+func (c *Client) SetTLS(cfg TLSConfig) error {
+	if c.HTTPClient == nil {
+		return errors.New("rchttp: client has no HTTPClient")
+	}
+	t, ok := c.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("rchttp: can't configure TLS for transport %T", c.HTTPClient.Transport)
+	}
+
+	tlsConfig := &tls.Config{RootCAs: cfg.RootCAs}
+	if t.TLSClientConfig != nil {
+		tlsConfig = t.TLSClientConfig.Clone()
+		if cfg.RootCAs != nil {
+			tlsConfig.RootCAs = cfg.RootCAs
+		}
+	}
+
+	if tlsConfig.RootCAs == nil && len(cfg.CAFile) > 0 {
+		b, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return &CertificateError{err}
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return &CertificateError{fmt.Errorf("no certificates found in %s", cfg.CAFile)}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	var r interface {
+		certificate() (*tls.Certificate, error)
+	}
+	switch {
+	case cfg.Vault != nil:
+		r = &vaultCert{watcher: cfg.Vault}
+	case len(cfg.CertFile) > 0 || len(cfg.KeyFile) > 0:
+		r = &certReloader{certFile: cfg.CertFile, keyFile: cfg.KeyFile}
+	}
+	if r != nil {
+		if _, err := r.certificate(); err != nil {
+			return err
+		}
+		tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return r.certificate()
+		}
+	}
+
+	t.TLSClientConfig = tlsConfig
+	return nil
+}
+
+// certReloader loads a client certificate, reloading it when the
+// certificate or key file is modified
+type certReloader struct {
+	certFile, keyFile string
+
+	mutex    sync.Mutex
+	cert     *tls.Certificate
+	modified time.Time
+}
+
+func (r *certReloader) certificate() (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	modified, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return r.loaded(err)
+	}
+	if r.cert != nil && !modified.After(r.modified) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return r.loaded(err)
+	}
+
+	if r.cert != nil {
+		log.Info("reloaded client certificate", log.Data{"cert_file": r.certFile})
+	}
+	r.cert = &cert
+	r.modified = modified
+	return r.cert, nil
+}
+
+// loaded returns the previously loaded certificate if a rotated one can't
+// be loaded, e.g. because only one of the files has been replaced
+func (r *certReloader) loaded(err error) (*tls.Certificate, error) {
+	if r.cert == nil {
+		return nil, &CertificateError{err}
+	}
+	log.Error(err, log.Data{"cert_file": r.certFile, "key_file": r.keyFile})
+	return r.cert, nil
+}
+
+// vaultCert parses a client certificate from a Vault secret, parsing it
+// again when the secret is rotated
+type vaultCert struct {
+	watcher *vault.Watcher
+
+	mutex  sync.Mutex
+	secret *vault.Secret
+	cert   *tls.Certificate
+}
+
+func (v *vaultCert) certificate() (*tls.Certificate, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	s := v.watcher.Secret()
+	if s == v.secret {
+		return v.cert, nil
+	}
+	v.secret = s
+
+	cert, err := tls.X509KeyPair([]byte(s.String("certificate")), []byte(s.String("private_key")))
+	if err != nil {
+		// keep the previous certificate if a rotated one is invalid
+		if v.cert == nil {
+			return nil, &CertificateError{err}
+		}
+		log.Error(err, log.Data{"lease_id": s.LeaseID})
+		return v.cert, nil
+	}
+
+	if v.cert != nil {
+		log.Info("reloaded client certificate from vault", log.Data{"lease_id": s.LeaseID})
+	}
+	v.cert = &cert
+	return v.cert, nil
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, f := range files {
+		fi, err := os.Stat(f)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// certificateError returns err as a CertificateError if it's caused by a
+// certificate, or err otherwise
+func certificateError(err error) error {
+	var certErr *CertificateError
+	if err == nil || errors.As(err, &certErr) {
+		return err
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &verifyErr),
+		errors.As(err, &unknownAuthority),
+		errors.As(err, &hostname),
+		errors.As(err, &invalid),
+		errors.As(err, &opErr) && opErr.Op == "remote error" && certificateAlerts[opErr.Err.Error()]:
+		return &CertificateError{err}
+	}
+	return err
+}
+
+// certificateAlerts are the TLS alerts a server sends when it rejects the
+// client's certificate
+var certificateAlerts = map[string]bool{
+	"tls: bad certificate":               true,
+	"tls: unsupported certificate":       true,
+	"tls: revoked certificate":           true,
+	"tls: expired certificate":           true,
+	"tls: unknown certificate":           true,
+	"tls: unknown certificate authority": true,
+	"tls: certificate required":          true,
+}
@@ -0,0 +1,245 @@
+package rchttp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/ONSdigital/go-ns/vault"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newCert returns a certificate signed by parent, or a self-signed CA if
+// parent is nil
+func newCert(t *testing.T, name string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert, key}
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// pem returns the PEM encoded certificate and key
+func (c *testCert) pem(t *testing.T) ([]byte, []byte) {
+	key, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key})
+}
+
+// write writes the certificate and key as PEM files, with the given
+// modification time
+func (c *testCert) write(t *testing.T, certFile, keyFile string, modified time.Time) {
+	cert, key := c.pem(t)
+	if err := ioutil.WriteFile(certFile, cert, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.Chtimes(f, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var events []log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		if name == "http_client" {
+			events = append(events, data)
+		}
+	})
+
+	dir, err := ioutil.TempDir("", "rchttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newCert(t, "ca", nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	newCert(t, "client-1", ca).write(t, certFile, keyFile, time.Now().Add(-time.Minute))
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.cert)
+
+	var clients []string
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		clients = append(clients, req.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{newCert(t, "server", ca).tlsCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	ts.Config.ErrorLog = log.ErrorLogger("")
+	ts.StartTLS()
+	defer ts.Close()
+
+	Convey("SetTLS should present a client certificate trusted by the server", t, func() {
+		c := testClient()
+		So(c.SetTLS(TLSConfig{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}), ShouldBeNil)
+
+		clients = nil
+		res, err := c.Get(context.Background(), ts.URL)
+		So(err, ShouldBeNil)
+		So(res.StatusCode, ShouldEqual, 200)
+		So(clients, ShouldResemble, []string{"client-1"})
+
+		Convey("and reload it when it's rotated", func() {
+			newCert(t, "client-2", ca).write(t, certFile, keyFile, time.Now())
+			c.HTTPClient.Transport.(*http.Transport).CloseIdleConnections()
+
+			_, err := c.Get(context.Background(), ts.URL)
+			So(err, ShouldBeNil)
+			So(clients, ShouldResemble, []string{"client-1", "client-2"})
+		})
+	})
+
+	Convey("SetTLS should present a client certificate from Vault", t, func() {
+		var mutex sync.Mutex
+		secret := map[string]string{}
+		setSecret := func(c *testCert) {
+			cert, key := c.pem(t)
+			mutex.Lock()
+			defer mutex.Unlock()
+			secret = map[string]string{"certificate": string(cert), "private_key": string(key)}
+		}
+		setSecret(newCert(t, "vault-1", ca))
+
+		vs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": secret})
+		}))
+		defer vs.Close()
+		v, err := vault.New(vault.Config{Address: vs.URL, Token: "token"})
+		So(err, ShouldBeNil)
+		watcher, err := v.Watch("secret/client", 10*time.Millisecond, nil)
+		So(err, ShouldBeNil)
+		defer watcher.Close()
+
+		c := testClient()
+		So(c.SetTLS(TLSConfig{Vault: watcher, CAFile: caFile}), ShouldBeNil)
+
+		clients = nil
+		_, err = c.Get(context.Background(), ts.URL)
+		So(err, ShouldBeNil)
+		So(clients, ShouldResemble, []string{"vault-1"})
+
+		Convey("and reload it when the secret is rotated", func() {
+			setSecret(newCert(t, "vault-2", ca))
+			for i := 0; i < 100 && watcher.Secret().String("certificate") != secret["certificate"]; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			c.HTTPClient.Transport.(*http.Transport).CloseIdleConnections()
+
+			_, err := c.Get(context.Background(), ts.URL)
+			So(err, ShouldBeNil)
+			So(clients, ShouldResemble, []string{"vault-1", "vault-2"})
+		})
+	})
+
+	Convey("SetTLS should fail if the certificate can't be loaded", t, func() {
+		err := testClient().SetTLS(TLSConfig{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile})
+		var certErr *CertificateError
+		So(errors.As(err, &certErr), ShouldBeTrue)
+	})
+
+	Convey("Do should return certificate errors without retrying", t, func() {
+		Convey("when the server's certificate isn't trusted", func() {
+			events = nil
+			_, err := testClient().Get(context.Background(), ts.URL)
+			var certErr *CertificateError
+			So(errors.As(err, &certErr), ShouldBeTrue)
+			So(events, ShouldHaveLength, 1)
+			So(events[0]["error_kind"], ShouldEqual, "certificate")
+		})
+
+		Convey("when the server rejects the client", func() {
+			events = nil
+			c := testClient()
+			So(c.SetTLS(TLSConfig{CAFile: caFile}), ShouldBeNil)
+			_, err := c.Get(context.Background(), ts.URL)
+			var certErr *CertificateError
+			So(errors.As(err, &certErr), ShouldBeTrue)
+			So(events, ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("Do should retry other connection errors", t, func() {
+		events = nil
+		c := testClient()
+		c.MaxRetries = 1
+		So(c.SetTLS(TLSConfig{CAFile: caFile}), ShouldBeNil)
+		_, err := c.Get(context.Background(), "https://127.0.0.1:1")
+		var certErr *CertificateError
+		So(errors.As(err, &certErr), ShouldBeFalse)
+		So(events, ShouldHaveLength, 2)
+		So(events[0], ShouldNotContainKey, "error_kind")
+	})
+}
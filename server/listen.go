@@ -0,0 +1,78 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixPrefix selects a unix socket for an address, e.g. "unix:/run/app.sock"
+const unixPrefix = "unix:"
+
+// DefaultSocketMode is the permission of unix sockets created by the server
+var DefaultSocketMode os.FileMode = 0660
+
+// listen adopts a listener from systemd socket activation if it's enabled,
+// or listens on the server address, which is a TCP address or a unix
+// socket path prefixed by "unix:"
+func (s *Server) listen() (net.Listener, error) {
+	if s.SocketActivation {
+		if l, err := inheritedListener(); l != nil || err != nil {
+			return l, err
+		}
+	}
+
+	if !strings.HasPrefix(s.Addr, unixPrefix) {
+		return net.Listen("tcp", s.Addr)
+	}
+
+	path := strings.TrimPrefix(s.Addr, unixPrefix)
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := s.SocketMode
+	if mode == 0 {
+		mode = DefaultSocketMode
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// removeStaleSocket removes a unix socket left behind by a process which
+// didn't shut down cleanly. A socket which accepts connections is in use,
+// and anything other than a socket isn't removed.
+func removeStaleSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("server: %s exists and isn't a socket", path)
+	}
+
+	c, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		c.Close()
+		return fmt.Errorf("server: socket %s is in use", path)
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
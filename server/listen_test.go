@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnixSocket(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	var started log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		if data["message"] == "server started" {
+			started = data
+		}
+	})
+
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.sock")
+
+	Convey("Server should serve requests on a unix socket", t, func() {
+		s := New(unixPrefix+path, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+		s.HandleOSSignals = false
+		s.SocketMode = 0600
+
+		l, err := s.listen()
+		So(err, ShouldBeNil)
+		fi, err := os.Stat(path)
+		So(err, ShouldBeNil)
+		So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+
+		done := make(chan error)
+		go func() {
+			done <- s.Serve(l)
+		}()
+
+		client := &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return net.Dial("unix", path)
+			},
+		}}
+		res, err := client.Get("http://unix/")
+		So(err, ShouldBeNil)
+		res.Body.Close()
+		So(res.StatusCode, ShouldEqual, http.StatusTeapot)
+		So(started["listener"], ShouldEqual, "unix")
+		So(started["bind_addr"], ShouldEqual, path)
+
+		Convey("and refuse to replace a socket in use", func() {
+			_, err := New(unixPrefix+path, nil).listen()
+			So(err, ShouldNotBeNil)
+		})
+
+		So(s.Shutdown(context.Background()), ShouldBeNil)
+		So(<-done, ShouldBeNil)
+		_, err = os.Stat(path)
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+
+	Convey("listen should remove a stale socket", t, func() {
+		l, err := net.Listen("unix", path)
+		So(err, ShouldBeNil)
+		l.(*net.UnixListener).SetUnlinkOnClose(false)
+		l.Close()
+		_, err = os.Stat(path)
+		So(err, ShouldBeNil)
+
+		l, err = New(unixPrefix+path, nil).listen()
+		So(err, ShouldBeNil)
+		l.Close()
+	})
+
+	Convey("listen should not remove other files", t, func() {
+		So(ioutil.WriteFile(path, []byte("data"), 0600), ShouldBeNil)
+		defer os.Remove(path)
+
+		_, err := New(unixPrefix+path, nil).listen()
+		So(err, ShouldNotBeNil)
+		b, err := ioutil.ReadFile(path)
+		So(err, ShouldBeNil)
+		So(string(b), ShouldEqual, "data")
+	})
+}
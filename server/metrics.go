@@ -0,0 +1,91 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ConnMetrics records Prometheus metrics for a server's connections from
+// its ConnState callbacks
+type ConnMetrics struct {
+	connections  *prometheus.CounterVec
+	open         *prometheus.GaugeVec
+	acceptErrors prometheus.Counter
+
+	mutex  sync.Mutex
+	states map[net.Conn]http.ConnState
+}
+
+// NewConnMetrics creates the connection metrics and registers them with
+// reg, which defaults to prometheus.DefaultRegisterer
+func NewConnMetrics(reg prometheus.Registerer) (*ConnMetrics, error) {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+
+	m := &ConnMetrics{
+		connections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_server_connections_total",
+			Help: "Total number of connections by state transition: new, hijacked or closed.",
+		}, []string{"state"}),
+		open: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "http_server_connections",
+			Help: "Number of open connections by state: new, active or idle.",
+		}, []string{"state"}),
+		acceptErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "http_server_accept_errors_total",
+			Help: "Total number of errors accepting connections.",
+		}),
+		states: map[net.Conn]http.ConnState{},
+	}
+
+	for _, c := range []prometheus.Collector{m.connections, m.open, m.acceptErrors} {
+		if err := reg.Register(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return m, nil
+}
+
+// ConnState records a connection changing state. It's called by Server
+// when ConnMetrics is set, or can be used as http.Server.ConnState.
+func (m *ConnMetrics) ConnState(c net.Conn, state http.ConnState) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if prev, ok := m.states[c]; ok {
+		m.open.WithLabelValues(prev.String()).Dec()
+	}
+
+	switch state {
+	case http.StateNew:
+		m.connections.WithLabelValues("new").Inc()
+	case http.StateHijacked, http.StateClosed:
+		m.connections.WithLabelValues(state.String()).Inc()
+		delete(m.states, c)
+		return
+	}
+
+	m.states[c] = state
+	m.open.WithLabelValues(state.String()).Inc()
+}
+
+// listener counts errors accepting connections, other than the listener
+// being closed when the server shuts down
+type listener struct {
+	net.Listener
+	metrics *ConnMetrics
+}
+
+func (l listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		l.metrics.acceptErrors.Inc()
+	}
+	return c, err
+}
@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// temporaryError is retried by http.Server
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "accept failed" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// failingListener returns a temporary error from the first call to Accept
+type failingListener struct {
+	net.Listener
+	failed bool
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	if !l.failed {
+		l.failed = true
+		return nil, temporaryError{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestConnMetrics(t *testing.T) {
+	Convey("ConnState should track connections by state", t, func() {
+		m, err := NewConnMetrics(prometheus.NewRegistry())
+		So(err, ShouldBeNil)
+
+		a, b := net.Pipe()
+		defer a.Close()
+		defer b.Close()
+
+		m.ConnState(a, http.StateNew)
+		m.ConnState(b, http.StateNew)
+		m.ConnState(a, http.StateActive)
+		So(testutil.ToFloat64(m.connections.WithLabelValues("new")), ShouldEqual, 2)
+		So(testutil.ToFloat64(m.open.WithLabelValues("new")), ShouldEqual, 1)
+		So(testutil.ToFloat64(m.open.WithLabelValues("active")), ShouldEqual, 1)
+
+		m.ConnState(a, http.StateIdle)
+		m.ConnState(b, http.StateHijacked)
+		So(testutil.ToFloat64(m.open.WithLabelValues("new")), ShouldEqual, 0)
+		So(testutil.ToFloat64(m.open.WithLabelValues("active")), ShouldEqual, 0)
+		So(testutil.ToFloat64(m.open.WithLabelValues("idle")), ShouldEqual, 1)
+		So(testutil.ToFloat64(m.connections.WithLabelValues("hijacked")), ShouldEqual, 1)
+
+		m.ConnState(a, http.StateClosed)
+		So(testutil.ToFloat64(m.open.WithLabelValues("idle")), ShouldEqual, 0)
+		So(testutil.ToFloat64(m.connections.WithLabelValues("closed")), ShouldEqual, 1)
+		So(m.states, ShouldBeEmpty)
+	})
+
+	Convey("Server should record connection metrics and accept errors", t, func() {
+		defer log.SetEvent(nil)
+		log.SetEvent(func(name string, context string, data log.Data) {})
+
+		m, err := NewConnMetrics(prometheus.NewRegistry())
+		So(err, ShouldBeNil)
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+
+		s := New("", http.NotFoundHandler())
+		s.HandleOSSignals = false
+		s.ConnMetrics = m
+		s.ErrorLog = log.ErrorLogger("")
+
+		done := make(chan error)
+		go func() {
+			done <- s.Serve(&failingListener{Listener: l})
+		}()
+
+		res, err := http.Get("http://" + l.Addr().String())
+		So(err, ShouldBeNil)
+		res.Body.Close()
+
+		So(s.Shutdown(context.Background()), ShouldBeNil)
+		So(<-done, ShouldBeNil)
+
+		So(testutil.ToFloat64(m.connections.WithLabelValues("new")), ShouldEqual, 1)
+		// connections closed by Shutdown change state asynchronously
+		closed := func() float64 { return testutil.ToFloat64(m.connections.WithLabelValues("closed")) }
+		for i := 0; i < 100 && closed() == 0; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(closed(), ShouldEqual, 1)
+		So(testutil.ToFloat64(m.acceptErrors), ShouldEqual, 1)
+	})
+}
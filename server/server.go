@@ -0,0 +1,206 @@
+// Package server wraps http.Server with request logging and graceful
+// shutdown on SIGINT or SIGTERM.
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/ONSdigital/go-ns/handlers"
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// DefaultShutdownTimeout is the time allowed for in-flight requests to
+// complete during shutdown
+var DefaultShutdownTimeout = 10 * time.Second
+
+// Server is a http.Server which logs requests and shuts down gracefully.
+// Its ErrorLog defaults to log.ErrorLogger, and ConnState is wrapped to
+// track open connections.
+type Server struct {
+	http.Server
+
+	// Middleware is applied inside log.Handler, the first being outermost
+	Middleware handlers.Chain
+	// ShutdownTimeout is the time allowed for in-flight requests to
+	// complete, after which connections are closed
+	ShutdownTimeout time.Duration
+	// HandleOSSignals shuts the server down on SIGINT or SIGTERM
+	HandleOSSignals bool
+	// ConnMetrics, if set, records metrics for the server's connections
+	ConnMetrics *ConnMetrics
+	// SocketMode is the permission of the socket when Addr is a unix
+	// socket, defaults to DefaultSocketMode
+	SocketMode os.FileMode
+	// H2C serves HTTP/2 without TLS, as well as HTTP/1, for internal
+	// listeners behind a proxy which terminates TLS
+	H2C bool
+	// SocketActivation adopts a listener passed by systemd socket
+	// activation, using LISTEN_FDS, instead of listening on Addr. It's
+	// opt-in, since it adopts whatever descriptor 3 the process inherited.
+	SocketActivation bool
+
+	setup sync.Once
+	// conns is the number of open connections
+	conns int64
+}
+
+// New returns a server listening on bindAddr which routes requests to
+// router. bindAddr is a TCP address, or a unix socket path prefixed by
+// "unix:", e.g. "unix:/run/service.sock".
+func New(bindAddr string, router http.Handler) *Server {
+	return &Server{
+		Server: http.Server{
+			Addr:              bindAddr,
+			Handler:           router,
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+		},
+		ShutdownTimeout: DefaultShutdownTimeout,
+		HandleOSSignals: true,
+	}
+}
+
+// ListenAndServe listens on the server address and serves requests until
+// the server is shut down
+func (s *Server) ListenAndServe() error {
+	l, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// ListenAndServeTLS listens on the server address and serves HTTPS requests
+// until the server is shut down
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	l, err := s.listen()
+	if err != nil {
+		return err
+	}
+	return s.serve(l, func(l net.Listener) error {
+		return s.Server.ServeTLS(l, certFile, keyFile)
+	})
+}
+
+// Serve serves requests from l until the server is shut down. It returns
+// nil once a graceful shutdown completes.
+func (s *Server) Serve(l net.Listener) error {
+	return s.serve(l, s.Server.Serve)
+}
+
+func (s *Server) serve(l net.Listener, serve func(net.Listener) error) error {
+	s.setup.Do(s.wrap)
+	if s.ConnMetrics != nil {
+		l = listener{l, s.ConnMetrics}
+	}
+
+	var signals chan os.Signal
+	if s.HandleOSSignals {
+		signals = make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+	}
+
+	log.Info("server started", log.Data{"bind_addr": l.Addr().String(), "listener": l.Addr().Network()})
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		return s.stopped(err)
+	case sig := <-signals:
+		log.Info("server shutting down", log.Data{
+			"signal":      sig.String(),
+			"timeout":     s.ShutdownTimeout,
+			"connections": atomic.LoadInt64(&s.conns),
+		})
+		if err := s.Shutdown(context.Background()); err != nil {
+			return err
+		}
+		return s.stopped(<-errs)
+	}
+}
+
+// wrap adds request logging, the error logger, h2c and connection tracking
+// to the http.Server. It runs once, so a server which serves several
+// listeners doesn't log each request more than once.
+func (s *Server) wrap() {
+	h := s.Handler
+	if h == nil {
+		h = http.DefaultServeMux
+	}
+	s.Handler = log.Handler(s.Middleware.Then(h))
+
+	if s.ErrorLog == nil {
+		s.ErrorLog = log.ErrorLogger("")
+	}
+
+	if s.H2C {
+		if s.Protocols == nil {
+			s.Protocols = new(http.Protocols)
+			s.Protocols.SetHTTP1(true)
+			s.Protocols.SetHTTP2(true)
+		}
+		s.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	connState := s.ConnState
+	s.ConnState = func(c net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			atomic.AddInt64(&s.conns, 1)
+		case http.StateHijacked, http.StateClosed:
+			atomic.AddInt64(&s.conns, -1)
+		}
+		if s.ConnMetrics != nil {
+			s.ConnMetrics.ConnState(c, state)
+		}
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests
+// to complete, up to the shutdown timeout, before closing all connections
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.ShutdownTimeout)
+		defer cancel()
+	}
+
+	err := s.Server.Shutdown(ctx)
+	if err != nil {
+		log.Error(err, log.Data{"timeout": s.ShutdownTimeout, "connections": atomic.LoadInt64(&s.conns)})
+		s.Server.Close()
+	}
+	return err
+}
+
+// stopped logs that the server has stopped, treating http.ErrServerClosed
+// as a clean shutdown
+func (s *Server) stopped(err error) error {
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	if err != nil {
+		log.Error(err, nil)
+	}
+	log.Info("server stopped", nil)
+	// the process usually exits once the server stops, so write any
+	// buffered or queued events
+	log.Flush(log.GetFatalFlushTimeout())
+	return err
+}
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ONSdigital/go-ns/handlers"
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type event struct {
+	name string
+	data log.Data
+}
+
+func TestServer(t *testing.T) {
+	defer log.SetEvent(nil)
+
+	events := make(chan event, 100)
+	var requestEvents int64
+	log.SetEvent(func(name string, context string, data log.Data) {
+		if name == "request" {
+			atomic.AddInt64(&requestEvents, 1)
+		}
+		events <- event{name, data}
+	})
+
+	nextEvent := func(name string) event {
+		for {
+			select {
+			case e := <-events:
+				if e.name == name || e.data["message"] == name {
+					return e
+				}
+			case <-time.After(5 * time.Second):
+				return event{}
+			}
+		}
+	}
+
+	Convey("New should return a server with defaults", t, func() {
+		s := New(":0", http.NotFoundHandler())
+		So(s.Addr, ShouldEqual, ":0")
+		So(s.ShutdownTimeout, ShouldEqual, DefaultShutdownTimeout)
+		So(s.HandleOSSignals, ShouldBeTrue)
+	})
+
+	Convey("Server should log requests and apply middleware", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+
+		s := New("", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}))
+		s.HandleOSSignals = false
+		s.Middleware = handlers.NewChain(func(h http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.Header().Set("X-Middleware", "true")
+				h.ServeHTTP(w, req)
+			})
+		})
+
+		done := make(chan error)
+		go func() {
+			done <- s.Serve(l)
+		}()
+		So(nextEvent("server started").data["bind_addr"], ShouldEqual, l.Addr().String())
+
+		res, err := http.Get("http://" + l.Addr().String() + "/test")
+		So(err, ShouldBeNil)
+		res.Body.Close()
+		So(res.StatusCode, ShouldEqual, http.StatusTeapot)
+		So(res.Header.Get("X-Middleware"), ShouldEqual, "true")
+
+		request := nextEvent("request")
+		So(request.data["status"], ShouldEqual, http.StatusTeapot)
+		So(request.data["path"], ShouldEqual, "/test")
+
+		So(s.Shutdown(context.Background()), ShouldBeNil)
+		So(<-done, ShouldBeNil)
+		So(nextEvent("server stopped").name, ShouldEqual, "info")
+	})
+
+	Convey("Server should wrap its handler once when serving several listeners", t, func() {
+		var requests int
+		atomic.StoreInt64(&requestEvents, 0)
+		s := New("", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests++
+		}))
+		s.HandleOSSignals = false
+
+		done := make(chan error, 2)
+		for i := 0; i < 2; i++ {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			So(err, ShouldBeNil)
+			go func() {
+				done <- s.Serve(l)
+			}()
+			addr := nextEvent("server started").data["bind_addr"].(string)
+
+			res, err := http.Get("http://" + addr)
+			So(err, ShouldBeNil)
+			res.Body.Close()
+		}
+		So(s.Shutdown(context.Background()), ShouldBeNil)
+		So(<-done, ShouldBeNil)
+		So(<-done, ShouldBeNil)
+
+		So(requests, ShouldEqual, 2)
+		So(atomic.LoadInt64(&requestEvents), ShouldEqual, 2)
+		So(s.ErrorLog, ShouldNotBeNil)
+	})
+
+	Convey("Server should serve HTTP/2 without TLS when H2C is set", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+
+		s := New("", http.NotFoundHandler())
+		s.HandleOSSignals = false
+		s.H2C = true
+
+		done := make(chan error)
+		go func() {
+			done <- s.Serve(l)
+		}()
+		nextEvent("server started")
+
+		protocols := new(http.Protocols)
+		protocols.SetUnencryptedHTTP2(true)
+		client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+		res, err := client.Get("http://" + l.Addr().String())
+		So(err, ShouldBeNil)
+		res.Body.Close()
+		So(res.ProtoMajor, ShouldEqual, 2)
+		So(nextEvent("request").data["http_version"], ShouldEqual, "2.0")
+
+		res, err = http.Get("http://" + l.Addr().String())
+		So(err, ShouldBeNil)
+		res.Body.Close()
+		So(res.ProtoMajor, ShouldEqual, 1)
+		So(nextEvent("request").data["http_version"], ShouldEqual, "1.1")
+
+		So(s.Shutdown(context.Background()), ShouldBeNil)
+		So(<-done, ShouldBeNil)
+	})
+
+	Convey("Server should drain in-flight requests on SIGTERM", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+
+		started := make(chan struct{})
+		s := New("", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			close(started)
+			time.Sleep(100 * time.Millisecond)
+			w.Write([]byte("done"))
+		}))
+
+		done := make(chan error)
+		go func() {
+			done <- s.Serve(l)
+		}()
+		nextEvent("server started")
+
+		responses := make(chan int)
+		go func() {
+			res, err := http.Get("http://" + l.Addr().String())
+			if err != nil {
+				responses <- 0
+				return
+			}
+			res.Body.Close()
+			responses <- res.StatusCode
+		}()
+
+		<-started
+		p, err := os.FindProcess(os.Getpid())
+		So(err, ShouldBeNil)
+		So(p.Signal(syscall.SIGTERM), ShouldBeNil)
+
+		shutdown := nextEvent("server shutting down")
+		So(shutdown.data["signal"], ShouldEqual, "terminated")
+		So(shutdown.data["connections"], ShouldEqual, 1)
+		So(<-responses, ShouldEqual, 200)
+		So(<-done, ShouldBeNil)
+	})
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd
+var listenFDsStart = 3
+
+// inheritedListener returns the first listener passed by systemd socket
+// activation, or nil if the process wasn't socket activated. The
+// environment variables are unset, so child processes don't also adopt
+// the listeners.
+func inheritedListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n < 1 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	name := "LISTEN_FD_" + strconv.Itoa(listenFDsStart)
+	if len(names[0]) > 0 {
+		name = names[0]
+	}
+
+	f := os.NewFile(uintptr(listenFDsStart), name)
+	defer f.Close()
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("server: adopting systemd socket %s: %v", name, err)
+	}
+
+	log.Info("adopted systemd socket", log.Data{
+		"fd":        listenFDsStart,
+		"name":      name,
+		"bind_addr": l.Addr().String(),
+		"listener":  l.Addr().Network(),
+		"fds":       n,
+	})
+	return l, nil
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSocketActivation(t *testing.T) {
+	defer log.SetEvent(nil)
+	defer func(start int) { listenFDsStart = start }(listenFDsStart)
+
+	var adopted log.Data
+	log.SetEvent(func(name string, context string, data log.Data) {
+		if data["message"] == "adopted systemd socket" {
+			adopted = data
+		}
+	})
+
+	Convey("listen should adopt a socket passed by systemd", t, func() {
+		tl, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer tl.Close()
+		f, err := tl.(*net.TCPListener).File()
+		So(err, ShouldBeNil)
+		// the inherited descriptor is closed once it's adopted
+		fd, err := syscall.Dup(int(f.Fd()))
+		f.Close()
+		So(err, ShouldBeNil)
+
+		listenFDsStart = fd
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		os.Setenv("LISTEN_FDS", "1")
+		os.Setenv("LISTEN_FDNAMES", "http")
+
+		s := New("127.0.0.1:0", nil)
+		s.SocketActivation = true
+		l, err := s.listen()
+		So(err, ShouldBeNil)
+		defer l.Close()
+		So(l.Addr().String(), ShouldEqual, tl.Addr().String())
+		So(adopted["name"], ShouldEqual, "http")
+		So(adopted["bind_addr"], ShouldEqual, tl.Addr().String())
+		So(os.Getenv("LISTEN_FDS"), ShouldBeEmpty)
+		So(os.Getenv("LISTEN_PID"), ShouldBeEmpty)
+	})
+
+	Convey("listen should ignore sockets passed to another process", t, func() {
+		adopted = nil
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+		os.Setenv("LISTEN_FDS", "1")
+		defer os.Unsetenv("LISTEN_PID")
+		defer os.Unsetenv("LISTEN_FDS")
+
+		s := New("127.0.0.1:0", nil)
+		s.SocketActivation = true
+		l, err := s.listen()
+		So(err, ShouldBeNil)
+		defer l.Close()
+		So(adopted, ShouldBeNil)
+	})
+
+	Convey("listen should ignore socket activation unless it's enabled", t, func() {
+		adopted = nil
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		os.Setenv("LISTEN_FDS", "1")
+		defer os.Unsetenv("LISTEN_PID")
+		defer os.Unsetenv("LISTEN_FDS")
+
+		l, err := New("127.0.0.1:0", nil).listen()
+		So(err, ShouldBeNil)
+		defer l.Close()
+		So(adopted, ShouldBeNil)
+		So(os.Getenv("LISTEN_FDS"), ShouldEqual, "1")
+	})
+}
@@ -0,0 +1,118 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+type pathParamsKey struct{}
+
+// NewRouter returns a handler which routes requests to the handlers for
+// the operations in a document, keyed by operationId. Every operation must
+// have a handler, and every handler an operation, so the document and the
+// service can't drift apart.
+//
+// The route template, e.g. /datasets/{id}, is recorded with log.SetRoute,
+// and the operation ID and tags are added to the request event, so it
+// should be wrapped by log.Handler. MetricLabels adds them to request
+// metrics. Requests for unknown paths are answered with a 404, and for
+// unknown methods with a 405.
+func NewRouter(doc *openapi3.T, handlers map[string]http.Handler) (http.Handler, error) {
+	operations := map[string]bool{}
+	for path, item := range doc.Paths.Map() {
+		for method, op := range item.Operations() {
+			if len(op.OperationID) == 0 {
+				return nil, fmt.Errorf("validator: %s %s has no operationId", method, path)
+			}
+			if _, ok := handlers[op.OperationID]; !ok {
+				return nil, fmt.Errorf("validator: no handler for operation %q", op.OperationID)
+			}
+			operations[op.OperationID] = true
+		}
+	}
+	for id := range handlers {
+		if !operations[id] {
+			return nil, fmt.Errorf("validator: handler for unknown operation %q", id)
+		}
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		route, pathParams, err := router.FindRoute(req)
+		switch {
+		case err == routers.ErrMethodNotAllowed:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		case err != nil:
+			http.NotFound(w, req)
+			return
+		}
+
+		op := route.Operation
+		log.SetRoute(req, template(route))
+		data := log.Data{"operation_id": op.OperationID}
+		if len(op.Tags) > 0 {
+			data["tags"] = op.Tags
+		}
+		log.AddRequestData(req, data)
+
+		ctx := context.WithValue(req.Context(), pathParamsKey{}, pathParams)
+		handlers[op.OperationID].ServeHTTP(w, req.WithContext(ctx))
+	}), nil
+}
+
+// template returns the route's path template, including the server's base
+// path
+func template(route *routers.Route) string {
+	base, err := route.Server.BasePath()
+	if err != nil {
+		return route.Path
+	}
+	return strings.TrimSuffix(base, "/") + route.Path
+}
+
+// PathParam returns a path parameter of a request routed by NewRouter, e.g.
+// "id" for /datasets/{id}
+func PathParam(req *http.Request, name string) string {
+	params, _ := req.Context().Value(pathParamsKey{}).(map[string]string)
+	return params[name]
+}
+
+// OperationID returns the ID of the operation NewRouter routed a request
+// to. Like log.Route, it can be called by outer middleware once the request
+// has been handled.
+func OperationID(req *http.Request) string {
+	id, _ := log.RequestData(req)["operation_id"].(string)
+	return id
+}
+
+// Tags returns the tags of the operation NewRouter routed a request to
+func Tags(req *http.Request) []string {
+	tags, _ := log.RequestData(req)["tags"].([]string)
+	return tags
+}
+
+// MetricLabels returns operation_id and tags labels for metrics.Config, the
+// tags being sorted and comma separated
+func MetricLabels() map[string]func(req *http.Request) string {
+	return map[string]func(req *http.Request) string{
+		"operation_id": OperationID,
+		"tags": func(req *http.Request) string {
+			tags := append([]string(nil), Tags(req)...)
+			sort.Strings(tags)
+			return strings.Join(tags, ",")
+		},
+	}
+}
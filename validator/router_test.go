@@ -0,0 +1,102 @@
+package validator
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/handlers/metrics"
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/ONSdigital/go-ns/log/logtest"
+	"github.com/prometheus/client_golang/prometheus"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRouter(t *testing.T) {
+	noop := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {})
+
+	Convey("NewRouter should require a handler for every operation", t, func() {
+		doc, err := LoadData([]byte(testDocument))
+		So(err, ShouldBeNil)
+
+		_, err = NewRouter(doc, map[string]http.Handler{"getDataset": noop})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "putDataset")
+	})
+
+	Convey("NewRouter should reject handlers for unknown operations", t, func() {
+		doc, err := LoadData([]byte(testDocument))
+		So(err, ShouldBeNil)
+
+		_, err = NewRouter(doc, map[string]http.Handler{"getDataset": noop, "putDataset": noop, "deleteDataset": noop})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "deleteDataset")
+	})
+
+	Convey("Requests should be routed by operation ID", t, func() {
+		rec := logtest.NewRecorder()
+		rec.Install()
+		defer rec.Uninstall()
+
+		doc, err := LoadData([]byte(testDocument))
+		So(err, ShouldBeNil)
+
+		var id, operationID string
+		var tags []string
+		router, err := NewRouter(doc, map[string]http.Handler{
+			"getDataset": http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				id = PathParam(req, "id")
+				operationID = OperationID(req)
+				tags = Tags(req)
+			}),
+			"putDataset": noop,
+		})
+		So(err, ShouldBeNil)
+
+		reg := prometheus.NewRegistry()
+		m, err := metrics.New(metrics.Config{Registerer: reg, Labels: MetricLabels()})
+		So(err, ShouldBeNil)
+		h := log.Handler(m.Handler(router))
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/datasets/cpih", nil))
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(id, ShouldEqual, "cpih")
+		So(operationID, ShouldEqual, "getDataset")
+		So(tags, ShouldResemble, []string{"datasets"})
+
+		So(rec, logtest.ShouldHaveLoggedEvent, "request", log.Data{
+			"route":        "/datasets/{id}",
+			"operation_id": "getDataset",
+		})
+
+		families, err := reg.Gather()
+		So(err, ShouldBeNil)
+		labels := map[string]string{}
+		for _, f := range families {
+			if f.GetName() == "http_server_requests_total" {
+				for _, l := range f.GetMetric()[0].GetLabel() {
+					labels[l.GetName()] = l.GetValue()
+				}
+			}
+		}
+		So(labels["path"], ShouldEqual, "/datasets/{id}")
+		So(labels["operation_id"], ShouldEqual, "getDataset")
+		So(labels["tags"], ShouldEqual, "datasets")
+	})
+
+	Convey("Unknown paths and methods should be rejected", t, func() {
+		doc, err := LoadData([]byte(testDocument))
+		So(err, ShouldBeNil)
+		router, err := NewRouter(doc, map[string]http.Handler{"getDataset": noop, "putDataset": noop})
+		So(err, ShouldBeNil)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("DELETE", "/datasets/cpih", nil))
+		So(w.Code, ShouldEqual, http.StatusMethodNotAllowed)
+	})
+}
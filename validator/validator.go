@@ -0,0 +1,183 @@
+// Package validator provides middleware which validates requests against an
+// OpenAPI 3 document, and a router which dispatches them to handlers by
+// operation ID.
+package validator
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// Violation describes how a request doesn't match the document
+type Violation struct {
+	// In is where the violation is, i.e. "path", "query", "header",
+	// "cookie" or "body"
+	In string `json:"in,omitempty"`
+	// Name is the name of the parameter
+	Name string `json:"name,omitempty"`
+	// Pointer is the JSON pointer to the invalid part of the body, e.g.
+	// /items/0/id
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
+}
+
+// Error is the body of a 400 response to a request which failed validation
+type Error struct {
+	Message    string      `json:"message"`
+	Violations []Violation `json:"violations"`
+}
+
+// Load reads and validates an OpenAPI 3 document in JSON or YAML
+func Load(path string) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// LoadData parses and validates an OpenAPI 3 document in JSON or YAML
+func LoadData(data []byte) (*openapi3.T, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err = doc.Validate(loader.Context); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// Validator validates requests against the operations in a document
+type Validator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// New returns a Validator for a document. Requests are matched against the
+// document's servers as well as its paths, so servers should usually be
+// relative, e.g. /v1, to match any host.
+func New(doc *openapi3.T) (*Validator, error) {
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Validator{
+		router: router,
+		options: &openapi3filter.Options{
+			MultiError: true,
+			// authentication is left to other middleware, e.g. handlers/jwt
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			// leave the request as it was sent
+			SkipSettingDefaults: true,
+		},
+	}, nil
+}
+
+// Handler is middleware which validates the path, query, header and cookie
+// parameters and body of requests for operations in the document. Requests
+// which fail are answered with a 400 listing the violations, and logged as
+// a "request failed validation" event. Requests which don't match an
+// operation are passed through, so the router can answer them.
+func (v *Validator) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		route, pathParams, err := v.router.FindRoute(req)
+		if err != nil {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		violations := v.validate(req.Context(), req, route, pathParams)
+		if len(violations) == 0 {
+			h.ServeHTTP(w, req)
+			return
+		}
+
+		// invalid requests are a client problem, not a service error
+		log.WarnR(req, "request failed validation", log.Data{
+			"operation_id": route.Operation.OperationID,
+			"route":        route.Path,
+			"violations":   violations,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(Error{Message: "request failed validation", Violations: violations})
+	})
+}
+
+func (v *Validator) validate(ctx context.Context, req *http.Request, route *routers.Route, pathParams map[string]string) []Violation {
+	err := openapi3filter.ValidateRequest(ctx, &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    v.options,
+	})
+	if err == nil {
+		return nil
+	}
+	return violations(err, Violation{})
+}
+
+// violations flattens a validation error into violations, each starting
+// from the fields in v. The errors are matched by type rather than with
+// errors.As, which would stop at the first error of a MultiError.
+func violations(err error, v Violation) []Violation {
+	switch e := err.(type) {
+	case openapi3.MultiError:
+		var vs []Violation
+		for _, err := range e {
+			vs = append(vs, violations(err, v)...)
+		}
+		return vs
+
+	case *openapi3filter.RequestError:
+		switch {
+		case e.Parameter != nil:
+			v.In, v.Name = e.Parameter.In, e.Parameter.Name
+		case e.RequestBody != nil:
+			v.In = "body"
+		}
+		if e.Err == nil {
+			v.Message = e.Reason
+			return []Violation{v}
+		}
+		switch e.Err.(type) {
+		case openapi3.MultiError, *openapi3.SchemaError:
+			// the reason is just "doesn't match schema"
+		default:
+			if len(e.Reason) > 0 && e.Reason != e.Err.Error() {
+				v.Message = e.Reason + ": "
+			}
+		}
+		return violations(e.Err, v)
+
+	case *openapi3.SchemaError:
+		if pointer := e.JSONPointer(); len(pointer) > 0 && v.In == "body" {
+			for _, p := range pointer {
+				v.Pointer += "/" + pointerEscaper.Replace(p)
+			}
+		}
+		v.Message += e.Reason
+		return []Violation{v}
+	}
+
+	v.Message += err.Error()
+	return []Violation{v}
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
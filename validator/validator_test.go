@@ -0,0 +1,204 @@
+package validator
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ONSdigital/go-ns/log"
+	"github.com/ONSdigital/go-ns/log/logtest"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testDocument = `
+openapi: 3.0.3
+info:
+  title: datasets
+  version: "1.0"
+paths:
+  /datasets/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema:
+          type: string
+          pattern: "^[a-z]+$"
+    get:
+      operationId: getDataset
+      tags: [datasets]
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            maximum: 100
+      responses:
+        "200":
+          description: a dataset
+    put:
+      operationId: putDataset
+      security:
+        - bearer: []
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [title]
+              properties:
+                title:
+                  type: string
+                keywords:
+                  type: array
+                  items:
+                    type: string
+      responses:
+        "200":
+          description: the updated dataset
+components:
+  securitySchemes:
+    bearer:
+      type: http
+      scheme: bearer
+`
+
+func testValidator() *Validator {
+	doc, err := LoadData([]byte(testDocument))
+	So(err, ShouldBeNil)
+	v, err := New(doc)
+	So(err, ShouldBeNil)
+	return v
+}
+
+func serve(v *Validator, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	var called bool
+	h := v.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		called = true
+		b, _ := ioutil.ReadAll(req.Body)
+		w.Write(b)
+	}))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w, called
+}
+
+func TestLoad(t *testing.T) {
+	Convey("Load should read a document from a file", t, func() {
+		path := filepath.Join(t.TempDir(), "openapi.yaml")
+		So(os.WriteFile(path, []byte(testDocument), 0600), ShouldBeNil)
+
+		doc, err := Load(path)
+		So(err, ShouldBeNil)
+		So(doc.Paths.Find("/datasets/{id}"), ShouldNotBeNil)
+	})
+
+	Convey("Load should return an error for a missing file", t, func() {
+		_, err := Load(filepath.Join(t.TempDir(), "missing.yaml"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("LoadData should return an error for an invalid document", t, func() {
+		_, err := LoadData([]byte(`{"openapi": "3.0.3", "info": {"title": "test"}, "paths": {}}`))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestHandler(t *testing.T) {
+	Convey("Valid requests should be passed through", t, func() {
+		v := testValidator()
+
+		req := httptest.NewRequest("GET", "/datasets/cpih?limit=10", nil)
+		w, called := serve(v, req)
+		So(called, ShouldBeTrue)
+		So(w.Code, ShouldEqual, http.StatusOK)
+	})
+
+	Convey("Request bodies should still be readable after validation", t, func() {
+		v := testValidator()
+
+		req := httptest.NewRequest("PUT", "/datasets/cpih", strings.NewReader(`{"title":"CPIH"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w, called := serve(v, req)
+		So(called, ShouldBeTrue)
+		So(w.Body.String(), ShouldEqual, `{"title":"CPIH"}`)
+	})
+
+	Convey("Requests which don't match an operation should be passed through", t, func() {
+		v := testValidator()
+
+		_, called := serve(v, httptest.NewRequest("GET", "/health", nil))
+		So(called, ShouldBeTrue)
+		_, called = serve(v, httptest.NewRequest("DELETE", "/datasets/cpih", nil))
+		So(called, ShouldBeTrue)
+	})
+
+	Convey("Invalid parameters should be rejected with a 400 listing the violations", t, func() {
+		rec := logtest.NewRecorder()
+		rec.Install()
+		defer rec.Uninstall()
+
+		v := testValidator()
+
+		req := httptest.NewRequest("GET", "/datasets/CPIH?limit=1000", nil)
+		w, called := serve(v, req)
+		So(called, ShouldBeFalse)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(w.Header().Get("Content-Type"), ShouldEqual, "application/json")
+
+		var body Error
+		So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+		So(body.Message, ShouldEqual, "request failed validation")
+		So(body.Violations, ShouldHaveLength, 2)
+		So(body.Violations[0].In, ShouldEqual, "path")
+		So(body.Violations[0].Name, ShouldEqual, "id")
+		So(body.Violations[1].In, ShouldEqual, "query")
+		So(body.Violations[1].Name, ShouldEqual, "limit")
+		So(body.Violations[1].Message, ShouldContainSubstring, "100")
+
+		So(rec, logtest.ShouldHaveLoggedEvent, "warn", log.Data{
+			"message":      "request failed validation",
+			"operation_id": "getDataset",
+			"route":        "/datasets/{id}",
+		})
+	})
+
+	Convey("Invalid bodies should be rejected with a pointer to each violation", t, func() {
+		v := testValidator()
+
+		req := httptest.NewRequest("PUT", "/datasets/cpih", strings.NewReader(`{"keywords":["a",1]}`))
+		req.Header.Set("Content-Type", "application/json")
+		w, called := serve(v, req)
+		So(called, ShouldBeFalse)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+
+		var body Error
+		So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+		So(body.Violations, ShouldHaveLength, 2)
+		pointers := []string{body.Violations[0].Pointer, body.Violations[1].Pointer}
+		So(pointers, ShouldContain, "/title")
+		So(pointers, ShouldContain, "/keywords/1")
+		So(body.Violations[0].In, ShouldEqual, "body")
+	})
+
+	Convey("Missing bodies should be rejected", t, func() {
+		v := testValidator()
+
+		req := httptest.NewRequest("PUT", "/datasets/cpih", nil)
+		req.Header.Set("Content-Type", "application/json")
+		w, called := serve(v, req)
+		So(called, ShouldBeFalse)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+
+		var body Error
+		So(json.Unmarshal(w.Body.Bytes(), &body), ShouldBeNil)
+		So(body.Violations, ShouldHaveLength, 1)
+		So(body.Violations[0].In, ShouldEqual, "body")
+	})
+}
@@ -0,0 +1,241 @@
+// Package vault reads secrets from HashiCorp Vault, and watches them for
+// rotation so credentials can be replaced without restarting.
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ONSdigital/go-ns/log"
+)
+
+// DefaultInterval is how often secrets without a lease, e.g. KV secrets,
+// are re-read by a Watcher
+var DefaultInterval = 5 * time.Minute
+
+// RetryInterval is how long a Watcher waits before retrying a failed read
+var RetryInterval = 5 * time.Second
+
+// Config configures a Vault client
+type Config struct {
+	// Address defaults to VAULT_ADDR
+	Address string
+	// Token defaults to VAULT_TOKEN
+	Token string
+	// HTTPClient defaults to a client with a 10 second timeout
+	HTTPClient *http.Client
+}
+
+// Client reads secrets with Vault's HTTP API
+type Client struct {
+	cfg Config
+}
+
+// Secret is a secret read from Vault
+type Secret struct {
+	// Data holds the secret's fields. The data of KV version 2 secrets is
+	// unwrapped, so it's the same for both versions.
+	Data          map[string]interface{}
+	LeaseID       string
+	LeaseDuration time.Duration
+}
+
+// String returns a field of the secret, or an empty string if it's missing
+// or isn't a string
+func (s *Secret) String(field string) string {
+	v, _ := s.Data[field].(string)
+	return v
+}
+
+// Error is returned when Vault responds with an error status
+type Error struct {
+	StatusCode int
+	Errors     []string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("vault: %d: %s", e.StatusCode, strings.Join(e.Errors, "; "))
+}
+
+// New returns a Vault client
+func New(cfg Config) (*Client, error) {
+	if len(cfg.Address) == 0 {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+	if len(cfg.Token) == 0 {
+		cfg.Token = os.Getenv("VAULT_TOKEN")
+	}
+	if len(cfg.Address) == 0 {
+		return nil, errors.New("vault: address is required")
+	}
+	if len(cfg.Token) == 0 {
+		return nil, errors.New("vault: token is required")
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+	return &Client{cfg: cfg}, nil
+}
+
+// Read reads the secret at a path, e.g. database/creds/datasets
+func (c *Client) Read(ctx context.Context, path string) (*Secret, error) {
+	req, err := http.NewRequest("GET", c.cfg.Address+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", c.cfg.Token)
+
+	resp, err := c.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		vaultErr := &Error{StatusCode: resp.StatusCode}
+		json.Unmarshal(b, vaultErr)
+		return nil, vaultErr
+	}
+
+	var body struct {
+		LeaseID       string                 `json:"lease_id"`
+		LeaseDuration int                    `json:"lease_duration"`
+		Data          map[string]interface{} `json:"data"`
+	}
+	if err = json.Unmarshal(b, &body); err != nil {
+		return nil, err
+	}
+
+	// KV version 2 nests the secret's data alongside its metadata
+	data := body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok && len(data) == 2 {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+
+	return &Secret{
+		Data:          data,
+		LeaseID:       body.LeaseID,
+		LeaseDuration: time.Duration(body.LeaseDuration) * time.Second,
+	}, nil
+}
+
+// Watcher re-reads a secret before its lease expires, or periodically if it
+// doesn't have one
+type Watcher struct {
+	client   *Client
+	path     string
+	interval time.Duration
+	onChange func(*Secret)
+
+	mutex  sync.Mutex
+	secret *Secret
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// Watch reads the secret at a path, and returns a Watcher which re-reads it
+// in the background. Leased secrets, e.g. dynamic database credentials, are
+// re-read when two thirds of the lease has elapsed, and others every
+// interval, defaulting to DefaultInterval. onChange, which may be nil, is
+// called with the new secret each time its data changes.
+func (c *Client) Watch(path string, interval time.Duration, onChange func(*Secret)) (*Watcher, error) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	s, err := c.Read(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		client:   c,
+		path:     path,
+		interval: interval,
+		onChange: onChange,
+		secret:   s,
+		done:     make(chan struct{}),
+	}
+
+	w.wg.Add(1)
+	go w.run()
+
+	return w, nil
+}
+
+// Secret returns the most recently read secret
+func (w *Watcher) Secret() *Secret {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.secret
+}
+
+// Close stops re-reading the secret
+func (w *Watcher) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.wg.Wait()
+	})
+	return nil
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	wait := w.next(w.Secret())
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-time.After(wait):
+		}
+
+		s, err := w.client.Read(context.Background(), w.path)
+		if err != nil {
+			log.Error(err, log.Data{"path": w.path})
+			wait = RetryInterval
+			continue
+		}
+		wait = w.next(s)
+
+		w.mutex.Lock()
+		changed := !reflect.DeepEqual(s.Data, w.secret.Data)
+		w.secret = s
+		w.mutex.Unlock()
+
+		if changed {
+			log.Info("vault secret rotated", log.Data{"path": w.path, "lease_id": s.LeaseID})
+			if w.onChange != nil {
+				w.onChange(s)
+			}
+		}
+	}
+}
+
+// next returns how long to wait before re-reading a secret
+func (w *Watcher) next(s *Secret) time.Duration {
+	if s.LeaseDuration > 0 {
+		return s.LeaseDuration * 2 / 3
+	}
+	return w.interval
+}
@@ -0,0 +1,180 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type fakeVault struct {
+	mutex   sync.Mutex
+	secrets map[string]interface{}
+	reads   int
+}
+
+func (f *fakeVault) set(path string, body interface{}) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.secrets[path] = body
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.reads++
+
+	if req.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+	body, ok := f.secrets[req.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{}})
+		return
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+func newTestClient() (*Client, *fakeVault, *httptest.Server) {
+	f := &fakeVault{secrets: map[string]interface{}{}}
+	server := httptest.NewServer(f)
+	c, err := New(Config{Address: server.URL, Token: "token"})
+	So(err, ShouldBeNil)
+	return c, f, server
+}
+
+func TestClient(t *testing.T) {
+	Convey("New should require an address and token", t, func() {
+		t.Setenv("VAULT_ADDR", "")
+		t.Setenv("VAULT_TOKEN", "")
+		_, err := New(Config{Token: "token"})
+		So(err, ShouldNotBeNil)
+		_, err = New(Config{Address: "http://localhost:8200"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("New should default to VAULT_ADDR and VAULT_TOKEN", t, func() {
+		t.Setenv("VAULT_ADDR", "http://localhost:8200/")
+		t.Setenv("VAULT_TOKEN", "token")
+		c, err := New(Config{})
+		So(err, ShouldBeNil)
+		So(c.cfg.Address, ShouldEqual, "http://localhost:8200")
+		So(c.cfg.Token, ShouldEqual, "token")
+	})
+
+	Convey("Read should return a leased secret", t, func() {
+		c, f, server := newTestClient()
+		defer server.Close()
+		f.set("/v1/database/creds/datasets", map[string]interface{}{
+			"lease_id":       "database/creds/datasets/abc",
+			"lease_duration": 3600,
+			"data":           map[string]interface{}{"username": "user", "password": "secret"},
+		})
+
+		s, err := c.Read(context.Background(), "database/creds/datasets")
+		So(err, ShouldBeNil)
+		So(s.LeaseID, ShouldEqual, "database/creds/datasets/abc")
+		So(s.LeaseDuration, ShouldEqual, time.Hour)
+		So(s.String("username"), ShouldEqual, "user")
+		So(s.String("password"), ShouldEqual, "secret")
+		So(s.String("missing"), ShouldBeEmpty)
+	})
+
+	Convey("Read should unwrap KV version 2 secrets", t, func() {
+		c, f, server := newTestClient()
+		defer server.Close()
+		f.set("/v1/secret/data/datasets", map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"password": "secret"},
+				"metadata": map[string]interface{}{"version": 2},
+			},
+		})
+
+		s, err := c.Read(context.Background(), "/secret/data/datasets")
+		So(err, ShouldBeNil)
+		So(s.Data, ShouldResemble, map[string]interface{}{"password": "secret"})
+	})
+
+	Convey("Read should return Vault errors", t, func() {
+		c, _, server := newTestClient()
+		defer server.Close()
+
+		_, err := c.Read(context.Background(), "secret/missing")
+		So(err, ShouldHaveSameTypeAs, &Error{})
+		So(err.(*Error).StatusCode, ShouldEqual, http.StatusNotFound)
+
+		c.cfg.Token = "wrong"
+		_, err = c.Read(context.Background(), "secret/missing")
+		So(err.Error(), ShouldEqual, "vault: 403: permission denied")
+	})
+}
+
+func TestWatcher(t *testing.T) {
+	Convey("Watch should return an error if the secret can't be read", t, func() {
+		c, _, server := newTestClient()
+		defer server.Close()
+
+		_, err := c.Watch("secret/missing", 0, nil)
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Watch should re-read secrets and report changes", t, func() {
+		c, f, server := newTestClient()
+		defer server.Close()
+		f.set("/v1/secret/datasets", map[string]interface{}{"data": map[string]interface{}{"password": "old"}})
+
+		changes := make(chan *Secret, 1)
+		w, err := c.Watch("secret/datasets", 10*time.Millisecond, func(s *Secret) { changes <- s })
+		So(err, ShouldBeNil)
+		defer w.Close()
+		So(w.Secret().String("password"), ShouldEqual, "old")
+
+		f.set("/v1/secret/datasets", map[string]interface{}{"data": map[string]interface{}{"password": "new"}})
+		select {
+		case s := <-changes:
+			So(s.String("password"), ShouldEqual, "new")
+		case <-time.After(time.Second):
+			So("secret wasn't re-read", ShouldBeEmpty)
+		}
+		So(w.Secret().String("password"), ShouldEqual, "new")
+	})
+
+	Convey("Watch should re-read leased secrets before the lease expires", t, func() {
+		c, f, server := newTestClient()
+		defer server.Close()
+		f.set("/v1/database/creds/datasets", map[string]interface{}{"lease_duration": 3, "data": map[string]interface{}{"username": "user"}})
+
+		w, err := c.Watch("database/creds/datasets", time.Millisecond, nil)
+		So(err, ShouldBeNil)
+		defer w.Close()
+		So(w.next(w.Secret()), ShouldEqual, 2*time.Second)
+		So(w.next(&Secret{}), ShouldEqual, time.Millisecond)
+	})
+
+	Convey("Close should stop the watcher and be safe to call again", t, func() {
+		c, f, server := newTestClient()
+		defer server.Close()
+		f.set("/v1/secret/datasets", map[string]interface{}{"data": map[string]interface{}{"password": "old"}})
+
+		w, err := c.Watch("secret/datasets", time.Millisecond, nil)
+		So(err, ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+
+		f.mutex.Lock()
+		reads := f.reads
+		f.mutex.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		f.mutex.Lock()
+		So(f.reads, ShouldEqual, reads)
+		f.mutex.Unlock()
+	})
+}